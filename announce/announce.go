@@ -0,0 +1,37 @@
+// Package announce contains Announcer implementations for common chat networks.
+package announce
+
+import (
+	"github.com/SoMuchForSubtlety/opendj"
+	"github.com/SoMuchForSubtlety/opendj/catalog"
+)
+
+// Texts select the texts of an announcer, so they can be translated.
+type Texts struct {
+	// Catalog holds the texts, nil uses catalog.Defaults.
+	Catalog *catalog.Catalog
+	// Locale is the locale of the channel, e.g. "de".
+	Locale string
+}
+
+// nowPlayingText returns the text announcing that entry started playing.
+func (t Texts) nowPlayingText(entry opendj.QueueEntry) string {
+	text := t.Catalog.Text(t.Locale, catalog.NowPlaying, entry.Media.Title, entry.PublicOwner())
+	if entry.Dedication != "" {
+		text += t.Catalog.Text(t.Locale, catalog.Dedication, entry.Dedication)
+	}
+	return text
+}
+
+// queueText returns the text summarizing the queue.
+func (t Texts) queueText(queue []opendj.QueueEntry) string {
+	if len(queue) == 0 {
+		return t.Catalog.Text(t.Locale, catalog.QueueEmpty)
+	}
+	return t.Catalog.Text(t.Locale, catalog.QueueSummary, len(queue), queue[0].Media.Title)
+}
+
+// errorText returns the text announcing a playback error.
+func (t Texts) errorText(err error) string {
+	return t.Catalog.Text(t.Locale, catalog.PlaybackError, err)
+}
@@ -0,0 +1,169 @@
+package announce
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SoMuchForSubtlety/opendj"
+)
+
+func TestMatrix(t *testing.T) {
+	var paths, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body struct{ Msgtype, Body string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Msgtype != "m.notice" {
+			http.Error(w, "bad message", http.StatusBadRequest)
+			return
+		}
+		paths = append(paths, r.URL.EscapedPath())
+		bodies = append(bodies, body.Body)
+	}))
+	defer server.Close()
+
+	m := &Matrix{Homeserver: server.URL, AccessToken: "token", RoomID: "!room:example.org"}
+	ctx := context.Background()
+	entry := opendj.QueueEntry{Media: opendj.Media{Title: "Song"}, Owner: "alice", Dedication: "for bob"}
+	if err := m.NowPlaying(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.QueueUpdate(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Error(ctx, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"now playing: Song (requested by alice) - for bob", "the queue is empty", "playback error: boom"}
+	if strings.Join(bodies, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", bodies, want)
+	}
+	prefix := "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/"
+	for i, path := range paths {
+		if !strings.HasPrefix(path, prefix) {
+			t.Errorf("sent to %s, want prefix %s", path, prefix)
+		}
+		for _, other := range paths[:i] {
+			if other == path {
+				t.Errorf("transaction ID of %s was reused", path)
+			}
+		}
+	}
+
+	m.AccessToken = "wrong"
+	if err := m.QueueUpdate(ctx, nil); err == nil {
+		t.Error("a rejected message returned no error")
+	}
+}
+
+func TestIRC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := make(chan string, 16)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PING :server\r\n"))
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &IRC{Addr: listener.Addr().String(), Nick: "dj", Channel: "#music", Password: "secret"}
+	if err = i.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	queue := []opendj.QueueEntry{{Media: opendj.Media{Title: "Next"}}, {Media: opendj.Media{Title: "Later"}}}
+	if err = i.QueueUpdate(ctx, queue); err != nil {
+		t.Fatal(err)
+	}
+	if err = i.Error(ctx, errors.New("first\nsecond")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		"PASS secret":         true,
+		"NICK dj":             true,
+		"USER dj 0 * :opendj": true,
+		"JOIN #music":         true,
+		"PONG :server":        true,
+		"PRIVMSG #music :2 songs in the queue, up next: Next": true,
+		"PRIVMSG #music :playback error: first":               true,
+		"PRIVMSG #music :second":                              true,
+	}
+	timeout := time.After(5 * time.Second)
+	for len(want) > 0 {
+		select {
+		case line := <-lines:
+			delete(want, line)
+		case <-timeout:
+			t.Fatalf("did not receive %v", want)
+		}
+	}
+
+	cancel()
+	for line := range lines {
+		if line == "QUIT" {
+			return
+		}
+	}
+	t.Error("did not quit when the context was cancelled")
+}
+
+func TestIRCSayInjection(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	i := &IRC{Channel: "#music", conn: client}
+	done := make(chan error, 1)
+	go func() {
+		done <- i.say("Song\r\nQUIT :bye\rKICK #music alice\x00\n" + strings.Repeat("ü", 300))
+		client.Close()
+	}()
+
+	var lines []string
+	scanner := bufio.NewScanner(server)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "PRIVMSG #music :") {
+			t.Errorf("injected line %q", line)
+		}
+		if strings.ContainsAny(line, "\r\x00") {
+			t.Errorf("line %q contains control characters", line)
+		}
+		if len(line)+2 > maxIRCLine {
+			t.Errorf("line of %d bytes is too long", len(line)+2)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %q was cut inside a character", line)
+		}
+	}
+	if len(lines) != 5 {
+		t.Errorf("got %d lines, want 3 short and the long line split in 2: %q", len(lines), lines)
+	}
+}
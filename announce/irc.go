@@ -0,0 +1,149 @@
+package announce
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/SoMuchForSubtlety/opendj"
+)
+
+// IRC announces into an IRC channel.
+type IRC struct {
+	// Addr is the host:port of the server.
+	Addr string
+	// TLS enables an encrypted connection.
+	TLS     bool
+	Nick    string
+	Channel string
+	// Password is the server password, it is optional.
+	Password string
+	// Texts are the announced texts, the zero value is english.
+	Texts
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Connect connects to the server and joins the channel.
+//
+// The connection is kept alive until ctx is cancelled.
+func (i *IRC) Connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if i.TLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", i.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", i.Addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", i.Addr, err)
+	}
+
+	i.mu.Lock()
+	i.conn = conn
+	i.mu.Unlock()
+
+	if i.Password != "" {
+		i.send("PASS " + i.Password)
+	}
+	i.send("NICK " + i.Nick)
+	i.send("USER " + i.Nick + " 0 * :opendj")
+	i.send("JOIN " + i.Channel)
+
+	go func() {
+		<-ctx.Done()
+		i.send("QUIT")
+		conn.Close()
+	}()
+	go i.read(conn)
+	return nil
+}
+
+// read answers pings until the connection is closed.
+func (i *IRC) read(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "PING") {
+			i.send("PONG" + strings.TrimPrefix(line, "PING"))
+		}
+	}
+}
+
+func (i *IRC) send(line string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.conn == nil {
+		return errors.New("not connected")
+	}
+	_, err := fmt.Fprintf(i.conn, "%s\r\n", line)
+	return err
+}
+
+// maxIRCLine is the length limit of a message including the trailing CR LF.
+const maxIRCLine = 512
+
+// say sends every line of text to the channel.
+//
+// Control characters are replaced, so titles can't end the message and send commands of their own,
+// and lines that are too long are split.
+func (i *IRC) say(text string) error {
+	prefix := "PRIVMSG " + i.Channel + " :"
+	limit := maxIRCLine - len("\r\n") - len(prefix)
+	if limit < utf8.UTFMax {
+		limit = utf8.UTFMax
+	}
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return ' '
+			}
+			return r
+		}, line)
+		for line != "" {
+			chunk := line
+			if len(chunk) > limit {
+				// cut at a rune boundary
+				cut := limit
+				for cut > 0 && !utf8.RuneStart(chunk[cut]) {
+					cut--
+				}
+				if cut == 0 {
+					cut = limit
+				}
+				chunk = chunk[:cut]
+			}
+			line = line[len(chunk):]
+			if err := i.send(prefix + chunk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NowPlaying announces the entry.
+func (i *IRC) NowPlaying(_ context.Context, entry opendj.QueueEntry) error {
+	return i.say(i.nowPlayingText(entry))
+}
+
+// QueueUpdate announces the queue.
+func (i *IRC) QueueUpdate(_ context.Context, queue []opendj.QueueEntry) error {
+	return i.say(i.queueText(queue))
+}
+
+// Error announces the error.
+func (i *IRC) Error(_ context.Context, err error) error {
+	return i.say(i.errorText(err))
+}
@@ -0,0 +1,77 @@
+package announce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/SoMuchForSubtlety/opendj"
+)
+
+// Matrix announces into a Matrix room using the client-server API.
+type Matrix struct {
+	// Homeserver is the base URL of the homeserver, e.g. https://matrix.org
+	Homeserver  string
+	AccessToken string
+	RoomID      string
+	// Client is used for requests, it defaults to http.DefaultClient.
+	Client *http.Client
+	// Texts are the announced texts, the zero value is english.
+	Texts
+
+	txn uint64
+}
+
+func (m *Matrix) send(ctx context.Context, text string) error {
+	// transaction IDs only have to be unique per access token
+	txn := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(atomic.AddUint64(&m.txn, 1), 10)
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", m.Homeserver, url.PathEscape(m.RoomID), txn)
+
+	body, err := json.Marshal(map[string]string{"msgtype": "m.notice", "body": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("matrix request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("matrix returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// NowPlaying announces the entry.
+func (m *Matrix) NowPlaying(ctx context.Context, entry opendj.QueueEntry) error {
+	return m.send(ctx, m.nowPlayingText(entry))
+}
+
+// QueueUpdate announces the queue.
+func (m *Matrix) QueueUpdate(ctx context.Context, queue []opendj.QueueEntry) error {
+	return m.send(ctx, m.queueText(queue))
+}
+
+// Error announces the error.
+func (m *Matrix) Error(ctx context.Context, err error) error {
+	return m.send(ctx, m.errorText(err))
+}
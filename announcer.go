@@ -0,0 +1,59 @@
+package opendj
+
+import "context"
+
+// An Announcer broadcasts what is happening to a community channel, e.g. IRC or Matrix.
+//
+// Implementations can be found in the announce subpackage.
+type Announcer interface {
+	// NowPlaying announces that entry started playing.
+	NowPlaying(ctx context.Context, entry QueueEntry) error
+	// QueueUpdate announces that the queue changed.
+	QueueUpdate(ctx context.Context, queue []QueueEntry) error
+	// Error announces a playback error.
+	Error(ctx context.Context, err error) error
+}
+
+// Announce passes all events to the announcer until ctx is cancelled.
+//
+// Errors returned by the announcer are passed to onError, which may be nil.
+// It blocks, so it should be started in its own goroutine.
+func (dj *Dj) Announce(ctx context.Context, a Announcer, onError func(error)) {
+	// don't replay old events, only announce what happens from now on
+	events, cancel := dj.Subscribe(64)
+	defer cancel()
+	for len(events) > 0 {
+		<-events
+	}
+
+	var limiter eventLimiter
+	for {
+		var event Event
+		select {
+		case <-ctx.Done():
+			return
+		case event = <-events:
+		}
+
+		if !limiter.allow(dj.cfg().EventRateLimits, event.Type, event.Time) {
+			continue
+		}
+
+		var err error
+		switch event.Type {
+		case EventSongStarted:
+			err = a.NowPlaying(ctx, dj.announced(event.Entry))
+		case EventQueueChanged:
+			queue := dj.Queue()
+			for i := range queue {
+				queue[i] = queue[i].Public()
+			}
+			err = a.QueueUpdate(ctx, queue)
+		case EventError:
+			err = a.Error(ctx, event.Err)
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package opendj
+
+import (
+	"sync"
+)
+
+// EventEntryPending is published when a request is waiting for approval.
+const EventEntryPending EventType = "entryPending"
+
+type pending struct {
+	sync.Mutex
+	entries []QueueEntry
+	handler func(QueueEntry)
+}
+
+// SetApprovalRequired enables or disables the moderation mode.
+//
+// While it is enabled AddEntry puts new requests into a pending list instead of the queue,
+// where they wait until a moderator calls Approve or Reject. Entries that are already
+// pending stay pending when it is disabled.
+func (dj *Dj) SetApprovalRequired(required bool) {
+	dj.updateConfig(func(c *Config) { c.RequireApproval = required })
+}
+
+// AddPendingHandler adds a function that will be called every time a request needs approval.
+func (dj *Dj) AddPendingHandler(f func(QueueEntry)) {
+	dj.pending.Lock()
+	defer dj.pending.Unlock()
+	dj.pending.handler = f
+}
+
+// addPending adds the entry to the pending list, it has to pass the content filter already.
+func (dj *Dj) addPending(entry QueueEntry) {
+	if entry.ID == 0 {
+		entry.assignID(dj.ids)
+	}
+
+	dj.pending.Lock()
+	dj.pending.entries = append(dj.pending.entries, entry)
+	handler := dj.pending.handler
+	dj.pending.Unlock()
+
+	if handler != nil {
+		dj.callHandler(EventEntryPending, func() { handler(entry) })
+	}
+	dj.publish(Event{Type: EventEntryPending, Time: dj.now(), Entry: entry})
+}
+
+// Pending returns a copy of the requests waiting for approval, oldest first.
+func (dj *Dj) Pending() []QueueEntry {
+	dj.pending.Lock()
+	defer dj.pending.Unlock()
+
+	entries := make([]QueueEntry, len(dj.pending.entries))
+	copy(entries, dj.pending.entries)
+	return entries
+}
+
+// takePending removes the pending entry with the given ID.
+func (dj *Dj) takePending(id uint64) (QueueEntry, bool) {
+	dj.pending.Lock()
+	defer dj.pending.Unlock()
+
+	for i, entry := range dj.pending.entries {
+		if entry.ID == id {
+			dj.pending.entries = append(dj.pending.entries[:i], dj.pending.entries[i+1:]...)
+			return entry, true
+		}
+	}
+	return QueueEntry{}, false
+}
+
+// Approve moves the pending request with the given ID to the end of the queue.
+//
+// returns ErrorUnknownEntry if no such request is pending, ErrorQueueFull if the queue limit
+// is reached or ErrorUserDurationCap if the owner has too much queued,
+// in which case the request stays pending.
+func (dj *Dj) Approve(id uint64) error {
+	return dj.As(directActor).Approve(id).Err
+}
+
+// approve is Approve without the audit log.
+func (dj *Dj) approve(id uint64) error {
+	entry, ok := dj.takePending(id)
+	if !ok {
+		return ErrorUnknownEntry
+	}
+
+	dj.lockQueue()
+	if err := dj.room(entry); err != nil {
+		dj.unlockQueue()
+
+		dj.pending.Lock()
+		dj.pending.entries = append([]QueueEntry{entry}, dj.pending.entries...)
+		dj.pending.Unlock()
+		return err
+	}
+	dj.waitingQueue.insert(dj.waitingQueue.len(), entry)
+	dj.unlockQueue()
+	return nil
+}
+
+// Reject drops the pending request with the given ID.
+//
+// returns ErrorUnknownEntry if no such request is pending.
+func (dj *Dj) Reject(id uint64) error {
+	return dj.As(directActor).Reject(id).Err
+}
+
+// reject is Reject without the audit log.
+func (dj *Dj) reject(id uint64) error {
+	if _, ok := dj.takePending(id); !ok {
+		return ErrorUnknownEntry
+	}
+	return nil
+}
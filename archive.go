@@ -0,0 +1,73 @@
+package opendj
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SetArchiveDir enables recording of every played track into the given directory.
+//
+// Each track is written to its own file with the title, artist, requester and dedication
+// embedded as metadata, so the archive can be navigated track by track. Artist and title
+// are parsed from the media title, see ParseTitle, anonymous requesters stay hidden.
+// An empty dir disables the recording.
+// returns an error if dir is not a directory.
+func (dj *Dj) SetArchiveDir(dir string) error {
+	return dj.updateConfig(func(c *Config) { c.ArchiveDir = dir })
+}
+
+// archiveArgs returns the ffmpeg output arguments to record the entry into the archive.
+func (dj *Dj) archiveArgs(entry QueueEntry, started time.Time) []string {
+	dir := dj.cfg().ArchiveDir
+	if dir == "" {
+		return nil
+	}
+
+	dj.archiveCount++
+	name := fmt.Sprintf("%s-%03d-%s.m4a", started.Format("20060102-150405"), dj.archiveCount, sanitizeFileName(entry.Media.Title))
+	artist, title := ParseTitle(entry.Media.Title)
+	if artist == "" {
+		title = entry.Media.Title
+	}
+	return []string{
+		"-c:a", "aac",
+		"-b:a", defaultBitrate,
+		"-metadata", "title=" + title,
+		"-metadata", "artist=" + artist,
+		"-metadata", "comment=requested by " + entry.PublicOwner(),
+		"-metadata", "description=" + entry.Dedication,
+		"-metadata", "purl=" + entry.Media.URL,
+		"-metadata", "track=" + fmt.Sprint(dj.archiveCount),
+		"-y", filepath.Join(dir, name),
+	}
+}
+
+// sanitizeFileName replaces all characters that are problematic in file names.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|':
+			return '_'
+		case r < ' ':
+			return -1
+		default:
+			return r
+		}
+	}, name)
+	if len(name) > 100 {
+		// cut at the start of a character, so the name stays valid UTF-8
+		n := 100
+		for n > 0 && !utf8.RuneStart(name[n]) {
+			n--
+		}
+		name = name[:n]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "untitled"
+	}
+	return name
+}
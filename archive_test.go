@@ -0,0 +1,59 @@
+package opendj
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestArchiveMetadata(t *testing.T) {
+	dj := newTestDj(t, nil)
+	if err := dj.SetArchiveDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		entry QueueEntry
+		want  []string
+	}{
+		{
+			QueueEntry{Media: Media{Title: "Daft Punk - One More Time (Official Video)"}, Owner: "alice"},
+			[]string{"title=One More Time", "artist=Daft Punk", "comment=requested by alice"},
+		},
+		{
+			QueueEntry{Media: Media{Title: "Lofi beats to study to"}, Owner: "bob", Anonymous: true},
+			[]string{"title=Lofi beats to study to", "artist=", "comment=requested by " + anonymousOwner},
+		},
+	}
+	for _, test := range tests {
+		metadata := map[string]bool{}
+		args := dj.archiveArgs(test.entry, time.Now())
+		for i, arg := range args {
+			if arg == "-metadata" {
+				metadata[args[i+1]] = true
+			}
+		}
+		for _, want := range test.want {
+			if !metadata[want] {
+				t.Errorf("the archive of %q has no metadata %q", test.entry.Media.Title, want)
+			}
+		}
+		if strings.Contains(strings.Join(args, " "), "bob") {
+			t.Errorf("the archive of an anonymous entry names its owner: %q", args)
+		}
+	}
+}
+
+func TestSanitizeFileNameKeepsCharacters(t *testing.T) {
+	for _, title := range []string{
+		strings.Repeat("夜に駆ける", 20),
+		"a" + strings.Repeat("🎵", 30),
+		strings.Repeat("x", 99) + "é",
+	} {
+		name := sanitizeFileName(title)
+		if len(name) > 100 || !utf8.ValidString(name) || !strings.HasPrefix(title, name) {
+			t.Errorf("sanitizeFileName(%q) = %q, want a valid prefix of at most 100 bytes", title, name)
+		}
+	}
+}
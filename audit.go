@@ -0,0 +1,458 @@
+package opendj
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An Actor is who made a change and through which interface, e.g. a chat user on Twitch.
+type Actor struct {
+	Name string `json:"name"`
+	// ID is the platform specific ID of the actor, it becomes the OwnerID of entries they add.
+	ID     string `json:"id,omitempty"`
+	Source Source `json:"source,omitempty"`
+	// Moderator is the role that may make every change, see Actions.
+	Moderator bool `json:"moderator,omitempty"`
+}
+
+// AuditAction is the kind of change an AuditRecord describes.
+type AuditAction string
+
+// The actions recorded in the audit log.
+const (
+	AuditAdded             AuditAction = "added"
+	AuditRemoved           AuditAction = "removed"
+	AuditMoved             AuditAction = "moved"
+	AuditReplaced          AuditAction = "replaced"
+	AuditSkipped           AuditAction = "skipped"
+	AuditPaused            AuditAction = "paused"
+	AuditResumed           AuditAction = "resumed"
+	AuditSeeked            AuditAction = "seeked"
+	AuditApproved          AuditAction = "approved"
+	AuditRejected          AuditAction = "rejected"
+	AuditNotesChanged      AuditAction = "notesChanged"
+	AuditVoiceTrackAdded   AuditAction = "voiceTrackAdded"
+	AuditVoiceTrackRemoved AuditAction = "voiceTrackRemoved"
+)
+
+// An AuditRecord is an action in the audit log.
+type AuditRecord struct {
+	Time   time.Time   `json:"time"`
+	Actor  Actor       `json:"actor"`
+	Action AuditAction `json:"action"`
+	// EntryID and Title are the entry that was changed, if any.
+	EntryID uint64 `json:"entryId,omitempty"`
+	Title   string `json:"title,omitempty"`
+	// Detail describes the change, e.g. the new position of a moved entry.
+	Detail string `json:"detail,omitempty"`
+	// Err is set if the action failed, e.g. because the actor tried to remove an entry that was already played.
+	Err string `json:"error,omitempty"`
+	// Reason is the reason code of Err.
+	Reason Reason `json:"reason,omitempty"`
+}
+
+type auditLog struct {
+	sync.Mutex
+	records []AuditRecord
+	size    int
+	w       io.Writer
+}
+
+// SetAuditLog records every change of the queue and playback, so disputes can be settled, e.g. who removed an entry.
+// Changes made through As are recorded with their actor, changes made on the Dj directly without a name.
+//
+// The last size records are kept in memory, see AuditRecords. If w is not nil every record is also
+// written to it as a line of JSON, e.g. into an append-only file. Write errors are passed to the error handler.
+// Passing 0 and nil disables the audit log.
+func (dj *Dj) SetAuditLog(size int, w io.Writer) {
+	dj.auditLog.Lock()
+	defer dj.auditLog.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	dj.auditLog.size, dj.auditLog.w = size, w
+	if len(dj.auditLog.records) > size {
+		dj.auditLog.records = append([]AuditRecord(nil), dj.auditLog.records[len(dj.auditLog.records)-size:]...)
+	}
+}
+
+// AuditRecords returns the records in the audit log, oldest first.
+func (dj *Dj) AuditRecords() []AuditRecord {
+	dj.auditLog.Lock()
+	defer dj.auditLog.Unlock()
+	return append([]AuditRecord(nil), dj.auditLog.records...)
+}
+
+// ExportAuditLog writes the records in the audit log to w.
+//
+// ReportCSV writes the columns time, actor, source, action, entry ID, title, detail, error and reason.
+func (dj *Dj) ExportAuditLog(w io.Writer, format ReportFormat) error {
+	records := dj.AuditRecords()
+	switch format {
+	case ReportCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"time", "actor", "source", "action", "entry", "title", "detail", "error", "reason"})
+		for _, r := range records {
+			_ = cw.Write([]string{
+				r.Time.Format(time.RFC3339),
+				r.Actor.Name,
+				string(r.Actor.Source),
+				string(r.Action),
+				strconv.FormatUint(r.EntryID, 10),
+				r.Title,
+				r.Detail,
+				r.Err,
+				string(r.Reason),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case ReportJSON:
+		if records == nil {
+			records = []AuditRecord{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	default:
+		return fmt.Errorf("unknown report format %d", format)
+	}
+}
+
+// audit adds a record to the audit log.
+func (dj *Dj) audit(actor Actor, action AuditAction, entry QueueEntry, detail string, err error) {
+	record := AuditRecord{
+		Time:    dj.now(),
+		Actor:   actor,
+		Action:  action,
+		EntryID: entry.ID,
+		Title:   entry.Media.Title,
+		Detail:  detail,
+	}
+	if err != nil {
+		record.Err = err.Error()
+		record.Reason = ReasonOf(err)
+	}
+
+	dj.auditLog.Lock()
+	if dj.auditLog.size > 0 {
+		dj.auditLog.records = append(dj.auditLog.records, record)
+		if len(dj.auditLog.records) > dj.auditLog.size {
+			dj.auditLog.records[0] = AuditRecord{}
+			dj.auditLog.records = dj.auditLog.records[1:]
+		}
+	}
+	var writeErr error
+	if dj.auditLog.w != nil {
+		line, _ := json.Marshal(record)
+		_, writeErr = dj.auditLog.w.Write(append(line, '\n'))
+	}
+	dj.auditLog.Unlock()
+
+	if writeErr != nil {
+		dj.reportError(fmt.Errorf("failed to write audit log: %w", writeErr))
+	}
+}
+
+// Actions makes changes on behalf of an actor and records them in the audit log, see SetAuditLog.
+// The methods behave like the methods of the Dj with the same name, but return a Result the
+// frontend can show to the actor. Failed attempts are recorded as well.
+//
+// Moderators may make every change. Other actors may add entries to the end of a queue and
+// skip, remove or change the notes of their own entries, everything else is rejected with ErrorNotAllowed.
+type Actions struct {
+	dj    *Dj
+	actor Actor
+}
+
+// As returns the actions of actor, e.g. a frontend calls dj.As(Actor{Name: user, Source: SourceWeb}).Skip().
+func (dj *Dj) As(actor Actor) Actions {
+	return Actions{dj: dj, actor: actor}
+}
+
+// directActor makes the changes called on the Dj directly, e.g. by the application or feeds.
+// They are recorded without a name.
+var directActor = Actor{Moderator: true}
+
+// AddEntry is like Dj.AddEntry. The result is StatusPending if the entry waits for approval.
+//
+// Entries added by actors that are no moderators are owned by the actor and have no notes.
+func (a Actions) AddEntry(entry QueueEntry) Result {
+	entry = a.request(entry)
+	err := a.dj.addEntry(entry)
+	result := a.finish(AuditAdded, entry, RequestQueue, err)
+	if err == nil && a.dj.isPending(entry.ID) {
+		result.Status = StatusPending
+	}
+	return result
+}
+
+// AddEntryTo is like AddEntry for the queue with the given name,
+// only moderators may add entries to queues other than RequestQueue.
+func (a Actions) AddEntryTo(name string, entry QueueEntry) Result {
+	entry = a.request(entry)
+	var err error
+	if name != RequestQueue {
+		err = a.moderator()
+	}
+	if err == nil {
+		err = a.dj.addEntryTo(name, entry)
+	}
+	result := a.finish(AuditAdded, entry, name, err)
+	if err == nil && name == RequestQueue && a.dj.isPending(entry.ID) {
+		result.Status = StatusPending
+	}
+	return result
+}
+
+// InsertEntry is like Dj.InsertEntry, only moderators may insert entries.
+func (a Actions) InsertEntry(entry QueueEntry, index int) Result {
+	entry.assignID(a.dj.ids)
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.insertEntry(entry, index)
+	}
+	return a.finish(AuditAdded, entry, "position "+strconv.Itoa(index+1), err)
+}
+
+// RemoveByID is like Dj.RemoveByID.
+func (a Actions) RemoveByID(id uint64) Result {
+	entry, err := a.dj.EntryByID(id)
+	entry.ID = id
+	if err == nil {
+		err = a.owner(entry)
+	}
+	if err == nil {
+		err = a.dj.removeByID(id)
+	}
+	return a.finish(AuditRemoved, entry, "", err)
+}
+
+// RemoveIndex is like Dj.RemoveIndex. The entry is removed by its ID,
+// so a song that started playing in the meantime does not shift a different entry to the index.
+func (a Actions) RemoveIndex(index int) Result {
+	entry, err := a.dj.EntryAtIndex(index)
+	if err == nil {
+		err = a.owner(entry)
+	}
+	if err == nil {
+		err = a.dj.removeByID(entry.ID)
+	}
+	return a.finish(AuditRemoved, entry, "position "+strconv.Itoa(index+1), err)
+}
+
+// MoveByID is like Dj.MoveByID, only moderators may move entries.
+func (a Actions) MoveByID(id uint64, to int) Result {
+	entry, _ := a.dj.EntryByID(id)
+	entry.ID = id
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.moveByID(id, to)
+	}
+	return a.finish(AuditMoved, entry, "to position "+strconv.Itoa(to+1), err)
+}
+
+// MoveIndex is like Dj.MoveIndex, only moderators may move entries.
+func (a Actions) MoveIndex(from, to int) Result {
+	entry, _ := a.dj.EntryAtIndex(from)
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.moveIndex(from, to)
+	}
+	return a.finish(AuditMoved, entry, fmt.Sprintf("from position %d to %d", from+1, to+1), err)
+}
+
+// ChangeIndex is like Dj.ChangeIndex, only moderators may replace entries.
+func (a Actions) ChangeIndex(entry QueueEntry, index int) Result {
+	entry.assignID(a.dj.ids)
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.changeIndex(entry, index)
+	}
+	return a.finish(AuditReplaced, entry, "position "+strconv.Itoa(index+1), err)
+}
+
+// MoveBetweenQueues is like Dj.MoveBetweenQueues, only moderators may move entries.
+func (a Actions) MoveBetweenQueues(from string, index int, to string, toIndex int) Result {
+	entry := QueueEntry{}
+	if entries, err := a.dj.QueueNamed(from); err == nil && index >= 0 && index < len(entries) {
+		entry = entries[index]
+	}
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.moveBetweenQueues(from, index, to, toIndex)
+	}
+	return a.finish(AuditMoved, entry, fmt.Sprintf("from %s position %d to %s position %d", from, index+1, to, toIndex+1), err)
+}
+
+// Skip is like Dj.Skip. Actors that are no moderators may only skip their own entries,
+// if the entry ends before it is skipped the next one keeps playing.
+func (a Actions) Skip() Result {
+	np, err := a.dj.CurrentlyPlaying()
+	if err == nil {
+		err = a.owner(np.Entry)
+	}
+	if err == nil && a.actor.Moderator {
+		err = a.dj.skip()
+	} else if err == nil {
+		err = a.dj.skipIfID(np.Entry.ID)
+	}
+	return a.finish(AuditSkipped, np.Entry, "", err)
+}
+
+// Pause is like Dj.Pause, only moderators may pause.
+func (a Actions) Pause() Result {
+	np, _ := a.dj.CurrentlyPlaying()
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.pause()
+	}
+	return a.finish(AuditPaused, np.Entry, "", err)
+}
+
+// Seek is like Dj.Seek, only moderators may seek.
+func (a Actions) Seek(position time.Duration) Result {
+	np, _ := a.dj.CurrentlyPlaying()
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.seek(position)
+	}
+	return a.finish(AuditSeeked, np.Entry, "to "+position.String(), err)
+}
+
+// Resume is like Dj.Resume, only moderators may resume.
+func (a Actions) Resume() Result {
+	np, _ := a.dj.CurrentlyPlaying()
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.resume()
+	}
+	return a.finish(AuditResumed, np.Entry, "", err)
+}
+
+// Approve is like Dj.Approve, only moderators may approve requests.
+func (a Actions) Approve(id uint64) Result {
+	entry := a.dj.pendingEntry(id)
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.approve(id)
+	}
+	return a.finish(AuditApproved, entry, "", err)
+}
+
+// Reject is like Dj.Reject, only moderators may reject requests.
+func (a Actions) Reject(id uint64) Result {
+	entry := a.dj.pendingEntry(id)
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.reject(id)
+	}
+	return a.finish(AuditRejected, entry, "", err)
+}
+
+// SetNotes is like Dj.SetNotes, the notes are not recorded.
+func (a Actions) SetNotes(id uint64, notes string) Result {
+	entry, err := a.dj.EntryByID(id)
+	entry.ID = id
+	if err == nil {
+		err = a.owner(entry)
+	}
+	if err == nil {
+		err = a.dj.setNotes(id, notes)
+	}
+	return a.finish(AuditNotesChanged, entry, "", err)
+}
+
+// AddVoiceTrack is like Dj.AddVoiceTrack, only moderators may add voice tracks.
+func (a Actions) AddVoiceTrack(after, before uint64, voice Media, overlap time.Duration) Result {
+	entry, _ := a.dj.EntryByID(after)
+	entry.ID = after
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.addVoiceTrack(after, before, voice, overlap)
+	}
+	return a.finish(AuditVoiceTrackAdded, entry, voice.Title, err)
+}
+
+// RemoveVoiceTrack is like Dj.RemoveVoiceTrack, only moderators may remove voice tracks.
+func (a Actions) RemoveVoiceTrack(id uint64) Result {
+	entry, _ := a.dj.EntryByID(id)
+	entry.ID = id
+	err := a.moderator()
+	if err == nil {
+		err = a.dj.removeVoiceTrack(id)
+	}
+	return a.finish(AuditVoiceTrackRemoved, entry, "", err)
+}
+
+// moderator returns ErrorNotAllowed if the actor is no moderator.
+func (a Actions) moderator() error {
+	if !a.actor.Moderator {
+		return ErrorNotAllowed
+	}
+	return nil
+}
+
+// request returns the entry with a new ID, if the actor is no moderator it is owned by the actor
+// and has no notes, so nobody can request songs in the name of others.
+func (a Actions) request(entry QueueEntry) QueueEntry {
+	entry.assignID(a.dj.ids)
+	if !a.actor.Moderator {
+		entry.Owner, entry.OwnerID = a.actor.Name, a.actor.ID
+		entry.Notes = ""
+	}
+	return entry
+}
+
+// owner returns ErrorNotAllowed if the actor is no moderator and does not own the entry.
+//
+// Entries with an OwnerID are owned by the actor with that ID, names like Discord nicknames
+// are not unique. Only entries without an OwnerID are compared by name.
+func (a Actions) owner(entry QueueEntry) error {
+	switch {
+	case a.actor.Moderator:
+		return nil
+	case entry.OwnerID != "":
+		if entry.OwnerID == a.actor.ID {
+			return nil
+		}
+	case entry.Owner != "" && entry.Owner == a.actor.Name:
+		return nil
+	}
+	return ErrorNotAllowed
+}
+
+// finish records the action and returns its result.
+func (a Actions) finish(action AuditAction, entry QueueEntry, detail string, err error) Result {
+	a.dj.audit(a.actor, action, entry, detail, err)
+	return ResultOf(entry.ID, err)
+}
+
+// pendingEntry returns the pending entry with the given ID, or an entry with only the ID if there is none.
+func (dj *Dj) pendingEntry(id uint64) QueueEntry {
+	for _, entry := range dj.Pending() {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return QueueEntry{ID: id}
+}
+
+// isPending reports whether the entry with the given ID waits for approval.
+func (dj *Dj) isPending(id uint64) bool {
+	dj.pending.Lock()
+	defer dj.pending.Unlock()
+
+	for _, entry := range dj.pending.entries {
+		if entry.ID == id {
+			return true
+		}
+	}
+	return false
+}
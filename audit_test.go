@@ -0,0 +1,165 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestActionsRemoveIndex(t *testing.T) {
+	dj := newTestDj(t, nil)
+	dj.SetAuditLog(10, nil)
+	bob, eve := dj.As(Actor{Name: "bob"}), dj.As(Actor{Name: "eve"})
+	bob.AddEntry(QueueEntry{Media: Media{Title: "bob's song"}, Owner: "bob"})
+	eve.AddEntry(QueueEntry{Media: Media{Title: "eve's song"}, Owner: "eve"})
+
+	if result := bob.RemoveIndex(1); result.Reason != ReasonNotAllowed {
+		t.Errorf("removing an entry of another user: reason = %q, want %q", result.Reason, ReasonNotAllowed)
+	}
+	if result := bob.RemoveIndex(0); !result.OK() {
+		t.Fatalf("removing an own entry: %v", result.Err)
+	}
+	queue := dj.Queue()
+	if len(queue) != 1 || queue[0].Owner != "eve" {
+		t.Errorf("queue after removal = %v, want only the entry of eve", queue)
+	}
+
+	records := dj.AuditRecords()
+	last := records[len(records)-1]
+	if last.Action != AuditRemoved || last.Actor.Name != "bob" || last.Title != "bob's song" || last.Err != "" {
+		t.Errorf("last audit record = %+v, want bob removing his song", last)
+	}
+}
+
+func TestDirectChangesAreAudited(t *testing.T) {
+	dj := newTestDj(t, nil)
+	dj.SetAuditLog(10, nil)
+
+	if err := dj.AddEntry(QueueEntry{Media: Media{Title: "song"}, Owner: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dj.RemoveIndex(3); !errors.Is(err, ErrorOutOfRange) {
+		t.Errorf("RemoveIndex() = %v, want %v", err, ErrorOutOfRange)
+	}
+	if err := dj.Skip(); !errors.Is(err, ErrorNothingPlaying) {
+		t.Errorf("Skip() = %v, want %v", err, ErrorNothingPlaying)
+	}
+
+	want := []struct {
+		action AuditAction
+		reason Reason
+	}{
+		{AuditAdded, ""},
+		{AuditRemoved, ReasonOutOfRange},
+		{AuditSkipped, ReasonNothingPlaying},
+	}
+	records := dj.AuditRecords()
+	if len(records) != len(want) {
+		t.Fatalf("got %d audit records, want %d", len(records), len(want))
+	}
+	for i, w := range want {
+		if records[i].Action != w.action || records[i].Reason != w.reason || records[i].Actor.Name != "" {
+			t.Errorf("record %d = %+v, want %s with reason %q", i, records[i], w.action, w.reason)
+		}
+	}
+}
+
+func TestIntakeIsAudited(t *testing.T) {
+	dj := newTestDj(t, nil)
+	dj.SetAuditLog(10, nil)
+	in := dj.NewIntake(SourceTwitch, 1, nil)
+	in.Requests() <- QueueEntry{Media: Media{Title: "song"}, Owner: "bob"}
+	in.Close()
+
+	records := dj.AuditRecords()
+	if len(records) != 1 || records[0].Actor != (Actor{Name: "bob", Source: SourceTwitch}) {
+		t.Errorf("audit records = %+v, want one record of bob via twitch", records)
+	}
+}
+
+func TestActionsAddEntryAsUser(t *testing.T) {
+	dj := newTestDj(t, nil)
+	if err := dj.AddQueue("jingles", 1); err != nil {
+		t.Fatal(err)
+	}
+	bob := dj.As(Actor{Name: "bob", ID: "42"})
+
+	result := bob.AddEntry(QueueEntry{ID: 7, Media: Media{Title: "song"}, Owner: "eve", OwnerID: "1", Notes: "vip"})
+	if !result.OK() {
+		t.Fatal(result.Err)
+	}
+	entry, err := dj.EntryByID(result.EntryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Owner != "bob" || entry.OwnerID != "42" || entry.Notes != "" || entry.ID == 7 {
+		t.Errorf("queued entry = %+v, want an entry of bob without notes", entry)
+	}
+
+	if result := bob.AddEntryTo("jingles", QueueEntry{Media: Media{Title: "jingle"}}); result.Reason != ReasonNotAllowed {
+		t.Errorf("adding to another queue: reason = %q, want %q", result.Reason, ReasonNotAllowed)
+	}
+	if result := bob.AddEntryTo(RequestQueue, QueueEntry{Media: Media{Title: "other song"}}); !result.OK() {
+		t.Errorf("adding to the request queue: %v", result.Err)
+	}
+
+	mod := dj.As(Actor{Name: "mod", Moderator: true})
+	if result := mod.AddEntryTo("jingles", QueueEntry{Media: Media{Title: "jingle"}, Owner: "station", Notes: "hourly"}); !result.OK() {
+		t.Fatal(result.Err)
+	}
+	jingles, _ := dj.QueueNamed("jingles")
+	if len(jingles) != 1 || jingles[0].Owner != "station" || jingles[0].Notes != "hourly" {
+		t.Errorf("jingles = %+v, want the entry of the moderator unchanged", jingles)
+	}
+}
+
+func TestSkipIfID(t *testing.T) {
+	dj := newTestDj(t, nil)
+	ctx, _, done := dj.skipper.track(context.Background(), 2)
+	defer done()
+
+	if err := dj.skipIfID(1); !errors.Is(err, ErrorUnknownEntry) {
+		t.Errorf("skipping an entry that ended = %v, want ErrorUnknownEntry", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("the next entry was skipped")
+	}
+	if err := dj.skipIfID(2); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Error("the playing entry was not skipped")
+	}
+}
+
+func TestActionsOwnerByID(t *testing.T) {
+	dj := newTestDj(t, nil)
+	alice := dj.As(Actor{Name: "alice", ID: "1"})
+	// eve uses the same nickname as alice
+	eve := dj.As(Actor{Name: "alice", ID: "2"})
+
+	result := alice.AddEntry(QueueEntry{Media: Media{Title: "alice's song"}})
+	if !result.OK() {
+		t.Fatal(result.Err)
+	}
+	if r := eve.SetNotes(result.EntryID, "mine now"); r.Reason != ReasonNotAllowed {
+		t.Errorf("changing the notes of another user with the same name: reason = %q, want %q", r.Reason, ReasonNotAllowed)
+	}
+	if r := eve.RemoveByID(result.EntryID); r.Reason != ReasonNotAllowed {
+		t.Errorf("removing an entry of another user with the same name: reason = %q, want %q", r.Reason, ReasonNotAllowed)
+	}
+	if r := dj.As(Actor{Name: "alice"}).RemoveByID(result.EntryID); r.Reason != ReasonNotAllowed {
+		t.Errorf("removing an entry with an owner ID without an ID: reason = %q, want %q", r.Reason, ReasonNotAllowed)
+	}
+	if r := alice.RemoveByID(result.EntryID); !r.OK() {
+		t.Errorf("removing an own entry: %v", r.Err)
+	}
+
+	// entries without an owner ID are still matched by name
+	if err := dj.AddEntry(QueueEntry{Media: Media{Title: "feed"}, Owner: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := dj.As(Actor{Name: "bob", ID: "3"}).RemoveIndex(0); !r.OK() {
+		t.Errorf("removing an own entry without an owner ID: %v", r.Err)
+	}
+}
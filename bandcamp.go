@@ -0,0 +1,133 @@
+package opendj
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// tralbumData matches the attribute Bandcamp pages embed their track and album data in.
+var tralbumData = regexp.MustCompile(`data-tralbum="([^"]*)"`)
+
+// BandcampResolver resolves Bandcamp tracks and albums with the data embedded in their pages.
+//
+// Albums can't be resolved as a single media, Expand returns their tracks instead.
+// Tracks that can't be streamed, e.g. unreleased tracks of a pre-order, are left out.
+type BandcampResolver struct {
+	Client *http.Client
+}
+
+// bandcampAlbum is the subset of the embedded data that is used.
+type bandcampAlbum struct {
+	Artist    string `json:"artist"`
+	ItemType  string `json:"item_type"`
+	TrackInfo []struct {
+		Title     string          `json:"title"`
+		Duration  float64         `json:"duration"`
+		TitleLink string          `json:"title_link"`
+		File      json.RawMessage `json:"file"`
+	} `json:"trackinfo"`
+}
+
+// Resolve returns the track at url, it returns ErrorMultipleMedia for albums.
+func (r BandcampResolver) Resolve(ctx context.Context, rawURL string) (Media, error) {
+	album, tracks, err := r.fetch(ctx, rawURL)
+	if err != nil {
+		return Media{}, err
+	}
+	if album.ItemType != "track" {
+		return Media{}, fmt.Errorf("%s: %w", rawURL, ErrorMultipleMedia)
+	}
+	if len(tracks) == 0 {
+		return Media{}, fmt.Errorf("%s can't be streamed: %w", rawURL, ErrorUnavailable)
+	}
+	tracks[0].URL = rawURL
+	return tracks[0], nil
+}
+
+// Expand returns all tracks of the album or the track at url.
+func (r BandcampResolver) Expand(ctx context.Context, rawURL string) ([]Media, error) {
+	_, tracks, err := r.fetch(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%s has no tracks that can be streamed: %w", rawURL, ErrorUnavailable)
+	}
+	return tracks, nil
+}
+
+// fetch reads the data embedded in the page at rawURL and returns the streamable tracks.
+func (r BandcampResolver) fetch(ctx context.Context, rawURL string) (bandcampAlbum, []Media, error) {
+	var album bandcampAlbum
+	page, err := url.Parse(rawURL)
+	if err != nil {
+		return album, nil, err
+	}
+
+	body, err := fetchPage(ctx, r.Client, rawURL)
+	if err != nil {
+		return album, nil, err
+	}
+	match := tralbumData.FindSubmatch(body)
+	if match == nil {
+		return album, nil, fmt.Errorf("%s is not a Bandcamp track or album", rawURL)
+	}
+	if err = json.Unmarshal([]byte(html.UnescapeString(string(match[1]))), &album); err != nil {
+		return album, nil, fmt.Errorf("failed to parse Bandcamp data of %s: %w", rawURL, err)
+	}
+
+	var tracks []Media
+	for _, track := range album.TrackInfo {
+		if len(track.File) == 0 || string(track.File) == "null" || track.TitleLink == "" {
+			continue
+		}
+		link, err := page.Parse(track.TitleLink)
+		if err != nil {
+			continue
+		}
+		title := track.Title
+		if album.Artist != "" {
+			title = album.Artist + " - " + title
+		}
+		tracks = append(tracks, Media{
+			Title:    title,
+			URL:      link.String(),
+			Duration: time.Duration(track.Duration * float64(time.Second)),
+		})
+	}
+	return album, tracks, nil
+}
+
+// fetchPage returns the body of the page at rawURL, client may be nil.
+// returns an error wrapping ErrorUnavailable if the page does not exist.
+func fetchPage(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, fmt.Errorf("%s: %w", rawURL, ErrorUnavailable)
+	default:
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", rawURL, resp.Status)
+	}
+	// pages are a few hundred kilobytes, the limit only protects against misbehaving servers
+	return io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+}
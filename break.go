@@ -0,0 +1,121 @@
+package opendj
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// breakCountdownInterval is how often EventBreakCountdown is published during a break.
+const breakCountdownInterval = 10 * time.Second
+
+// Events published during a break, Remaining is set to the time left in the break.
+const (
+	EventBreakStarted   EventType = "breakStarted"
+	EventBreakCountdown EventType = "breakCountdown"
+	EventBreakEnded     EventType = "breakEnded"
+)
+
+type intermission struct {
+	duration time.Duration
+	filler   Media
+}
+
+type breaks struct {
+	sync.Mutex
+	next *intermission
+}
+
+// StartBreak stops taking entries from the queue for d once the current entry ended,
+// for shows that take scheduled breaks.
+//
+// filler is played in a loop during the break, if its URL is empty silence is played instead.
+// EventBreakStarted, EventBreakCountdown and EventBreakEnded are published to show a countdown.
+// Skip ends the break early. Calling StartBreak again before the break started replaces it.
+func (dj *Dj) StartBreak(d time.Duration, filler Media) {
+	dj.breaks.Lock()
+	defer dj.breaks.Unlock()
+	dj.breaks.next = &intermission{duration: d, filler: filler}
+}
+
+// take returns the pending break, if any.
+func (b *breaks) take() (intermission, bool) {
+	b.Lock()
+	defer b.Unlock()
+	if b.next == nil {
+		return intermission{}, false
+	}
+	next := *b.next
+	b.next = nil
+	return next, true
+}
+
+// pending returns the duration of the break that starts after the current entry, if any.
+func (b *breaks) pending() time.Duration {
+	b.Lock()
+	defer b.Unlock()
+	if b.next == nil {
+		return 0
+	}
+	return b.next.duration
+}
+
+// playBreak plays the break into the stream, it returns ErrorSkipped if the break was ended early.
+func (dj *Dj) playBreak(ctx context.Context, stream io.Writer, b intermission) (err error) {
+	ctx, skipped, done := dj.skipper.track(ctx, 0)
+	defer done()
+
+	end := dj.now().Add(b.duration)
+	dj.publish(Event{Type: EventBreakStarted, Remaining: b.duration})
+	defer func() {
+		if err != nil && skipped() {
+			err = ErrorSkipped
+		}
+		dj.publish(Event{Type: EventBreakEnded})
+	}()
+
+	countdownCtx, stopCountdown := context.WithCancel(ctx)
+	defer stopCountdown()
+	go func() {
+		ticker := time.NewTicker(breakCountdownInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-countdownCtx.Done():
+				return
+			case <-ticker.C:
+				if remaining := end.Sub(dj.now()); remaining > 0 {
+					dj.publish(Event{Type: EventBreakCountdown, Remaining: remaining})
+				}
+			}
+		}
+	}()
+
+	for {
+		remaining := end.Sub(dj.now())
+		if remaining <= 0 {
+			return nil
+		}
+		if b.filler.URL == "" {
+			return writeSilence(ctx, stream, remaining)
+		}
+
+		// the track is opened again for every loop since the input can't be rewound
+		filler, err := dj.openTrack(ctx, b.filler)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// a broken filler should not end the stream, the rest of the break is silent
+			dj.reportError(err)
+			b.filler = Media{}
+			continue
+		}
+		err = writeStream(ctx, stream, filler, nil, "-re", "-i", "pipe:0", "-t", ffmpegDuration(remaining))
+		filler.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
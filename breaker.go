@@ -0,0 +1,105 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrorCircuitOpen is returned by Resolve while the circuit breaker is open.
+var ErrorCircuitOpen = errors.New("too many failures, resolution is paused")
+
+// Events published by the circuit breaker.
+const (
+	// EventCircuitOpened is published when the circuit breaker opens, Err is the last failure.
+	EventCircuitOpened EventType = "circuitOpened"
+	// EventCircuitClosed is published when the cooldown is over and the queue is played again.
+	EventCircuitClosed EventType = "circuitClosed"
+)
+
+type breaker struct {
+	sync.Mutex
+	failures  int
+	openUntil time.Time
+	open      bool
+}
+
+// SetCircuitBreaker stops resolving and playing requests for cooldown after threshold
+// resolutions or tracks failed in a row, e.g. because the signature extraction of yt-dlp broke.
+//
+// While the breaker is open Resolve returns ErrorCircuitOpen and the fallback playlist or
+// silence is played instead of the queue, so the queue is not burned through and dropped.
+// A threshold <= 0 disables the breaker.
+func (dj *Dj) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	dj.updateConfig(func(c *Config) {
+		c.BreakerThreshold = threshold
+		c.BreakerCooldown = cooldown
+	})
+}
+
+// circuitOpen reports whether the breaker is open, publishing EventCircuitClosed once the cooldown is over.
+func (dj *Dj) circuitOpen() bool {
+	dj.breaker.Lock()
+	if !dj.breaker.open {
+		dj.breaker.Unlock()
+		return false
+	}
+	if dj.now().Before(dj.breaker.openUntil) {
+		dj.breaker.Unlock()
+		return true
+	}
+	// let requests through again, the next failure opens the breaker right away
+	dj.breaker.open = false
+	dj.breaker.failures = dj.cfg().BreakerThreshold - 1
+	dj.breaker.Unlock()
+
+	dj.publish(Event{Type: EventCircuitClosed})
+	return false
+}
+
+// recordResult counts consecutive failures and opens the breaker once the threshold is reached.
+// Unavailable media and skips are not failures.
+func (dj *Dj) recordResult(err error) {
+	if errors.Is(err, ErrorUnavailable) || errors.Is(err, ErrorSkipped) || errors.Is(err, ErrorCircuitOpen) {
+		return
+	}
+	cfg := dj.cfg()
+	if cfg.BreakerThreshold <= 0 {
+		return
+	}
+
+	dj.breaker.Lock()
+	if err == nil {
+		dj.breaker.failures = 0
+		dj.breaker.Unlock()
+		return
+	}
+	dj.breaker.failures++
+	opened := !dj.breaker.open && dj.breaker.failures >= cfg.BreakerThreshold
+	if opened {
+		dj.breaker.open = true
+		dj.breaker.openUntil = dj.now().Add(cfg.BreakerCooldown)
+	}
+	dj.breaker.Unlock()
+
+	if opened {
+		dj.publish(Event{Type: EventCircuitOpened, Err: err})
+	}
+}
+
+// playFallback plays an entry of the fallback playlist, or silence if there is none.
+func (dj *Dj) playFallback(ctx context.Context, stream io.Writer) error {
+	entry, ok := dj.fallback.next(dj.now())
+	if !ok {
+		dj.setCurrentEntry(QueueEntry{})
+		return writeSilence(ctx, stream, 15*time.Second)
+	}
+
+	dj.setCurrentEntry(entry)
+	if err := dj.playEntry(ctx, stream, entry); err != nil && !errors.Is(err, ErrorSkipped) {
+		return err
+	}
+	return nil
+}
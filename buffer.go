@@ -0,0 +1,345 @@
+package opendj
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// tsPacketSize is the size of an MPEG-TS packet, data is dropped in whole packets.
+const tsPacketSize = 188
+
+// BufferPolicy decides what happens when an output does not keep up with the encoder.
+type BufferPolicy int
+
+const (
+	// BufferBlock stalls the encoder until the output caught up.
+	// Since all outputs share the encoder a slow output delays all others.
+	BufferBlock BufferPolicy = iota
+	// BufferDropOldest discards the oldest buffered audio of the slow output,
+	// so the encoder and the other outputs keep going.
+	BufferDropOldest
+)
+
+// SetOutputBuffer sets how many bytes of the stream are buffered for every output and what happens
+// when an output falls behind by more than that. Bigger buffers absorb longer hiccups of outputs
+// at the cost of latency. The default is no buffer with BufferBlock.
+//
+// With BufferDropOldest the encoder is paced to real time, so only audio an output really
+// fell behind on is dropped. The buffer should hold at least a second of the stream then.
+//
+// The change applies the next time playback is started.
+// returns an error if size is negative or the policy is unknown.
+func (dj *Dj) SetOutputBuffer(size int, policy BufferPolicy) error {
+	return dj.updateConfig(func(c *Config) {
+		c.OutputBufferSize = size
+		c.OutputBufferPolicy = policy
+	})
+}
+
+// SetTargetLatency sets the maximum time audio may wait in the buffer of an output.
+// Older audio is dropped so outputs that fell behind catch up, which keeps the delay between
+// a request and hearing it short. <= 0 disables it, which is the default. Like with BufferDropOldest
+// the encoder is paced to real time while it is enabled.
+//
+// The change applies the next time playback or an output is started.
+func (dj *Dj) SetTargetLatency(d time.Duration) {
+	dj.updateConfig(func(c *Config) { c.TargetLatency = d })
+}
+
+// Latency returns the estimated delay between encoding audio and it being sent by the slowest output.
+//
+// The latency of every output is part of PipelineState.
+func (dj *Dj) Latency() time.Duration {
+	var latency time.Duration
+	for _, state := range dj.PipelineState() {
+		if state.Latency > latency {
+			latency = state.Latency
+		}
+	}
+	return latency
+}
+
+// errClosedBuffer is returned when writing to a buffer whose reader is closed.
+var errClosedBuffer = errors.New("write to closed buffer")
+
+// writeMark records when the data up to end was written.
+type writeMark struct {
+	end int64
+	at  time.Time
+}
+
+// streamBuffer is a bounded buffer between the encoder and an output.
+type streamBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	data   []byte
+	size   int
+	policy BufferPolicy
+	// target is the maximum age of buffered data, older data is dropped. <= 0 disables it.
+	target time.Duration
+
+	// readOffset is the stream offset of data[0], the amount of bytes read or dropped
+	readOffset int64
+	marks      []writeMark
+	latency    time.Duration
+
+	// writeErr is set once the writer is done, readErr once the reader is done
+	writeErr error
+	readErr  error
+}
+
+// newStreamBuffer returns a buffer for an output that starts reading the stream at offset.
+func newStreamBuffer(offset int64, size int, policy BufferPolicy, target time.Duration) *streamBuffer {
+	b := &streamBuffer{readOffset: offset, size: size, policy: policy, target: target}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Write appends p to the buffer, either waiting for room or dropping old data.
+func (b *streamBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.target > 0 {
+		for len(b.marks) > 0 && now.Sub(b.marks[0].at) > b.target {
+			mark := b.marks[0]
+			b.dropUntil(mark.end)
+			if len(b.marks) > 0 && b.marks[0] == mark {
+				// less than a packet was left to drop
+				b.marks = b.marks[1:]
+			}
+		}
+	}
+
+	if b.policy == BufferDropOldest {
+		if excess := len(b.data) + len(p) - b.size; excess > 0 && len(b.data) > 0 {
+			b.dropUntil(b.readOffset + int64(excess) + tsPacketSize - 1)
+		}
+	} else {
+		// data bigger than the buffer is accepted once the buffer is empty
+		for b.readErr == nil && len(b.data) > 0 && len(b.data)+len(p) > b.size {
+			b.cond.Wait()
+		}
+	}
+	if b.readErr != nil {
+		return 0, b.readErr
+	}
+
+	b.data = append(b.data, p...)
+	b.marks = append(b.marks, writeMark{end: b.readOffset + int64(len(b.data)), at: now})
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+// dropUntil discards the buffered data before offset, rounded down to a packet boundary
+// so the output stays in sync. The lock has to be held.
+func (b *streamBuffer) dropUntil(offset int64) {
+	offset -= offset % tsPacketSize
+	if end := b.readOffset + int64(len(b.data)); offset > end {
+		offset = end
+	}
+	if offset > b.readOffset {
+		// dropped data was never sent, so it does not count towards the latency
+		latency := b.latency
+		b.consume(int(offset - b.readOffset))
+		b.latency = latency
+	}
+}
+
+// consume removes n bytes from the front of the buffer and updates the latency. The lock has to be held.
+func (b *streamBuffer) consume(n int) {
+	b.data = b.data[n:]
+	b.readOffset += int64(n)
+	for len(b.marks) > 0 && b.marks[0].end <= b.readOffset {
+		b.latency = time.Since(b.marks[0].at)
+		b.marks = b.marks[1:]
+	}
+}
+
+// Read reads buffered data, waiting until there is some.
+func (b *streamBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.data) == 0 && b.writeErr == nil && b.readErr == nil {
+		b.cond.Wait()
+	}
+	if b.readErr != nil {
+		return 0, b.readErr
+	}
+	if len(b.data) == 0 {
+		return 0, b.writeErr
+	}
+
+	n := copy(p, b.data)
+	b.consume(n)
+	b.cond.Broadcast()
+	return n, nil
+}
+
+// Latency returns how long the most recently read data waited in the buffer.
+func (b *streamBuffer) Latency() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.marks) > 0 {
+		// the output is behind, the oldest buffered data is at least this old
+		if age := time.Since(b.marks[0].at); age > b.latency {
+			return age
+		}
+	}
+	return b.latency
+}
+
+// Close ends the stream, the reader gets io.EOF after reading the buffered data.
+func (b *streamBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.writeErr == nil {
+		b.writeErr = io.EOF
+	}
+	b.cond.Broadcast()
+	return nil
+}
+
+// CloseWithError closes the reader side, writes return err from now on.
+func (b *streamBuffer) CloseWithError(err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		err = errClosedBuffer
+	}
+	if b.readErr == nil {
+		b.readErr = err
+	}
+	b.data, b.marks = nil, nil
+	b.cond.Broadcast()
+	return nil
+}
+
+// paceLead is how far the paced encoder may run ahead of real time, to absorb jitter.
+const paceLead = 250 * time.Millisecond
+
+// maxPaceLag is how far the encoder may fall behind real time before pacing starts over,
+// e.g. after a pause, so it does not catch up in a burst that would be dropped.
+const maxPaceLag = time.Second
+
+// pacer passes the MPEG-TS stream of the encoder through at real time, using the timestamps
+// of the audio packets. Without it the encoder produces audio as fast as it can, which
+// buffers that drop data would throw away while outputs read at real time.
+type pacer struct {
+	w    io.Writer
+	lead time.Duration
+
+	start time.Time
+	// position is the stream time of the last timestamp
+	position time.Duration
+	// lastPTS is the last timestamp in 90 kHz units, lastDelta the step to it
+	lastPTS   int64
+	lastDelta int64
+	// packet collects a packet split across writes
+	packet []byte
+}
+
+func newPacer(w io.Writer, lead time.Duration) *pacer {
+	return &pacer{w: w, lead: lead, lastPTS: -1}
+}
+
+// Write writes p, waiting before every packet with a timestamp until it is due.
+func (pc *pacer) Write(p []byte) (int, error) {
+	written := 0
+	for i := 0; i < len(p); {
+		if len(pc.packet) == 0 && p[i] != tsSyncByte {
+			// not aligned, resynchronize on the next packet
+			i++
+			continue
+		}
+		n := tsPacketSize - len(pc.packet)
+		if n > len(p)-i {
+			n = len(p) - i
+		}
+		pc.packet = append(pc.packet, p[i:i+n]...)
+		i += n
+		if len(pc.packet) < tsPacketSize {
+			break
+		}
+		pts, ok := packetPTS(pc.packet)
+		pc.packet = pc.packet[:0]
+		if !ok {
+			continue
+		}
+
+		// everything before the packet is due already
+		if start := i - tsPacketSize; start > written {
+			if _, err := pc.w.Write(p[written:start]); err != nil {
+				return written, err
+			}
+			written = start
+		}
+		pc.wait(pts)
+	}
+	if written < len(p) {
+		if _, err := pc.w.Write(p[written:]); err != nil {
+			return written, err
+		}
+	}
+	return len(p), nil
+}
+
+// wait advances the stream time to pts and sleeps until it is due.
+func (pc *pacer) wait(pts int64) {
+	if pc.lastPTS >= 0 {
+		delta := pts - pc.lastPTS
+		if delta <= 0 || delta > 10*90000 {
+			// the next track starts with its own timestamps, assume the usual spacing
+			delta = pc.lastDelta
+		}
+		pc.lastDelta = delta
+		pc.position += time.Duration(delta) * time.Second / 90000
+	}
+	pc.lastPTS = pts
+
+	now := time.Now()
+	if pc.start.IsZero() {
+		pc.start = now
+		return
+	}
+	ahead := pc.start.Add(pc.position).Sub(now)
+	if ahead > pc.lead {
+		time.Sleep(ahead - pc.lead)
+	} else if ahead < -maxPaceLag {
+		pc.start = now.Add(-pc.position)
+	}
+}
+
+// tsSyncByte starts every MPEG-TS packet.
+const tsSyncByte = 0x47
+
+// packetPTS returns the presentation timestamp of the audio PES packet starting in the TS packet.
+func packetPTS(packet []byte) (int64, bool) {
+	// payload_unit_start_indicator
+	if packet[1]&0x40 == 0 {
+		return 0, false
+	}
+	payload := packet[4:]
+	switch packet[3] >> 4 & 3 {
+	case 1:
+	case 3:
+		if int(packet[4])+1 > len(payload) {
+			return 0, false
+		}
+		payload = payload[int(packet[4])+1:]
+	default:
+		return 0, false
+	}
+	// PES start code and an audio stream ID with a PTS
+	if len(payload) < 14 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 ||
+		payload[3] < 0xC0 || payload[3] > 0xDF || payload[7]&0x80 == 0 {
+		return 0, false
+	}
+	pts := int64(payload[9]>>1&7)<<30 | int64(payload[10])<<22 | int64(payload[11]>>1)<<15 |
+		int64(payload[12])<<7 | int64(payload[13]>>1)
+	return pts, true
+}
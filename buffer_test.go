@@ -0,0 +1,128 @@
+package opendj
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+// testPacket returns a TS packet with the sequence number seq at its end,
+// it starts an audio PES packet with the timestamp pts if pts is not negative.
+func testPacket(seq uint32, pts int64) []byte {
+	packet := make([]byte, tsPacketSize)
+	packet[0], packet[1], packet[2], packet[3] = tsSyncByte, 0x01, 0x00, 0x10
+	if pts >= 0 {
+		packet[1] |= 0x40
+		pes := packet[4:]
+		pes[2], pes[3] = 1, 0xC0
+		pes[6], pes[7], pes[8] = 0x80, 0x80, 5
+		pes[9] = 0x21 | byte(pts>>29)&0x0E
+		pes[10] = byte(pts >> 22)
+		pes[11] = byte(pts>>14) | 1
+		pes[12] = byte(pts >> 7)
+		pes[13] = byte(pts<<1) | 1
+	}
+	binary.BigEndian.PutUint32(packet[tsPacketSize-4:], seq)
+	return packet
+}
+
+func TestPacketPTS(t *testing.T) {
+	for _, want := range []int64{0, 126000, 1<<33 - 1} {
+		if got, ok := packetPTS(testPacket(0, want)); !ok || got != want {
+			t.Errorf("packetPTS() = %d, %v, want %d", got, ok, want)
+		}
+	}
+	if _, ok := packetPTS(testPacket(0, -1)); ok {
+		t.Error("packetPTS() found a timestamp in a packet without PES header")
+	}
+}
+
+// TestDropOldestContiguous checks that an output reading at real time gets every packet
+// with BufferDropOldest, as long as the encoder is paced.
+func TestDropOldestContiguous(t *testing.T) {
+	const (
+		chunks          = 40
+		packetsPerChunk = 4
+		// 25ms of audio per chunk
+		ptsStep = 2250
+	)
+	tests := []struct {
+		name           string
+		paced          bool
+		wantContiguous bool
+	}{
+		{"paced", true, true},
+		{"unpaced", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := newFanout(1, 2*packetsPerChunk*tsPacketSize, BufferDropOldest, 0)
+			r := stream.add()
+
+			go func() {
+				var w io.Writer = stream
+				if tt.paced {
+					w = newPacer(stream, 0)
+				}
+				// the encoder writes big blocks as fast as it can, the second half
+				// is a new track whose timestamps start over
+				var block []byte
+				seq := uint32(0)
+				for i := 0; i < chunks; i++ {
+					pts := int64(126000 + (i%(chunks/2))*ptsStep)
+					for j := 0; j < packetsPerChunk; j++ {
+						if j > 0 {
+							pts = -1
+						}
+						block = append(block, testPacket(seq, pts)...)
+						seq++
+					}
+					if len(block) >= 10*packetsPerChunk*tsPacketSize {
+						_, _ = w.Write(block)
+						block = nil
+					}
+				}
+				_, _ = w.Write(block)
+				stream.Close()
+			}()
+
+			// read like ffmpeg with -re
+			var start time.Time
+			var position time.Duration
+			lastPTS := int64(-1)
+			var received []uint32
+			packet := make([]byte, tsPacketSize)
+			for {
+				if _, err := io.ReadFull(r, packet); err != nil {
+					break
+				}
+				if pts, ok := packetPTS(packet); ok {
+					if lastPTS >= 0 && pts > lastPTS {
+						position += time.Duration(pts-lastPTS) * time.Second / 90000
+					} else if lastPTS >= 0 {
+						position += ptsStep * time.Second / 90000
+					}
+					lastPTS = pts
+					if start.IsZero() {
+						start = time.Now()
+					}
+					time.Sleep(time.Until(start.Add(position)))
+				}
+				received = append(received, binary.BigEndian.Uint32(packet[tsPacketSize-4:]))
+			}
+
+			contiguous := len(received) == chunks*packetsPerChunk
+			for i, seq := range received {
+				if seq != uint32(i) {
+					contiguous = false
+					break
+				}
+			}
+			if contiguous != tt.wantContiguous {
+				t.Errorf("received %d of %d packets, contiguous = %v, want %v",
+					len(received), chunks*packetsPerChunk, contiguous, tt.wantContiguous)
+			}
+		})
+	}
+}
@@ -0,0 +1,691 @@
+package opendj
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventCalendarChanged is published when a calendar followed with FollowCalendar changed.
+const EventCalendarChanged EventType = "calendarChanged"
+
+// calendarHorizon is how far ahead FollowCalendar looks for shows.
+const calendarHorizon = 7 * 24 * time.Hour
+
+// maxOccurrences limits the expansion of a recurring event, so broken rules can't take forever.
+const maxOccurrences = 10000
+
+// A CalendarShow is a show scheduled in an iCalendar feed.
+//
+// Title is the SUMMARY of the event. Host is the X-OPENDJ-HOST property or the name of the
+// ORGANIZER, Playlist the X-OPENDJ-PLAYLIST property or the URL of the event and Artwork
+// the X-OPENDJ-ARTWORK or IMAGE property.
+type CalendarShow struct {
+	UID      string
+	Title    string
+	Host     string
+	Playlist string
+	Artwork  string
+	Start    time.Time
+	End      time.Time
+}
+
+// CalendarFeed reads shows from an iCalendar feed.
+type CalendarFeed struct {
+	// URL is an http, https or webcal URL or the path of a local file.
+	URL string
+	// Location is used for times without a time zone, nil uses the location set with SetLocation
+	// when the feed is followed and the local time zone otherwise.
+	Location *time.Location
+	Client   *http.Client
+}
+
+// Shows returns the shows of the feed that overlap the range from to, sorted by start.
+func (f CalendarFeed) Shows(ctx context.Context, from, to time.Time) ([]CalendarShow, error) {
+	var data []byte
+	var err error
+	if isLocalFile(f.URL) {
+		data, err = os.ReadFile(filePath(f.URL))
+	} else {
+		data, err = fetchPage(ctx, f.Client, strings.Replace(f.URL, "webcal://", "https://", 1))
+	}
+	if err != nil {
+		return nil, err
+	}
+	loc := f.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	return ParseCalendar(bytes.NewReader(data), from, to, loc)
+}
+
+// ParseCalendar returns the shows of an iCalendar file that overlap the range from to, sorted by start.
+//
+// Times without a time zone are in loc. Daily, weekly and monthly recurrences with INTERVAL, COUNT,
+// UNTIL and weekly BYDAY are expanded, EXDATE, changed occurrences and cancelled events are respected.
+// Recurring shows follow the wall clock of their time zone, see SetLocation.
+func ParseCalendar(r io.Reader, from, to time.Time, loc *time.Location) ([]CalendarShow, error) {
+	events, err := parseEvents(r, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// changed occurrences replace the occurrence they have the ID of
+	type occurrence struct {
+		uid   string
+		start int64
+	}
+	replaced := make(map[occurrence]bool)
+	for _, e := range events {
+		if !e.recurrenceID.IsZero() {
+			replaced[occurrence{e.show.UID, e.recurrenceID.UnixNano()}] = true
+		}
+	}
+
+	var shows []CalendarShow
+	for _, e := range events {
+		starts, err := e.occurrences(to)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", e.show.Title, err)
+		}
+		for _, start := range starts {
+			if e.recurrenceID.IsZero() && replaced[occurrence{e.show.UID, start.UnixNano()}] {
+				continue
+			}
+			show := e.show
+			show.Start, show.End = start, start.Add(e.duration)
+			if e.cancelled || !show.End.After(from) || !show.Start.Before(to) {
+				continue
+			}
+			shows = append(shows, show)
+		}
+	}
+	sort.SliceStable(shows, func(i, j int) bool {
+		return shows[i].Start.Before(shows[j].Start)
+	})
+	return shows, nil
+}
+
+// calendarEvent is a VEVENT of an iCalendar file.
+type calendarEvent struct {
+	show     CalendarShow
+	duration time.Duration
+	allDay   bool
+	rule     map[string]string
+	exdates  []time.Time
+	// recurrenceID is the start of the occurrence a changed occurrence replaces
+	recurrenceID time.Time
+	cancelled    bool
+}
+
+// icsProperty is a content line of an iCalendar file.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseEvents returns the events of an iCalendar file.
+func parseEvents(r io.Reader, loc *time.Location) ([]calendarEvent, error) {
+	props, err := readICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []calendarEvent
+	var event *calendarEvent
+	var end time.Time
+	// depth counts the components nested in an event, like alarms
+	depth := 0
+	for _, p := range props {
+		switch {
+		case p.name == "BEGIN" && p.value == "VEVENT" && event == nil:
+			event, end = &calendarEvent{}, time.Time{}
+			continue
+		case event == nil:
+			continue
+		case p.name == "BEGIN":
+			depth++
+			continue
+		case p.name == "END" && depth > 0:
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+
+		switch p.name {
+		case "END":
+			if event.show.Start.IsZero() {
+				return nil, fmt.Errorf("event %q has no start", event.show.Title)
+			}
+			if !end.IsZero() {
+				event.duration = end.Sub(event.show.Start)
+			} else if event.duration == 0 && event.allDay {
+				event.duration = 24 * time.Hour
+			}
+			if event.duration < 0 {
+				return nil, fmt.Errorf("event %q ends before it starts", event.show.Title)
+			}
+			events = append(events, *event)
+			event = nil
+		case "UID":
+			event.show.UID = p.value
+		case "SUMMARY":
+			event.show.Title = unescapeICS(p.value)
+		case "X-OPENDJ-HOST":
+			event.show.Host = unescapeICS(p.value)
+		case "ORGANIZER":
+			if event.show.Host == "" {
+				event.show.Host = strings.Trim(p.params["CN"], `"`)
+			}
+		case "X-OPENDJ-PLAYLIST":
+			event.show.Playlist = unescapeICS(p.value)
+		case "URL":
+			if event.show.Playlist == "" {
+				event.show.Playlist = p.value
+			}
+		case "X-OPENDJ-ARTWORK":
+			event.show.Artwork = p.value
+		case "IMAGE":
+			if event.show.Artwork == "" && p.params["VALUE"] == "URI" {
+				event.show.Artwork = p.value
+			}
+		case "STATUS":
+			event.cancelled = strings.EqualFold(p.value, "CANCELLED")
+		case "DTSTART":
+			if event.show.Start, event.allDay, err = parseICSTime(p, loc); err != nil {
+				return nil, err
+			}
+		case "DTEND":
+			if end, _, err = parseICSTime(p, loc); err != nil {
+				return nil, err
+			}
+		case "DURATION":
+			if event.duration, err = parseICSDuration(p.value); err != nil {
+				return nil, err
+			}
+		case "RRULE":
+			event.rule = make(map[string]string)
+			for _, part := range strings.Split(p.value, ";") {
+				name, value, _ := strings.Cut(part, "=")
+				event.rule[strings.ToUpper(name)] = strings.ToUpper(value)
+			}
+		case "EXDATE":
+			for _, value := range strings.Split(p.value, ",") {
+				t, _, err := parseICSTime(icsProperty{p.name, p.params, value}, loc)
+				if err != nil {
+					return nil, err
+				}
+				event.exdates = append(event.exdates, t)
+			}
+		case "RECURRENCE-ID":
+			if event.recurrenceID, _, err = parseICSTime(p, loc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return events, nil
+}
+
+// occurrences returns the starts of the event until to.
+func (e calendarEvent) occurrences(to time.Time) ([]time.Time, error) {
+	start := e.show.Start
+	if e.rule == nil || !e.recurrenceID.IsZero() {
+		return []time.Time{start}, nil
+	}
+
+	interval := 1
+	if value, ok := e.rule["INTERVAL"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid interval %q", value)
+		}
+		interval = n
+	}
+	count := maxOccurrences
+	if value, ok := e.rule["COUNT"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid count %q", value)
+		}
+		count = n
+	}
+	until := to
+	if value, ok := e.rule["UNTIL"]; ok {
+		t, _, err := parseICSTime(icsProperty{value: value}, start.Location())
+		if err != nil {
+			return nil, err
+		}
+		if t.Before(until) {
+			until = t
+		}
+	}
+
+	// the days of the occurrences, relative to the day of the start
+	var days []int
+	var step func(n int) (years, months, days int)
+	switch e.rule["FREQ"] {
+	case "DAILY":
+		days = []int{0}
+		step = func(n int) (int, int, int) { return 0, 0, n * interval }
+	case "WEEKLY":
+		weekdays := map[string]time.Weekday{
+			"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+			"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+		}
+		// weeks start on Monday
+		offset := (int(start.Weekday()) + 6) % 7
+		if value, ok := e.rule["BYDAY"]; ok {
+			for _, name := range strings.Split(value, ",") {
+				weekday, ok := weekdays[name]
+				if !ok {
+					return nil, fmt.Errorf("unsupported day %q", name)
+				}
+				days = append(days, (int(weekday)+6)%7-offset)
+			}
+			sort.Ints(days)
+		} else {
+			days = []int{0}
+		}
+		step = func(n int) (int, int, int) { return 0, 0, 7 * n * interval }
+	case "MONTHLY":
+		days = []int{0}
+		step = func(n int) (int, int, int) { return 0, n * interval, 0 }
+	default:
+		return nil, fmt.Errorf("unsupported recurrence rule %q", e.rule["FREQ"])
+	}
+
+	year, month, day := start.Date()
+	wall := wallClock(start)
+	var starts []time.Time
+	// excluded occurrences count as well
+	counted := 0
+	for n := 0; counted < count && n < maxOccurrences; n++ {
+		dy, dm, dd := step(n)
+		if e.rule["FREQ"] == "MONTHLY" && time.Date(year+dy, month+time.Month(dm), day, 0, 0, 0, 0, time.UTC).Day() != day {
+			// months without the day are skipped
+			continue
+		}
+		for _, offset := range days {
+			t := wallClockTime(year+dy, month+time.Month(dm), day+dd+offset, wall, start.Location())
+			if t.Before(start) {
+				continue
+			}
+			if t.After(until) || counted >= count {
+				return starts, nil
+			}
+			counted++
+			if !e.excluded(t) {
+				starts = append(starts, t)
+			}
+		}
+	}
+	return starts, nil
+}
+
+// excluded reports whether the occurrence starting at t is excluded with EXDATE.
+func (e calendarEvent) excluded(t time.Time) bool {
+	for _, exdate := range e.exdates {
+		if exdate.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// readICS returns the unfolded content lines of an iCalendar file.
+func readICS(r io.Reader) ([]icsProperty, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			// folded continuation of the previous line
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+
+	props := make([]icsProperty, 0, len(lines))
+	for _, line := range lines {
+		// the value starts at the first colon that is not quoted in a parameter
+		quoted, split := false, -1
+		for i, c := range line {
+			if c == '"' {
+				quoted = !quoted
+			} else if c == ':' && !quoted {
+				split = i
+				break
+			}
+		}
+		if split < 0 {
+			return nil, fmt.Errorf("invalid calendar line %q", line)
+		}
+		parts := strings.Split(line[:split], ";")
+		p := icsProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[split+1:]}
+		for _, param := range parts[1:] {
+			name, value, _ := strings.Cut(param, "=")
+			p.params[strings.ToUpper(name)] = value
+		}
+		if p.name == "BEGIN" || p.name == "END" {
+			p.value = strings.ToUpper(p.value)
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// parseICSTime parses a date or date-time property, allDay is set for dates.
+func parseICSTime(p icsProperty, loc *time.Location) (t time.Time, allDay bool, err error) {
+	value := strings.TrimSpace(p.value)
+	if tzid := strings.Trim(p.params["TZID"], `"`); tzid != "" {
+		// unknown zones, like the Windows names some clients use, fall back to loc
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			loc = zone
+		}
+	}
+
+	if p.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		date, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+		}
+		return wallClockTime(date.Year(), date.Month(), date.Day(), 0, loc), true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid time %q", value)
+		}
+		return t.In(loc), false, nil
+	}
+	wall, err := time.Parse("20060102T150405", value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q", value)
+	}
+	return wallClockTime(wall.Year(), wall.Month(), wall.Day(), wallClock(wall), loc), false, nil
+}
+
+// parseICSDuration parses a duration like PT1H30M or P1W.
+func parseICSDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "-")
+	negative := strings.HasPrefix(value, "-")
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	var d time.Duration
+	number := ""
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			number += string(c)
+		case c == 'T' && number == "":
+		case units[c] != 0 && number != "":
+			n, _ := strconv.Atoi(number)
+			d += time.Duration(n) * units[c]
+			number = ""
+		default:
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+// unescapeICS resolves the escapes of a text value.
+func unescapeICS(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// runningShow is the show FollowCalendar is playing.
+type runningShow struct {
+	show CalendarShow
+	// ids are the entries that were queued for the show
+	ids []uint64
+	// loaded is false if the playlist failed to load, it is loaded again on the next check
+	loaded bool
+}
+
+// FollowCalendar checks the calendar feed every interval and plays its shows.
+//
+// When a show starts, its playlist is expanded and added to the named queue, see AddQueue.
+// Local .m3u and .pls files are imported with ImportPlaylistFile, everything else is expanded with
+// the resolver. When the show ends, its entries that were not played yet are removed again.
+// The shows are set as the show schedule, see SetShowSchedule. If the calendar changes,
+// EventCalendarChanged is published. A running show that was removed or got another playlist
+// is ended, changes to its end are applied right away.
+// Failed checks and playlists are passed to the error handler.
+// It blocks until ctx is cancelled, so it should be started in its own goroutine.
+func (dj *Dj) FollowCalendar(ctx context.Context, feed CalendarFeed, interval time.Duration, queue string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	if feed.Location == nil {
+		feed.Location = dj.cfg().location()
+	}
+
+	var shows []CalendarShow
+	var current *runningShow
+	var checked time.Time
+	check := true
+	for {
+		now := dj.now()
+		if check {
+			updated, err := feed.Shows(ctx, now.Add(-calendarHorizon), now.Add(calendarHorizon))
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				dj.reportError(fmt.Errorf("failed to check calendar %s: %w", feed.URL, err))
+			} else {
+				// only the range both checks looked at is compared
+				if shows != nil && !sameShows(shows, updated, now.Add(-calendarHorizon), checked.Add(calendarHorizon)) {
+					dj.publish(Event{Type: EventCalendarChanged})
+				}
+				shows, checked = updated, now
+				dj.SetShowSchedule(scheduledShows(shows))
+			}
+		}
+		current = dj.followShow(ctx, current, shows, now, queue)
+
+		// wake up for the next start or end, or the next check
+		wait := interval
+		for _, show := range shows {
+			for _, t := range []time.Time{show.Start, show.End} {
+				if d := t.Sub(now); d > 0 && d < wait {
+					wait = d
+				}
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check = true
+		case <-timer.C:
+			check = false
+		}
+	}
+}
+
+// followShow ends the current show if it is over and starts the show that is on at now.
+// It returns the show that is playing.
+func (dj *Dj) followShow(ctx context.Context, current *runningShow, shows []CalendarShow, now time.Time, queue string) *runningShow {
+	var active *CalendarShow
+	for i := range shows {
+		// if shows overlap the one that started last is on
+		if !shows[i].Start.After(now) && shows[i].End.After(now) {
+			active = &shows[i]
+		}
+	}
+
+	if current != nil {
+		if active != nil && active.UID == current.show.UID && active.Start.Equal(current.show.Start) && active.Playlist == current.show.Playlist {
+			current.show = *active
+			if !current.loaded {
+				dj.startShow(ctx, current, queue)
+			}
+			return current
+		}
+		dj.endShow(current, queue)
+		current = nil
+	}
+	if active == nil {
+		return nil
+	}
+
+	current = &runningShow{show: *active}
+	dj.startShow(ctx, current, queue)
+	return current
+}
+
+// startShow adds the playlist of the show to the queue.
+//
+// If the playlist fails to load the show stays on without entries, so the next show is not
+// started early, and followShow tries again.
+func (dj *Dj) startShow(ctx context.Context, show *runningShow, queue string) {
+	entries, err := dj.showEntries(ctx, show.show)
+	if err != nil {
+		if ctx.Err() == nil {
+			dj.reportError(fmt.Errorf("failed to load the playlist of %q: %w", show.show.Title, err))
+		}
+		return
+	}
+	show.loaded = true
+	for _, entry := range entries {
+		result := dj.As(directActor).AddEntryTo(queue, entry)
+		if result.Err != nil {
+			dj.reportError(fmt.Errorf("failed to queue %q for %q: %w", entry.Media.Title, show.show.Title, result.Err))
+			continue
+		}
+		show.ids = append(show.ids, result.EntryID)
+	}
+}
+
+// endShow removes the entries of the show that were not played yet.
+func (dj *Dj) endShow(show *runningShow, queue string) {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	q := dj.lookupQueue(queue)
+	if q == nil {
+		return
+	}
+	ids := make(map[uint64]bool, len(show.ids))
+	for _, id := range show.ids {
+		ids[id] = true
+	}
+	var indexes []int
+	q.each(func(i int, entry QueueEntry) bool {
+		if ids[entry.ID] {
+			indexes = append(indexes, i)
+		}
+		return true
+	})
+	// from the back, so the indexes of the others stay the same
+	for i := len(indexes) - 1; i >= 0; i-- {
+		q.remove(indexes[i])
+	}
+}
+
+// showEntries returns the entries of the playlist of the show.
+func (dj *Dj) showEntries(ctx context.Context, show CalendarShow) ([]QueueEntry, error) {
+	if show.Playlist == "" {
+		return nil, nil
+	}
+	var entries []QueueEntry
+	switch ext := strings.ToLower(filepath.Ext(show.Playlist)); {
+	case isLocalFile(show.Playlist) && (ext == ".m3u" || ext == ".m3u8" || ext == ".pls"):
+		var err error
+		if entries, err = ImportPlaylistFile(filePath(show.Playlist)); err != nil {
+			return nil, err
+		}
+	default:
+		media, err := dj.Expand(ctx, show.Playlist)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range media {
+			entries = append(entries, QueueEntry{Media: m})
+		}
+	}
+	for i := range entries {
+		entries[i].Owner = show.Host
+		entries[i].Tags = append(entries[i].Tags, show.Title)
+	}
+	return entries, nil
+}
+
+// sameShows reports whether both schedules contain the same shows overlapping the range from to.
+func sameShows(a, b []CalendarShow, from, to time.Time) bool {
+	within := func(shows []CalendarShow) []CalendarShow {
+		var result []CalendarShow
+		for _, show := range shows {
+			if show.End.After(from) && show.Start.Before(to) {
+				result = append(result, show)
+			}
+		}
+		return result
+	}
+	a, b = within(a), within(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.UID != y.UID || x.Title != y.Title || x.Host != y.Host || x.Playlist != y.Playlist ||
+			x.Artwork != y.Artwork || !x.Start.Equal(y.Start) || !x.End.Equal(y.End) {
+			return false
+		}
+	}
+	return true
+}
+
+// scheduledShows converts calendar shows for SetShowSchedule.
+func scheduledShows(shows []CalendarShow) []ScheduledShow {
+	scheduled := make([]ScheduledShow, len(shows))
+	for i, show := range shows {
+		scheduled[i] = ScheduledShow{
+			Show:  Show{Name: show.Title, Host: show.Host, Artwork: show.Artwork},
+			Start: show.Start,
+			End:   show.End,
+		}
+	}
+	return scheduled
+}
@@ -0,0 +1,227 @@
+package opendj
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadICSUnfolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines string
+		want  icsProperty
+	}{
+		{"plain", "SUMMARY:Morning Show", icsProperty{"SUMMARY", map[string]string{}, "Morning Show"}},
+		{"folded with a space", "SUMMARY:Morning\r\n  Show", icsProperty{"SUMMARY", map[string]string{}, "Morning Show"}},
+		{"folded with a tab", "SUMMARY:Mor\r\n\tning Show", icsProperty{"SUMMARY", map[string]string{}, "Morning Show"}},
+		{"folded twice", "DESCRIPTION:a\n b\n c", icsProperty{"DESCRIPTION", map[string]string{}, "abc"}},
+		{"lower case name", "summary:Show", icsProperty{"SUMMARY", map[string]string{}, "Show"}},
+		{"parameters", "DTSTART;TZID=Europe/Berlin;VALUE=DATE-TIME:20240101T100000", icsProperty{"DTSTART", map[string]string{"TZID": "Europe/Berlin", "VALUE": "DATE-TIME"}, "20240101T100000"}},
+		{"quoted colon", `ORGANIZER;CN="DJ: Alice":mailto:alice@example.com`, icsProperty{"ORGANIZER", map[string]string{"CN": `"DJ: Alice"`}, "mailto:alice@example.com"}},
+		{"folded parameter", "ORGANIZER;CN=Al\r\n ice:mailto:alice@example.com", icsProperty{"ORGANIZER", map[string]string{"CN": "Alice"}, "mailto:alice@example.com"}},
+	}
+	for _, test := range tests {
+		props, err := readICS(strings.NewReader("BEGIN:VCALENDAR\r\n" + test.lines + "\r\nEND:VCALENDAR\r\n"))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(props) != 3 || !reflect.DeepEqual(props[1], test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, props, test.want)
+		}
+	}
+
+	if _, err := readICS(strings.NewReader("BEGIN:VCARD\r\nEND:VCARD\r\n")); err == nil {
+		t.Error("a vCard was read as calendar")
+	}
+	if _, err := readICS(strings.NewReader("BEGIN:VCALENDAR\r\nno colon\r\n")); err == nil {
+		t.Error("a line without a value was accepted")
+	}
+}
+
+func TestParseICSTime(t *testing.T) {
+	berlin := loadLocation(t, "Europe/Berlin")
+	newYork := loadLocation(t, "America/New_York")
+	tests := []struct {
+		name   string
+		prop   icsProperty
+		want   time.Time
+		allDay bool
+	}{
+		{"floating", icsProperty{value: "20240701T200000"}, time.Date(2024, 7, 1, 20, 0, 0, 0, newYork), false},
+		{"utc", icsProperty{value: "20240701T200000Z"}, time.Date(2024, 7, 1, 20, 0, 0, 0, time.UTC), false},
+		{"tzid", icsProperty{params: map[string]string{"TZID": "Europe/Berlin"}, value: "20240701T200000"}, time.Date(2024, 7, 1, 20, 0, 0, 0, berlin), false},
+		{"quoted tzid", icsProperty{params: map[string]string{"TZID": `"Europe/Berlin"`}, value: "20240101T200000"}, time.Date(2024, 1, 1, 20, 0, 0, 0, berlin), false},
+		{"unknown tzid", icsProperty{params: map[string]string{"TZID": "W. Europe Standard Time"}, value: "20240701T200000"}, time.Date(2024, 7, 1, 20, 0, 0, 0, newYork), false},
+		{"all day", icsProperty{params: map[string]string{"VALUE": "DATE"}, value: "20240701"}, time.Date(2024, 7, 1, 0, 0, 0, 0, newYork), true},
+		{"all day without value type", icsProperty{value: "20240701"}, time.Date(2024, 7, 1, 0, 0, 0, 0, newYork), true},
+		{"all day with tzid", icsProperty{params: map[string]string{"TZID": "Europe/Berlin", "VALUE": "DATE"}, value: "20240701"}, time.Date(2024, 7, 1, 0, 0, 0, 0, berlin), true},
+	}
+	for _, test := range tests {
+		if test.prop.params == nil {
+			test.prop.params = map[string]string{}
+		}
+		got, allDay, err := parseICSTime(test.prop, newYork)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !got.Equal(test.want) || allDay != test.allDay {
+			t.Errorf("%s: got %v, all day %v, want %v, all day %v", test.name, got, allDay, test.want, test.allDay)
+		}
+	}
+
+	for _, value := range []string{"2024-07-01", "20241301", "20240701T25", "tomorrow"} {
+		if _, _, err := parseICSTime(icsProperty{params: map[string]string{}, value: value}, newYork); err == nil {
+			t.Errorf("%q was parsed", value)
+		}
+	}
+}
+
+func TestParseCalendarRecurrence(t *testing.T) {
+	berlin := loadLocation(t, "Europe/Berlin")
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, berlin)
+	}
+	from, to := at(time.January, 1, 0), at(time.December, 31, 0)
+
+	tests := []struct {
+		name  string
+		event string
+		want  []time.Time
+	}{
+		{"single", "DTSTART:20240301T200000\nDURATION:PT1H", []time.Time{at(time.March, 1, 20)}},
+		{
+			"daily count",
+			"DTSTART:20240301T200000\nDURATION:PT1H\nRRULE:FREQ=DAILY;COUNT=3",
+			[]time.Time{at(time.March, 1, 20), at(time.March, 2, 20), at(time.March, 3, 20)},
+		},
+		{
+			"daily interval until",
+			"DTSTART:20240301T200000\nDURATION:PT1H\nRRULE:FREQ=DAILY;INTERVAL=2;UNTIL=20240306T200000",
+			[]time.Time{at(time.March, 1, 20), at(time.March, 3, 20), at(time.March, 5, 20)},
+		},
+		{
+			"weekly byday across daylight saving time",
+			"DTSTART:20240325T200000\nDURATION:PT1H\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			[]time.Time{at(time.March, 25, 20), at(time.March, 27, 20), at(time.April, 1, 20), at(time.April, 3, 20)},
+		},
+		{
+			"weekly byday before the start",
+			"DTSTART:20240327T200000\nDURATION:PT1H\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3",
+			[]time.Time{at(time.March, 27, 20), at(time.April, 1, 20), at(time.April, 3, 20)},
+		},
+		{
+			"monthly skips short months",
+			"DTSTART:20240131T200000\nDURATION:PT1H\nRRULE:FREQ=MONTHLY;COUNT=3",
+			[]time.Time{at(time.January, 31, 20), at(time.March, 31, 20), at(time.May, 31, 20)},
+		},
+		{
+			"exdate",
+			"DTSTART:20240301T200000\nDURATION:PT1H\nRRULE:FREQ=DAILY;COUNT=3\nEXDATE:20240302T200000",
+			[]time.Time{at(time.March, 1, 20), at(time.March, 3, 20)},
+		},
+		{
+			"cancelled",
+			"DTSTART:20240301T200000\nDURATION:PT1H\nSTATUS:CANCELLED",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		ics := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:show\nSUMMARY:Show\n" + test.event + "\nEND:VEVENT\nEND:VCALENDAR\n"
+		shows, err := ParseCalendar(strings.NewReader(ics), from, to, berlin)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var got []time.Time
+		for _, show := range shows {
+			got = append(got, show.Start)
+			if show.End.Sub(show.Start) != time.Hour {
+				t.Errorf("%s: show from %v to %v, want an hour", test.name, show.Start, show.End)
+			}
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(test.want[i]) {
+				t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+
+	ics := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:show\nDTSTART:20240301T200000\nRRULE:FREQ=YEARLY\nEND:VEVENT\nEND:VCALENDAR\n"
+	if _, err := ParseCalendar(strings.NewReader(ics), from, to, berlin); err == nil {
+		t.Error("an unsupported rule was accepted")
+	}
+}
+
+func TestFollowShow(t *testing.T) {
+	dj := newTestDj(t, nil)
+	if err := dj.AddQueue("shows", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := dj.AddEntryTo("shows", QueueEntry{Media: Media{Title: "Jingle", URL: "https://example.com/jingle"}}); err != nil {
+		t.Fatal(err)
+	}
+	playlist := filepath.Join(t.TempDir(), "show.m3u")
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	shows := []CalendarShow{{UID: "show", Title: "Show", Host: "alice", Playlist: playlist, Start: start, End: start.Add(time.Hour)}}
+	titles := func() []string {
+		entries, _ := dj.QueueNamed("shows")
+		var titles []string
+		for _, entry := range entries {
+			titles = append(titles, entry.Media.Title)
+		}
+		return titles
+	}
+	ctx := context.Background()
+
+	if current := dj.followShow(ctx, nil, shows, start.Add(-time.Minute), "shows"); current != nil {
+		t.Fatalf("a show started before its start: %+v", current)
+	}
+
+	// the playlist does not exist yet
+	current := dj.followShow(ctx, nil, shows, start, "shows")
+	if current == nil || current.loaded {
+		t.Fatalf("show with a missing playlist = %+v, want it running without entries", current)
+	}
+	if got := titles(); len(got) != 1 {
+		t.Fatalf("queue = %v, want only the jingle", got)
+	}
+
+	m3u := "#EXTM3U\n#EXTINF:180,First\nhttps://example.com/1\n#EXTINF:180,Second\nhttps://example.com/2\n"
+	if err := os.WriteFile(playlist, []byte(m3u), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	current = dj.followShow(ctx, current, shows, start.Add(time.Minute), "shows")
+	if current == nil || !current.loaded {
+		t.Fatalf("show after the playlist appeared = %+v, want it loaded", current)
+	}
+	if got := strings.Join(titles(), ","); got != "Jingle,First,Second" {
+		t.Fatalf("queue = %s, want the jingle and the playlist", got)
+	}
+	entries, _ := dj.QueueNamed("shows")
+	if entries[1].Owner != "alice" {
+		t.Errorf("show entry is owned by %q, want the host", entries[1].Owner)
+	}
+
+	// loaded shows are not queued again
+	current = dj.followShow(ctx, current, shows, start.Add(2*time.Minute), "shows")
+	if got := len(titles()); got != 3 {
+		t.Errorf("queue has %d entries after another check, want 3", got)
+	}
+
+	if current = dj.followShow(ctx, current, shows, start.Add(time.Hour), "shows"); current != nil {
+		t.Errorf("show is still running after its end: %+v", current)
+	}
+	if got := strings.Join(titles(), ","); got != "Jingle" {
+		t.Errorf("queue after the show = %s, want only the jingle", got)
+	}
+}
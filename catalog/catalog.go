@@ -0,0 +1,205 @@
+// Package catalog holds the texts of the bundled chat modules, so channels that are not in english
+// can translate or customize every built-in string.
+//
+// Texts are fmt format strings looked up by Key. A Catalog keeps overrides per locale and falls
+// back to the language of a locale, e.g. "de" for "de-AT", then to the overrides without locale
+// and finally to Defaults. The arguments of each key are listed with it, translations can use
+// explicit argument indexes like %[2]s if the words are in a different order.
+package catalog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/SoMuchForSubtlety/opendj"
+)
+
+// A Key identifies a text.
+type Key string
+
+// The texts of the announcers in the announce package.
+const (
+	// NowPlaying gets the title and owner.
+	NowPlaying Key = "nowPlaying"
+	// Dedication gets the dedication, it is appended to NowPlaying.
+	Dedication Key = "dedication"
+	// QueueEmpty has no arguments.
+	QueueEmpty Key = "queueEmpty"
+	// QueueSummary gets the amount of entries and the title of the next one.
+	QueueSummary Key = "queueSummary"
+	// PlaybackError gets the error.
+	PlaybackError Key = "playbackError"
+)
+
+// The replies of the commands package.
+const (
+	// Queued gets the title and the one based position.
+	Queued Key = "queued"
+	// QueuedMany gets the amount of queued entries and the one based position of the first,
+	// it is used when an album or playlist was queued.
+	QueuedMany Key = "queuedMany"
+	// Pending gets the title, it is used if the request waits for approval.
+	Pending Key = "pending"
+	// QueueEntry gets the one based position, title and owner, it is used for every line of the queue.
+	QueueEntry Key = "queueEntry"
+	// Skipped gets the title.
+	Skipped Key = "skipped"
+	// Removed gets the title.
+	Removed Key = "removed"
+	// NowPlayingReply gets the title, owner and progress.
+	NowPlayingReply Key = "nowPlayingReply"
+	// InvalidIndex gets the one based index.
+	InvalidIndex Key = "invalidIndex"
+	// MissingQuery has no arguments.
+	MissingQuery Key = "missingQuery"
+	// InvalidPosition gets the argument that is no position.
+	InvalidPosition Key = "invalidPosition"
+)
+
+// The texts of the discord package.
+const (
+	// RequestedBy gets the owner, it is the description of now playing embeds.
+	RequestedBy Key = "requestedBy"
+	// Busy has no arguments, it is the reply while too many commands are running.
+	Busy Key = "busy"
+	// The descriptions of the slash commands and their options have no arguments.
+	PlayDescription       Key = "playDescription"
+	QueryDescription      Key = "queryDescription"
+	QueueDescription      Key = "queueDescription"
+	SkipDescription       Key = "skipDescription"
+	RemoveDescription     Key = "removeDescription"
+	PositionDescription   Key = "positionDescription"
+	NowPlayingDescription Key = "nowPlayingDescription"
+)
+
+// The texts of the obs package.
+const (
+	// OverlayNowPlaying gets the title and owner.
+	OverlayNowPlaying Key = "overlayNowPlaying"
+	// ShowWithHost gets the name and host of a show.
+	ShowWithHost Key = "showWithHost"
+)
+
+// ReasonKey returns the key of the text explaining a rejection to the requester, it has no arguments.
+func ReasonKey(reason opendj.Reason) Key {
+	return Key("reason." + string(reason))
+}
+
+// Messages map keys to their texts.
+type Messages map[Key]string
+
+// Defaults are the english texts.
+var Defaults = Messages{
+	NowPlaying:    "now playing: %s (requested by %s)",
+	Dedication:    " - %s",
+	QueueEmpty:    "the queue is empty",
+	QueueSummary:  "%d songs in the queue, up next: %s",
+	PlaybackError: "playback error: %s",
+
+	Queued:          "queued %s at position %d",
+	QueuedMany:      "queued %d tracks starting at position %d",
+	Pending:         "%s is waiting for approval",
+	QueueEntry:      "%d. %s (%s)",
+	Skipped:         "skipped %s",
+	Removed:         "removed %s",
+	NowPlayingReply: "now playing %s (%s) [%s]",
+	InvalidIndex:    "there is no entry %d",
+	MissingQuery:    "missing URL or search query",
+	InvalidPosition: "%q is not a valid position",
+
+	RequestedBy:           "requested by %s",
+	Busy:                  "too many commands at once, try again in a moment",
+	PlayDescription:       "Queue a song by URL or search query",
+	QueryDescription:      "URL or search query",
+	QueueDescription:      "Show the queue",
+	SkipDescription:       "Skip the current song",
+	RemoveDescription:     "Remove an entry from the queue",
+	PositionDescription:   "Position in the queue",
+	NowPlayingDescription: "Show the current song",
+
+	OverlayNowPlaying: "%s - requested by %s",
+	ShowWithHost:      "%s with %s",
+
+	ReasonKey(opendj.ReasonQueueFull):      "the queue is full",
+	ReasonKey(opendj.ReasonUserLimit):      "you have too much in the queue",
+	ReasonKey(opendj.ReasonBlocked):        "that song is not allowed",
+	ReasonKey(opendj.ReasonDuplicate):      "that song is already in the queue",
+	ReasonKey(opendj.ReasonNotAllowed):     "you are not allowed to do that",
+	ReasonKey(opendj.ReasonUnknownEntry):   "that entry is not in the queue",
+	ReasonKey(opendj.ReasonUnknownQueue):   "there is no such queue",
+	ReasonKey(opendj.ReasonOutOfRange):     "there is no such position",
+	ReasonKey(opendj.ReasonNothingPlaying): "nothing is playing right now",
+	ReasonKey(opendj.ReasonNotSeekable):    "the current track can't be seeked right now",
+	ReasonKey(opendj.ReasonInvalidClip):    "that part of the song doesn't exist",
+	ReasonKey(opendj.ReasonNotAdjacent):    "those entries are not next to each other",
+	ReasonKey(opendj.ReasonUnavailable):    "that song is unavailable",
+	ReasonKey(opendj.ReasonRejected):       "your request was rejected",
+}
+
+// A Catalog holds the texts per locale.
+//
+// The zero value and nil use Defaults, a Catalog is safe for concurrent use.
+type Catalog struct {
+	mu      sync.RWMutex
+	locales map[string]Messages
+}
+
+// Set overrides texts for the locale, e.g. "de" or "pt-BR", keys that are not set keep their text.
+// The empty locale overrides the texts of every locale that does not set them itself.
+func (c *Catalog) Set(locale string, messages Messages) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.locales == nil {
+		c.locales = make(map[string]Messages)
+	}
+	locale = normalize(locale)
+	merged := make(Messages, len(c.locales[locale])+len(messages))
+	for key, text := range c.locales[locale] {
+		merged[key] = text
+	}
+	for key, text := range messages {
+		merged[key] = text
+	}
+	c.locales[locale] = merged
+}
+
+// Lookup returns the format string of key for the locale.
+func (c *Catalog) Lookup(locale string, key Key) string {
+	if c != nil {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		locale = normalize(locale)
+		candidates := []string{locale}
+		if language, _, found := strings.Cut(locale, "-"); found {
+			candidates = append(candidates, language)
+		}
+		for _, candidate := range append(candidates, "") {
+			if text, ok := c.locales[candidate][key]; ok {
+				return text
+			}
+		}
+	}
+	return Defaults[key]
+}
+
+// Text returns the text of key for the locale formatted with args.
+func (c *Catalog) Text(locale string, key Key, args ...interface{}) string {
+	return fmt.Sprintf(c.Lookup(locale, key), args...)
+}
+
+// Reason returns the text explaining the reason of a rejection to the requester, see opendj.ReasonOf.
+// Reasons without a text, e.g. custom ones of an admission hook, use the text of opendj.ReasonRejected.
+func (c *Catalog) Reason(locale string, reason opendj.Reason) string {
+	if c.Lookup(locale, ReasonKey(reason)) != "" {
+		return c.Text(locale, ReasonKey(reason))
+	}
+	return c.Text(locale, ReasonKey(opendj.ReasonRejected))
+}
+
+// normalize returns the locale in lower case with dashes, so "pt_BR" and "pt-br" are the same.
+func normalize(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
@@ -0,0 +1,54 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/SoMuchForSubtlety/opendj"
+)
+
+func TestLookup(t *testing.T) {
+	c := &Catalog{}
+	c.Set("", Messages{Skipped: "skipped: %s"})
+	c.Set("de", Messages{Skipped: "%s übersprungen", Removed: "%s entfernt"})
+	c.Set("de-AT", Messages{Removed: "%s gelöscht"})
+	c.Set("de", Messages{QueueEmpty: "die Warteschlange ist leer"})
+
+	tests := []struct {
+		locale string
+		key    Key
+		want   string
+	}{
+		{"de", Skipped, "%s übersprungen"},
+		{"de", QueueEmpty, "die Warteschlange ist leer"},
+		{"DE_at", Removed, "%s gelöscht"},
+		{"de-AT", Skipped, "%s übersprungen"},
+		{"fr", Skipped, "skipped: %s"},
+		{"fr", Removed, Defaults[Removed]},
+		{"", Skipped, "skipped: %s"},
+	}
+	for _, tt := range tests {
+		if got := c.Lookup(tt.locale, tt.key); got != tt.want {
+			t.Errorf("Lookup(%q, %q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+
+	var empty *Catalog
+	if got, want := empty.Text("de", Queued, "Song", 2), "queued Song at position 2"; got != want {
+		t.Errorf("nil catalog returned %q, want %q", got, want)
+	}
+}
+
+func TestReason(t *testing.T) {
+	c := &Catalog{}
+	c.Set("de", Messages{ReasonKey(opendj.ReasonQueueFull): "die Warteschlange ist voll"})
+
+	if got, want := c.Reason("de", opendj.ReasonQueueFull), "die Warteschlange ist voll"; got != want {
+		t.Errorf("translated reason is %q, want %q", got, want)
+	}
+	if got, want := c.Reason("de", opendj.ReasonDuplicate), Defaults[ReasonKey(opendj.ReasonDuplicate)]; got != want {
+		t.Errorf("untranslated reason is %q, want %q", got, want)
+	}
+	if got, want := c.Reason("", opendj.Reason("custom")), Defaults[ReasonKey(opendj.ReasonRejected)]; got != want {
+		t.Errorf("unknown reason is %q, want %q", got, want)
+	}
+}
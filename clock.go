@@ -0,0 +1,80 @@
+package opendj
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/SoMuchForSubtlety/opendj/rtmp"
+)
+
+// A Clock tells the time, it is used for scheduling decisions like wait time estimates,
+// quotas and expiry, for the restart backoff of pipeline components and to pace native
+// RTMP outputs. ffmpeg always times the media with the real clock.
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel once d elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// An IDGenerator returns the IDs of new entries, they have to be unique and never zero.
+type IDGenerator interface {
+	NextID() uint64
+}
+
+// SequentialIDs counts up from 1, the zero value is ready to use. It is safe for concurrent use.
+type SequentialIDs struct {
+	last atomic.Uint64
+}
+
+// NextID returns the next number.
+func (s *SequentialIDs) NextID() uint64 {
+	return s.last.Add(1)
+}
+
+// processIDs are used by default, so IDs are unique within the process.
+var processIDs = &SequentialIDs{}
+
+// An Option configures a Dj when it is created, see NewDj.
+type Option func(*Dj)
+
+// WithClock makes the Dj use c instead of the system clock, e.g. a fake clock in tests.
+func WithClock(c Clock) Option {
+	return func(dj *Dj) { dj.timeSource = c }
+}
+
+// WithIDGenerator makes the Dj use g for entry IDs, e.g. for deterministic IDs in tests.
+// By default IDs are unique within the process.
+func WithIDGenerator(g IDGenerator) Option {
+	return func(dj *Dj) { dj.ids = g }
+}
+
+// Now returns the current time of the clock set with WithClock, e.g. to compare it with
+// the time of events.
+func (dj *Dj) Now() time.Time {
+	return dj.now()
+}
+
+// now returns the current time of the configured clock.
+func (dj *Dj) now() time.Time {
+	if dj.timeSource == nil {
+		return time.Now()
+	}
+	return dj.timeSource.Now()
+}
+
+// withClock attaches the clock of the Dj to ctx, so native RTMP outputs are paced by it.
+func (dj *Dj) withClock(ctx context.Context) context.Context {
+	if dj.timeSource == nil {
+		return ctx
+	}
+	return rtmp.WithClock(ctx, dj.timeSource)
+}
+
+// after is like time.After with the configured clock.
+func (dj *Dj) after(d time.Duration) <-chan time.Time {
+	if dj.timeSource == nil {
+		return time.After(d)
+	}
+	return dj.timeSource.After(d)
+}
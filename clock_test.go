@@ -0,0 +1,121 @@
+package opendj
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := fakeWaiter{c.now.Add(d), make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- c.now
+	} else {
+		c.waiters = append(c.waiters, w)
+	}
+	return w.c
+}
+
+// advance moves the clock forward and fires all timers that are due.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+		} else {
+			w.c <- c.now
+		}
+	}
+	c.waiters = waiting
+}
+
+// waitForTimer waits until something waits for the clock and returns how long until it fires.
+func (c *fakeClock) waitForTimer(t *testing.T) time.Duration {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		if len(c.waiters) > 0 {
+			d := c.waiters[0].at.Sub(c.now)
+			c.mu.Unlock()
+			return d
+		}
+		c.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("nothing waits for the clock")
+	return 0
+}
+
+func TestFakeClockAndIDs(t *testing.T) {
+	start := time.Date(2024, time.May, 1, 20, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	dj := newTestDj(t, nil, WithClock(clock))
+	events, cancel := dj.Subscribe(16)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		if err := dj.AddEntry(QueueEntry{Media: Media{Title: "Song"}}); err != nil {
+			t.Fatal(err)
+		}
+		clock.advance(time.Minute)
+	}
+	for i, entry := range dj.Queue() {
+		if want := uint64(i + 1); entry.ID != want {
+			t.Errorf("entry %d has ID %d, want %d", i, entry.ID, want)
+		}
+		if want := start.Add(time.Duration(i) * time.Minute); !entry.RequestedAt.Equal(want) {
+			t.Errorf("entry %d was requested at %s, want %s", i, entry.RequestedAt, want)
+		}
+	}
+	if event := <-events; !event.Time.Equal(start) {
+		t.Errorf("first event at %s, want %s", event.Time, start)
+	}
+	if !dj.Now().Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("Now() = %s, want the fake time", dj.Now())
+	}
+
+	if err := dj.SetArchiveDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	args := dj.archiveArgs(dj.Queue()[0], dj.now())
+	if name := filepath.Base(args[len(args)-1]); !strings.HasPrefix(name, "20240501-200300-001-") {
+		t.Errorf("archive file %s is not named after the fake time", name)
+	}
+
+	select {
+	case <-dj.after(time.Hour):
+		t.Fatal("timer fired before the clock was advanced")
+	default:
+	}
+	timer := dj.after(time.Hour)
+	clock.advance(time.Hour)
+	if at := <-timer; !at.Equal(start.Add(time.Hour + 3*time.Minute)) {
+		t.Errorf("timer fired at %s", at)
+	}
+}
@@ -0,0 +1,195 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ClockElementKind is what a ClockElement plays.
+type ClockElementKind int
+
+const (
+	// ClockRequests plays Count entries from the queues.
+	ClockRequests ClockElementKind = iota
+	// ClockLibrary plays Count tracks of the fallback library matching Query and Category.
+	ClockLibrary
+	// ClockMedia plays Media, e.g. a news jingle or a sweeper.
+	ClockMedia
+)
+
+// A ClockElement is a slot of an HourClock.
+type ClockElement struct {
+	Kind ClockElementKind
+	// Count is how many entries or tracks are played, it defaults to one.
+	Count int
+	// Query and Category select the library tracks, see Library.Search and LibraryTrack.Category.
+	Query    string
+	Category string
+	// Media is played by ClockMedia elements.
+	Media Media
+	// Fixed elements start At into the hour at the earliest, e.g. 0 for the top of the hour,
+	// and are played once per hour, see HourClock.
+	Fixed bool
+	At    time.Duration
+}
+
+// count returns how often the element is played.
+func (e ClockElement) count() int {
+	if e.Kind == ClockMedia || e.Count <= 0 {
+		return 1
+	}
+	return e.Count
+}
+
+// An HourClock is a template for every hour, the clock wheel of radio automation,
+// e.g. a news jingle at :00, three requests, a library track and a sweeper.
+//
+// The clock starts over at the beginning of every hour, see SetLocation, and plays its elements
+// in order. A fixed element waits until its time of the hour, requests are played in the meantime.
+// Once all elements were played, the elements that are not fixed repeat until the next hour.
+// Request elements are skipped while nothing is queued, library elements if there is no
+// fallback library or no matching track, see SetFallbackLibrary.
+type HourClock struct {
+	Elements []ClockElement
+}
+
+// clockWheel is the state of the active HourClock.
+type clockWheel struct {
+	sync.Mutex
+	clock *HourClock
+	hour  time.Time
+	// position is the index of the current element, remaining how often it is still played
+	position  int
+	remaining int
+	// repeating is set once the elements of the hour were played
+	repeating bool
+}
+
+// SetHourClock programs every hour with the clock, nil plays the queue as usual.
+//
+// The library and media elements are played like fallback tracks, without song events.
+// Breaks still take precedence over the clock.
+func (dj *Dj) SetHourClock(c *HourClock) {
+	dj.wheel.Lock()
+	defer dj.wheel.Unlock()
+
+	if c != nil {
+		copied := HourClock{Elements: append([]ClockElement(nil), c.Elements...)}
+		c = &copied
+	}
+	dj.wheel.clock = c
+	dj.wheel.hour = time.Time{}
+}
+
+// next returns the element to play now, ok is false if there is no clock or the clock only waits.
+// requests tells whether anything is queued.
+func (w *clockWheel) next(now time.Time, requests bool) (ClockElement, bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.clock == nil || len(w.clock.Elements) == 0 {
+		return ClockElement{}, false
+	}
+	hour := hourStart(now)
+	if !hour.Equal(w.hour) {
+		w.hour, w.position, w.repeating = hour, 0, false
+		w.remaining = w.clock.Elements[0].count()
+	}
+
+	elements := w.clock.Elements
+	for skipped := 0; skipped <= len(elements); skipped++ {
+		element := elements[w.position]
+		switch {
+		case w.repeating && element.Fixed:
+		case element.Fixed && now.Sub(hour) < element.At:
+			// too early, requests are played until then
+			return ClockElement{Kind: ClockRequests}, requests
+		case element.Kind == ClockRequests && !requests:
+		default:
+			if w.remaining--; w.remaining <= 0 {
+				w.advance()
+			}
+			return element, true
+		}
+		w.advance()
+	}
+	return ClockElement{}, false
+}
+
+// advance moves to the next element, the lock has to be held.
+func (w *clockWheel) advance() {
+	if w.position++; w.position >= len(w.clock.Elements) {
+		w.position, w.repeating = 0, true
+	}
+	w.remaining = w.clock.Elements[w.position].count()
+}
+
+// hasRequests reports whether any queue has entries.
+func (dj *Dj) hasRequests() bool {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+	for _, q := range dj.drainOrder() {
+		if q.len() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// playClockElement plays a library or media element of the hour clock.
+//
+// ok is false if the element had nothing to play.
+func (dj *Dj) playClockElement(ctx context.Context, stream io.Writer, element ClockElement) (ok bool, err error) {
+	var entry QueueEntry
+	switch element.Kind {
+	case ClockMedia:
+		entry = QueueEntry{Media: element.Media}
+	case ClockLibrary:
+		if entry, ok = dj.fallback.fromLibrary(element.Query, element.Category, dj.now()); !ok {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+
+	dj.setCurrentEntry(entry)
+	if err = dj.playEntry(ctx, stream, entry); err != nil && !errors.Is(err, ErrorSkipped) {
+		return true, err
+	}
+	return true, nil
+}
+
+// fromLibrary returns a track of the fallback library matching query and category and records it as played.
+// The separations of the rotation are kept if one is set.
+func (f *fallback) fromLibrary(query, category string, now time.Time) (QueueEntry, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.library == nil {
+		return QueueEntry{}, false
+	}
+	var track LibraryTrack
+	var ok bool
+	if category != "" {
+		r := Rotation{Pattern: []string{category}}
+		if f.rotation != nil {
+			r.ArtistSeparation, r.TitleSeparation = f.rotation.ArtistSeparation, f.rotation.TitleSeparation
+		}
+		track, _, ok = f.library.pickRotation(&r, 0, query, now)
+	} else {
+		track, ok = f.library.pick(query, f.recent, now)
+	}
+	if !ok {
+		return QueueEntry{}, false
+	}
+
+	entry := track.entry()
+	f.recent = append(f.recent, entry)
+	if len(f.recent) > f.separation {
+		f.recent = f.recent[len(f.recent)-f.separation:]
+	}
+	return entry, true
+}
@@ -0,0 +1,260 @@
+// Package commands parses chat commands like "!play <url>" and executes them against a Dj,
+// so chat bots don't have to reimplement the parsing.
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoMuchForSubtlety/opendj"
+	"github.com/SoMuchForSubtlety/opendj/catalog"
+)
+
+var (
+	// ErrorNotCommand is returned by Parse if the line does not start with the prefix.
+	ErrorNotCommand = errors.New("not a command")
+	// ErrorUnknownCommand is returned by Parse if the command name is not known.
+	ErrorUnknownCommand = errors.New("unknown command")
+	// ErrorNotAllowed is returned by Execute if the caller may not run the command.
+	ErrorNotAllowed = opendj.ErrorNotAllowed
+)
+
+// Action is what a command does.
+type Action int
+
+// The supported actions.
+const (
+	ActionPlay Action = iota
+	ActionQueue
+	ActionSkip
+	ActionRemove
+	ActionNowPlaying
+)
+
+// DefaultNames maps the default command names to their actions.
+var DefaultNames = map[string]Action{
+	"play":       ActionPlay,
+	"queue":      ActionQueue,
+	"skip":       ActionSkip,
+	"remove":     ActionRemove,
+	"nowplaying": ActionNowPlaying,
+	"np":         ActionNowPlaying,
+}
+
+// A Command is a parsed chat command.
+type Command struct {
+	Action Action
+	// Query is the URL or search query of ActionPlay.
+	Query string
+	// Index is the zero based queue index of ActionRemove.
+	Index int
+}
+
+// A Caller is the user that sent a command.
+type Caller struct {
+	Name string
+	// ID is the platform specific ID of the user, it becomes the OwnerID of their requests.
+	ID string
+	// Moderators may skip any song and remove any entry.
+	Moderator bool
+	Source    opendj.Source
+}
+
+// A Parser parses and executes commands.
+//
+// The zero value uses "!" as prefix, DefaultNames and english replies.
+type Parser struct {
+	// Prefix is the string every command starts with.
+	Prefix string
+	// Names maps command names to actions, names are case-insensitive.
+	Names map[string]Action
+	// Catalog holds the replies, nil uses catalog.Defaults.
+	Catalog *catalog.Catalog
+	// Locale selects the replies in the catalog, e.g. "de".
+	Locale string
+	// QueueLength is the maximum amount of entries listed by the queue command, it defaults to 5.
+	QueueLength int
+}
+
+// Parse parses a chat line into a Command.
+//
+// returns ErrorNotCommand if line is not a command at all and ErrorUnknownCommand
+// if the command name is not known.
+func (p *Parser) Parse(line string) (Command, error) {
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = "!"
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, prefix) {
+		return Command{}, ErrorNotCommand
+	}
+
+	name, args, _ := strings.Cut(strings.TrimPrefix(line, prefix), " ")
+	args = strings.TrimSpace(args)
+	names := p.Names
+	if names == nil {
+		names = DefaultNames
+	}
+	action, ok := names[strings.ToLower(name)]
+	if !ok {
+		return Command{}, fmt.Errorf("%q: %w", name, ErrorUnknownCommand)
+	}
+
+	cmd := Command{Action: action}
+	switch action {
+	case ActionPlay:
+		if args == "" {
+			return Command{}, &argumentError{key: catalog.MissingQuery}
+		}
+		cmd.Query = args
+	case ActionRemove:
+		index, err := strconv.Atoi(args)
+		if err != nil || index < 1 {
+			return Command{}, &argumentError{key: catalog.InvalidPosition, args: []interface{}{args}}
+		}
+		cmd.Index = index - 1
+	}
+	return cmd, nil
+}
+
+// Execute runs the command for the caller and returns the reply.
+// Changes are recorded in the audit log of the Dj, see opendj.SetAuditLog.
+//
+// The reply is also returned for expected failures like an invalid index,
+// in which case err is set as well. opendj.ReasonOf returns the reason code of err.
+func (p *Parser) Execute(ctx context.Context, dj *opendj.Dj, caller Caller, cmd Command) (string, error) {
+	text := func(key catalog.Key, args ...interface{}) string {
+		return p.Catalog.Text(p.Locale, key, args...)
+	}
+	actions := dj.As(opendj.Actor{Name: caller.Name, ID: caller.ID, Source: caller.Source, Moderator: caller.Moderator})
+
+	switch cmd.Action {
+	case ActionPlay:
+		query := cmd.Query
+		var media []opendj.Media
+		if isURL(query) {
+			// albums and playlists are queued as one entry per track
+			expanded, err := dj.Expand(ctx, query)
+			if err != nil {
+				return "", err
+			}
+			media = expanded
+		} else {
+			found, err := dj.Resolve(ctx, "ytsearch1:"+query)
+			if err != nil {
+				return "", err
+			}
+			media = []opendj.Media{found}
+		}
+
+		queued := 0
+		var first opendj.Result
+		var err error
+		for _, m := range media {
+			entry := opendj.QueueEntry{Media: m, Owner: caller.Name, Source: caller.Source}
+			result := actions.AddEntry(entry)
+			if !result.OK() {
+				err = result.Err
+				break
+			}
+			if queued == 0 {
+				first = result
+			}
+			queued++
+		}
+		if queued == 0 {
+			return p.Catalog.Reason(p.Locale, opendj.ReasonOf(err)), err
+		}
+		if first.Status == opendj.StatusPending {
+			return text(catalog.Pending, media[0].Title), err
+		}
+		// the first entry may have started playing already
+		index, _ := dj.IndexByID(first.EntryID)
+		position := index + 1
+		if queued == 1 || p.Catalog.Lookup(p.Locale, catalog.QueuedMany) == "" {
+			return text(catalog.Queued, media[0].Title, position), err
+		}
+		return text(catalog.QueuedMany, queued, position), err
+
+	case ActionQueue:
+		entries, _ := dj.QueueSnapshot()
+		if len(entries) == 0 {
+			return text(catalog.QueueEmpty), nil
+		}
+		length := p.QueueLength
+		if length <= 0 {
+			length = 5
+		}
+		var lines []string
+		for i, entry := range entries {
+			if i >= length {
+				break
+			}
+			lines = append(lines, text(catalog.QueueEntry, i+1, entry.Media.Title, entry.PublicOwner()))
+		}
+		return strings.Join(lines, "\n"), nil
+
+	case ActionSkip:
+		np, _ := dj.CurrentlyPlaying()
+		if result := actions.Skip(); !result.OK() {
+			return p.Catalog.Reason(p.Locale, result.Reason), result.Err
+		}
+		return text(catalog.Skipped, np.Entry.Media.Title), nil
+
+	case ActionRemove:
+		entry, err := dj.EntryAtIndex(cmd.Index)
+		if err != nil {
+			return text(catalog.InvalidIndex, cmd.Index+1), err
+		}
+		// by ID, the index may point to a different entry once a song was popped
+		if result := actions.RemoveByID(entry.ID); !result.OK() {
+			return p.Catalog.Reason(p.Locale, result.Reason), result.Err
+		}
+		return text(catalog.Removed, entry.Media.Title), nil
+
+	case ActionNowPlaying:
+		np, err := dj.CurrentlyPlaying()
+		if err != nil {
+			return p.Catalog.Reason(p.Locale, opendj.ReasonNothingPlaying), nil
+		}
+		return text(catalog.NowPlayingReply, np.Entry.Media.Title, np.Entry.PublicOwner(), np.Progress.Round(time.Second)), nil
+	}
+
+	return "", fmt.Errorf("action %d: %w", cmd.Action, ErrorUnknownCommand)
+}
+
+// Handle parses and executes a chat line.
+//
+// returns ErrorNotCommand for lines that are not commands, they should be ignored.
+// For invalid arguments the reply explaining them is returned with the error.
+func (p *Parser) Handle(ctx context.Context, dj *opendj.Dj, caller Caller, line string) (string, error) {
+	cmd, err := p.Parse(line)
+	var argErr *argumentError
+	if errors.As(err, &argErr) {
+		return p.Catalog.Text(p.Locale, argErr.key, argErr.args...), err
+	} else if err != nil {
+		return "", err
+	}
+	return p.Execute(ctx, dj, caller, cmd)
+}
+
+// argumentError is returned by Parse for missing or invalid arguments.
+type argumentError struct {
+	key  catalog.Key
+	args []interface{}
+}
+
+func (e *argumentError) Error() string {
+	return fmt.Sprintf(catalog.Defaults[e.key], e.args...)
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SoMuchForSubtlety/opendj"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line    string
+		parser  Parser
+		want    Command
+		wantErr error
+	}{
+		{line: "!play https://youtu.be/abc", want: Command{Action: ActionPlay, Query: "https://youtu.be/abc"}},
+		{line: "  !PLAY  some song  ", want: Command{Action: ActionPlay, Query: "some song"}},
+		{line: "!queue", want: Command{Action: ActionQueue}},
+		{line: "!np", want: Command{Action: ActionNowPlaying}},
+		{line: "!remove 3", want: Command{Action: ActionRemove, Index: 2}},
+		{line: "?skip", parser: Parser{Prefix: "?"}, want: Command{Action: ActionSkip}},
+		{line: "!next", parser: Parser{Names: map[string]Action{"next": ActionSkip}}, want: Command{Action: ActionSkip}},
+		{line: "hello !play", wantErr: ErrorNotCommand},
+		{line: "!skip", parser: Parser{Prefix: "?"}, wantErr: ErrorNotCommand},
+		{line: "!dance", wantErr: ErrorUnknownCommand},
+		{line: "!play"},
+		{line: "!remove"},
+		{line: "!remove 0"},
+		{line: "!remove first"},
+	}
+	for _, tt := range tests {
+		cmd, err := tt.parser.Parse(tt.line)
+		if tt.want == (Command{}) {
+			if err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", tt.line, cmd)
+			} else if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse(%q) returned %v, want %v", tt.line, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || cmd != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.line, cmd, err, tt.want)
+		}
+	}
+}
+
+// newTestDj returns a Dj with yt-dlp and ffmpeg replaced by scripts, so tests don't need them installed.
+func newTestDj(t *testing.T, queue []opendj.QueueEntry) *opendj.Dj {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"yt-dlp", "ffmpeg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\nexec cat\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return opendj.NewDj(queue)
+}
+
+func TestExecute(t *testing.T) {
+	dj := newTestDj(t, []opendj.QueueEntry{
+		{Media: opendj.Media{Title: "First"}, Owner: "alice"},
+		{Media: opendj.Media{Title: "Second"}, Owner: "bob"},
+	})
+	p := &Parser{}
+	ctx := context.Background()
+	alice := Caller{Name: "alice"}
+
+	reply, err := p.Handle(ctx, dj, alice, "!queue")
+	if want := "1. First (alice)\n2. Second (bob)"; err != nil || reply != want {
+		t.Errorf("queue replied %q, %v, want %q", reply, err, want)
+	}
+
+	reply, err = p.Handle(ctx, dj, alice, "!remove 2")
+	if !errors.Is(err, ErrorNotAllowed) {
+		t.Errorf("removing the entry of someone else replied %q, %v, want %v", reply, err, ErrorNotAllowed)
+	}
+	reply, err = p.Handle(ctx, dj, alice, "!remove 5")
+	if err == nil {
+		t.Errorf("removing a missing entry replied %q without an error", reply)
+	}
+	reply, err = p.Handle(ctx, dj, Caller{Name: "mod", Moderator: true}, "!remove 2")
+	if want := "removed Second"; err != nil || reply != want {
+		t.Errorf("moderator remove replied %q, %v, want %q", reply, err, want)
+	}
+
+	reply, err = p.Handle(ctx, dj, alice, "!np")
+	if want := "nothing is playing right now"; err != nil || reply != want {
+		t.Errorf("now playing replied %q, %v, want %q", reply, err, want)
+	}
+	if _, err = p.Handle(ctx, dj, alice, "just chatting"); !errors.Is(err, ErrorNotCommand) {
+		t.Errorf("a chat line returned %v, want %v", err, ErrorNotCommand)
+	}
+}
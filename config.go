@@ -0,0 +1,239 @@
+package opendj
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the settings of a Dj that can be changed while it is playing.
+//
+// The individual setters like SetQueueLimit change a single field of it.
+type Config struct {
+	// QueueLimit is the maximum amount of entries in the request queue, <= 0 means unlimited.
+	QueueLimit int
+	// UserQuota is the maximum amount of songs per user per UserQuotaWindow, <= 0 disables the quota.
+	UserQuota       int
+	UserQuotaWindow time.Duration
+	// UserDurationCap is the maximum total duration of the entries of a user in the queue, <= 0 is unlimited.
+	UserDurationCap time.Duration
+	// Filter is the filter every new entry is checked against.
+	Filter ContentFilter
+	// AdmissionHook is called for every new entry after the filter, see SetAdmissionHook.
+	AdmissionHook func(QueueEntry) error
+	// Scheduler picks the next entry, nil plays entries in queue order.
+	Scheduler Scheduler
+	// MaxWait and OwnerLeft decide when waiting entries expire, see SetEntryExpiry.
+	MaxWait   time.Duration
+	OwnerLeft func(QueueEntry) bool
+	// Present and PresencePolicy handle entries of absent owners, see SetPresenceChecker.
+	Present        func(nick string) bool
+	PresencePolicy PresencePolicy
+	// PreferenceStore persists user preferences, see SetPreferenceStore.
+	PreferenceStore Store
+	// RequireApproval puts new requests into the pending list, see SetApprovalRequired.
+	RequireApproval bool
+
+	// ArchiveDir is the directory played tracks are recorded into, empty disables recording.
+	ArchiveDir string
+	// IdleFillerDir is the directory idle filler is played from, empty plays silence, see SetIdleFiller.
+	IdleFillerDir string
+	// UpNextLead is how long before the end of an entry EventUpNext is published, see SetUpNextLead.
+	UpNextLead time.Duration
+	// Gap is the silence between two tracks, nil uses the default of 5 seconds, see SetGap.
+	Gap *time.Duration
+	// PreRoll is the delay before each track, PreRollSting is played during it if not empty.
+	PreRoll      time.Duration
+	PreRollSting string
+	// StingerEvery and Stingers play a short sting between tracks, see SetStingers.
+	StingerEvery int
+	Stingers     []string
+	// TranscodeMode decides whether tracks are transcoded or copied.
+	TranscodeMode TranscodeMode
+	// Outputs are the outputs PlayTo streams to if it is called without any, by name.
+	// While playing, outputs added to the map are started and removed ones are stopped.
+	// Outputs are told apart by their name only, so a changed output needs a new name to be restarted.
+	Outputs map[string]Output
+	// OutputBufferSize and OutputBufferPolicy control the buffer of every output, see SetOutputBuffer.
+	OutputBufferSize   int
+	OutputBufferPolicy BufferPolicy
+	// SkipFade is how long skipped and cut off tracks fade out, see SetSkipFade.
+	SkipFade time.Duration
+	// TargetLatency is the maximum age of buffered audio, see SetTargetLatency.
+	TargetLatency time.Duration
+	// Ducking and ErrorAnnouncement mix announcements into tracks, see SetDucking and SetErrorAnnouncement.
+	Ducking           bool
+	ErrorAnnouncement func(err error) (announcement Media, ok bool)
+	// ReplayGain and ReplayGainPreamp apply the stored gain of local files, see SetReplayGain.
+	ReplayGain       bool
+	ReplayGainPreamp float64
+	// GainSchedule and GainRamp change the volume by time of day, see SetGainSchedule.
+	GainSchedule []GainPeriod
+	GainRamp     time.Duration
+	// Location is the time zone schedules follow, nil uses the local time zone, see SetLocation.
+	Location *time.Location
+	// ProcessLimits restricts the resources of spawned processes.
+	ProcessLimits ProcessLimits
+
+	// TrackSource reads the audio of tracks, nil uses an empty TrackSourceRegistry.
+	TrackSource TrackSource
+	// Resolver resolves media metadata, nil uses YtdlpResolver.
+	Resolver Resolver
+	// ResolverConcurrency and ResolverRate limit the resolution of media, see SetResolverLimits.
+	ResolverConcurrency int
+	ResolverRate        float64
+	// WarmUp is how many entries are resolved before playback starts, see SetWarmUp.
+	WarmUp int
+	// HostRateLimits limit the resolutions per host, see SetHostRateLimit.
+	HostRateLimits map[string]HostRateLimit
+
+	// BreakerThreshold and BreakerCooldown configure the circuit breaker, see SetCircuitBreaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// EventRateLimits is the minimum interval between notifications per event type, see SetEventRateLimit.
+	EventRateLimits map[EventType]time.Duration
+	// AsyncHandlers calls handlers in their own goroutine, see SetAsyncHandlers.
+	AsyncHandlers bool
+	// HandlerTimeout is how long playback waits for a handler, see SetHandlerTimeout.
+	HandlerTimeout time.Duration
+}
+
+// Validate returns an error if the config contains invalid values.
+func (c Config) Validate() error {
+	switch {
+	case c.UserQuota > 0 && c.UserQuotaWindow <= 0:
+		return errors.New("the user quota window has to be positive")
+	case c.PreRoll < 0:
+		return errors.New("the pre-roll can't be negative")
+	case c.TranscodeMode != TranscodeAlways && c.TranscodeMode != TranscodeAuto:
+		return fmt.Errorf("unknown transcode mode %d", c.TranscodeMode)
+	case c.ProcessLimits.Nice < -20 || c.ProcessLimits.Nice > 19:
+		return fmt.Errorf("niceness %d is out of range", c.ProcessLimits.Nice)
+	case c.ProcessLimits.IOClass < 0 || c.ProcessLimits.IOClass > 3:
+		return fmt.Errorf("IO class %d is out of range", c.ProcessLimits.IOClass)
+	case c.ProcessLimits.IOLevel < 0 || c.ProcessLimits.IOLevel > 7:
+		return fmt.Errorf("IO level %d is out of range", c.ProcessLimits.IOLevel)
+	case c.ProcessLimits.Threads < 0:
+		return errors.New("the thread limit can't be negative")
+	case c.OutputBufferSize < 0:
+		return errors.New("the output buffer size can't be negative")
+	case c.OutputBufferPolicy != BufferBlock && c.OutputBufferPolicy != BufferDropOldest:
+		return fmt.Errorf("unknown buffer policy %d", c.OutputBufferPolicy)
+	case c.PresencePolicy < PresenceIgnore || c.PresencePolicy > PresenceDefer:
+		return fmt.Errorf("unknown presence policy %d", c.PresencePolicy)
+	case c.GainRamp < 0:
+		return errors.New("the gain ramp can't be negative")
+	case c.ResolverRate < 0:
+		return errors.New("the resolver rate can't be negative")
+	case c.Gap != nil && *c.Gap < 0:
+		return errors.New("the gap can't be negative")
+	case c.HandlerTimeout < 0:
+		return errors.New("the handler timeout can't be negative")
+	}
+	for _, p := range c.GainSchedule {
+		if p.Start < 0 || p.Start >= 24*time.Hour || p.End < 0 || p.End >= 24*time.Hour {
+			return errors.New("gain periods have to be within a day")
+		}
+	}
+	for name, output := range c.Outputs {
+		if output == nil {
+			return fmt.Errorf("output %q is nil", name)
+		}
+	}
+	for t, interval := range c.EventRateLimits {
+		if interval < 0 {
+			return fmt.Errorf("the rate limit for %s can't be negative", t)
+		}
+	}
+
+	if c.ArchiveDir != "" {
+		info, err := os.Stat(c.ArchiveDir)
+		if err != nil {
+			return fmt.Errorf("invalid archive dir: %w", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("archive dir %s is not a directory", c.ArchiveDir)
+		}
+	}
+	if c.IdleFillerDir != "" {
+		info, err := os.Stat(c.IdleFillerDir)
+		if err != nil {
+			return fmt.Errorf("invalid idle filler dir: %w", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("idle filler dir %s is not a directory", c.IdleFillerDir)
+		}
+	}
+	return nil
+}
+
+// config holds the current Config, it is replaced as a whole on every change.
+type config struct {
+	mu      sync.Mutex
+	current atomic.Pointer[Config]
+	// changed is closed and replaced on every change
+	changed chan struct{}
+}
+
+// store replaces the current configuration, config.mu has to be held.
+func (c *config) store(cfg *Config) {
+	c.current.Store(cfg)
+	if c.changed != nil {
+		close(c.changed)
+		c.changed = nil
+	}
+}
+
+// watch returns a channel that is closed on the next change of the configuration.
+func (c *config) watch() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changed == nil {
+		c.changed = make(chan struct{})
+	}
+	return c.changed
+}
+
+// Config returns the current configuration.
+func (dj *Dj) Config() Config {
+	return *dj.cfg()
+}
+
+// ApplyConfig validates cfg and replaces the current configuration with it.
+//
+// The change takes effect immediately, settings that are used per track like the
+// pre-roll apply from the next track on. The config is not changed if it is invalid.
+func (dj *Dj) ApplyConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	dj.config.mu.Lock()
+	dj.config.store(&cfg)
+	dj.config.mu.Unlock()
+	return nil
+}
+
+// cfg returns the current configuration, it must not be modified.
+func (dj *Dj) cfg() *Config {
+	if c := dj.config.current.Load(); c != nil {
+		return c
+	}
+	return &Config{}
+}
+
+// updateConfig applies update to a copy of the current configuration and stores it
+// if it is valid, otherwise the configuration stays unchanged and the error is returned.
+func (dj *Dj) updateConfig(update func(*Config)) error {
+	dj.config.mu.Lock()
+	defer dj.config.mu.Unlock()
+
+	cfg := *dj.cfg()
+	update(&cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	dj.config.store(&cfg)
+	return nil
+}
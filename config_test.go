@@ -0,0 +1,145 @@
+package opendj
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signalOutput reads the stream until it is stopped and reports starting and stopping.
+type signalOutput struct {
+	started, stopped chan struct{}
+}
+
+func newSignalOutput() signalOutput {
+	return signalOutput{started: make(chan struct{}, 16), stopped: make(chan struct{}, 16)}
+}
+
+func (o signalOutput) Start(ctx context.Context, stream io.Reader) error {
+	o.started <- struct{}{}
+	go func() { _, _ = io.Copy(io.Discard, stream) }()
+	<-ctx.Done()
+	o.stopped <- struct{}{}
+	return nil
+}
+
+func receive(t *testing.T, c <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting until the output %s", what)
+	}
+}
+
+func TestConfigOutputs(t *testing.T) {
+	dj := newTestDj(t, nil)
+	first, second := newSignalOutput(), newSignalOutput()
+	cfg := dj.Config()
+	cfg.Outputs = map[string]Output{"first": first}
+	if err := dj.ApplyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- dj.PlayTo(ctx) }()
+	receive(t, first.started, "first started")
+
+	dj.updateConfig(func(c *Config) { c.Outputs = map[string]Output{"first": first, "second": second} })
+	receive(t, second.started, "second started")
+	// unrelated changes leave running outputs alone
+	dj.SetGap(0)
+	dj.updateConfig(func(c *Config) { c.Outputs = map[string]Output{"second": second} })
+	receive(t, first.stopped, "first stopped")
+
+	select {
+	case <-first.started:
+		t.Error("the first output was restarted after it was removed")
+	case <-second.started:
+		t.Error("the second output was restarted by a change of the configuration")
+	case <-time.After(50 * time.Millisecond):
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var names []string
+		for _, c := range dj.PipelineState() {
+			if c.Name != resolverComponent {
+				names = append(names, c.Name)
+			}
+		}
+		if equalStrings(names, []string{"encoder", "output second"}) {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("the pipeline has the components %v", names)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("PlayTo() = %v", err)
+	}
+}
+
+func TestConfigGap(t *testing.T) {
+	dj := newTestDj(t, nil)
+	if gap := dj.cfg().gap(); gap != defaultGap {
+		t.Errorf("gap() = %s without a configured gap, want %s", gap, defaultGap)
+	}
+	dj.SetGap(0)
+	if gap := dj.cfg().gap(); gap != 0 {
+		t.Errorf("gap() = %s after SetGap(0)", gap)
+	}
+
+	dj.SetGap(-time.Minute)
+	if gap := dj.cfg().gap(); gap != 0 {
+		t.Errorf("gap() = %s after SetGap(-1m), want 0", gap)
+	}
+	if err := dj.ApplyConfig(dj.Config()); err != nil {
+		t.Errorf("ApplyConfig() of the current config = %v", err)
+	}
+
+	negative := -time.Second
+	if err := (Config{Gap: &negative}).Validate(); err == nil {
+		t.Error("Validate() accepted a negative gap")
+	}
+	if err := (Config{Outputs: map[string]Output{"nil": nil}}).Validate(); err == nil {
+		t.Error("Validate() accepted a nil output")
+	}
+	if err := dj.PlayTo(context.Background()); err == nil {
+		t.Error("PlayTo() without outputs succeeded")
+	}
+}
+
+func TestSettersValidate(t *testing.T) {
+	dj := newTestDj(t, nil)
+
+	for name, err := range map[string]error{
+		"SetTranscodeMode":   dj.SetTranscodeMode(TranscodeMode(42)),
+		"SetProcessLimits":   dj.SetProcessLimits(ProcessLimits{Nice: 40}),
+		"SetOutputBuffer":    dj.SetOutputBuffer(-1, BufferBlock),
+		"SetGainSchedule":    dj.SetGainSchedule([]GainPeriod{{Start: 25 * time.Hour}}, 0),
+		"SetPresenceChecker": dj.SetPresenceChecker(nil, PresencePolicy(42)),
+		"SetArchiveDir":      dj.SetArchiveDir(filepath.Join(t.TempDir(), "missing")),
+		"SetIdleFiller":      dj.SetIdleFiller(filepath.Join(t.TempDir(), "missing")),
+	} {
+		if err == nil {
+			t.Errorf("%s accepted an invalid value", name)
+		}
+	}
+
+	dj.SetPreRoll(-time.Second, "")
+	dj.SetUserQuota(3, -time.Hour)
+	dj.SetHandlerTimeout(-time.Second)
+	dj.SetResolverLimits(1, -1)
+	if err := dj.ApplyConfig(dj.Config()); err != nil {
+		t.Errorf("setters installed an invalid config: %v", err)
+	}
+	if cfg := dj.cfg(); cfg.TranscodeMode != TranscodeAlways || cfg.ArchiveDir != "" || cfg.UserQuota != 0 {
+		t.Errorf("rejected changes were applied: %+v", cfg)
+	}
+}
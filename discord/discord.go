@@ -0,0 +1,428 @@
+// Package discord wires a Dj to Discord.
+//
+// It handles slash commands through the Discord interactions endpoint and posts
+// now playing embeds to a channel using the Discord REST API.
+//
+// Playback into voice channels is not supported. It needs a gateway connection and encrypted
+// Opus over UDP, which would pull in a Discord library like discordgo. Use one of the stream
+// outputs instead, e.g. an Icecast or HLS output that listeners or a music bot can play.
+package discord
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SoMuchForSubtlety/opendj"
+	"github.com/SoMuchForSubtlety/opendj/catalog"
+	"github.com/SoMuchForSubtlety/opendj/commands"
+)
+
+const apiBase = "https://discord.com/api/v10"
+
+// Discord interaction and response types, see the Discord developer documentation.
+const (
+	interactionPing               = 1
+	interactionApplicationCommand = 2
+
+	responsePong                   = 1
+	responseChannelMessage         = 4
+	responseDeferredChannelMessage = 5
+
+	applicationCommandTypeChatInput = 1
+	optionTypeString                = 3
+	optionTypeInteger               = 4
+
+	// permissionManageMessages is the permission that makes a member a moderator.
+	permissionManageMessages int64 = 1 << 13
+)
+
+const (
+	commandTimeout        = time.Minute
+	defaultRequestTimeout = 10 * time.Second
+	defaultMaxCommands    = 16
+	maxEmbedDescription   = 4096
+	embedColor            = 0x5865F2
+	// maxTimestampSkew is how old or new a signed request may be, older ones could be replayed.
+	maxTimestampSkew = 5 * time.Minute
+)
+
+// A Bot connects a Dj to Discord.
+type Bot struct {
+	Dj *opendj.Dj
+	// ApplicationID and PublicKey are shown in the Discord developer portal.
+	ApplicationID string
+	PublicKey     ed25519.PublicKey
+	// Token is the bot token, it is used to register commands and post messages.
+	Token string
+	// Parser executes the commands, the zero value uses the defaults of the commands package.
+	// Its Catalog and Locale are also used for the now playing embeds and the command descriptions.
+	Parser commands.Parser
+	// Client is used for requests to Discord, it defaults to a client with a 10 second timeout.
+	Client *http.Client
+	// MaxCommands is the amount of commands that are executed at the same time, it defaults to 16.
+	// Further commands are answered with the Busy text until one finished.
+	MaxCommands int
+
+	initOnce sync.Once
+	// slots has room for every command that may be executed
+	slots   chan struct{}
+	running sync.WaitGroup
+}
+
+// NewBot returns a Bot for the application with the given hex encoded public key.
+func NewBot(dj *opendj.Dj, applicationID, publicKey, token string) (*Bot, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key %q", publicKey)
+	}
+	return &Bot{Dj: dj, ApplicationID: applicationID, PublicKey: key, Token: token}, nil
+}
+
+type commandOption struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        int    `json:"type"`
+	Required    bool   `json:"required"`
+	// descriptionKey is the catalog key of Description
+	descriptionKey catalog.Key
+}
+
+type applicationCommand struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Type        int             `json:"type"`
+	Options     []commandOption `json:"options,omitempty"`
+	// descriptionKey is the catalog key of Description
+	descriptionKey catalog.Key
+	// action is executed by the command, slash commands don't depend on the names of the parser
+	action commands.Action
+}
+
+// slashCommands are the registered commands.
+var slashCommands = []applicationCommand{
+	{Name: "play", action: commands.ActionPlay, descriptionKey: catalog.PlayDescription, Options: []commandOption{
+		{Name: "query", descriptionKey: catalog.QueryDescription, Type: optionTypeString, Required: true},
+	}},
+	{Name: "queue", action: commands.ActionQueue, descriptionKey: catalog.QueueDescription},
+	{Name: "skip", action: commands.ActionSkip, descriptionKey: catalog.SkipDescription},
+	{Name: "remove", action: commands.ActionRemove, descriptionKey: catalog.RemoveDescription, Options: []commandOption{
+		{Name: "position", descriptionKey: catalog.PositionDescription, Type: optionTypeInteger, Required: true},
+	}},
+	{Name: "nowplaying", action: commands.ActionNowPlaying, descriptionKey: catalog.NowPlayingDescription},
+}
+
+// RegisterCommands registers the slash commands for the guild, or globally if guildID is empty.
+func (b *Bot) RegisterCommands(ctx context.Context, guildID string) error {
+	path := "/applications/" + b.ApplicationID + "/commands"
+	if guildID != "" {
+		path = "/applications/" + b.ApplicationID + "/guilds/" + guildID + "/commands"
+	}
+	cmds := make([]applicationCommand, len(slashCommands))
+	for i, cmd := range slashCommands {
+		cmd.Type = applicationCommandTypeChatInput
+		cmd.Description = b.text(cmd.descriptionKey)
+		options := make([]commandOption, len(cmd.Options))
+		for j, option := range cmd.Options {
+			option.Description = b.text(option.descriptionKey)
+			options[j] = option
+		}
+		cmd.Options = options
+		cmds[i] = cmd
+	}
+	return b.request(ctx, http.MethodPut, path, cmds)
+}
+
+type interaction struct {
+	Type  int    `json:"type"`
+	Token string `json:"token"`
+	Data  struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+	Member *struct {
+		Nick        string `json:"nick"`
+		Permissions string `json:"permissions"`
+		User        user   `json:"user"`
+	} `json:"member"`
+	User *user `json:"user"`
+}
+
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// ServeHTTP handles requests to the interactions endpoint URL configured in the developer portal.
+//
+// Requests with a signed timestamp more than 5 minutes off the clock of the Dj are rejected,
+// so recorded requests can't be replayed.
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || !ed25519.Verify(b.PublicKey, append([]byte(timestamp), body...), signature) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	if !b.fresh(timestamp) {
+		http.Error(w, "stale request timestamp", http.StatusUnauthorized)
+		return
+	}
+
+	var in interaction
+	if err = json.Unmarshal(body, &in); err != nil {
+		http.Error(w, "invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch in.Type {
+	case interactionPing:
+		_ = json.NewEncoder(w).Encode(map[string]int{"type": responsePong})
+	case interactionApplicationCommand:
+		if !b.acquire() {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"type": responseChannelMessage,
+				"data": map[string]string{"content": b.text(catalog.Busy)},
+			})
+			return
+		}
+		// resolving media can take longer than the 3 seconds Discord waits for a response
+		_ = json.NewEncoder(w).Encode(map[string]int{"type": responseDeferredChannelMessage})
+		go func() {
+			defer b.release()
+			b.execute(in)
+		}()
+	default:
+		http.Error(w, "unsupported interaction type", http.StatusBadRequest)
+	}
+}
+
+// fresh reports whether the unix timestamp is within maxTimestampSkew of the clock of the Dj.
+func (b *Bot) fresh(timestamp string) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := b.Dj.Now().Sub(time.Unix(seconds, 0))
+	return skew <= maxTimestampSkew && skew >= -maxTimestampSkew
+}
+
+// acquire reserves a slot for a command, it returns false if all are taken.
+func (b *Bot) acquire() bool {
+	b.initOnce.Do(func() {
+		max := b.MaxCommands
+		if max <= 0 {
+			max = defaultMaxCommands
+		}
+		b.slots = make(chan struct{}, max)
+	})
+	select {
+	case b.slots <- struct{}{}:
+		b.running.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Bot) release() {
+	<-b.slots
+	b.running.Done()
+}
+
+// Wait blocks until all commands that are being executed finished, e.g. after the
+// HTTP server was shut down.
+func (b *Bot) Wait() {
+	b.running.Wait()
+}
+
+// execute runs the command of the interaction and edits the deferred response.
+func (b *Bot) execute(in interaction) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	reply, err := b.run(ctx, in)
+	if reply == "" && err != nil {
+		reply = err.Error()
+	}
+	_ = b.request(ctx, http.MethodPatch, "/webhooks/"+b.ApplicationID+"/"+in.Token+"/messages/@original", map[string]string{
+		"content": reply,
+	})
+}
+
+func (b *Bot) run(ctx context.Context, in interaction) (string, error) {
+	caller := commands.Caller{Source: opendj.SourceDiscord}
+	if in.Member != nil {
+		caller.Name = in.Member.Nick
+		if caller.Name == "" {
+			caller.Name = in.Member.User.Username
+		}
+		caller.ID = in.Member.User.ID
+		permissions, _ := strconv.ParseInt(in.Member.Permissions, 10, 64)
+		caller.Moderator = permissions&permissionManageMessages != 0
+	} else if in.User != nil {
+		caller.Name, caller.ID = in.User.Username, in.User.ID
+	}
+
+	cmd, reply, err := b.command(in)
+	if err != nil {
+		return reply, err
+	}
+	return b.Parser.Execute(ctx, b.Dj, caller, cmd)
+}
+
+// command returns the command of the interaction, for invalid options the reply explaining them is returned with the error.
+func (b *Bot) command(in interaction) (commands.Command, string, error) {
+	var cmd commands.Command
+	found := false
+	for _, slash := range slashCommands {
+		if slash.Name == in.Data.Name {
+			cmd.Action, found = slash.action, true
+			break
+		}
+	}
+	if !found {
+		return cmd, "", fmt.Errorf("%q: %w", in.Data.Name, commands.ErrorUnknownCommand)
+	}
+
+	var position json.Number
+	for _, option := range in.Data.Options {
+		switch option.Name {
+		case "query":
+			_ = json.Unmarshal(option.Value, &cmd.Query)
+		case "position":
+			_ = json.Unmarshal(option.Value, &position)
+		}
+	}
+	switch cmd.Action {
+	case commands.ActionPlay:
+		if cmd.Query = strings.TrimSpace(cmd.Query); cmd.Query == "" {
+			reply, err := b.optionError(catalog.MissingQuery)
+			return cmd, reply, err
+		}
+	case commands.ActionRemove:
+		index, err := strconv.Atoi(position.String())
+		if err != nil || index < 1 {
+			reply, err := b.optionError(catalog.InvalidPosition, position.String())
+			return cmd, reply, err
+		}
+		cmd.Index = index - 1
+	}
+	return cmd, "", nil
+}
+
+type embed struct {
+	Title       string `json:"title"`
+	URL         string `json:"url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Color       int    `json:"color"`
+}
+
+// PostNowPlaying posts an embed for the entry to the channel.
+func (b *Bot) PostNowPlaying(ctx context.Context, channelID string, entry opendj.QueueEntry) error {
+	description := b.text(catalog.RequestedBy, entry.PublicOwner())
+	if entry.Dedication != "" {
+		description += "\n" + entry.Dedication
+	}
+	description = truncate(description, maxEmbedDescription)
+	return b.request(ctx, http.MethodPost, "/channels/"+channelID+"/messages", map[string]interface{}{
+		"embeds": []embed{{
+			Title:       entry.Media.Title,
+			URL:         entry.Media.URL,
+			Description: description,
+			Color:       embedColor,
+		}},
+	})
+}
+
+// optionError returns the reply for an invalid option and an error with its default text.
+func (b *Bot) optionError(key catalog.Key, args ...interface{}) (string, error) {
+	return b.text(key, args...), errors.New(fmt.Sprintf(catalog.Defaults[key], args...))
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// AnnounceIn posts a now playing embed to the channel every time a new song starts,
+// until ctx is cancelled. Errors are passed to onError, which may be nil.
+func (b *Bot) AnnounceIn(ctx context.Context, channelID string, onError func(error)) {
+	events, cancel := b.Dj.Subscribe(16)
+	defer cancel()
+
+	started := b.Dj.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			// don't announce replayed events from before the announcer started
+			if event.Type != opendj.EventSongStarted || event.Time.Before(started) {
+				continue
+			}
+			if err := b.PostNowPlaying(ctx, channelID, event.Entry); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// text returns the text of key in the catalog of the parser.
+func (b *Bot) text(key catalog.Key, args ...interface{}) string {
+	return b.Parser.Catalog.Text(b.Parser.Locale, key, args...)
+}
+
+func (b *Bot) request(ctx context.Context, method, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, apiBase+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bot "+b.Token)
+
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultRequestTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("discord request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("discord returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
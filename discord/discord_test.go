@@ -0,0 +1,177 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SoMuchForSubtlety/opendj"
+	"github.com/SoMuchForSubtlety/opendj/commands"
+)
+
+// blockingTransport answers every request once release is closed.
+type blockingTransport struct {
+	release  chan struct{}
+	requests chan *http.Request
+}
+
+func (t blockingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests <- r
+	<-t.release
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil)), Request: r}, nil
+}
+
+func newTestBot(t *testing.T) (*Bot, ed25519.PrivateKey) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"yt-dlp", "ffmpeg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\nexec cat\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot, err := NewBot(opendj.NewDj(nil), "app", hex.EncodeToString(public), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot, private
+}
+
+// signed sends body signed with key and the timestamp to the bot.
+func signed(bot *Bot, key ed25519.PrivateKey, timestamp time.Time, body []byte) *httptest.ResponseRecorder {
+	stamp := strconv.FormatInt(timestamp.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/interactions", bytes.NewReader(body))
+	r.Header.Set("X-Signature-Timestamp", stamp)
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(key, append([]byte(stamp), body...))))
+	w := httptest.NewRecorder()
+	bot.ServeHTTP(w, r)
+	return w
+}
+
+// interact sends a signed queue command and returns the response type.
+func interact(t *testing.T, bot *Bot, key ed25519.PrivateKey) int {
+	t.Helper()
+	body := []byte(`{"type":2,"token":"interaction","data":{"name":"queue"},"user":{"id":"1","username":"bob"}}`)
+	w := signed(bot, key, bot.Dj.Now(), body)
+
+	var response struct {
+		Type int `json:"type"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return response.Type
+}
+
+func TestCommandsAreBounded(t *testing.T) {
+	bot, key := newTestBot(t)
+	transport := blockingTransport{release: make(chan struct{}), requests: make(chan *http.Request, 4)}
+	bot.Client = &http.Client{Transport: transport}
+	bot.MaxCommands = 1
+
+	if got := interact(t, bot, key); got != responseDeferredChannelMessage {
+		t.Fatalf("first command got response type %d, want a deferred response", got)
+	}
+	// the first command is stuck editing its response
+	select {
+	case <-transport.requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first command did not edit its response")
+	}
+	if got := interact(t, bot, key); got != responseChannelMessage {
+		t.Errorf("command while busy got response type %d, want a busy message", got)
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		bot.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Wait() returned while a command is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(transport.release)
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after the command finished")
+	}
+
+	if got := interact(t, bot, key); got != responseDeferredChannelMessage {
+		t.Errorf("command after the first finished got response type %d, want a deferred response", got)
+	}
+	bot.Wait()
+}
+
+func TestStaleTimestampRejected(t *testing.T) {
+	bot, key := newTestBot(t)
+	ping := []byte(`{"type":1}`)
+	for _, offset := range []time.Duration{-time.Hour, -6 * time.Minute, 6 * time.Minute} {
+		if w := signed(bot, key, bot.Dj.Now().Add(offset), ping); w.Code != http.StatusUnauthorized {
+			t.Errorf("ping signed %v from now got status %d, want %d", offset, w.Code, http.StatusUnauthorized)
+		}
+	}
+	if w := signed(bot, key, bot.Dj.Now().Add(-time.Minute), ping); w.Code != http.StatusOK {
+		t.Errorf("ping signed a minute ago got status %d", w.Code)
+	}
+}
+
+func TestSlashCommandsIgnoreNames(t *testing.T) {
+	bot, _ := newTestBot(t)
+	// the chat commands are renamed, the slash commands keep working
+	bot.Parser.Names = map[string]commands.Action{"spielen": commands.ActionPlay, "weg": commands.ActionRemove}
+
+	parse := func(body string) (commands.Command, string, error) {
+		var in interaction
+		if err := json.Unmarshal([]byte(body), &in); err != nil {
+			t.Fatal(err)
+		}
+		return bot.command(in)
+	}
+	cmd, _, err := parse(`{"data":{"name":"play","options":[{"name":"query","value":"https://example.com/song"}]}}`)
+	if err != nil || cmd.Action != commands.ActionPlay || cmd.Query != "https://example.com/song" {
+		t.Errorf("play = %+v, %v", cmd, err)
+	}
+	cmd, _, err = parse(`{"data":{"name":"remove","options":[{"name":"position","value":3}]}}`)
+	if err != nil || cmd.Action != commands.ActionRemove || cmd.Index != 2 {
+		t.Errorf("remove = %+v, %v, want index 2", cmd, err)
+	}
+	if _, reply, err := parse(`{"data":{"name":"remove","options":[{"name":"position","value":0}]}}`); err == nil || reply == "" {
+		t.Errorf("remove at position 0 = %q, %v, want an explanation", reply, err)
+	}
+	if _, _, err = parse(`{"data":{"name":"spielen"}}`); !errors.Is(err, commands.ErrorUnknownCommand) {
+		t.Errorf("unregistered slash command = %v, want ErrorUnknownCommand", err)
+	}
+}
+
+func TestTruncateKeepsCharacters(t *testing.T) {
+	s := strings.Repeat("音楽🎵", 1000)
+	for _, n := range []int{0, 1, 5, 8, maxEmbedDescription} {
+		got := truncate(s, n)
+		if len(got) > n || !utf8.ValidString(got) || !strings.HasPrefix(s, got) {
+			t.Errorf("truncate(s, %d) = %d bytes, valid %v", n, len(got), utf8.ValidString(got))
+		}
+	}
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("truncate() changed a short string to %q", got)
+	}
+}
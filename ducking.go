@@ -0,0 +1,160 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// maxInterjections is how many announcements can be waiting to be mixed into the current track.
+const maxInterjections = 4
+
+// duckingGraph mixes the announcements of input 1 into the main audio, lowering the main audio while they play.
+const duckingGraph = "[1:a]asplit=2[sc][voice];" +
+	"[main][sc]sidechaincompress=threshold=0.015:ratio=10:attack=10:release=500[ducked];" +
+	"[ducked][voice]amix=inputs=2:duration=first:dropout_transition=0:normalize=0"
+
+// errTooManyInterjections is returned when announcements are requested faster than they can be played.
+var errTooManyInterjections = errors.New("too many announcements are waiting")
+
+type ducker struct {
+	sync.Mutex
+	// feed receives the announcements for the track that is playing, it is nil if ducking is not possible
+	feed chan Media
+}
+
+// SetDucking enables mixing announcements into the music with Interject.
+//
+// The music is lowered while an announcement plays instead of stopping the track.
+// Tracks are transcoded while ducking is enabled, it applies from the next track on.
+func (dj *Dj) SetDucking(enabled bool) {
+	dj.updateConfig(func(c *Config) { c.Ducking = enabled })
+}
+
+// SetErrorAnnouncement sets a function that returns an announcement for an error, e.g. a TTS
+// clip saying that a request could not be played. The announcement is mixed into the current
+// track with ducking whenever the error handler fires, ok false skips the announcement.
+//
+// Setting it enables ducking, see SetDucking. Passing nil disables the announcements.
+func (dj *Dj) SetErrorAnnouncement(announce func(err error) (announcement Media, ok bool)) {
+	dj.updateConfig(func(c *Config) { c.ErrorAnnouncement = announce })
+}
+
+// ducking reports whether announcements can be mixed into tracks.
+func (c *Config) ducking() bool {
+	return c.Ducking || c.ErrorAnnouncement != nil
+}
+
+// Interject mixes media into the track that is playing, lowering the music while it plays.
+//
+// returns ErrorNothingPlaying if no track that supports ducking is playing, see SetDucking.
+func (dj *Dj) Interject(media Media) error {
+	dj.ducker.Lock()
+	defer dj.ducker.Unlock()
+
+	if dj.ducker.feed == nil {
+		return ErrorNothingPlaying
+	}
+	select {
+	case dj.ducker.feed <- media:
+		return nil
+	default:
+		return errTooManyInterjections
+	}
+}
+
+// announceError interjects the announcement for err if one is configured.
+func (dj *Dj) announceError(err error) {
+	announce := dj.cfg().ErrorAnnouncement
+	if announce == nil {
+		return
+	}
+	if media, ok := announce(err); ok {
+		_ = dj.Interject(media)
+	}
+}
+
+// writeDuckedStream is like writeProfileStream, but also mixes in announcements passed to Interject.
+//
+// args are the input options, the filters are applied to the main audio before mixing.
+func (dj *Dj) writeDuckedStream(ctx context.Context, stream io.Writer, input io.Reader, profile *EncodingProfile, extraOutput, args []string, filters ...string) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	feed := make(chan Media, maxInterjections)
+	dj.ducker.Lock()
+	dj.ducker.feed = feed
+	dj.ducker.Unlock()
+	defer func() {
+		dj.ducker.Lock()
+		dj.ducker.feed = nil
+		dj.ducker.Unlock()
+	}()
+
+	feedCtx, stopFeed := context.WithCancel(ctx)
+	defer stopFeed()
+	go dj.feedInterjections(feedCtx, w, feed)
+
+	chain := profile.filterChain(append(filters, "aresample=44100", "aformat=channel_layouts=stereo")...)
+	graph := "[0:a]" + chain + "[main];" + duckingGraph
+	if len(extraOutput) > 0 {
+		graph += ",asplit=2[out][archive]"
+		extraOutput = append([]string{"-map", "[archive]"}, extraOutput...)
+	} else {
+		graph += "[out]"
+	}
+
+	args = append(args,
+		"-f", "s16le", "-ar", "44100", "-ac", "2", "-i", "pipe:3",
+		"-filter_complex", graph,
+		"-map", "[out]",
+	)
+	args = append(args, profile.outputArgs()...)
+	return runEncoder(ctx, stream, input, append(args, extraOutput...), r)
+}
+
+// feedInterjections writes silence into w, replaced by the decoded announcements from feed.
+// The encoder reads it as fast as the main audio, so announcements start right away.
+func (dj *Dj) feedInterjections(ctx context.Context, w *os.File, feed <-chan Media) {
+	defer w.Close()
+
+	silence := make([]byte, 4096)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case media := <-feed:
+			if err := dj.decodeInto(ctx, w, media); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// not announced, a broken announcement would announce its own error
+				dj.notifyError(err)
+			}
+		default:
+			if _, err := w.Write(silence); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// decodeInto writes media as raw PCM into w, inputArgs are passed to ffmpeg before the input.
+func (dj *Dj) decodeInto(ctx context.Context, w io.Writer, media Media, inputArgs ...string) error {
+	audio, err := dj.openTrack(ctx, media)
+	if err != nil {
+		return err
+	}
+	defer audio.Close()
+
+	args := append(inputArgs, "-i", "pipe:0", "-f", "s16le", "-ar", "44100", "-ac", "2", "pipe:1")
+	cmd := newCommand(ctx, "ffmpeg", args...)
+	cmd.Stdin = audio
+	cmd.Stdout = w
+	return cmd.Run()
+}
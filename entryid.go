@@ -0,0 +1,110 @@
+package opendj
+
+import "errors"
+
+// ErrorUnknownEntry is returned if no entry with the given ID is in any queue.
+var ErrorUnknownEntry = errors.New("unknown entry")
+
+// assignID gives the entry a new ID from ids, ids may be nil.
+//
+// An ID set by the caller is replaced, so a new entry can't take the ID of one that is already queued.
+func (e *QueueEntry) assignID(ids IDGenerator) {
+	if ids == nil {
+		ids = processIDs
+	}
+	e.ID = ids.NextID()
+}
+
+// findEntry returns the queue and index of the entry with the given ID, the lock has to be held.
+func (dj *Dj) findEntry(id uint64) (*queue, int, bool) {
+	for _, q := range dj.drainOrder() {
+		index := -1
+		q.each(func(i int, entry QueueEntry) bool {
+			if entry.ID == id {
+				index = i
+			}
+			return index < 0
+		})
+		if index >= 0 {
+			return q, index, true
+		}
+	}
+	return nil, 0, false
+}
+
+// EntryByID returns the entry with the given ID from any queue.
+//
+// returns ErrorUnknownEntry if there is no such entry, e.g. because it was already played.
+func (dj *Dj) EntryByID(id uint64) (QueueEntry, error) {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+
+	q, i, ok := dj.findEntry(id)
+	if !ok {
+		return QueueEntry{}, ErrorUnknownEntry
+	}
+	return q.at(i), nil
+}
+
+// IndexByID returns the index of the entry with the given ID in the queue it is in.
+//
+// returns ErrorUnknownEntry if there is no such entry, e.g. because it was already played.
+func (dj *Dj) IndexByID(id uint64) (int, error) {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+
+	_, i, ok := dj.findEntry(id)
+	if !ok {
+		return 0, ErrorUnknownEntry
+	}
+	return i, nil
+}
+
+// RemoveByID removes the entry with the given ID from the queue it is in.
+//
+// Unlike RemoveIndex it can't remove the wrong entry if the queue changed in the meantime.
+// returns ErrorUnknownEntry if there is no such entry.
+func (dj *Dj) RemoveByID(id uint64) error {
+	return dj.As(directActor).RemoveByID(id).Err
+}
+
+// removeByID is RemoveByID without the audit log.
+func (dj *Dj) removeByID(id uint64) error {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	q, i, ok := dj.findEntry(id)
+	if !ok {
+		return ErrorUnknownEntry
+	}
+	q.remove(i)
+	return nil
+}
+
+// MoveByID moves the entry with the given ID to index to within its queue.
+//
+// if to is too high the entry is moved to the end.
+// returns ErrorUnknownEntry if there is no such entry or an error if to is < 0.
+func (dj *Dj) MoveByID(id uint64, to int) error {
+	return dj.As(directActor).MoveByID(id, to).Err
+}
+
+// moveByID is MoveByID without the audit log.
+func (dj *Dj) moveByID(id uint64, to int) error {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	q, from, ok := dj.findEntry(id)
+	if !ok {
+		return ErrorUnknownEntry
+	} else if to < 0 {
+		return ErrorOutOfRange
+	}
+	if to >= q.len() {
+		to = q.len() - 1
+	}
+	if from != to {
+		q.move(from, to)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package opendj
+
+// entryBlock is the maximum amount of entries in a block of an entryList.
+const entryBlock = 128
+
+// entryList is a sequence of entries stored in blocks of up to entryBlock entries.
+//
+// Inserting, removing and moving an entry only shifts the entries of one block and the
+// list of blocks instead of the whole queue, which matters for queues with thousands of entries.
+type entryList struct {
+	blocks [][]QueueEntry
+	n      int
+}
+
+func newEntryList(entries []QueueEntry) entryList {
+	l := entryList{n: len(entries)}
+	for len(entries) > 0 {
+		// blocks start half full so inserts don't have to split them right away
+		n := entryBlock / 2
+		if len(entries) < n {
+			n = len(entries)
+		}
+		block := make([]QueueEntry, n, entryBlock)
+		copy(block, entries)
+		l.blocks = append(l.blocks, block)
+		entries = entries[n:]
+	}
+	return l
+}
+
+// locate returns the block of the entry at index and its index within the block.
+// For index == n it returns the position after the last entry.
+func (l *entryList) locate(index int) (block, i int) {
+	for b, entries := range l.blocks {
+		if index < len(entries) {
+			return b, index
+		}
+		index -= len(entries)
+	}
+	return len(l.blocks), index
+}
+
+func (l *entryList) insert(index int, entry QueueEntry) {
+	b, i := l.locate(index)
+	if b == len(l.blocks) {
+		if b > 0 && len(l.blocks[b-1]) < entryBlock {
+			b, i = b-1, len(l.blocks[b-1])
+		} else {
+			l.blocks = append(l.blocks, make([]QueueEntry, 0, entryBlock))
+		}
+	}
+
+	block := l.blocks[b]
+	if len(block) == entryBlock {
+		// split the full block in half
+		half := make([]QueueEntry, entryBlock/2, entryBlock)
+		copy(half, block[entryBlock/2:])
+		for j := entryBlock / 2; j < entryBlock; j++ {
+			block[j] = QueueEntry{}
+		}
+		l.blocks[b] = block[:entryBlock/2]
+		l.blocks = append(l.blocks, nil)
+		copy(l.blocks[b+2:], l.blocks[b+1:])
+		l.blocks[b+1] = half
+		if i >= entryBlock/2 {
+			b, i = b+1, i-entryBlock/2
+		}
+		block = l.blocks[b]
+	}
+
+	block = append(block, QueueEntry{})
+	copy(block[i+1:], block[i:])
+	block[i] = entry
+	l.blocks[b] = block
+	l.n++
+}
+
+func (l *entryList) remove(index int) QueueEntry {
+	b, i := l.locate(index)
+	block := l.blocks[b]
+	entry := block[i]
+	copy(block[i:], block[i+1:])
+	block[len(block)-1] = QueueEntry{}
+	block = block[:len(block)-1]
+	l.blocks[b] = block
+	l.n--
+
+	if len(block) == 0 {
+		l.removeBlock(b)
+	} else if b+1 < len(l.blocks) && len(block)+len(l.blocks[b+1]) <= entryBlock/2 {
+		// merge small neighbours so removals don't leave many tiny blocks behind
+		l.blocks[b] = append(block, l.blocks[b+1]...)
+		l.removeBlock(b + 1)
+	}
+	return entry
+}
+
+func (l *entryList) removeBlock(b int) {
+	copy(l.blocks[b:], l.blocks[b+1:])
+	l.blocks[len(l.blocks)-1] = nil
+	l.blocks = l.blocks[:len(l.blocks)-1]
+}
+
+// appendTo appends all entries in order to buf and returns the result.
+func (l *entryList) appendTo(buf []QueueEntry) []QueueEntry {
+	for _, block := range l.blocks {
+		buf = append(buf, block...)
+	}
+	return buf
+}
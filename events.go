@@ -0,0 +1,294 @@
+package opendj
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultEventReplay is how many events are replayed to new subscribers by default.
+const defaultEventReplay = 16
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// The types of events published by a Dj.
+const (
+	EventSongStarted  EventType = "songStarted"
+	EventSongEnded    EventType = "songEnded"
+	EventQueueChanged EventType = "queueChanged"
+	EventError        EventType = "error"
+	// EventStreamReconnected is published when an output is running again after it failed,
+	// Component is the name of the output as in PipelineState.
+	EventStreamReconnected EventType = "streamReconnected"
+)
+
+// An Event is something that happened in a Dj.
+type Event struct {
+	Type EventType
+	// Seq increases by one with every event, so subscribers can order events and detect gaps.
+	Seq  uint64
+	Time time.Time
+	// Entry is the entry that started or ended playing.
+	Entry QueueEntry
+	// Err is the error for EventError and the error playback ended with for EventSongEnded.
+	Err error
+	// QueueVersion is the version of the request queue after the change for EventQueueChanged.
+	QueueVersion uint64
+	// Remaining is the time left in a break for the break events.
+	Remaining time.Duration
+	// Listeners is the listener count for EventListenersChanged.
+	Listeners int
+	// Component is the pipeline component for EventStreamReconnected.
+	Component string
+	// Show is the show for the show events.
+	Show Show
+}
+
+type eventBus struct {
+	sync.Mutex
+	seq         uint64
+	subscribers map[chan Event]struct{}
+	replay      []Event
+	replaySize  int
+	replaySet   bool
+}
+
+// SetEventReplay sets how many of the most recent events are delivered to new subscribers
+// right away, so they can show the correct state without waiting for the next event.
+// The latest event of each type that describes the state, like EventSongStarted or
+// EventShowStarted, is delivered in addition, so bursts of other events don't evict it.
+func (dj *Dj) SetEventReplay(n int) {
+	dj.events.Lock()
+	defer dj.events.Unlock()
+	dj.events.replaySize, dj.events.replaySet = n, true
+	dj.events.trim()
+}
+
+// Subscribe returns a channel that receives all events published from now on,
+// preceded by the most recent events.
+//
+// buffer is the size of the channel, events are dropped for subscribers that fall behind.
+// cancel has to be called once the subscriber is no longer interested, it closes the channel.
+//
+// Unlike the AddXHandler functions events carry sequence numbers, a subscriber that reconnects
+// can catch up with EventsSince. Event.Typed converts events to their concrete types.
+func (dj *Dj) Subscribe(buffer int) (events <-chan Event, cancel func()) {
+	dj.events.Lock()
+	defer dj.events.Unlock()
+
+	if buffer < len(dj.events.replay) {
+		buffer = len(dj.events.replay)
+	}
+	ch := make(chan Event, buffer)
+	for _, event := range dj.events.replay {
+		ch <- event
+	}
+	if dj.events.subscribers == nil {
+		dj.events.subscribers = make(map[chan Event]struct{})
+	}
+	dj.events.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			dj.events.Lock()
+			defer dj.events.Unlock()
+			delete(dj.events.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// publish sends the event to all subscribers.
+func (dj *Dj) publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = dj.now()
+	}
+
+	dj.events.Lock()
+	defer dj.events.Unlock()
+
+	dj.events.seq++
+	event.Seq = dj.events.seq
+	dj.events.replay = append(dj.events.replay, event)
+	dj.events.trim()
+	for ch := range dj.events.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// EventsSince returns the events after the given sequence number that are still in the replay buffer,
+// oldest first. ok is false if some of them are not available anymore, see SetEventReplay.
+func (dj *Dj) EventsSince(seq uint64) (events []Event, ok bool) {
+	dj.events.Lock()
+	defer dj.events.Unlock()
+
+	if seq >= dj.events.seq {
+		return nil, seq == dj.events.seq
+	}
+	for _, event := range dj.events.replay {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, len(events) == int(dj.events.seq-seq)
+}
+
+// reconnectTracker is like pipeline.tracker, but also publishes EventStreamReconnected
+// when the component runs again after a restart.
+func (dj *Dj) reconnectTracker(name string) func(ComponentStatus, error) {
+	track := dj.pipeline.tracker(name)
+	restarting := false
+	return func(status ComponentStatus, err error) {
+		track(status, err)
+		if status == ComponentRunning && restarting {
+			dj.publish(Event{Type: EventStreamReconnected, Component: name})
+		}
+		restarting = status == ComponentRestarting
+	}
+}
+
+// stateEvents describe the state of the Dj, the latest event of each of these types is always replayed.
+var stateEvents = map[EventType]bool{
+	EventSongStarted:      true,
+	EventSongEnded:        true,
+	EventQueueChanged:     true,
+	EventUpNext:           true,
+	EventBreakStarted:     true,
+	EventBreakEnded:       true,
+	EventShowStarted:      true,
+	EventShowEnded:        true,
+	EventCircuitOpened:    true,
+	EventCircuitClosed:    true,
+	EventListenersChanged: true,
+	EventCalendarChanged:  true,
+	EventYtdlpOutdated:    true,
+	EventYtdlpUpdated:     true,
+}
+
+// trim drops old events from the replay buffer. The latest event of every state type is kept,
+// of the others only the most recent ones fit into the replay size.
+func (b *eventBus) trim() {
+	size := b.replaySize
+	if !b.replaySet {
+		size = defaultEventReplay
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	latest := make(map[EventType]bool)
+	kept, others := len(b.replay), 0
+	for i := len(b.replay) - 1; i >= 0; i-- {
+		event := b.replay[i]
+		if stateEvents[event.Type] && !latest[event.Type] {
+			latest[event.Type] = true
+		} else if others < size {
+			others++
+		} else {
+			continue
+		}
+		kept--
+		b.replay[kept] = event
+	}
+	for i := range b.replay[:kept] {
+		b.replay[i] = Event{}
+	}
+	b.replay = append(b.replay[:0], b.replay[kept:]...)
+}
+
+// reportError passes err to the error handler, publishes it and announces it.
+func (dj *Dj) reportError(err error) {
+	dj.notifyError(err)
+	dj.announceError(err)
+}
+
+// notifyError passes err to the error handler and publishes it.
+func (dj *Dj) notifyError(err error) {
+	if handler := dj.handlers.load().errorHander; handler != nil {
+		dj.callHandler(EventError, func() { handler(err) })
+	}
+	dj.publish(Event{Type: EventError, Err: err})
+}
+
+// lockQueue locks the queues for a change, unlockQueue publishes an event if anything changed.
+func (dj *Dj) lockQueue() {
+	dj.waitingQueue.Lock()
+	dj.lockedVersion = dj.queuesVersion()
+}
+
+func (dj *Dj) unlockQueue() {
+	changed := dj.queuesVersion() != dj.lockedVersion
+	version := dj.waitingQueue.version
+	dj.waitingQueue.Unlock()
+
+	if changed {
+		dj.publish(Event{Type: EventQueueChanged, QueueVersion: version})
+	}
+}
+
+// queuesVersion returns the sum of the versions of all queues, the lock has to be held.
+func (dj *Dj) queuesVersion() uint64 {
+	version := dj.waitingQueue.version
+	for _, named := range dj.queues {
+		version += named.queue.version
+	}
+	return version
+}
+
+// The concrete events returned by Event.Typed.
+type (
+	// SongStarted is published when an entry starts playing.
+	SongStarted struct {
+		Seq   uint64
+		Time  time.Time
+		Entry QueueEntry
+	}
+	// SongEnded is published when an entry stopped playing, Err is set if playback failed.
+	SongEnded struct {
+		Seq   uint64
+		Time  time.Time
+		Entry QueueEntry
+		Err   error
+	}
+	// QueueChanged is published whenever the request queue was modified.
+	QueueChanged struct {
+		Seq          uint64
+		Time         time.Time
+		QueueVersion uint64
+	}
+	// StreamError is published for every error reported by the Dj.
+	StreamError struct {
+		Seq  uint64
+		Time time.Time
+		Err  error
+	}
+	// StreamReconnected is published when an output is running again after it failed.
+	StreamReconnected struct {
+		Seq       uint64
+		Time      time.Time
+		Component string
+	}
+)
+
+// Typed returns the concrete event for e, e.g. SongStarted for EventSongStarted,
+// so subscribers can use a type switch instead of looking at the fields that apply to e.Type.
+// Events without a concrete type are returned as is.
+func (e Event) Typed() any {
+	switch e.Type {
+	case EventSongStarted:
+		return SongStarted{Seq: e.Seq, Time: e.Time, Entry: e.Entry}
+	case EventSongEnded:
+		return SongEnded{Seq: e.Seq, Time: e.Time, Entry: e.Entry, Err: e.Err}
+	case EventQueueChanged:
+		return QueueChanged{Seq: e.Seq, Time: e.Time, QueueVersion: e.QueueVersion}
+	case EventError:
+		return StreamError{Seq: e.Seq, Time: e.Time, Err: e.Err}
+	case EventStreamReconnected:
+		return StreamReconnected{Seq: e.Seq, Time: e.Time, Component: e.Component}
+	}
+	return e
+}
@@ -0,0 +1,41 @@
+package opendj
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplayKeepsStateEvents(t *testing.T) {
+	dj := newTestDj(t, nil)
+	dj.SetEventReplay(3)
+
+	dj.publish(Event{Type: EventShowStarted, Show: Show{Name: "morning show"}})
+	dj.publish(Event{Type: EventSongStarted, Entry: QueueEntry{ID: 1}})
+	dj.publish(Event{Type: EventSongStarted, Entry: QueueEntry{ID: 2}})
+	for i := 0; i < 50; i++ {
+		dj.publish(Event{Type: EventError, Err: errors.New("resolution failed")})
+	}
+
+	events, cancel := dj.Subscribe(0)
+	defer cancel()
+	var replayed []Event
+	for len(events) > 0 {
+		replayed = append(replayed, <-events)
+	}
+
+	want := []EventType{EventShowStarted, EventSongStarted, EventError, EventError, EventError}
+	if len(replayed) != len(want) {
+		t.Fatalf("replayed %d events, want %d", len(replayed), len(want))
+	}
+	for i, event := range replayed {
+		if event.Type != want[i] {
+			t.Errorf("event %d is %s, want %s", i, event.Type, want[i])
+		}
+		if i > 0 && event.Seq <= replayed[i-1].Seq {
+			t.Errorf("event %d has seq %d after %d", i, event.Seq, replayed[i-1].Seq)
+		}
+	}
+	if replayed[1].Entry.ID != 2 {
+		t.Errorf("replayed song %d, want the latest song 2", replayed[1].Entry.ID)
+	}
+}
@@ -0,0 +1,55 @@
+package opendj
+
+import "time"
+
+// EventEntryExpired is published for every entry that was dropped from a queue by the expiry.
+const EventEntryExpired EventType = "entryExpired"
+
+// SetEntryExpiry drops entries that waited longer than maxWait or whose owner left,
+// so overnight queues don't play requests for people long gone.
+//
+// ownerLeft is consulted for every waiting entry before the next entry is picked, it may be nil
+// and must not block. A maxWait <= 0 disables the time limit.
+// Dropped entries are passed to the expiry handler and published as EventEntryExpired.
+func (dj *Dj) SetEntryExpiry(maxWait time.Duration, ownerLeft func(QueueEntry) bool) {
+	dj.updateConfig(func(c *Config) {
+		c.MaxWait = maxWait
+		c.OwnerLeft = ownerLeft
+	})
+}
+
+// AddExpiryHandler adds a function that will be called every time an entry expires.
+func (dj *Dj) AddExpiryHandler(f func(QueueEntry)) {
+	dj.handlers.update(func(h *handlerSet) { h.expiryHandler = f })
+}
+
+// expire removes all expired entries from the queues and returns them, the lock has to be held.
+func (dj *Dj) expire(now time.Time) []QueueEntry {
+	cfg := dj.cfg()
+	if cfg.MaxWait <= 0 && cfg.OwnerLeft == nil {
+		return nil
+	}
+
+	var expired []QueueEntry
+	for _, q := range dj.drainOrder() {
+		for i := 0; i < q.len(); i++ {
+			entry := q.at(i)
+			if (cfg.MaxWait > 0 && now.Sub(entry.RequestedAt) > cfg.MaxWait) || (cfg.OwnerLeft != nil && cfg.OwnerLeft(entry)) {
+				expired = append(expired, q.remove(i))
+				i--
+			}
+		}
+	}
+	return expired
+}
+
+// notifyExpired calls the expiry handler and publishes an event for every entry.
+func (dj *Dj) notifyExpired(expired []QueueEntry) {
+	for _, entry := range expired {
+		if handler := dj.handlers.load().expiryHandler; handler != nil {
+			entry := entry
+			dj.callHandler(EventEntryExpired, func() { handler(entry) })
+		}
+		dj.publish(Event{Type: EventEntryExpired, Entry: entry})
+	}
+}
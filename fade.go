@@ -0,0 +1,130 @@
+package opendj
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// pcmBytesPerSecond is the data rate of the raw PCM faded tracks are decoded to, 44.1kHz 16 bit stereo.
+const pcmBytesPerSecond = 44100 * 2 * 2
+
+// pcmInputArgs are the ffmpeg input options for the raw PCM written by decodePCM.
+var pcmInputArgs = []string{"-f", "s16le", "-ar", "44100", "-ac", "2", "-i", "pipe:0"}
+
+// SetSkipFade fades tracks out over d when they are skipped or cut off by the end of their clip,
+// instead of cutting the audio.
+//
+// Fading requires decoding every track, so tracks are always transcoded while it is enabled.
+// Skipping a track that is already fading out cuts it right away. d <= 0 disables fading.
+func (dj *Dj) SetSkipFade(d time.Duration) {
+	dj.updateConfig(func(c *Config) { c.SkipFade = d })
+}
+
+// clipFade returns the filter fading out the end of a clip that cuts off the media, if any.
+func (e QueueEntry) clipFade(d time.Duration) string {
+	if d <= 0 || e.Clip.End <= 0 || (e.Media.Duration > 0 && e.Clip.End >= e.Media.Duration) {
+		return ""
+	}
+	clip := e.Clip.duration(e.Clip.End)
+	if d > clip {
+		d = clip
+	}
+	return "afade=t=out:st=" + ffmpegDuration(clip-d) + ":d=" + ffmpegDuration(d)
+}
+
+// decodePCM decodes the input given by args to raw PCM that can be faded out.
+// The decoder is stopped once the returned fader is closed.
+func decodePCM(ctx context.Context, input io.Reader, fade time.Duration, args ...string) (*fader, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := newCommand(ctx, "ffmpeg", append(args, "-f", "s16le", "-ar", "44100", "-ac", "2", "pipe:1")...)
+	cmd.Stdin = input
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &fader{
+		ReadCloser: &commandReader{ReadCloser: stdout, cmd: cmd, cancel: cancel},
+		length:     int(fade.Seconds() * pcmBytesPerSecond),
+	}, nil
+}
+
+// fader passes raw PCM through until start is called,
+// then it lowers the volume to zero over its length and ends the stream.
+type fader struct {
+	io.ReadCloser
+
+	mu     sync.Mutex
+	fading bool
+	length int
+	left   int
+	// odd holds the first byte of a sample that was split between two reads, only Read uses it
+	odd []byte
+}
+
+// start starts fading out, it returns false if the fader is already fading.
+func (f *fader) start() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fading {
+		return false
+	}
+	f.fading, f.left = true, f.length
+	return true
+}
+
+// faded reports whether the stream was ended by a fade.
+func (f *fader) faded() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fading
+}
+
+func (f *fader) Read(p []byte) (int, error) {
+	if len(p) < 2 {
+		return f.ReadCloser.Read(p)
+	}
+	f.mu.Lock()
+	done := f.fading && f.left <= 0
+	f.mu.Unlock()
+	if done {
+		return 0, io.EOF
+	}
+
+	// the lock is not held while reading, so start doesn't block while the input stalls
+	carried := copy(p, f.odd)
+	f.odd = f.odd[:0]
+	n, err := f.ReadCloser.Read(p[carried:])
+	n += carried
+
+	if n%2 == 1 && err == nil {
+		f.odd = append(f.odd, p[n-1])
+		n--
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.fading {
+		return n, err
+	}
+	if n > f.left {
+		n = f.left
+	}
+	for i := 0; i+1 < n; i += 2 {
+		gain := float64(f.left-i) / float64(f.length)
+		sample := int16(uint16(p[i]) | uint16(p[i+1])<<8)
+		sample = int16(float64(sample) * gain)
+		p[i], p[i+1] = byte(sample), byte(uint16(sample)>>8)
+	}
+	f.left -= n
+	if f.left <= 0 {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,201 @@
+package opendj
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoMuchForSubtlety/opendj/rtmp"
+)
+
+const (
+	// maxConsecutiveFailures is how often streaming to an endpoint may fail in a row before failing over.
+	maxConsecutiveFailures = 3
+	// stableStreamDuration is how long a stream has to run to reset the failure counter.
+	stableStreamDuration = time.Minute
+	restartDelay         = 2 * time.Second
+	failbackInterval     = 30 * time.Second
+	// failbackChecks is how many checks in a row the primary server has to be reachable before failing back.
+	failbackChecks = 3
+	// maxFailbackInterval limits the backoff of the checks while the primary server keeps failing after failbacks.
+	maxFailbackInterval = 10 * time.Minute
+)
+
+// AddFailoverHandler adds a function that will be called every time the stream started by Play
+// switches from one RTMP endpoint to another, either because the current one kept failing
+// or because the primary one became reachable again.
+func (dj *Dj) AddFailoverHandler(f func(from, to string)) {
+	dj.handlers.update(func(h *handlerSet) { h.failoverHandler = f })
+}
+
+// RTMPOutput streams to an RTMP server.
+//
+// If the server keeps failing the stream fails over to the Failover servers in order,
+// switching back to URL once it was reachable for a while. If it fails again before streaming
+// stably, it has to be reachable for longer before the next switch back.
+//
+// The stream is published with ffmpeg, or in Go like NativeRTMPOutput if Native is set
+// or ffmpeg was built without RTMP support.
+type RTMPOutput struct {
+	URL      string
+	Failover []string
+	// OnFailover gets called every time the output switches servers, it may be nil.
+	OnFailover func(from, to string)
+	// Native publishes in Go instead of running ffmpeg.
+	Native bool
+}
+
+var (
+	ffmpegRTMPOnce sync.Once
+	ffmpegRTMP     bool
+)
+
+// ffmpegSupportsRTMP reports whether ffmpeg can publish to RTMP servers, the result is cached.
+func ffmpegSupportsRTMP() bool {
+	ffmpegRTMPOnce.Do(func() {
+		output, err := exec.Command("ffmpeg", "-hide_banner", "-protocols").Output()
+		if err != nil {
+			return
+		}
+		_, outputs, _ := strings.Cut(string(output), "Output:")
+		for _, protocol := range strings.Fields(outputs) {
+			if protocol == "rtmp" {
+				ffmpegRTMP = true
+				return
+			}
+		}
+	})
+	return ffmpegRTMP
+}
+
+// publish streams to a single server.
+func (o RTMPOutput) publish(ctx context.Context, server string, stream io.Reader) error {
+	if o.Native || !ffmpegSupportsRTMP() {
+		return rtmp.Publish(ctx, server, stream)
+	}
+	cmd := newCommand(
+		ctx,
+		"ffmpeg",
+		"-re",
+		"-i", "pipe:0",
+		"-c", "copy",
+		"-f", "flv",
+		server,
+	)
+	cmd.Stdin = stream
+	return cmd.Run()
+}
+
+// Start streams to the RTMP servers until the stream ends or all servers failed.
+func (o RTMPOutput) Start(ctx context.Context, stream io.Reader) error {
+	servers := append([]string{o.URL}, o.Failover...)
+
+	current, failures := 0, 0
+	// interval is how often the primary server is checked, failedBack is set until it streamed stably again
+	interval, failedBack := failbackInterval, false
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		failback := make(chan struct{})
+		if current != 0 {
+			go watchPrimary(runCtx, servers[0], interval, failback, cancel)
+		}
+
+		started := time.Now()
+		err := o.publish(runCtx, servers[current], stream)
+		cancel()
+
+		select {
+		case <-failback:
+			o.switchServer(servers[current], servers[0])
+			current, failures, failedBack = 0, 0, true
+			continue
+		default:
+		}
+
+		if err == nil {
+			// the stream ended
+			return nil
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if time.Since(started) > stableStreamDuration {
+			failures = 0
+			if current == 0 {
+				interval, failedBack = failbackInterval, false
+			}
+		}
+		failures++
+		if failures >= maxConsecutiveFailures {
+			if current == len(servers)-1 {
+				return fmt.Errorf("failed to stream to %s: %w", servers[current], err)
+			}
+			if current == 0 && failedBack {
+				// the primary server is flapping, back off so the stream does not keep switching
+				if interval *= 2; interval > maxFailbackInterval {
+					interval = maxFailbackInterval
+				}
+			}
+			o.switchServer(servers[current], servers[current+1])
+			current++
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+func (o RTMPOutput) switchServer(from, to string) {
+	if o.OnFailover != nil {
+		o.OnFailover(from, to)
+	}
+}
+
+// watchPrimary checks the primary server every interval, it closes reachable and cancels the running
+// stream once the server accepted connections failbackChecks times in a row.
+func watchPrimary(ctx context.Context, primary string, interval time.Duration, reachable chan<- struct{}, cancel func()) {
+	u, err := url.Parse(primary)
+	if err != nil {
+		return
+	}
+	addr := u.Host
+	if u.Port() == "" && u.Scheme == "rtmps" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	} else if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "1935")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	checks := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		if err != nil {
+			checks = 0
+			continue
+		}
+		conn.Close()
+		if checks++; checks < failbackChecks {
+			continue
+		}
+		close(reachable)
+		cancel()
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFailoverCancelledWhileWaiting checks that a cancelled output returns without
+// waiting for the restart delay.
+func TestFailoverCancelledWhileWaiting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing listens there anymore, so every attempt fails right away
+	addr := listener.Addr().String()
+	listener.Close()
+
+	output := RTMPOutput{URL: "rtmp://" + addr + "/live/key", Native: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- output.Start(ctx, strings.NewReader("")) }()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Start() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(restartDelay / 2):
+		t.Fatal("Start() waited for the restart delay after it was cancelled")
+	}
+}
+
+// TestFailbackNeedsStablePrimary checks that a single successful check is not enough to fail back.
+func TestFailbackNeedsStablePrimary(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan struct{}, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+			accepted <- struct{}{}
+		}
+	}()
+
+	reachable := make(chan struct{})
+	cancelled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchPrimary(ctx, "rtmp://"+listener.Addr().String()+"/live/key", 10*time.Millisecond, reachable, func() { close(cancelled) })
+
+	select {
+	case <-reachable:
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not fail back to a reachable primary server")
+	}
+	// every check before the failback connected
+	for i := 0; i < failbackChecks; i++ {
+		select {
+		case <-accepted:
+		case <-time.After(time.Second):
+			t.Fatalf("failed back after %d checks, want %d", i, failbackChecks)
+		}
+	}
+	<-cancelled
+}
@@ -0,0 +1,72 @@
+package opendj
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EventIdleFiller is published when a file of the idle filler directory starts playing,
+// Entry.Media holds its title and path, so overlays can show an intermission.
+const EventIdleFiller EventType = "idleFiller"
+
+// SetIdleFiller plays random files from dir while nothing is queued instead of silence.
+//
+// A new file is picked for every idle chunk, files shorter than a chunk are looped.
+// The title is the file name without its extension. An empty dir plays silence.
+// returns an error if dir is not a directory.
+func (dj *Dj) SetIdleFiller(dir string) error {
+	return dj.updateConfig(func(c *Config) { c.IdleFillerDir = dir })
+}
+
+// writeIdle writes d of the idle filler into the stream, silence if there is none.
+func (dj *Dj) writeIdle(ctx context.Context, stream io.Writer, d time.Duration) error {
+	file, ok := pickFiller(dj.cfg().IdleFillerDir)
+	if !ok {
+		return writeSilence(ctx, stream, d)
+	}
+
+	name := filepath.Base(file)
+	dj.publish(Event{Type: EventIdleFiller, Entry: QueueEntry{Media: Media{
+		Title:    strings.TrimSuffix(name, filepath.Ext(name)),
+		URL:      file,
+		Duration: d,
+	}}})
+	fade := ffmpegDuration(time.Second)
+	return writeStream(
+		ctx,
+		stream,
+		nil,
+		nil,
+		"-re",
+		"-stream_loop", "-1",
+		"-i", file,
+		"-af", "afade=t=in:d="+fade+",afade=t=out:st="+ffmpegDuration(d-time.Second)+":d="+fade,
+		"-t", ffmpegDuration(d),
+	)
+}
+
+// pickFiller returns a random file from dir, hidden files are ignored.
+func pickFiller(dir string) (string, bool) {
+	if dir == "" {
+		return "", false
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return "", false
+	}
+	return files[rand.Intn(len(files))], true
+}
@@ -0,0 +1,91 @@
+package opendj
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContentFilter decides which entries are allowed to enter the queue.
+//
+// The zero value allows everything.
+type ContentFilter struct {
+	// BlockedKeywords are matched case-insensitively against the media title.
+	BlockedKeywords []string
+	// AllowedCategories restricts entries to the given categories.
+	// If it is empty the category is not checked.
+	AllowedCategories []string
+	// Category looks up the category of the given media, e.g. via the YouTube API.
+	// It is only called if AllowedCategories is not empty.
+	Category func(Media) (string, error)
+}
+
+// FilterError is returned when an entry is rejected by the content filter.
+type FilterError struct {
+	Entry QueueEntry
+	// Keyword is the blocked keyword found in the title, if any.
+	Keyword string
+	// Category is the category that is not allowed, if any.
+	Category string
+}
+
+func (e *FilterError) Error() string {
+	if e.Keyword != "" {
+		return fmt.Sprintf("%q contains blocked keyword %q", e.Entry.Media.Title, e.Keyword)
+	}
+	return fmt.Sprintf("%q is in category %q which is not allowed", e.Entry.Media.Title, e.Category)
+}
+
+// SetContentFilter sets the filter that every new entry is checked against.
+func (dj *Dj) SetContentFilter(filter ContentFilter) {
+	dj.updateConfig(func(c *Config) { c.Filter = filter })
+}
+
+// SetAdmissionHook sets a function that is called for every new entry after the content filter.
+//
+// It can implement arbitrary synchronous policies like profanity lists or external moderation APIs.
+// If it returns an error the entry is rejected and the error is returned to the caller unchanged,
+// so it can carry a reason that is shown to the requester, see WithReason for a reason code.
+// A nil hook admits everything.
+func (dj *Dj) SetAdmissionHook(hook func(QueueEntry) error) {
+	dj.updateConfig(func(c *Config) { c.AdmissionHook = hook })
+}
+
+// admit checks the clip of the entry and the entry against the content filter and the admission hook.
+func (dj *Dj) admit(entry QueueEntry) error {
+	if err := entry.Clip.validate(); err != nil {
+		return err
+	}
+	cfg := dj.cfg()
+	if err := cfg.Filter.check(entry); err != nil {
+		return err
+	}
+	if cfg.AdmissionHook != nil {
+		return cfg.AdmissionHook(entry)
+	}
+	return nil
+}
+
+// check returns a *FilterError if the entry is not allowed by the filter.
+func (f ContentFilter) check(entry QueueEntry) error {
+	title := strings.ToLower(entry.Media.Title)
+	for _, keyword := range f.BlockedKeywords {
+		if keyword != "" && strings.Contains(title, strings.ToLower(keyword)) {
+			return &FilterError{Entry: entry, Keyword: keyword}
+		}
+	}
+
+	if len(f.AllowedCategories) == 0 || f.Category == nil {
+		return nil
+	}
+
+	category, err := f.Category(entry.Media)
+	if err != nil {
+		return fmt.Errorf("failed to look up category: %w", err)
+	}
+	for _, allowed := range f.AllowedCategories {
+		if strings.EqualFold(category, allowed) {
+			return nil
+		}
+	}
+	return &FilterError{Entry: entry, Category: category}
+}
@@ -0,0 +1,135 @@
+package opendj
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// A GainPeriod changes the volume during a time of day, e.g. -6dB between 00:00 and 08:00.
+//
+// Start and End are wall clock times of day in the location set with SetLocation,
+// if End is before Start the period wraps around midnight.
+type GainPeriod struct {
+	Start time.Duration
+	End   time.Duration
+	// Gain is the volume change in dB.
+	Gain float64
+}
+
+// contains reports whether the time of day d is within the period.
+func (p GainPeriod) contains(d time.Duration) bool {
+	if p.Start <= p.End {
+		return d >= p.Start && d < p.End
+	}
+	return d >= p.Start || d < p.End
+}
+
+// SetGainSchedule sets the periods in which the volume is changed, for channels that play 24/7.
+//
+// At the start and end of a period the volume is ramped over ramp instead of jumping.
+// If periods overlap the first one applies. Passing no periods disables the schedule.
+// The gain is applied through the filter chain, so tracks are transcoded while a schedule is set.
+// returns an error if a period is not within a day or ramp is negative.
+func (dj *Dj) SetGainSchedule(periods []GainPeriod, ramp time.Duration) error {
+	return dj.updateConfig(func(c *Config) {
+		c.GainSchedule = append([]GainPeriod(nil), periods...)
+		c.GainRamp = ramp
+	})
+}
+
+// stepGain returns the gain at t without ramping.
+func (c *Config) stepGain(t time.Time) float64 {
+	d := timeOfDay(t)
+	for _, p := range c.GainSchedule {
+		if p.contains(d) {
+			return p.Gain
+		}
+	}
+	return 0
+}
+
+// gainAt returns the gain at t including ramps.
+func (c *Config) gainAt(t time.Time) float64 {
+	if c.GainRamp > 0 {
+		if b, ok := c.lastBoundary(t); ok && t.Sub(b) < c.GainRamp {
+			from, to := c.stepGain(b.Add(-time.Nanosecond)), c.stepGain(b)
+			return from + (to-from)*float64(t.Sub(b))/float64(c.GainRamp)
+		}
+	}
+	return c.stepGain(t)
+}
+
+// lastBoundary returns the latest start or end of a period within the ramp before t.
+func (c *Config) lastBoundary(t time.Time) (time.Time, bool) {
+	var last time.Time
+	found := false
+	for _, b := range c.boundaries(t.Add(-c.GainRamp), t) {
+		if !found || b.After(last) {
+			last, found = b, true
+		}
+	}
+	return last, found
+}
+
+// boundaries returns all starts and ends of periods in (from, to].
+func (c *Config) boundaries(from, to time.Time) []time.Time {
+	var result []time.Time
+	// the range is short, checking the surrounding days is enough
+	year, month, day := from.Date()
+	for offset := -1; offset <= 1; offset++ {
+		for _, p := range c.GainSchedule {
+			for _, d := range []time.Duration{p.Start, p.End} {
+				if b := wallClockTime(year, month, day+offset, d, from.Location()); b.After(from) && !b.After(to) {
+					result = append(result, b)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// gainFilter returns a volume filter for a track that starts at start and plays for d,
+// or an empty string if the volume does not change.
+func (c *Config) gainFilter(start time.Time, d time.Duration) string {
+	if len(c.GainSchedule) == 0 {
+		return ""
+	}
+	start = start.In(c.location())
+
+	// the volume is interpolated linearly between (fromT, fromGain) and (toT, toGain), in seconds of the track
+	fromT, fromGain := 0.0, c.gainAt(start)
+	toT, toGain := 0.0, fromGain
+	if b, ok := c.lastBoundary(start); ok && c.GainRamp > 0 && start.Sub(b) < c.GainRamp {
+		// a ramp is in progress
+		toT, toGain = b.Add(c.GainRamp).Sub(start).Seconds(), c.stepGain(b)
+	} else if next := c.boundaries(start, start.Add(d)); len(next) > 0 {
+		b := next[0]
+		for _, other := range next[1:] {
+			if other.Before(b) {
+				b = other
+			}
+		}
+		fromT, toT, toGain = b.Sub(start).Seconds(), b.Add(c.GainRamp).Sub(start).Seconds(), c.stepGain(b)
+	}
+
+	if fromGain == toGain {
+		if fromGain == 0 {
+			return ""
+		}
+		return "volume=" + formatGain(fromGain) + "dB"
+	}
+	if toT <= fromT {
+		toT = fromT + 0.001
+	}
+	return fmt.Sprintf("volume=eval=frame:volume='pow(10,(%s+(%s)*clip((t-%s)/%s,0,1))/20)'",
+		formatGain(fromGain),
+		formatGain(toGain-fromGain),
+		strconv.FormatFloat(fromT, 'f', 3, 64),
+		strconv.FormatFloat(toT-fromT, 'f', 3, 64),
+	)
+}
+
+func formatGain(g float64) string {
+	return strconv.FormatFloat(g, 'f', 2, 64)
+}
@@ -0,0 +1,46 @@
+package opendj
+
+import "time"
+
+// defaultGap is the silence between two tracks if nothing else is configured.
+const defaultGap = 5 * time.Second
+
+// SetGap sets the silence between two tracks, 0 plays tracks back to back.
+// Negative durations are treated as 0, tracks can't overlap.
+//
+// It defaults to 5 seconds.
+func (dj *Dj) SetGap(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	dj.updateConfig(func(c *Config) { c.Gap = &d })
+}
+
+// gap returns the configured silence between two tracks.
+func (c *Config) gap() time.Duration {
+	if c.Gap == nil {
+		return defaultGap
+	}
+	return *c.Gap
+}
+
+// overhead returns the time played around every entry on top of its duration, the gap and the pre-roll.
+func (c *Config) overhead() time.Duration {
+	return c.gap() + c.preRoll()
+}
+
+// preRoll returns the delay before every track.
+func (c *Config) preRoll() time.Duration {
+	if c.PreRoll < 0 {
+		return 0
+	}
+	return c.PreRoll
+}
+
+// padFilter returns the filter that appends the gap to a track.
+func (c *Config) padFilter() string {
+	if c.gap() <= 0 {
+		return "anull"
+	}
+	return "apad=pad_dur=" + ffmpegDuration(c.gap())
+}
@@ -1,5 +1,5 @@
 module github.com/SoMuchForSubtlety/opendj
 
-go 1.19
+go 1.20
 
 require golang.org/x/sync v0.6.0
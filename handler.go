@@ -0,0 +1,82 @@
+package opendj
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrorHandlerPanic is reported when a handler panicked, the handler is not removed.
+	ErrorHandlerPanic = errors.New("handler panicked")
+	// ErrorHandlerTimeout is reported when a handler did not return within the timeout.
+	ErrorHandlerTimeout = errors.New("handler timed out")
+)
+
+// SetAsyncHandlers decides whether handlers are called in their own goroutine.
+//
+// By default handlers are called synchronously, playback waits for them and they are called
+// in the order the events happened. Asynchronous handlers don't hold up playback,
+// but may run concurrently and out of order.
+//
+// Handlers can be added or replaced at any time, also while playing. A replaced handler
+// is not called for events after the AddXHandler call returned, but may still be running
+// for an earlier event.
+func (dj *Dj) SetAsyncHandlers(async bool) {
+	dj.updateConfig(func(c *Config) { c.AsyncHandlers = async })
+}
+
+// SetHandlerTimeout sets how long a synchronous handler may run before playback continues without it,
+// e.g. if a handler hangs on an HTTP request. The handler keeps running in the background
+// and the timeout is reported as an error wrapping ErrorHandlerTimeout. <= 0 waits forever.
+func (dj *Dj) SetHandlerTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	dj.updateConfig(func(c *Config) { c.HandlerTimeout = d })
+}
+
+// callHandler calls f, a handler for an event of type t, if the rate limit of t allows it.
+//
+// A panic in f is reported as an error wrapping ErrorHandlerPanic instead of crashing the Dj.
+func (dj *Dj) callHandler(t EventType, f func()) {
+	if !dj.allowHandler(t) {
+		return
+	}
+
+	report := func(err error) {
+		if t == EventError {
+			// the error handler itself failed, don't call it again
+			dj.publish(Event{Type: EventError, Err: err})
+			return
+		}
+		dj.notifyError(err)
+	}
+	done := make(chan struct{})
+	run := func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				report(fmt.Errorf("%s %w: %v", t, ErrorHandlerPanic, r))
+			}
+		}()
+		f()
+	}
+
+	cfg := dj.cfg()
+	switch {
+	case cfg.AsyncHandlers:
+		go run()
+	case cfg.HandlerTimeout > 0:
+		go run()
+		timer := time.NewTimer(cfg.HandlerTimeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			report(fmt.Errorf("%s %w after %s", t, ErrorHandlerTimeout, cfg.HandlerTimeout))
+		}
+	default:
+		run()
+	}
+}
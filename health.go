@@ -0,0 +1,113 @@
+package opendj
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// maxOutputSilence is how long the stream may not be written to before playback is considered stuck.
+const maxOutputSilence = 30 * time.Second
+
+// health tracks the liveness of the playback.
+type health struct {
+	sync.Mutex
+	playing   bool
+	lastWrite time.Time
+	outputs   int
+}
+
+// Healthy returns an error if the Dj is not able to play anything right now.
+//
+// It checks that yt-dlp and ffmpeg are executable, that playback is running,
+// at least one output is alive and the stream was written to recently.
+// The stream is not written to while paused, so the last check is skipped during a pause
+// and starts over when playback is resumed.
+// It is meant to be used for liveness probes.
+func (dj *Dj) Healthy() error {
+	for _, dependency := range []string{"yt-dlp", "ffmpeg"} {
+		if _, err := exec.LookPath(dependency); err != nil {
+			return fmt.Errorf("dependency %s is not executable: %w", dependency, err)
+		}
+	}
+
+	dj.clock.Lock()
+	paused := dj.clock.resumed != nil
+	dj.clock.Unlock()
+
+	dj.health.Lock()
+	defer dj.health.Unlock()
+	if !dj.health.playing {
+		return errors.New("playback is not running")
+	} else if dj.health.outputs == 0 {
+		return errors.New("no output is running")
+	} else if since := dj.now().Sub(dj.health.lastWrite); since > maxOutputSilence && !paused {
+		return fmt.Errorf("nothing was streamed for %s", since.Round(time.Second))
+	}
+	return nil
+}
+
+// HealthHandler returns a http.Handler that responds with 200 if the Dj is healthy
+// and 503 with the reason otherwise, e.g. to be served as /healthz.
+func (dj *Dj) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := dj.Healthy(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+func (h *health) started(now time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	h.playing = true
+	h.outputs = 0
+	h.lastWrite = now
+}
+
+func (h *health) stopped() {
+	h.Lock()
+	defer h.Unlock()
+	h.playing = false
+}
+
+func (h *health) outputAdded() {
+	h.Lock()
+	defer h.Unlock()
+	h.outputs++
+}
+
+func (h *health) outputStopped() {
+	h.Lock()
+	defer h.Unlock()
+	h.outputs--
+}
+
+func (h *health) wrote(now time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	h.lastWrite = now
+}
+
+// healthWriter records every write to the stream.
+type healthWriter struct {
+	w      io.Writer
+	health *health
+	now    func() time.Time
+}
+
+func (hw healthWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	if n > 0 {
+		hw.health.wrote(hw.now())
+	}
+	return n, err
+}
@@ -0,0 +1,59 @@
+package opendj
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestHealthyWhilePaused(t *testing.T) {
+	dj := newTestDj(t, nil)
+	dj.health.started(dj.now())
+	dj.health.outputAdded()
+	dj.clock.start(dj.now(), 0)
+	silent := func() {
+		dj.health.Lock()
+		dj.health.lastWrite = dj.now().Add(-2 * maxOutputSilence)
+		dj.health.Unlock()
+	}
+
+	silent()
+	if err := dj.Healthy(); err == nil {
+		t.Error("Healthy() = nil after a long silence")
+	}
+	if err := dj.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dj.Healthy(); err != nil {
+		t.Errorf("Healthy() while paused = %v", err)
+	}
+	if err := dj.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dj.Healthy(); err != nil {
+		t.Errorf("Healthy() right after resuming = %v", err)
+	}
+	silent()
+	if err := dj.Healthy(); err == nil {
+		t.Error("Healthy() = nil after a long silence since resuming")
+	}
+}
+
+func TestHealthyUsesClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)}
+	dj := newTestDj(t, nil, WithClock(clock))
+	dj.health.started(dj.now())
+	dj.health.outputAdded()
+
+	if err := dj.Healthy(); err != nil {
+		t.Fatalf("Healthy() right after starting = %v", err)
+	}
+	clock.advance(2 * maxOutputSilence)
+	if err := dj.Healthy(); err == nil {
+		t.Error("Healthy() = nil after a long silence on the clock")
+	}
+	healthWriter{w: io.Discard, health: &dj.health, now: dj.now}.Write([]byte{0})
+	if err := dj.Healthy(); err != nil {
+		t.Errorf("Healthy() after a write = %v", err)
+	}
+}
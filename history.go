@@ -0,0 +1,163 @@
+package opendj
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A HistoryEntry is a QueueEntry that was played during the current session.
+type HistoryEntry struct {
+	Entry QueueEntry
+	// Started is the time the entry started playing.
+	Started time.Time
+	// Offset is the position in the broadcast the entry started playing at.
+	Offset time.Duration
+	// Duration is how long the entry was played, it is zero while it is still playing.
+	Duration time.Duration
+	// Err is the error playback ended with, if any.
+	Err error
+}
+
+type history struct {
+	sync.Mutex
+	sessionStart time.Time
+	entries      []HistoryEntry
+}
+
+// History returns all entries played in the current session, oldest first.
+func (dj *Dj) History() []HistoryEntry {
+	dj.history.Lock()
+	defer dj.history.Unlock()
+
+	entries := make([]HistoryEntry, len(dj.history.entries))
+	copy(entries, dj.history.entries)
+	return entries
+}
+
+// HistoryInto is like History, but copies the entries into buf, reusing its memory, see QueueInto.
+func (dj *Dj) HistoryInto(buf []HistoryEntry) []HistoryEntry {
+	dj.history.Lock()
+	defer dj.history.Unlock()
+	return append(buf[:0], dj.history.entries...)
+}
+
+// startSession resets the history, it gets called when playback starts.
+func (h *history) startSession(start time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	h.sessionStart = start
+	h.entries = nil
+}
+
+// started records that entry started playing.
+func (h *history) started(entry QueueEntry, started time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	h.entries = append(h.entries, HistoryEntry{
+		Entry:   entry,
+		Started: started,
+		Offset:  started.Sub(h.sessionStart),
+	})
+}
+
+// ended records that the last started entry ended.
+func (h *history) ended(ended time.Time, err error) {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.entries) == 0 {
+		return
+	}
+	last := &h.entries[len(h.entries)-1]
+	last.Duration = ended.Sub(last.Started)
+	last.Err = err
+}
+
+type tracklistEntry struct {
+	Track    int           `json:"track"`
+	Title    string        `json:"title"`
+	URL      string        `json:"url"`
+	Owner    string        `json:"owner"`
+	Started  time.Time     `json:"started"`
+	Offset   time.Duration `json:"offset"`
+	Duration time.Duration `json:"duration"`
+}
+
+// WriteTracklistJSON writes the tracklist of the current session as JSON to w.
+//
+// Offsets and durations are encoded as nanoseconds.
+func (dj *Dj) WriteTracklistJSON(w io.Writer) error {
+	var tracks []tracklistEntry
+	for i, played := range dj.History() {
+		tracks = append(tracks, tracklistEntry{
+			Track:    i + 1,
+			Title:    played.Entry.Media.Title,
+			URL:      played.Entry.Media.URL,
+			Owner:    played.Entry.PublicOwner(),
+			Started:  played.Started,
+			Offset:   played.Offset,
+			Duration: played.Duration,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tracks)
+}
+
+// WriteCueSheet writes the tracklist of the current session as a cue sheet to w.
+//
+// file is the name of the recording the cue sheet belongs to, e.g. the VOD file name,
+// its extension decides the file type, see cueFileType. The performer of a track is the
+// artist parsed from its title, or the requester if there is none.
+func (dj *Dj) WriteCueSheet(w io.Writer, file string) error {
+	dj.history.Lock()
+	sessionStart := dj.history.sessionStart
+	dj.history.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "TITLE %s\n", cueQuote("opendj session "+sessionStart.Format(time.RFC3339)))
+	fmt.Fprintf(&b, "FILE %s %s\n", cueQuote(file), cueFileType(file))
+	for i, played := range dj.History() {
+		artist, title := ParseTitle(played.Entry.Media.Title)
+		if artist == "" {
+			artist, title = played.Entry.PublicOwner(), played.Entry.Media.Title
+		}
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE %s\n", cueQuote(title))
+		fmt.Fprintf(&b, "    PERFORMER %s\n", cueQuote(artist))
+		fmt.Fprintf(&b, "    INDEX 01 %s\n", cueTime(played.Offset))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cueFileType returns the cue sheet file type of the recording by its extension.
+//
+// Only WAVE, AIFF and MP3 exist for audio, players decode other formats like the
+// AAC of the archive, see SetArchiveDir, as WAVE.
+func cueFileType(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".mp3":
+		return "MP3"
+	case ".aif", ".aiff":
+		return "AIFF"
+	default:
+		return "WAVE"
+	}
+}
+
+// cueTime formats d as mm:ss:ff with 75 frames per second.
+func cueTime(d time.Duration) string {
+	frames := d * 75 / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", frames/75/60, frames/75%60, frames%75)
+}
+
+func cueQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
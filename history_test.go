@@ -0,0 +1,60 @@
+package opendj
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCueSheet(t *testing.T) {
+	dj := newTestDj(t, nil)
+	start := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+	dj.history.startSession(start)
+	dj.history.started(QueueEntry{Media: Media{Title: "Daft Punk - One More Time (Official Video)"}, Owner: "alice"}, start)
+	dj.history.started(QueueEntry{Media: Media{Title: "Lofi beats"}, Owner: "bob", Anonymous: true}, start.Add(90*time.Second))
+
+	var b bytes.Buffer
+	if err := dj.WriteCueSheet(&b, "session.m4a"); err != nil {
+		t.Fatal(err)
+	}
+	sheet := b.String()
+	for _, want := range []string{
+		`FILE "session.m4a" WAVE`,
+		`TITLE "One More Time"`,
+		`PERFORMER "Daft Punk"`,
+		`TITLE "Lofi beats"`,
+		`PERFORMER "` + anonymousOwner + `"`,
+		`INDEX 01 01:30:00`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("the cue sheet does not contain %s:\n%s", want, sheet)
+		}
+	}
+	if strings.Contains(sheet, "alice") || strings.Contains(sheet, "bob") {
+		t.Errorf("the cue sheet names requesters:\n%s", sheet)
+	}
+
+	b.Reset()
+	if err := dj.WriteTracklistJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "bob") {
+		t.Errorf("the tracklist names an anonymous requester: %s", b.String())
+	}
+}
+
+func TestCueFileType(t *testing.T) {
+	for file, want := range map[string]string{
+		"vod.mp3":  "MP3",
+		"VOD.MP3":  "MP3",
+		"vod.aiff": "AIFF",
+		"vod.wav":  "WAVE",
+		"vod.m4a":  "WAVE",
+		"vod":      "WAVE",
+	} {
+		if got := cueFileType(file); got != want {
+			t.Errorf("cueFileType(%q) = %s, want %s", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package opendj
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A HostRateLimit limits how fast media of a host is resolved.
+type HostRateLimit struct {
+	// PerSecond is the sustained rate of resolutions.
+	PerSecond float64
+	// Burst is how many resolutions may start at once after a quiet period, it is at least 1.
+	Burst int
+}
+
+// tokenBucket implements HostRateLimit, tokens may become negative to queue up waiting callers.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// SetHostRateLimit limits the resolutions of media on host and its subdomains, so batch imports and
+// refreshes don't hammer a site and trigger rate limits or IP bans. It applies in addition to
+// the limits of SetResolverLimits. perSecond <= 0 removes the limit.
+func (dj *Dj) SetHostRateLimit(host string, perSecond float64, burst int) {
+	host = strings.ToLower(host)
+	dj.updateConfig(func(c *Config) {
+		limits := make(map[string]HostRateLimit, len(c.HostRateLimits)+1)
+		for k, v := range c.HostRateLimits {
+			limits[k] = v
+		}
+		if perSecond > 0 {
+			limits[host] = HostRateLimit{PerSecond: perSecond, Burst: burst}
+		} else {
+			delete(limits, host)
+		}
+		c.HostRateLimits = limits
+	})
+}
+
+// waitHost blocks until the rate limit of the host of rawURL allows another resolution.
+func (dj *Dj) waitHost(ctx context.Context, rawURL string) error {
+	limits := dj.cfg().HostRateLimits
+	if len(limits) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	// the limit of a domain applies to its subdomains as well
+	host := strings.ToLower(u.Hostname())
+	var limit HostRateLimit
+	for host != "" {
+		var ok bool
+		if limit, ok = limits[host]; ok {
+			break
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			return nil
+		}
+		host = parent
+	}
+	if host == "" {
+		return nil
+	}
+
+	delay := dj.resolvers.reserve(host, limit, time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// the resolution is not made, so callers queued behind it don't wait for it
+		dj.resolvers.refund(host, limit)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token of the host's bucket and returns how long to wait until it is available.
+func (p *resolverPool) reserve(host string, limit HostRateLimit, now time.Time) time.Duration {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if p.buckets == nil {
+		p.buckets = make(map[string]*tokenBucket)
+	}
+	b, ok := p.buckets[host]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		p.buckets[host] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * limit.PerSecond
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / limit.PerSecond * float64(time.Second))
+}
+
+// refund returns a token reserved with reserve that was not used.
+func (p *resolverPool) refund(host string, limit HostRateLimit) {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if b, ok := p.buckets[host]; ok && b.tokens < burst {
+		b.tokens++
+	}
+}
@@ -0,0 +1,30 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitHostRefundsCancelledWaits(t *testing.T) {
+	dj := newTestDj(t, nil)
+	dj.SetHostRateLimit("example.com", 1, 1)
+
+	if err := dj.waitHost(context.Background(), "https://example.com/1"); err != nil {
+		t.Fatal(err)
+	}
+	// every cancelled wait would otherwise delay the next caller by another second
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		err := dj.waitHost(ctx, "https://music.example.com/2")
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("wait %d = %v, want the deadline to pass", i, err)
+		}
+	}
+
+	if delay := dj.resolvers.reserve("example.com", HostRateLimit{PerSecond: 1, Burst: 1}, time.Now()); delay > time.Second {
+		t.Errorf("next resolution waits %v after cancelled waits, want at most a second", delay)
+	}
+}
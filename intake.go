@@ -0,0 +1,109 @@
+package opendj
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrorQueueFull is returned when an entry is added to a queue that reached its limit.
+var ErrorQueueFull = errors.New("queue is full")
+
+// intakeRetryInterval is how often an Intake retries adding an entry to a full queue.
+const intakeRetryInterval = time.Second
+
+// An Intake feeds entries from a producer (chat bot, HTTP API, scheduler) into the queue.
+//
+// Entries sent to Requests() go through the same admission checks as AddEntry.
+// If the queue is full the Intake stops reading from the channel until there is room again,
+// so producers are slowed down instead of having their entries dropped, see Close.
+type Intake struct {
+	dj       *Dj
+	source   Source
+	requests chan QueueEntry
+	rejected func(QueueEntry, error)
+
+	closeOnce sync.Once
+	// closing is closed by Close, it stops waiting for room in the queue
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// SetQueueLimit sets the maximum amount of entries in the queue.
+//
+// AddEntry and InsertEntry return ErrorQueueFull once the limit is reached.
+// A limit <= 0 means the queue is unlimited.
+func (dj *Dj) SetQueueLimit(limit int) {
+	dj.updateConfig(func(c *Config) { c.QueueLimit = limit })
+}
+
+// NewIntake creates an Intake for the given source.
+//
+// Every entry that is sent through the Intake gets tagged with the source.
+// buffer is the amount of entries that can be sent without blocking.
+// rejected gets called with every entry that was not admitted into the queue, it may be nil.
+func (dj *Dj) NewIntake(source Source, buffer int, rejected func(QueueEntry, error)) *Intake {
+	in := &Intake{
+		dj:       dj,
+		source:   source,
+		requests: make(chan QueueEntry, buffer),
+		rejected: rejected,
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go in.run()
+	return in
+}
+
+// Source returns the source the Intake was created for.
+func (in *Intake) Source() Source {
+	return in.source
+}
+
+// Requests returns the channel new entries can be sent to.
+func (in *Intake) Requests() chan<- QueueEntry {
+	return in.requests
+}
+
+// Close stops the Intake after all buffered entries have been processed.
+//
+// It does not wait for room in a full queue, entries that don't fit anymore are rejected
+// with ErrorQueueFull. Nothing may be sent to Requests() after Close was called.
+func (in *Intake) Close() {
+	in.closeOnce.Do(func() {
+		close(in.closing)
+		close(in.requests)
+	})
+	<-in.done
+}
+
+func (in *Intake) run() {
+	defer close(in.done)
+
+	for entry := range in.requests {
+		entry.Source = in.source
+		entry.assignID(in.dj.ids)
+		err := in.addEntry(entry)
+		in.dj.audit(Actor{Name: entry.Owner, Source: in.source}, AuditAdded, entry, RequestQueue, err)
+
+		if err != nil && in.rejected != nil {
+			in.rejected(entry, err)
+		}
+	}
+}
+
+// addEntry adds the entry, waiting for room in a full queue until the Intake is closed.
+func (in *Intake) addEntry(entry QueueEntry) error {
+	err := in.dj.addEntry(entry)
+	for errors.Is(err, ErrorQueueFull) {
+		timer := time.NewTimer(intakeRetryInterval)
+		select {
+		case <-in.closing:
+			timer.Stop()
+			return err
+		case <-timer.C:
+			err = in.dj.addEntry(entry)
+		}
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package opendj
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIntakeCloseWithFullQueue(t *testing.T) {
+	dj := newTestDj(t, []QueueEntry{{}})
+	dj.SetQueueLimit(1)
+
+	var mu sync.Mutex
+	var rejected []error
+	in := dj.NewIntake(SourceTwitch, 2, func(_ QueueEntry, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		rejected = append(rejected, err)
+	})
+	in.Requests() <- QueueEntry{Owner: "bob"}
+	in.Requests() <- QueueEntry{Owner: "alice"}
+
+	closed := make(chan struct{})
+	go func() {
+		in.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() waits for room in the full queue")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(rejected) != 2 || !errors.Is(rejected[0], ErrorQueueFull) || !errors.Is(rejected[1], ErrorQueueFull) {
+		t.Errorf("rejected with %v, want ErrorQueueFull for both entries", rejected)
+	}
+}
+
+func TestIntakeWaitsForRoom(t *testing.T) {
+	dj := newTestDj(t, []QueueEntry{{}})
+	dj.SetQueueLimit(1)
+	in := dj.NewIntake(SourceTwitch, 1, func(_ QueueEntry, err error) {
+		t.Errorf("entry rejected with %v", err)
+	})
+	in.Requests() <- QueueEntry{Owner: "bob"}
+
+	// make room once the Intake waits
+	time.Sleep(100 * time.Millisecond)
+	if _, err := dj.pop(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(dj.Queue()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	in.Close()
+	if queue := dj.Queue(); len(queue) != 1 || queue[0].Owner != "bob" {
+		t.Errorf("queue = %+v, want the entry of bob", queue)
+	}
+}
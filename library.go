@@ -0,0 +1,372 @@
+package opendj
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// libraryIndexKey is the key of the URLs of all tracks of a Library in its Store,
+// every track is saved under libraryTrackKey.
+const libraryIndexKey = "library/index"
+
+// libraryTrackKey returns the key the track with the given URL is saved under.
+func libraryTrackKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "library/tracks/" + hex.EncodeToString(sum[:])
+}
+
+// A LibraryTrack is a track in a Library.
+type LibraryTrack struct {
+	Media Media
+	// Artist and Title are parsed from the media title with ParseTitle if they are empty.
+	Artist string
+	Title  string
+	Tags   []string
+	// Category is the rotation category of the track, e.g. CategoryHeavy, see SetRotation.
+	Category string
+
+	// Plays and LastPlayed are updated whenever the track is played from the library.
+	Plays      int
+	LastPlayed time.Time
+}
+
+// entry returns a queue entry for the track.
+func (t LibraryTrack) entry() QueueEntry {
+	return QueueEntry{Media: t.Media, Tags: append([]string(nil), t.Tags...)}
+}
+
+// A Library is a collection of tracks the station owns, e.g. local files or objects in S3,
+// that can be searched and played when nothing was requested, see SetFallbackLibrary.
+//
+// Tracks are identified by their media URL. The library is kept in memory with a trigram
+// index for searches. Every track is saved under its own key in the Store, so recording a play
+// only writes that track, and the list of URLs is only saved when tracks are added or removed.
+// Any database can back the library by implementing Store. It is safe for concurrent use.
+type Library struct {
+	mu     sync.RWMutex
+	tracks map[string]*LibraryTrack
+	index  trigramIndex
+	store  Store
+}
+
+// NewLibrary returns the library saved in store, or an empty one if nothing was saved yet.
+// store may be nil to keep the library in memory only.
+func NewLibrary(store Store) (*Library, error) {
+	l := &Library{tracks: make(map[string]*LibraryTrack), index: make(trigramIndex), store: store}
+	if store == nil {
+		return l, nil
+	}
+
+	data, err := store.Load(libraryIndexKey)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load library: %w", err)
+	}
+	var urls []string
+	if err = json.Unmarshal(data, &urls); err != nil {
+		return nil, fmt.Errorf("failed to parse library: %w", err)
+	}
+	for _, url := range urls {
+		if data, err = store.Load(libraryTrackKey(url)); err != nil {
+			return nil, fmt.Errorf("failed to load library track %s: %w", url, err)
+		}
+		track := &LibraryTrack{}
+		if err = json.Unmarshal(data, track); err != nil {
+			return nil, fmt.Errorf("failed to parse library track %s: %w", url, err)
+		}
+		l.tracks[url] = track
+		l.index.add(track)
+	}
+	return l, nil
+}
+
+// Add adds the tracks to the library, tracks with the same URL are replaced
+// but keep their play statistics.
+func (l *Library) Add(tracks ...LibraryTrack) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	added := false
+	for _, track := range tracks {
+		track := track
+		if track.Media.URL == "" {
+			return errors.New("library tracks need a URL")
+		}
+		if track.Artist == "" && track.Title == "" {
+			track.Artist, track.Title = ParseTitle(track.Media.Title)
+		}
+		existing, ok := l.tracks[track.Media.URL]
+		if ok {
+			if track.Plays == 0 && track.LastPlayed.IsZero() {
+				track.Plays, track.LastPlayed = existing.Plays, existing.LastPlayed
+			}
+			l.index.remove(existing)
+		}
+		added = added || !ok
+		l.tracks[track.Media.URL] = &track
+		l.index.add(&track)
+		if err := l.saveTrack(&track); err != nil {
+			return err
+		}
+	}
+	if !added {
+		return nil
+	}
+	return l.saveIndex()
+}
+
+// Remove removes the track with the given URL, it is not an error if there is none.
+func (l *Library) Remove(url string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	track, ok := l.tracks[url]
+	if !ok {
+		return nil
+	}
+	delete(l.tracks, url)
+	l.index.remove(track)
+	// Store can't delete, the saved track is ignored without its URL in the index
+	return l.saveIndex()
+}
+
+// Track returns the track with the given URL.
+func (l *Library) Track(url string) (LibraryTrack, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	track, ok := l.tracks[url]
+	if !ok {
+		return LibraryTrack{}, false
+	}
+	return *track, true
+}
+
+// Len returns the amount of tracks in the library.
+func (l *Library) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.tracks)
+}
+
+// Search returns all tracks matching every word of query, sorted by artist and title.
+//
+// A word matches if the artist, title or a tag contains it, ignoring case. Words can be
+// restricted to a field with artist:, title: and tag:, e.g. "tag:ambient artist:eno".
+// Tag words have to match a whole tag. An empty query returns all tracks.
+func (l *Library) Search(query string) []LibraryTrack {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var found []LibraryTrack
+	for _, track := range l.matching(strings.Fields(strings.ToLower(query))) {
+		found = append(found, *track)
+	}
+	sortTracks(found)
+	return found
+}
+
+// matches reports whether the track matches all lower case query words.
+func (t *LibraryTrack) matches(words []string) bool {
+	artist, title := strings.ToLower(t.Artist), strings.ToLower(t.Title)
+	hasTag := func(word string, whole bool) bool {
+		for _, tag := range t.Tags {
+			tag = strings.ToLower(tag)
+			if tag == word || (!whole && strings.Contains(tag, word)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, word := range words {
+		field, value, found := strings.Cut(word, ":")
+		if !found || value == "" {
+			field, value = "", word
+		}
+		var ok bool
+		switch field {
+		case "artist":
+			ok = strings.Contains(artist, value)
+		case "title":
+			ok = strings.Contains(title, value)
+		case "tag":
+			ok = hasTag(value, true)
+		default:
+			ok = strings.Contains(artist, word) || strings.Contains(title, word) || hasTag(word, false)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Index adds all audio files in dir and its subdirectories that are not in the library yet.
+//
+// The files are probed with ffprobe for their title and duration, files that can't be probed
+// are skipped. It returns the amount of added tracks and the first error.
+// The tracks are local files, they are only played with a FileSource whose root contains dir.
+func (l *Library) Index(ctx context.Context, dir string) (int, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			if _, ok := l.Track(path); !ok {
+				paths = append(paths, path)
+			}
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to index %s: %w", dir, err)
+	}
+
+	var tracks []LibraryTrack
+	var firstErr error
+	for _, path := range paths {
+		media, err := ProbeResolver{Root: dir}.Resolve(ctx, path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to probe %s: %w", path, err)
+			}
+			continue
+		}
+		tracks = append(tracks, LibraryTrack{Media: media})
+	}
+	if err = l.Add(tracks...); err != nil {
+		return 0, err
+	}
+	return len(tracks), firstErr
+}
+
+// pick returns the track matching query that was played longest ago, skipping tracks with
+// the same artist or title as one of the recent entries where possible, and records it as played.
+func (l *Library) pick(query string, recent []QueueEntry, now time.Time) (LibraryTrack, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	candidates := l.matching(strings.Fields(strings.ToLower(query)))
+	var separated []*LibraryTrack
+	for _, track := range candidates {
+		if separationDistance(track.entry(), recent) < 0 {
+			separated = append(separated, track)
+		}
+	}
+	if len(separated) > 0 {
+		candidates = separated
+	}
+	if len(candidates) == 0 {
+		return LibraryTrack{}, false
+	}
+
+	// shuffle first so tracks that were never played are picked in random order
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	pick := candidates[0]
+	for _, track := range candidates[1:] {
+		if track.LastPlayed.Before(pick.LastPlayed) {
+			pick = track
+		}
+	}
+	l.played(pick, now)
+	return *pick, true
+}
+
+// played records that the track was played, the lock has to be held.
+func (l *Library) played(track *LibraryTrack, now time.Time) {
+	track.Plays++
+	track.LastPlayed = now
+	// the statistics are not worth interrupting playback for, they are saved again with the next play
+	_ = l.saveTrack(track)
+}
+
+// saveTrack writes the track into the store, the lock has to be held.
+func (l *Library) saveTrack(track *LibraryTrack) error {
+	if l.store == nil {
+		return nil
+	}
+	data, err := json.Marshal(track)
+	if err != nil {
+		return err
+	}
+	if err = l.store.Save(libraryTrackKey(track.Media.URL), data); err != nil {
+		return fmt.Errorf("failed to save library track %s: %w", track.Media.URL, err)
+	}
+	return nil
+}
+
+// saveIndex writes the URLs of all tracks into the store, the lock has to be held.
+func (l *Library) saveIndex() error {
+	if l.store == nil {
+		return nil
+	}
+	urls := make([]string, 0, len(l.tracks))
+	for url := range l.tracks {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	data, err := json.Marshal(urls)
+	if err != nil {
+		return err
+	}
+	if err = l.store.Save(libraryIndexKey, data); err != nil {
+		return fmt.Errorf("failed to save library: %w", err)
+	}
+	return nil
+}
+
+func sortTracks(tracks []LibraryTrack) {
+	sort.Slice(tracks, func(i, j int) bool {
+		a, b := tracks[i], tracks[j]
+		if !strings.EqualFold(a.Artist, b.Artist) {
+			return strings.ToLower(a.Artist) < strings.ToLower(b.Artist)
+		}
+		if !strings.EqualFold(a.Title, b.Title) {
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		}
+		return a.Media.URL < b.Media.URL
+	})
+}
+
+// SetFallbackLibrary plays tracks from lib matching query whenever the queue is empty,
+// instead of the fallback playlist set with SetFallback. An empty query plays the whole library.
+//
+// The track that was played longest ago is picked, the same artist or title is not repeated
+// within separation tracks where possible. Passing a nil library disables it.
+func (dj *Dj) SetFallbackLibrary(lib *Library, query string, separation int) {
+	dj.fallback.Lock()
+	defer dj.fallback.Unlock()
+
+	dj.fallback.library = lib
+	dj.fallback.query = query
+	// negative separations would slice past the recent entries
+	if separation < 0 {
+		separation = 0
+	}
+	dj.fallback.separation = separation
+}
@@ -0,0 +1,135 @@
+package opendj
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingStore counts the saves per key.
+type countingStore struct {
+	MemoryStore
+	mu    sync.Mutex
+	saves map[string]int
+}
+
+func (s *countingStore) Save(key string, data []byte) error {
+	s.mu.Lock()
+	if s.saves == nil {
+		s.saves = make(map[string]int)
+	}
+	s.saves[key]++
+	s.mu.Unlock()
+	return s.MemoryStore.Save(key, data)
+}
+
+func (s *countingStore) reset() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saves := s.saves
+	s.saves = nil
+	return saves
+}
+
+func TestLibrarySavesIncrementally(t *testing.T) {
+	store := &countingStore{}
+	lib, err := NewLibrary(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lib.Add(
+		LibraryTrack{Media: Media{Title: "Artist A - One", URL: "file:///a"}},
+		LibraryTrack{Media: Media{Title: "Artist B - Two", URL: "file:///b"}},
+		LibraryTrack{Media: Media{Title: "Artist C - Three", URL: "file:///c"}},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if saves := store.reset(); len(saves) != 4 || saves[libraryIndexKey] != 1 {
+		t.Errorf("adding 3 tracks saved %v, want every track and the index once", saves)
+	}
+
+	track, ok := lib.pick("one", nil, time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC))
+	if !ok {
+		t.Fatal("pick() found nothing")
+	}
+	if saves := store.reset(); len(saves) != 1 || saves[libraryTrackKey(track.Media.URL)] != 1 {
+		t.Errorf("a play saved %v, want only the played track", saves)
+	}
+
+	if err = lib.Add(LibraryTrack{Media: Media{Title: "Artist A - One (Remaster)", URL: "file:///a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if saves := store.reset(); saves[libraryIndexKey] != 0 {
+		t.Errorf("replacing a track saved the index")
+	}
+	if err = lib.Remove("file:///b"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewLibrary(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Search(""); len(got) != 2 || got[0].Plays != 1 || got[0].Media.Title != "Artist A - One (Remaster)" {
+		t.Errorf("loaded library = %+v, want the replaced track with its play and the third track", got)
+	}
+	if got := loaded.Search("one"); len(got) != 1 {
+		t.Errorf("the index of the loaded library found %d tracks, want 1", len(got))
+	}
+}
+
+// randomLibrary returns a library of n tracks with titles made of random syllables.
+func randomLibrary(rng *rand.Rand, n int) *Library {
+	syllables := []string{"blue", "moon", "ri", "ver", "night", "e", "no", "am", "bi", "ent", "dub", "jazz", "house", "café", "über", "ka", "lo", "ta", "mi", "sun"}
+	word := func() string {
+		var b strings.Builder
+		for i := 0; i < 2+rng.Intn(3); i++ {
+			b.WriteString(syllables[rng.Intn(len(syllables))])
+		}
+		return b.String()
+	}
+	lib, _ := NewLibrary(nil)
+	tracks := make([]LibraryTrack, n)
+	for i := range tracks {
+		tracks[i] = LibraryTrack{
+			Media:  Media{URL: fmt.Sprintf("file:///%d", i)},
+			Artist: word(),
+			Title:  word() + " " + word(),
+			Tags:   []string{word()},
+		}
+	}
+	_ = lib.Add(tracks...)
+	return lib
+}
+
+// TestLibraryIndexMatchesScan compares indexed searches with checking every track.
+func TestLibraryIndexMatchesScan(t *testing.T) {
+	lib := randomLibrary(rand.New(rand.NewSource(1)), 500)
+	queries := []string{"", "bl", "moon", "MOON river", "artist:eno", "title:night", "tag:dubjazz", "tag:du",
+		"ver", "café", "ÜBER", "xyz", "artist:", "foo:moon", "eno tag:jazzsun", "ue", "kalota"}
+	for _, query := range queries {
+		words := strings.Fields(strings.ToLower(query))
+		var want []LibraryTrack
+		for _, track := range lib.tracks {
+			if track.matches(words) {
+				want = append(want, *track)
+			}
+		}
+		sortTracks(want)
+		if got := lib.Search(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("Search(%q) found %d tracks, want %d", query, len(got), len(want))
+		}
+	}
+}
+
+func BenchmarkLibrarySearch(b *testing.B) {
+	lib := randomLibrary(rand.New(rand.NewSource(1)), 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lib.Search("moonriver kalo")
+	}
+}
@@ -0,0 +1,96 @@
+package opendj
+
+import (
+	"sort"
+	"strings"
+)
+
+// trigramIndex maps every three byte substring of the lower case artists, titles and tags
+// to the URLs of the tracks containing it, so searches only check tracks that can match.
+type trigramIndex map[string]map[string]struct{}
+
+// trigrams calls f for every trigram of the searchable fields of the track.
+func (t *LibraryTrack) trigrams(f func(string)) {
+	fields := append([]string{t.Artist, t.Title}, t.Tags...)
+	for _, field := range fields {
+		field = strings.ToLower(field)
+		for i := 0; i+3 <= len(field); i++ {
+			f(field[i : i+3])
+		}
+	}
+}
+
+func (x trigramIndex) add(t *LibraryTrack) {
+	t.trigrams(func(trigram string) {
+		urls, ok := x[trigram]
+		if !ok {
+			urls = make(map[string]struct{})
+			x[trigram] = urls
+		}
+		urls[t.Media.URL] = struct{}{}
+	})
+}
+
+func (x trigramIndex) remove(t *LibraryTrack) {
+	t.trigrams(func(trigram string) {
+		delete(x[trigram], t.Media.URL)
+		if len(x[trigram]) == 0 {
+			delete(x, trigram)
+		}
+	})
+}
+
+// candidates returns the URLs of the tracks that may match the lower case query words.
+// all is true if the words are too short to use the index and every track has to be checked.
+func (x trigramIndex) candidates(words []string) (urls map[string]struct{}, all bool) {
+	var sets []map[string]struct{}
+	for _, word := range words {
+		if _, value, found := strings.Cut(word, ":"); found && value != "" {
+			word = value
+		}
+		for i := 0; i+3 <= len(word); i++ {
+			sets = append(sets, x[word[i:i+3]])
+		}
+	}
+	if len(sets) == 0 {
+		return nil, true
+	}
+
+	// start with the rarest trigram, so the intersection stays small
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+	urls = make(map[string]struct{}, len(sets[0]))
+	for url := range sets[0] {
+		urls[url] = struct{}{}
+	}
+	for _, containing := range sets[1:] {
+		if len(urls) == 0 {
+			break
+		}
+		for url := range urls {
+			if _, ok := containing[url]; !ok {
+				delete(urls, url)
+			}
+		}
+	}
+	return urls, false
+}
+
+// matching returns the tracks matching the lower case query words, the lock has to be held.
+func (l *Library) matching(words []string) []*LibraryTrack {
+	var found []*LibraryTrack
+	urls, all := l.index.candidates(words)
+	if all {
+		for _, track := range l.tracks {
+			if track.matches(words) {
+				found = append(found, track)
+			}
+		}
+		return found
+	}
+	for url := range urls {
+		if track := l.tracks[url]; track.matches(words) {
+			found = append(found, track)
+		}
+	}
+	return found
+}
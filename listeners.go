@@ -0,0 +1,195 @@
+package opendj
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventListenersChanged is published when the listener count changes, Listeners is the new count.
+const EventListenersChanged EventType = "listenersChanged"
+
+// A ListenerSource reports how many listeners a downstream server has.
+type ListenerSource interface {
+	Listeners(ctx context.Context) (int, error)
+}
+
+// ListenerStats are the listener counts collected by PollListeners.
+type ListenerStats struct {
+	Listeners int
+	// Peak is the highest count seen since polling started.
+	Peak      int
+	UpdatedAt time.Time
+}
+
+type listenerStats struct {
+	sync.Mutex
+	stats ListenerStats
+}
+
+// Stats returns the most recent listener counts.
+//
+// All counts are zero until PollListeners received the first result.
+func (dj *Dj) Stats() ListenerStats {
+	dj.listeners.Lock()
+	defer dj.listeners.Unlock()
+	return dj.listeners.stats
+}
+
+// PollListeners asks source for the listener count every interval until ctx is cancelled.
+//
+// Changes are published as EventListenersChanged, failed polls are passed to the error handler.
+// It blocks, so it should be started in its own goroutine.
+func (dj *Dj) PollListeners(ctx context.Context, source ListenerSource, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	first := true
+	for {
+		count, err := source.Listeners(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if handler := dj.handlers.load().errorHander; handler != nil {
+				err := fmt.Errorf("failed to poll listeners: %w", err)
+				dj.callHandler(EventError, func() { handler(err) })
+			}
+		} else {
+			dj.listeners.Lock()
+			changed := first || count != dj.listeners.stats.Listeners
+			dj.listeners.stats.Listeners = count
+			if count > dj.listeners.stats.Peak {
+				dj.listeners.stats.Peak = count
+			}
+			dj.listeners.stats.UpdatedAt = dj.now()
+			dj.listeners.Unlock()
+
+			first = false
+			if changed {
+				dj.publish(Event{Type: EventListenersChanged, Listeners: count})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NginxRTMPStats reads the listener count from the stat page of nginx-rtmp.
+type NginxRTMPStats struct {
+	// URL is the URL of the rtmp_stat page, e.g. http://localhost:8080/stat
+	URL string
+	// App and Stream are the application and stream name, e.g. live and the stream key.
+	App    string
+	Stream string
+}
+
+// Listeners returns the amount of clients playing the stream, the publisher is not counted.
+func (s NginxRTMPStats) Listeners(ctx context.Context) (int, error) {
+	var stats struct {
+		Applications []struct {
+			Name    string `xml:"name"`
+			Streams []struct {
+				Name    string `xml:"name"`
+				Clients []struct {
+					Publishing *struct{} `xml:"publishing"`
+				} `xml:"client"`
+			} `xml:"live>stream"`
+		} `xml:"server>application"`
+	}
+	if err := getStats(ctx, s.URL, func(resp *http.Response) error {
+		return xml.NewDecoder(resp.Body).Decode(&stats)
+	}); err != nil {
+		return 0, err
+	}
+
+	for _, app := range stats.Applications {
+		if app.Name != s.App {
+			continue
+		}
+		for _, stream := range app.Streams {
+			if stream.Name != s.Stream {
+				continue
+			}
+			listeners := 0
+			for _, client := range stream.Clients {
+				if client.Publishing == nil {
+					listeners++
+				}
+			}
+			return listeners, nil
+		}
+	}
+	// the stream is not listed while nobody publishes to it
+	return 0, nil
+}
+
+// IcecastStats reads the listener count from the status-json.xsl page of Icecast.
+type IcecastStats struct {
+	// URL is the URL of the status page, e.g. http://localhost:8000/status-json.xsl
+	URL string
+	// Mount is the mount point, e.g. /stream
+	Mount string
+}
+
+// Listeners returns the current listeners of the mount point.
+func (s IcecastStats) Listeners(ctx context.Context) (int, error) {
+	var status struct {
+		Icestats struct {
+			// Source is an object if there is a single source and an array otherwise.
+			Source json.RawMessage `json:"source"`
+		} `json:"icestats"`
+	}
+	if err := getStats(ctx, s.URL, func(resp *http.Response) error {
+		return json.NewDecoder(resp.Body).Decode(&status)
+	}); err != nil {
+		return 0, err
+	}
+
+	type source struct {
+		ListenURL string `json:"listenurl"`
+		Listeners int    `json:"listeners"`
+	}
+	var sources []source
+	if err := json.Unmarshal(status.Icestats.Source, &sources); err != nil {
+		var single source
+		if json.Unmarshal(status.Icestats.Source, &single) == nil {
+			sources = []source{single}
+		}
+	}
+	for _, src := range sources {
+		if strings.HasSuffix(src.ListenURL, s.Mount) {
+			return src.Listeners, nil
+		}
+	}
+	return 0, nil
+}
+
+// getStats requests url and passes the response to decode.
+func getStats(ctx context.Context, url string, decode func(*http.Response) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return decode(resp)
+}
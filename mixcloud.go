@@ -0,0 +1,141 @@
+package opendj
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mixcloudAPI is the base URL of the public Mixcloud API.
+const mixcloudAPI = "https://api.mixcloud.com"
+
+// maxMixcloudPages limits how many pages of a playlist are fetched.
+const maxMixcloudPages = 20
+
+// MixcloudResolver resolves Mixcloud shows and playlists with the public Mixcloud API.
+//
+// Show URLs look like https://www.mixcloud.com/<user>/<show>/, playlists like
+// https://www.mixcloud.com/<user>/playlists/<playlist>/. Playlists can't be resolved
+// as a single media, Expand returns their shows instead.
+type MixcloudResolver struct {
+	Client *http.Client
+	// API is the base URL of the API, it defaults to https://api.mixcloud.com
+	API string
+}
+
+// mixcloudShow is the subset of a cloudcast returned by the API that is used.
+type mixcloudShow struct {
+	Name        string  `json:"name"`
+	URL         string  `json:"url"`
+	AudioLength float64 `json:"audio_length"`
+	User        struct {
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+func (s mixcloudShow) media() Media {
+	title := s.Name
+	if s.User.Name != "" {
+		title = s.User.Name + " - " + title
+	}
+	return Media{
+		Title:    title,
+		URL:      s.URL,
+		Duration: time.Duration(s.AudioLength * float64(time.Second)),
+	}
+}
+
+// Resolve returns the show at url, it returns ErrorMultipleMedia for playlists.
+func (r MixcloudResolver) Resolve(ctx context.Context, rawURL string) (Media, error) {
+	path, playlist, err := mixcloudPath(rawURL)
+	if err != nil {
+		return Media{}, err
+	}
+	if playlist {
+		return Media{}, fmt.Errorf("%s: %w", rawURL, ErrorMultipleMedia)
+	}
+
+	var show mixcloudShow
+	if err = r.get(ctx, r.api()+path, &show); err != nil {
+		return Media{}, err
+	}
+	media := show.media()
+	media.URL = rawURL
+	return media, nil
+}
+
+// Expand returns all shows of the playlist or the show at url.
+func (r MixcloudResolver) Expand(ctx context.Context, rawURL string) ([]Media, error) {
+	path, playlist, err := mixcloudPath(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if !playlist {
+		media, err := r.Resolve(ctx, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		return []Media{media}, nil
+	}
+
+	var media []Media
+	next := r.api() + path + "cloudcasts/"
+	for page := 0; next != "" && page < maxMixcloudPages; page++ {
+		var shows struct {
+			Data   []mixcloudShow `json:"data"`
+			Paging struct {
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+		if err = r.get(ctx, next, &shows); err != nil {
+			return nil, err
+		}
+		for _, show := range shows.Data {
+			media = append(media, show.media())
+		}
+		next = shows.Paging.Next
+	}
+	if len(media) == 0 {
+		return nil, fmt.Errorf("%s is empty: %w", rawURL, ErrorUnavailable)
+	}
+	return media, nil
+}
+
+func (r MixcloudResolver) api() string {
+	if r.API != "" {
+		return strings.TrimSuffix(r.API, "/")
+	}
+	return mixcloudAPI
+}
+
+// get decodes the API response at rawURL into v.
+func (r MixcloudResolver) get(ctx context.Context, rawURL string, v any) error {
+	body, err := fetchPage(ctx, r.Client, rawURL)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse Mixcloud response for %s: %w", rawURL, err)
+	}
+	return nil
+}
+
+// mixcloudPath returns the API path of a Mixcloud URL and whether it is a playlist.
+func mixcloudPath(rawURL string) (path string, playlist bool, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false, err
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(segments) == 2 && segments[0] != "" && segments[1] != "":
+		return "/" + segments[0] + "/" + segments[1] + "/", false, nil
+	case len(segments) == 3 && segments[1] == "playlists" && segments[2] != "":
+		return "/" + segments[0] + "/playlists/" + segments[2] + "/", true, nil
+	}
+	return "", false, fmt.Errorf("%s is not a Mixcloud show or playlist", rawURL)
+}
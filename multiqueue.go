@@ -0,0 +1,162 @@
+package opendj
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// RequestQueue is the name of the default queue all entries are added to.
+const RequestQueue = "requests"
+
+// ErrorUnknownQueue is returned when a queue name does not exist.
+var ErrorUnknownQueue = errors.New("unknown queue")
+
+// A namedQueue is an additional queue, e.g. a show queue curated by a live DJ.
+//
+// All named queues are guarded by the lock of the request queue.
+type namedQueue struct {
+	name     string
+	priority int
+	queue    *queue
+}
+
+// AddQueue adds a named queue that is drained before all queues with a lower priority.
+//
+// The request queue has priority 0, so a queue with a higher priority is played
+// before any requests and one with a lower priority only when there are none.
+// Queues with the same priority are drained in the order they were added.
+// returns an error if a queue with that name already exists.
+func (dj *Dj) AddQueue(name string, priority int) error {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+
+	if name == RequestQueue || dj.namedQueue(name) != nil {
+		return fmt.Errorf("queue %q already exists", name)
+	}
+	dj.queues = append(dj.queues, &namedQueue{name: name, priority: priority, queue: &queue{ids: dj.ids}})
+	sort.SliceStable(dj.queues, func(i, j int) bool {
+		return dj.queues[i].priority > dj.queues[j].priority
+	})
+	return nil
+}
+
+// QueueNamed returns a copy of the queue with the given name.
+func (dj *Dj) QueueNamed(name string) ([]QueueEntry, error) {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+
+	q := dj.lookupQueue(name)
+	if q == nil {
+		return nil, fmt.Errorf("%q: %w", name, ErrorUnknownQueue)
+	}
+	return q.items.appendTo(nil), nil
+}
+
+// AddEntryTo adds the passed QueueEntry at the end of the queue with the given name.
+//
+// The entry is checked against the content filter and the admission hook, the queue limit only applies to the request queue.
+func (dj *Dj) AddEntryTo(name string, newEntry QueueEntry) error {
+	return dj.As(directActor).AddEntryTo(name, newEntry).Err
+}
+
+// addEntryTo is AddEntryTo without the audit log.
+func (dj *Dj) addEntryTo(name string, newEntry QueueEntry) error {
+	if name == RequestQueue {
+		return dj.addEntry(newEntry)
+	}
+
+	newEntry.stamp(dj.now())
+	newEntry.clipFromURL()
+	if err := dj.admit(newEntry); err != nil {
+		return err
+	}
+
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	q := dj.lookupQueue(name)
+	if q == nil {
+		return fmt.Errorf("%q: %w", name, ErrorUnknownQueue)
+	}
+	q.insert(q.len(), newEntry)
+	return nil
+}
+
+// MoveBetweenQueues moves the entry at index in the queue from to toIndex in the queue to.
+//
+// if toIndex is too high the entry is added at the end.
+// returns an error if a queue does not exist, an index is out of range or the entry
+// is moved into the request queue while its limit or the owner's duration cap is reached.
+func (dj *Dj) MoveBetweenQueues(from string, index int, to string, toIndex int) error {
+	return dj.As(directActor).MoveBetweenQueues(from, index, to, toIndex).Err
+}
+
+// moveBetweenQueues is MoveBetweenQueues without the audit log.
+func (dj *Dj) moveBetweenQueues(from string, index int, to string, toIndex int) error {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	src, dst := dj.lookupQueue(from), dj.lookupQueue(to)
+	if src == nil {
+		return fmt.Errorf("%q: %w", from, ErrorUnknownQueue)
+	} else if dst == nil {
+		return fmt.Errorf("%q: %w", to, ErrorUnknownQueue)
+	} else if index < 0 || index >= src.len() || toIndex < 0 {
+		return ErrorOutOfRange
+	}
+
+	if src == dst {
+		if toIndex >= src.len() {
+			toIndex = src.len() - 1
+		}
+		if index != toIndex {
+			src.move(index, toIndex)
+		}
+		return nil
+	}
+	if dst == &dj.waitingQueue {
+		if err := dj.room(src.at(index)); err != nil {
+			return err
+		}
+	}
+	dst.insert(toIndex, src.remove(index))
+	return nil
+}
+
+// lookupQueue returns the queue with the given name or nil, the lock has to be held.
+func (dj *Dj) lookupQueue(name string) *queue {
+	if name == RequestQueue {
+		return &dj.waitingQueue
+	}
+	if named := dj.namedQueue(name); named != nil {
+		return named.queue
+	}
+	return nil
+}
+
+func (dj *Dj) namedQueue(name string) *namedQueue {
+	for _, named := range dj.queues {
+		if named.name == name {
+			return named
+		}
+	}
+	return nil
+}
+
+// drainOrder returns all queues in the order they are played from, the lock has to be held.
+func (dj *Dj) drainOrder() []*queue {
+	order := make([]*queue, 0, len(dj.queues)+1)
+	requestsAdded := false
+	for _, named := range dj.queues {
+		if !requestsAdded && named.priority <= 0 {
+			order = append(order, &dj.waitingQueue)
+			requestsAdded = true
+		}
+		order = append(order, named.queue)
+	}
+	if !requestsAdded {
+		order = append(order, &dj.waitingQueue)
+	}
+	return order
+}
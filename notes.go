@@ -0,0 +1,64 @@
+package opendj
+
+import "encoding/json"
+
+// anonymousOwner is shown instead of the owner of anonymous entries.
+const anonymousOwner = "anonymous"
+
+// PublicOwner returns the owner of the entry as it may be shown to everyone.
+func (e QueueEntry) PublicOwner() string {
+	if e.Anonymous {
+		return anonymousOwner
+	}
+	return e.Owner
+}
+
+// Public returns a copy of the entry without moderator notes and,
+// if the entry is anonymous, without its owner, for serving it to everyone.
+func (e QueueEntry) Public() QueueEntry {
+	e.Notes = ""
+	if e.Anonymous {
+		e.Owner, e.OwnerID = "", ""
+	}
+	return e
+}
+
+// MarshalJSON encodes the Public entry, so moderator notes and the owner of anonymous
+// entries never leak through NowPlaying, queue snapshots, events or webhooks.
+func (e QueueEntry) MarshalJSON() ([]byte, error) {
+	type plain QueueEntry
+	return json.Marshal(plain(e.Public()))
+}
+
+// SetNotes sets the moderator notes of the entry with the given ID in any queue.
+//
+// returns ErrorUnknownEntry if there is no such entry.
+func (dj *Dj) SetNotes(id uint64, notes string) error {
+	return dj.As(directActor).SetNotes(id, notes).Err
+}
+
+// setNotes is SetNotes without the audit log.
+func (dj *Dj) setNotes(id uint64, notes string) error {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	q, i, ok := dj.findEntry(id)
+	if !ok {
+		return ErrorUnknownEntry
+	}
+	entry := q.at(i)
+	entry.Notes = notes
+	q.replace(i, entry)
+	return nil
+}
+
+// Notes returns the moderator notes of the entry with the given ID in any queue.
+//
+// returns ErrorUnknownEntry if there is no such entry.
+func (dj *Dj) Notes(id uint64) (string, error) {
+	entry, err := dj.EntryByID(id)
+	if err != nil {
+		return "", err
+	}
+	return entry.Notes, nil
+}
@@ -0,0 +1,59 @@
+package opendj
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalAnonymousEntry(t *testing.T) {
+	entry := QueueEntry{Media: Media{Title: "Lofi beats"}, Owner: "alice", OwnerID: "123", Anonymous: true, Notes: "asked twice"}
+
+	for name, v := range map[string]any{
+		"entry":       entry,
+		"pointer":     &entry,
+		"now playing": NowPlaying{Entry: entry},
+		"queue":       []QueueEntry{entry},
+	} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, secret := range []string{"alice", "123", "asked twice"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s: %s leaks %q", name, data, secret)
+			}
+		}
+		if !strings.Contains(string(data), "Lofi beats") {
+			t.Errorf("%s: %s is missing the title", name, data)
+		}
+	}
+
+	entry.Anonymous = false
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "alice") || strings.Contains(string(data), "asked twice") {
+		t.Errorf("public entry encoded as %s", data)
+	}
+}
+
+func TestExportHistoryAnonymousOwner(t *testing.T) {
+	dj := newTestDj(t, nil)
+	start := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+	dj.history.startSession(start)
+	dj.history.started(QueueEntry{Media: Media{Title: "Lofi beats"}, Owner: "alice", Anonymous: true}, start)
+
+	for _, format := range []ReportFormat{ReportCSV, ReportJSON} {
+		var b bytes.Buffer
+		if err := dj.ExportHistory(&b, format); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(b.String(), "alice") || !strings.Contains(b.String(), anonymousOwner) {
+			t.Errorf("format %d: got %s", format, b.String())
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package obs updates OBS through obs-websocket when songs start or playback goes idle,
+// e.g. to show the current title in a text source and switch to an idle scene.
+package obs
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoMuchForSubtlety/opendj"
+	"github.com/SoMuchForSubtlety/opendj/catalog"
+)
+
+// obs-websocket v5 op codes.
+const (
+	opHello           = 0
+	opIdentify        = 1
+	opIdentified      = 2
+	opRequest         = 6
+	opRequestResponse = 7
+
+	rpcVersion = 1
+)
+
+type message struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+}
+
+type requestResponse struct {
+	RequestID     string `json:"requestId"`
+	RequestStatus struct {
+		Result  bool   `json:"result"`
+		Code    int    `json:"code"`
+		Comment string `json:"comment"`
+	} `json:"requestStatus"`
+}
+
+// A Client is a connection to obs-websocket.
+type Client struct {
+	ws *wsConn
+
+	mu        sync.Mutex
+	nextID    int
+	responses map[string]chan requestResponse
+	readErr   error
+}
+
+// Dial connects to obs-websocket at addr, e.g. ws://localhost:4455,
+// and authenticates with password if the server requires it.
+func Dial(ctx context.Context, addr, password string) (*Client, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(u))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to OBS: %w", err)
+	}
+	if strings.EqualFold(u.Scheme, "wss") {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+	ws, err := dialWebsocket(conn, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Client{ws: ws, responses: make(map[string]chan requestResponse)}
+	if err = c.identify(password); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	go c.read()
+	return c, nil
+}
+
+// identify performs the obs-websocket handshake.
+func (c *Client) identify(password string) error {
+	raw, err := c.ws.ReadText()
+	if err != nil {
+		return fmt.Errorf("failed to read hello: %w", err)
+	}
+	var hello message
+	if err = json.Unmarshal(raw, &hello); err != nil || hello.Op != opHello {
+		return errors.New("unexpected hello from OBS")
+	}
+	var helloData struct {
+		Authentication *struct {
+			Challenge string `json:"challenge"`
+			Salt      string `json:"salt"`
+		} `json:"authentication"`
+	}
+	if err = json.Unmarshal(hello.D, &helloData); err != nil {
+		return err
+	}
+
+	identify := map[string]interface{}{"rpcVersion": rpcVersion, "eventSubscriptions": 0}
+	if auth := helloData.Authentication; auth != nil {
+		secret := sha256.Sum256([]byte(password + auth.Salt))
+		response := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(secret[:]) + auth.Challenge))
+		identify["authentication"] = base64.StdEncoding.EncodeToString(response[:])
+	}
+	if err = c.send(opIdentify, identify); err != nil {
+		return err
+	}
+
+	raw, err = c.ws.ReadText()
+	if err != nil {
+		return fmt.Errorf("failed to identify, is the password correct? %w", err)
+	}
+	var identified message
+	if err = json.Unmarshal(raw, &identified); err != nil || identified.Op != opIdentified {
+		return errors.New("OBS did not accept the identification")
+	}
+	return nil
+}
+
+func (c *Client) send(op int, data interface{}) error {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(message{Op: op, D: d})
+	if err != nil {
+		return err
+	}
+	return c.ws.WriteText(msg)
+}
+
+// read dispatches responses until the connection is closed.
+func (c *Client) read() {
+	for {
+		raw, err := c.ws.ReadText()
+		if err != nil {
+			c.mu.Lock()
+			c.readErr = err
+			for id, ch := range c.responses {
+				close(ch)
+				delete(c.responses, id)
+			}
+			c.mu.Unlock()
+			return
+		}
+
+		var msg message
+		if json.Unmarshal(raw, &msg) != nil || msg.Op != opRequestResponse {
+			continue
+		}
+		var resp requestResponse
+		if json.Unmarshal(msg.D, &resp) != nil {
+			continue
+		}
+		c.mu.Lock()
+		if ch, ok := c.responses[resp.RequestID]; ok {
+			ch <- resp
+			delete(c.responses, resp.RequestID)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Request sends a request to OBS and waits for it to complete.
+func (c *Client) Request(ctx context.Context, requestType string, data interface{}) error {
+	c.mu.Lock()
+	if c.readErr != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("connection to OBS lost: %w", c.readErr)
+	}
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	ch := make(chan requestResponse, 1)
+	c.responses[id] = ch
+	c.mu.Unlock()
+
+	err := c.send(opRequest, map[string]interface{}{
+		"requestType": requestType,
+		"requestId":   id,
+		"requestData": data,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.responses, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	case resp, ok := <-ch:
+		if !ok {
+			return errors.New("connection to OBS lost")
+		}
+		if !resp.RequestStatus.Result {
+			return fmt.Errorf("%s failed with code %d: %s", requestType, resp.RequestStatus.Code, resp.RequestStatus.Comment)
+		}
+		return nil
+	}
+}
+
+// SetText sets the text of a text source.
+func (c *Client) SetText(ctx context.Context, source, text string) error {
+	return c.Request(ctx, "SetInputSettings", map[string]interface{}{
+		"inputName":     source,
+		"inputSettings": map[string]string{"text": text},
+	})
+}
+
+// SetScene switches the program scene.
+func (c *Client) SetScene(ctx context.Context, scene string) error {
+	return c.Request(ctx, "SetCurrentProgramScene", map[string]string{"sceneName": scene})
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.ws.Close()
+}
+
+// An Overlay keeps OBS in sync with the playback of a Dj.
+//
+// Empty fields are not updated.
+type Overlay struct {
+	Client *Client
+	// TextSource is the name of the text source that shows the current song.
+	TextSource string
+	// Format returns the text for the entry, it defaults to catalog.OverlayNowPlaying.
+	Format func(opendj.QueueEntry) string
+	// IdleText is shown in TextSource while nothing is playing.
+	IdleText string
+	// PlayingScene is switched to when a song starts.
+	PlayingScene string
+	// IdleScene is switched to when a song ends and the queue is empty.
+	IdleScene string
+	// ShowSource is the name of the text source that shows the current show, see opendj.SetShowSchedule.
+	// ShowFormat returns its text, it defaults to catalog.ShowWithHost or the name if there is no host.
+	ShowSource string
+	ShowFormat func(opendj.Show) string
+	// Catalog holds the default texts, nil uses catalog.Defaults.
+	Catalog *catalog.Catalog
+	// Locale selects the texts in the catalog, e.g. "de".
+	Locale string
+}
+
+// Run updates OBS until ctx is cancelled.
+// Errors are passed to onError, which may be nil.
+func (o *Overlay) Run(ctx context.Context, dj *opendj.Dj, onError func(error)) {
+	events, cancel := dj.Subscribe(16)
+	defer cancel()
+
+	for {
+		var event opendj.Event
+		select {
+		case <-ctx.Done():
+			return
+		case event = <-events:
+		}
+
+		var err error
+		switch event.Type {
+		case opendj.EventSongStarted:
+			err = o.playing(ctx, event.Entry)
+		case opendj.EventSongEnded:
+			if len(dj.Queue()) == 0 {
+				err = o.idle(ctx)
+			}
+		case opendj.EventShowStarted:
+			err = o.show(ctx, event.Show)
+		case opendj.EventShowEnded:
+			if _, ok := dj.CurrentShow(); !ok {
+				err = o.show(ctx, opendj.Show{})
+			}
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (o *Overlay) playing(ctx context.Context, entry opendj.QueueEntry) error {
+	if o.TextSource != "" {
+		text := o.Catalog.Text(o.Locale, catalog.OverlayNowPlaying, entry.Media.Title, entry.PublicOwner())
+		if o.Format != nil {
+			text = o.Format(entry)
+		}
+		if err := o.Client.SetText(ctx, o.TextSource, text); err != nil {
+			return err
+		}
+	}
+	if o.PlayingScene != "" {
+		return o.Client.SetScene(ctx, o.PlayingScene)
+	}
+	return nil
+}
+
+func (o *Overlay) idle(ctx context.Context) error {
+	if o.TextSource != "" {
+		if err := o.Client.SetText(ctx, o.TextSource, o.IdleText); err != nil {
+			return err
+		}
+	}
+	if o.IdleScene != "" {
+		return o.Client.SetScene(ctx, o.IdleScene)
+	}
+	return nil
+}
+
+func (o *Overlay) show(ctx context.Context, show opendj.Show) error {
+	if o.ShowSource == "" {
+		return nil
+	}
+	text := show.Name
+	if o.ShowFormat != nil && show != (opendj.Show{}) {
+		text = o.ShowFormat(show)
+	} else if show.Host != "" {
+		text = o.Catalog.Text(o.Locale, catalog.ShowWithHost, show.Name, show.Host)
+	}
+	return o.Client.SetText(ctx, o.ShowSource, text)
+}
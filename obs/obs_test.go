@@ -0,0 +1,143 @@
+package obs
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeOBS is a minimal obs-websocket server.
+type fakeOBS struct {
+	t        *testing.T
+	listener net.Listener
+	password string
+	requests chan map[string]interface{}
+}
+
+func newFakeOBS(t *testing.T, password string) *fakeOBS {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	s := &fakeOBS{t: t, listener: listener, password: password, requests: make(chan map[string]interface{}, 16)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeOBS) addr() string {
+	return "ws://" + s.listener.Addr().String()
+}
+
+func (s *fakeOBS) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil || req.Header.Get("Sec-WebSocket-Protocol") != "obswebsocket.json" {
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		return
+	}
+	accept := sha1.Sum([]byte(req.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+	conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n"))
+	ws := &wsConn{conn: conn, reader: reader}
+
+	const salt, challenge = "salt", "challenge"
+	s.write(conn, opHello, map[string]interface{}{
+		"rpcVersion":     1,
+		"authentication": map[string]string{"salt": salt, "challenge": challenge},
+	})
+	var identify struct {
+		Authentication string `json:"authentication"`
+	}
+	if op := s.read(ws, &identify); op != opIdentify {
+		return
+	}
+	secret := sha256.Sum256([]byte(s.password + salt))
+	response := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(secret[:]) + challenge))
+	if identify.Authentication != base64.StdEncoding.EncodeToString(response[:]) {
+		return
+	}
+	s.write(conn, opIdentified, map[string]int{"negotiatedRpcVersion": 1})
+
+	for {
+		var request map[string]interface{}
+		if op := s.read(ws, &request); op != opRequest {
+			return
+		}
+		s.requests <- request
+		ok := request["requestType"] != "SetCurrentProgramScene"
+		s.write(conn, opRequestResponse, map[string]interface{}{
+			"requestId":     request["requestId"],
+			"requestStatus": map[string]interface{}{"result": ok, "code": 600, "comment": "no such scene"},
+		})
+	}
+}
+
+// read reads a message from the client and returns its op code.
+func (s *fakeOBS) read(ws *wsConn, data interface{}) int {
+	raw, err := ws.ReadText()
+	if err != nil {
+		return -1
+	}
+	var msg message
+	if err = json.Unmarshal(raw, &msg); err != nil || json.Unmarshal(msg.D, data) != nil {
+		s.t.Errorf("invalid message %s", raw)
+		return -1
+	}
+	return msg.Op
+}
+
+// write sends an unmasked text frame like a server.
+func (s *fakeOBS) write(conn net.Conn, op int, data interface{}) {
+	d, _ := json.Marshal(data)
+	payload, _ := json.Marshal(message{Op: op, D: d})
+	header := []byte{0x80 | opText, 126, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	conn.Write(append(header, payload...))
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeOBS(t, "secret")
+	ctx := context.Background()
+	c, err := Dial(ctx, server.addr(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err = c.SetText(ctx, "Now Playing", "Song - requested by alice"); err != nil {
+		t.Fatal(err)
+	}
+	request := <-server.requests
+	data, _ := json.Marshal(request["requestData"])
+	if request["requestType"] != "SetInputSettings" || string(data) != `{"inputName":"Now Playing","inputSettings":{"text":"Song - requested by alice"}}` {
+		t.Errorf("unexpected request %v", request)
+	}
+
+	err = c.SetScene(ctx, "Missing")
+	if err == nil || !strings.Contains(err.Error(), "no such scene") {
+		t.Errorf("a failed request returned %v", err)
+	}
+}
+
+func TestClientWrongPassword(t *testing.T) {
+	server := newFakeOBS(t, "secret")
+	if c, err := Dial(context.Background(), server.addr(), "wrong"); err == nil {
+		c.Close()
+		t.Error("identified with the wrong password")
+	}
+}
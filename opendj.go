@@ -1,13 +1,16 @@
 package opendj
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"strings"
+	"sort"
+	"strconv"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -15,20 +18,81 @@ import (
 
 var ErrorEmptyQueue = errors.New("can't pop from empty queue")
 
+// ErrorOutOfRange is returned when an index is not in the queue.
+var ErrorOutOfRange = errors.New("index out of range")
+
 // Dj stores the queue and handlers
 type Dj struct {
-	waitingQueue queue
+	waitingQueue  queue
+	lockedVersion uint64
+	// upNext is the ID of the entry reserved by peek
+	upNext uint64
+	// currentEntry is the entry that is playing, it is guarded by the lock of clock
 	currentEntry QueueEntry
 
 	handlers handlers
+	config   config
+	queues   []*namedQueue
+
+	archiveCount int
+	// sinceStinger is the amount of tracks played since the last sting
+	sinceStinger int
+
+	history   history
+	fallback  fallback
+	wheel     clockWheel
+	shows     showSchedule
+	auditLog  auditLog
+	resolvers resolverPool
+
+	pending        pending
+	breaks         breaks
+	breaker        breaker
+	listeners      listenerStats
+	pipeline       pipeline
+	ducker         ducker
+	skipper        skipper
+	stopper        encoderStop
+	health         health
+	events         eventBus
+	handlerLimiter eventLimiter
 
-	songStarted time.Time
+	clock playClock
+	// timeSource and ids are set by options, nil uses the defaults
+	timeSource Clock
+	ids        IDGenerator
 }
 
+// handlers holds the registered handlers. They are replaced as a whole on every registration,
+// so they can be read without locking while playing.
 type handlers struct {
+	mu      sync.Mutex
+	current atomic.Pointer[handlerSet]
+}
+
+type handlerSet struct {
 	newSongHandler   func(QueueEntry)
 	endOfSongHandler func(QueueEntry, error)
 	errorHander      func(error)
+	failoverHandler  func(from, to string)
+	expiryHandler    func(QueueEntry)
+}
+
+// load returns the current handlers.
+func (h *handlers) load() *handlerSet {
+	if set := h.current.Load(); set != nil {
+		return set
+	}
+	return &handlerSet{}
+}
+
+// update applies update to a copy of the current handlers and stores it.
+func (h *handlers) update(update func(*handlerSet)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	set := *h.load()
+	update(&set)
+	h.current.Store(&set)
 }
 
 // Media represents a video or song that can be streamed.
@@ -40,20 +104,121 @@ type Media struct {
 	Duration time.Duration
 }
 
+// Source identifies where a QueueEntry was requested from.
+type Source string
+
+// Common sources, any other value can be used as well.
+const (
+	SourceTwitch  Source = "twitch"
+	SourceDiscord Source = "discord"
+	SourceWeb     Source = "web"
+	SourceAPI     Source = "api"
+)
+
 // A QueueEntry represents media and metadata the can be ented into a queue.
 type QueueEntry struct {
+	// ID uniquely identifies the entry, it is assigned when the entry is added to a queue,
+	// an ID set by the caller is replaced.
+	ID uint64
+
 	Media      Media
 	Owner      string
 	Dedication string
+	// Anonymous hides the owner in announcements, webhooks and chat responses.
+	// The owner is still used for limits and statistics, see Public.
+	Anonymous bool
+	// Notes are for moderators only, like "second warning for this user".
+	// They are never serialized to JSON so they don't leak through public APIs, see SetNotes.
+	Notes string `json:"-"`
+
+	// Source is the platform the entry was requested from.
+	Source Source
+	// OwnerID is the platform specific ID of the owner, nicks are not always unique or stable.
+	OwnerID string
+	// RequestedAt is the time the entry was requested.
+	// It is set when the entry is added to the queue if it is zero.
+	RequestedAt time.Time
+
+	// Intro and Outro are optional clips, like a "requested by" voice clip,
+	// that are played immediately before and after the main media.
+	// Together with the media they count as a single entry.
+	Intro *Media
+	Outro *Media
+	// OutroOverlap starts the outro this long before the main media ends, crossfading into it,
+	// e.g. for voice tracks, see AddVoiceTrack. It is ignored while ducking is enabled.
+	OutroOverlap time.Duration
+
+	// Clip restricts playback to a portion of the media.
+	Clip ClipRange
+	// Profile overrides the encoding options for the entry including intro and outro, it may be nil.
+	Profile *EncodingProfile
+
+	// Tags are free-form labels, e.g. a genre or the show the entry was requested in.
+	Tags []string
+
+	// Dead is set by RefreshQueueMetadata if the media is no longer available.
+	// Dead entries are never played, a queue with only dead entries counts as empty.
+	Dead bool
+}
+
+// A ClipRange is a portion of media given by offsets from its start.
+//
+// A zero End means the media is played until it ends.
+type ClipRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ErrorInvalidClip is returned when an entry is added whose Clip starts before the media
+// or ends before it starts.
+var ErrorInvalidClip = errors.New("invalid clip range")
+
+// validate returns ErrorInvalidClip if the range is invalid.
+func (c ClipRange) validate() error {
+	if c.Start < 0 || c.End < 0 || (c.End > 0 && c.End <= c.Start) {
+		return fmt.Errorf("%w %s to %s", ErrorInvalidClip, c.Start, c.End)
+	}
+	return nil
+}
+
+// duration returns how long the clip of media with duration d is.
+func (c ClipRange) duration(d time.Duration) time.Duration {
+	if c.End > 0 && c.End < d {
+		d = c.End
+	}
+	d -= c.Start
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// inputArgs returns the ffmpeg input options to only read the clip.
+func (c ClipRange) inputArgs() []string {
+	var args []string
+	if c.Start > 0 {
+		args = append(args, "-ss", ffmpegDuration(c.Start))
+	}
+	if c.End > 0 {
+		args = append(args, "-to", ffmpegDuration(c.End))
+	}
+	return args
 }
 
-type queue struct {
-	Items []QueueEntry
-	sync.Mutex
+// TotalDuration returns the duration of the clipped media including intro and outro.
+func (e QueueEntry) TotalDuration() time.Duration {
+	d := e.Clip.duration(e.Media.Duration)
+	if e.Intro != nil {
+		d += e.Intro.Duration
+	}
+	if e.Outro != nil {
+		d += e.Outro.Duration - e.outroOverlap()
+	}
+	return d
 }
 
 // NewDj initializes and returns a new Dj struct.
-func NewDj(queue []QueueEntry) (dj *Dj) {
+func NewDj(queue []QueueEntry, options ...Option) (dj *Dj) {
 	_, err := exec.LookPath("yt-dlp")
 	if err != nil {
 		panic(err)
@@ -65,20 +230,30 @@ func NewDj(queue []QueueEntry) (dj *Dj) {
 	}
 
 	dj = &Dj{}
-	dj.waitingQueue.Items = queue
+	for _, option := range options {
+		option(dj)
+	}
+	dj.waitingQueue.ids = dj.ids
+	dj.pipeline.clock = dj.now
+	for i := range queue {
+		queue[i].assignID(dj.ids)
+	}
+	dj.waitingQueue.reset(queue)
 
 	return dj
 }
 
 // AddNewSongHandler adds a function that will be called every time a new song starts playing.
+//
+// Like all handlers it can be added while playing, see SetAsyncHandlers for the ordering guarantees.
 func (dj *Dj) AddNewSongHandler(f func(QueueEntry)) {
-	dj.handlers.newSongHandler = f
+	dj.handlers.update(func(h *handlerSet) { h.newSongHandler = f })
 }
 
 // AddEndOfSongHandler adds a function that will be called every time a song stops playing.
 // It gets passed the QueueEntry that finished playing and any errors encountered during playback.
 func (dj *Dj) AddEndOfSongHandler(f func(QueueEntry, error)) {
-	dj.handlers.endOfSongHandler = f
+	dj.handlers.update(func(h *handlerSet) { h.endOfSongHandler = f })
 }
 
 // AddPlaybackErrorHandler adds a function that will be called every time an error occurs during playback.
@@ -86,38 +261,92 @@ func (dj *Dj) AddEndOfSongHandler(f func(QueueEntry, error)) {
 // In effect this mean it will be called every time ffmpeg or yt-dlp exit with an error.
 // Sometimes ffmpeg can exit with code 1 even though the song was streamed successfully.
 func (dj *Dj) AddPlaybackErrorHandler(f func(error)) {
-	dj.handlers.errorHander = f
+	dj.handlers.update(func(h *handlerSet) { h.errorHander = f })
 }
 
-// Queue return the current queue as a list of queue entries.
+// Queue return a copy of the current queue as a list of queue entries.
 func (dj *Dj) Queue() []QueueEntry {
-	return dj.waitingQueue.Items
+	return dj.QueueInto(nil)
 }
 
-// AddEntry adds the passed QueueEntry at the end of the queue.
-func (dj *Dj) AddEntry(newEntry QueueEntry) {
+// QueueByAge returns a copy of the queue sorted by the time the entries were requested, oldest first.
+func (dj *Dj) QueueByAge() []QueueEntry {
 	dj.waitingQueue.Lock()
-	dj.waitingQueue.Items = append(dj.waitingQueue.Items, newEntry)
+	entries := dj.waitingQueue.items.appendTo(nil)
 	dj.waitingQueue.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].RequestedAt.Before(entries[j].RequestedAt)
+	})
+	return entries
+}
+
+// stamp sets RequestedAt to now if it was not set by the caller.
+func (e *QueueEntry) stamp(now time.Time) {
+	if e.RequestedAt.IsZero() {
+		e.RequestedAt = now
+	}
+}
+
+// AddEntry adds the passed QueueEntry at the end of the queue.
+//
+// If approval is required the entry is added to the pending list instead, see SetApprovalRequired.
+// If the media URL has a start time like ?t=90 and the entry has no clip start, playback starts there.
+// returns ErrorInvalidClip if the clip range is invalid, a *FilterError if the entry is rejected by the
+// content filter, the error of the admission hook, ErrorQueueFull if the queue limit is reached or
+// ErrorUserDurationCap if the owner has too much queued.
+func (dj *Dj) AddEntry(newEntry QueueEntry) error {
+	return dj.As(directActor).AddEntry(newEntry).Err
+}
+
+// addEntry is AddEntry without the audit log.
+func (dj *Dj) addEntry(newEntry QueueEntry) error {
+	newEntry.stamp(dj.now())
+	newEntry.clipFromURL()
+	if err := dj.admit(newEntry); err != nil {
+		return err
+	}
+	if dj.cfg().RequireApproval {
+		dj.addPending(newEntry)
+		return nil
+	}
+
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	if err := dj.room(newEntry); err != nil {
+		return err
+	}
+	dj.waitingQueue.insert(dj.waitingQueue.len(), newEntry)
+	return nil
 }
 
 // InsertEntry inserts the passed QueueEntry into the queue at the given index.
 //
 // if the index is too high it has the same effect as AddEntry().
-// returns an error if the index is < 0.
+// returns an error if the index is < 0, the entry is rejected by the content filter
+// or the queue limit or the owner's duration cap is reached.
 func (dj *Dj) InsertEntry(newEntry QueueEntry, index int) error {
-	dj.waitingQueue.Lock()
-	defer dj.waitingQueue.Unlock()
+	return dj.As(directActor).InsertEntry(newEntry, index).Err
+}
+
+// insertEntry is InsertEntry without the audit log.
+func (dj *Dj) insertEntry(newEntry QueueEntry, index int) error {
+	newEntry.stamp(dj.now())
+	newEntry.clipFromURL()
+	if err := dj.admit(newEntry); err != nil {
+		return err
+	}
+
+	dj.lockQueue()
+	defer dj.unlockQueue()
 
 	if index < 0 {
-		return errors.New("index out of range")
-	} else if index >= len(dj.waitingQueue.Items) {
-		dj.waitingQueue.Items = append(dj.waitingQueue.Items, newEntry)
-		return nil
+		return ErrorOutOfRange
+	} else if err := dj.room(newEntry); err != nil {
+		return err
 	}
-	dj.waitingQueue.Items = append(dj.waitingQueue.Items, QueueEntry{})
-	copy(dj.waitingQueue.Items[index+1:], dj.waitingQueue.Items[index:])
-	dj.waitingQueue.Items[index] = newEntry
+	dj.waitingQueue.insert(index, newEntry)
 	return nil
 }
 
@@ -125,42 +354,155 @@ func (dj *Dj) InsertEntry(newEntry QueueEntry, index int) error {
 //
 // returns an error if the index is out of range.
 func (dj *Dj) RemoveIndex(index int) error {
-	dj.waitingQueue.Lock()
-	defer dj.waitingQueue.Unlock()
-	if index >= len(dj.waitingQueue.Items) || index < 0 {
-		return errors.New("index out of range")
+	return dj.As(directActor).RemoveIndex(index).Err
+}
+
+// MoveIndex moves the QueueEntry at index from to index to, shifting the entries in between.
+//
+// returns an error if either index is out of range.
+func (dj *Dj) MoveIndex(from, to int) error {
+	return dj.As(directActor).MoveIndex(from, to).Err
+}
+
+// moveIndex is MoveIndex without the audit log.
+func (dj *Dj) moveIndex(from, to int) error {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	if from < 0 || from >= dj.waitingQueue.len() || to < 0 || to >= dj.waitingQueue.len() {
+		return ErrorOutOfRange
+	}
+	if from != to {
+		dj.waitingQueue.move(from, to)
 	}
-	dj.waitingQueue.Items = append(dj.waitingQueue.Items[:index], dj.waitingQueue.Items[index+1:]...)
 	return nil
 }
 
-// ChangeIndex swaps the QueueEntry the index for the provided one
+// ChangeIndex swaps the QueueEntry the index for the provided one, which gets a new ID.
 //
-// returns an error if the index is out of range
+// returns an error if the index is out of range or the entry is rejected by the content filter.
 func (dj *Dj) ChangeIndex(newEntry QueueEntry, index int) error {
-	dj.waitingQueue.Lock()
-	defer dj.waitingQueue.Unlock()
+	return dj.As(directActor).ChangeIndex(newEntry, index).Err
+}
 
-	if index < 0 || index >= len(dj.waitingQueue.Items) {
-		return errors.New("index out of range")
+// changeIndex is ChangeIndex without the audit log.
+func (dj *Dj) changeIndex(newEntry QueueEntry, index int) error {
+	if err := dj.admit(newEntry); err != nil {
+		return err
 	}
 
-	dj.waitingQueue.Items[index] = newEntry
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	if index < 0 || index >= dj.waitingQueue.len() {
+		return ErrorOutOfRange
+	}
+
+	dj.waitingQueue.replace(index, newEntry)
 
 	return nil
 }
 
+// pop removes and returns the entry that should be played next.
+//
+// Expired entries are dropped first.
 func (dj *Dj) pop() (QueueEntry, error) {
+	var expired []QueueEntry
+	// handlers are called after the lock was released
+	defer func() { dj.notifyExpired(expired) }()
+
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	expired = dj.expire(dj.now())
+
+	cfg := dj.cfg()
+	for {
+		q, index, err := dj.selectNext()
+		dj.upNext = 0
+		if err != nil {
+			return QueueEntry{}, err
+		}
+		if cfg.absent(q.at(index), PresenceSkip) {
+			expired = append(expired, q.remove(index))
+			continue
+		}
+		entry := q.remove(index)
+		if cfg.Scheduler != nil {
+			cfg.Scheduler.Played(entry, dj.now())
+		}
+		return entry, nil
+	}
+}
+
+// peek returns the entry that will be played next without removing it.
+//
+// The entry is reserved, so pop returns it as long as it is still in a queue and eligible,
+// even if the scheduler would pick another one by then.
+func (dj *Dj) peek() (QueueEntry, error) {
 	dj.waitingQueue.Lock()
 	defer dj.waitingQueue.Unlock()
 
-	if len(dj.waitingQueue.Items) < 1 {
-		return QueueEntry{}, ErrorEmptyQueue
+	q, index, err := dj.selectNext()
+	if err != nil {
+		return QueueEntry{}, err
 	}
+	dj.upNext = q.at(index).ID
+	return q.at(index), nil
+}
 
-	entry := dj.waitingQueue.Items[0]
-	dj.waitingQueue.Items = dj.waitingQueue.Items[1:]
-	return entry, nil
+// selectNext returns the queue and index of the entry that should be played next, the lock has to be held.
+//
+// Queues are drained in priority order and within a queue the scheduler picks the entry.
+func (dj *Dj) selectNext() (*queue, int, error) {
+	quota := dj.eligible(dj.now())
+	// dead entries stay in the queue to be reviewed, but they are never played
+	eligible := func(entry QueueEntry) bool { return !entry.Dead && quota(entry) }
+	if dj.upNext != 0 {
+		if q, index, ok := dj.findEntry(dj.upNext); ok && eligible(q.at(index)) {
+			return q, index, nil
+		}
+	}
+	cfg := dj.cfg()
+	empty := true
+	for _, q := range dj.drainOrder() {
+		var eligibleEntries []QueueEntry
+		var eligibleIndices []int
+		q.each(func(i int, entry QueueEntry) bool {
+			if !entry.Dead {
+				empty = false
+			}
+			if eligible(entry) {
+				eligibleEntries = append(eligibleEntries, entry)
+				eligibleIndices = append(eligibleIndices, i)
+			}
+			return true
+		})
+		if len(eligibleEntries) == 0 {
+			continue
+		}
+
+		var candidates []QueueEntry
+		var indices []int
+		for _, i := range cfg.preferPresent(eligibleEntries) {
+			candidates = append(candidates, eligibleEntries[i])
+			indices = append(indices, eligibleIndices[i])
+		}
+
+		next := 0
+		if cfg.Scheduler != nil {
+			next = cfg.Scheduler.Next(candidates)
+			if next < 0 || next >= len(candidates) {
+				next = 0
+			}
+		}
+		return q, indices[next], nil
+	}
+
+	if empty {
+		return nil, 0, ErrorEmptyQueue
+	}
+	return nil, 0, errNothingPlayable
 }
 
 // EntryAtIndex returns the QueueEntry at the given index or error if the index is out of range
@@ -168,11 +510,11 @@ func (dj *Dj) EntryAtIndex(index int) (QueueEntry, error) {
 	dj.waitingQueue.Lock()
 	defer dj.waitingQueue.Unlock()
 
-	if index >= len(dj.waitingQueue.Items) || index < 0 {
-		return QueueEntry{}, errors.New("index out of range")
+	if index >= dj.waitingQueue.len() || index < 0 {
+		return QueueEntry{}, ErrorOutOfRange
 	}
 
-	entry := dj.waitingQueue.Items[index]
+	entry := dj.waitingQueue.at(index)
 
 	return entry, nil
 }
@@ -181,105 +523,429 @@ func (dj *Dj) EntryAtIndex(index int) (QueueEntry, error) {
 //
 // If nothing is in the playlist it waits for new content to be added.
 // Any encoutered errors are handled by the errorHandler.
-func (dj *Dj) Play(rtmpServer string) {
-	const fifoPath = "/tmp/opendj-fifo"
-	_ = os.Remove(fifoPath)
+//
+// If the RTMP server keeps failing the stream fails over to the failover servers in order,
+// switching back to rtmpServer once it was reachable for a while, see RTMPOutput.
+func (dj *Dj) Play(rtmpServer string, failover ...string) {
+	output := RTMPOutput{
+		URL:      rtmpServer,
+		Failover: failover,
+		OnFailover: func(from, to string) {
+			if handler := dj.handlers.load().failoverHandler; handler != nil {
+				dj.callHandler("failover", func() { handler(from, to) })
+			}
+		},
+	}
+	if err := dj.PlayTo(context.Background(), output); err != nil {
+		dj.reportError(err)
+	}
+}
 
-	if err := syscall.Mkfifo(fifoPath, 0o0644); err != nil {
-		panic(err)
+// PlayTo starts the playback to all given outputs and blocks until it ends.
+//
+// Without outputs it streams to the Outputs of the configuration, adding and removing
+// outputs as the configuration changes, see Config.Outputs.
+//
+// If nothing is in the playlist it waits for new content to be added.
+// The encoder and every output are supervised independently: if one of them fails the
+// error is passed to the errorHandler and only that component is restarted. Components
+// that keep failing are given up, playback continues as long as at least one output
+// is running or until ctx is cancelled.
+func (dj *Dj) PlayTo(ctx context.Context, outputs ...Output) error {
+	configured := len(outputs) == 0
+	named := make(map[string]Output, len(outputs))
+	for i, output := range outputs {
+		named[strconv.Itoa(i+1)] = output
+	}
+	if configured {
+		named = dj.cfg().Outputs
+	}
+	if len(named) == 0 {
+		return errors.New("no outputs given")
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(dj.withClock(dj.withLimits(ctx)))
+	defer cancel()
+	if n := dj.cfg().WarmUp; n > 0 {
+		dj.warmUp(ctx, n)
 	}
+	dj.history.startSession(dj.now())
 
-	eg := errgroup.Group{}
-	eg.Go(func() error {
-		emptyStreamCounter := 0
+	cfg := dj.cfg()
+	stream := newFanout(0, cfg.OutputBufferSize, cfg.OutputBufferPolicy, cfg.TargetLatency)
+	dj.health.started(dj.now())
+	defer dj.health.stopped()
 
-		fifo, err := os.OpenFile(fifoPath, os.O_CREATE|os.O_WRONLY, os.ModeNamedPipe)
-		if err != nil {
-			return err
-		}
-		defer fifo.Close()
+	encoderErrs, outputErrs := make(chan error), make(chan error)
+	encoderDone, outputDone := make(chan struct{}), make(chan struct{})
+	go dj.reportErrors(encoderErrs, encoderDone)
+	go dj.reportErrors(outputErrs, outputDone)
 
-		for {
-			entry, err := dj.pop()
+	dj.pipeline.reset()
+	encoderState := dj.pipeline.tracker("encoder")
+	outputGroup := errgroup.Group{}
+	var started, running, givenUp atomic.Int32
+	// start supervises the output until it is given up or stop is called
+	start := func(name string, output Output) (stop func()) {
+		outputCtx, stop := context.WithCancel(ctx)
+		name = "output " + name
+		state, latency := dj.reconnectTracker(name), dj.pipeline.latencyOf(name)
+		started.Add(1)
+		running.Add(1)
+		stream.outputAdded()
+		dj.health.outputAdded()
+		outputGroup.Go(func() error {
+			err := dj.supervise(outputCtx, outputErrs, state, func(ctx context.Context) error {
+				r := stream.add()
+				latency(r.Latency)
+				err := output.Start(ctx, r)
+				r.CloseWithError(errNoOutputs)
+				return err
+			})
+			dj.health.outputStopped()
+			stream.outputStopped()
+			if outputCtx.Err() != nil && ctx.Err() == nil {
+				// the output was removed from the configuration
+				dj.pipeline.remove(name)
+			}
 			if err != nil {
-				dj.currentEntry = QueueEntry{}
-				// In the case that the queue is empty, input 15 seconds of
-				// silence into the pipe up to 4 consecutive times before
-				// returning
-				if errors.Is(err, ErrorEmptyQueue) {
-					if emptyStreamCounter >= 4 {
-						break
+				givenUp.Add(1)
+				dj.reportError(fmt.Errorf("output given up: %w", err))
+			}
+			if running.Add(-1) == 0 {
+				// nobody is listening anymore
+				cancel()
+			}
+			return nil
+		})
+		return stop
+	}
+	stops := make(map[string]func(), len(named))
+	for _, name := range sortedNames(named) {
+		stops[name] = start(name, named[name])
+	}
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	if configured {
+		changed := dj.config.watch()
+		outputGroup.Go(func() error {
+			for {
+				select {
+				case <-watchCtx.Done():
+					return nil
+				case <-changed:
+				}
+				changed = dj.config.watch()
+				current := dj.cfg().Outputs
+				for name, stop := range stops {
+					if _, ok := current[name]; !ok {
+						stop()
+						delete(stops, name)
 					}
-
-					if err = writeToFIFO(
-						fifo,
-						"-re",
-						"-t", "00:00:15",
-						"-f", "lavfi",
-						"-i", "anullsrc",
-					); err != nil {
-						return err
+				}
+				for _, name := range sortedNames(current) {
+					if _, ok := stops[name]; !ok {
+						stops[name] = start(name, current[name])
 					}
-
-					emptyStreamCounter++
-					continue
 				}
+			}
+		})
+	}
+
+	// outputs read at real time, if their buffers drop data the encoder has to keep that pace
+	var paced io.Writer = stream
+	if cfg.OutputBufferPolicy == BufferDropOldest || cfg.TargetLatency > 0 {
+		paced = newPacer(stream, paceLead)
+	}
+	writer := healthWriter{w: paced, health: &dj.health, now: dj.now}
+	// stopping only the encoder ends the stream, the outputs finish on their own
+	encoderCtx, stopped := dj.stopper.start(ctx)
+	stream.cancelOn(encoderCtx)
+	err := dj.supervise(encoderCtx, encoderErrs, encoderState, func(ctx context.Context) error {
+		return dj.encode(ctx, writer)
+	})
+	stopped()
+	stopWatching()
+	stream.Close()
+	_ = outputGroup.Wait()
+	close(encoderErrs)
+	close(outputErrs)
+	<-encoderDone
+	<-outputDone
+	if givenUp.Load() == started.Load() && parent.Err() == nil {
+		return errNoOutputs
+	}
+	return err
+}
+
+// sortedNames returns the names of the outputs in alphabetical order.
+func sortedNames(outputs map[string]Output) []string {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
+// encode writes the queue into the stream until it is empty for a while or ctx is cancelled.
+func (dj *Dj) encode(ctx context.Context, stream io.Writer) error {
+	emptyStreamCounter := 0
+	for ctx.Err() == nil && !dj.stopper.stopping() {
+		if b, ok := dj.breaks.take(); ok {
+			dj.setCurrentEntry(QueueEntry{})
+			if err := dj.playBreak(ctx, stream, b); err != nil && !errors.Is(err, ErrorSkipped) {
 				return err
 			}
+			continue
+		}
+
+		if dj.circuitOpen() {
+			// leave the queue alone until the cooldown is over
+			if err := dj.playFallback(ctx, stream); err != nil {
+				return err
+			}
+			continue
+		}
 
-			dj.currentEntry = entry
-			output, err := exec.Command("yt-dlp", "-f", "bestaudio", "-g", entry.Media.URL).Output()
+		if element, ok := dj.wheel.next(dj.localNow(), dj.hasRequests()); ok && element.Kind != ClockRequests {
+			played, err := dj.playClockElement(ctx, stream, element)
 			if err != nil {
 				return err
 			}
-			audioURL := strings.TrimSpace(string(output))
+			if played {
+				emptyStreamCounter = 0
+				continue
+			}
+		}
+
+		entry, err := dj.pop()
+		if err != nil {
+			dj.setCurrentEntry(QueueEntry{})
+			// In the case that the queue is empty, input 15 seconds of
+			// silence or idle filler into the pipe up to 4 consecutive times before
+			// returning
+			if errors.Is(err, ErrorEmptyQueue) {
+				if entry, ok := dj.fallback.next(dj.now()); ok {
+					emptyStreamCounter = 0
+					dj.setCurrentEntry(entry)
+					if err = dj.playEntry(ctx, stream, entry); err != nil && !errors.Is(err, ErrorSkipped) {
+						return err
+					}
+					continue
+				}
+
+				if emptyStreamCounter >= 4 {
+					break
+				}
 
-			if dj.handlers.newSongHandler != nil {
-				dj.handlers.newSongHandler(entry)
+				if err = dj.writeIdle(ctx, stream, 15*time.Second); err != nil {
+					return err
+				}
+
+				emptyStreamCounter++
+				continue
+			} else if errors.Is(err, errNothingPlayable) {
+				// wait for entries to become playable again
+				if err = dj.writeIdle(ctx, stream, 15*time.Second); err != nil {
+					return err
+				}
+				continue
 			}
 
-			dj.songStarted = time.Now()
-			if err = writeToFIFO(
-				fifo,
-				"-reconnect", "1",
-				"-i", audioURL,
-				"-af", "apad=pad_dur=5",
-			); err != nil {
+			return err
+		}
+
+		dj.setCurrentEntry(entry)
+		err = dj.playEntry(ctx, stream, entry)
+		if ctx.Err() == nil {
+			dj.recordResult(err)
+		}
+		if err != nil && !errors.Is(err, ErrorSkipped) {
+			if dj.cfg().BreakerThreshold <= 0 || ctx.Err() != nil {
 				return err
 			}
+			// the breaker takes care of repeated failures, so a broken track does not stop the encoder
+			dj.reportError(err)
+		}
+
+		if handler := dj.handlers.load().endOfSongHandler; handler != nil {
+			entry, err := entry, err
+			dj.callHandler(EventSongEnded, func() { handler(entry, err) })
+		}
+		dj.publish(Event{Type: EventSongEnded, Entry: entry, Err: err})
 
-			if dj.handlers.endOfSongHandler != nil {
-				dj.handlers.endOfSongHandler(entry, err)
+		if ctx.Err() == nil && !dj.stopper.stopping() {
+			if err := dj.writeStinger(ctx, stream); err != nil && ctx.Err() == nil {
+				dj.reportError(err)
 			}
 		}
-		return nil
-	})
+	}
+	return nil
+}
 
-	eg.Go(func() error {
-		time.Sleep(5 * time.Second)
+// playEntry plays the entry including its intro and outro into the stream.
+//
+// returns ErrorSkipped if the entry was skipped.
+func (dj *Dj) playEntry(ctx context.Context, stream io.Writer, entry QueueEntry) (err error) {
+	ctx, skipped, done := dj.skipper.track(ctx, entry.ID)
+	defer done()
+	defer func() {
+		if err != nil && skipped() {
+			err = ErrorSkipped
+		}
+	}()
 
-		cmd := exec.Command(
-			"ffmpeg",
-			"-re",
-			"-i", fifoPath,
-			"-c", "copy",
-			"-f", "flv",
-			rtmpServer,
-		)
+	var intro, outro io.ReadCloser
+	audio, err := dj.openTrack(ctx, entry.Media)
+	if err != nil {
+		return err
+	}
+	// audio is reopened by seeks
+	defer func() { audio.Close() }()
+	if entry.Intro != nil {
+		if intro, err = dj.openTrack(ctx, *entry.Intro); err != nil {
+			return err
+		}
+		defer intro.Close()
+	}
+	if entry.Outro != nil {
+		if outro, err = dj.openTrack(ctx, *entry.Outro); err != nil {
+			return err
+		}
+		defer outro.Close()
+	}
+
+	if handler := dj.handlers.load().newSongHandler; handler != nil {
+		dj.callHandler(EventSongStarted, func() { handler(entry) })
+	}
+	dj.publish(Event{Type: EventSongStarted, Entry: entry})
+
+	if err = dj.writePreRoll(ctx, stream); err != nil {
+		return err
+	}
 
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to stream from fifo: %w", err)
+	started := dj.now()
+	dj.clock.start(started, entry.Clip.Start)
+	dj.history.started(entry, started)
+	defer func() {
+		dj.clock.stop(dj.now())
+		if err != nil && skipped() {
+			err = ErrorSkipped
 		}
+		dj.history.ended(dj.now(), err)
+	}()
+	go dj.announceUpNext(ctx)
+	cfg := dj.cfg()
 
-		return nil
-	})
+	if intro != nil {
+		gain := cfg.gainFilter(dj.now(), entry.Intro.Duration)
+		args := append([]string{"-i", "pipe:0"}, entry.Profile.filterArgs(gain)...)
+		if err = writeProfileStream(ctx, stream, pausableReader{ctx, &dj.clock, intro}, entry.Profile, nil, args...); err != nil {
+			return err
+		}
+	}
 
-	if err := eg.Wait(); err != nil {
-		if dj.handlers.errorHander != nil {
-			dj.handlers.errorHander(err)
+	// a seek restarts the track at the new position, see Seek
+	archive := dj.archiveArgs(entry, dj.now())
+	track := entry
+	for {
+		trackCtx, seeked := dj.skipper.seekable(ctx)
+		outro, err = dj.playTrack(trackCtx, stream, track, audio, outro, archive)
+		position, ok := seeked()
+		if !ok || ctx.Err() != nil {
+			break
+		}
+		audio.Close()
+		if audio, err = dj.openTrack(ctx, entry.Media); err != nil {
+			return err
+		}
+		// the archive can't be continued, it ends at the first seek
+		track.Clip.Start, archive = position, nil
+		elapsed := position - entry.Clip.Start
+		if entry.Intro != nil {
+			elapsed += entry.Intro.Duration
 		}
+		dj.clock.seek(dj.now(), elapsed)
 	}
+	if err != nil {
+		return err
+	}
+
+	if outro != nil {
+		gain := cfg.gainFilter(dj.now(), entry.Outro.Duration)
+		args := append([]string{"-i", "pipe:0"}, entry.Profile.filterArgs(cfg.padFilter(), gain)...)
+		err = writeProfileStream(ctx, stream, pausableReader{ctx, &dj.clock, outro}, entry.Profile, nil, args...)
+	}
+	return err
+}
+
+// playTrack plays the clip of the entry from audio into the stream, without intro and outro.
+//
+// The outro is mixed into the end of the track if the entry crossfades, it returns the outro
+// if it still has to be played.
+func (dj *Dj) playTrack(ctx context.Context, stream io.Writer, entry QueueEntry, audio, outro io.ReadCloser, archive []string) (io.ReadCloser, error) {
+	var err error
+	cfg := dj.cfg()
+
+	// the pause between songs goes after the outro if there is one
+	pad := cfg.padFilter()
+	if outro != nil {
+		pad = "anull"
+	}
+	var input io.Reader = pausableReader{ctx, &dj.clock, audio}
+	copyAudio := false
+	gain := cfg.gainFilter(dj.now(), entry.Clip.duration(entry.Media.Duration))
+	replayGain := dj.replayGainFilter(ctx, entry.Media)
+	userVolume := dj.userVolumeFilter(entry)
+	fade := entry.clipFade(cfg.SkipFade)
+	overlap := entry.outroOverlap()
+	if cfg.ducking() {
+		overlap = 0
+	}
+	if cfg.TranscodeMode == TranscodeAuto && !entry.Profile.transcoded() && gain == "" && replayGain == "" && userVolume == "" && cfg.SkipFade <= 0 && overlap <= 0 {
+		var info streamInfo
+		// a failed probe is not fatal, the track is transcoded instead
+		info, input, _ = peekStreamInfo(ctx, input)
+		copyAudio = info.copyable()
+	}
+
+	args := append(entry.Clip.inputArgs(), "-i", "pipe:0")
+	var soft *fader
+	if cfg.SkipFade > 0 {
+		if soft, err = decodePCM(ctx, input, cfg.SkipFade, args...); err != nil {
+			return outro, err
+		}
+		defer soft.Close()
+		dj.skipper.setFade(soft.start)
+		input, args = soft, append([]string(nil), pcmInputArgs...)
+	}
+	if copyAudio {
+		if err = copyStream(ctx, stream, input, archive, args...); err != nil {
+			return outro, err
+		}
+		if outro == nil && cfg.gap() > 0 {
+			err = writeSilence(ctx, stream, cfg.gap())
+		}
+	} else if cfg.ducking() {
+		err = dj.writeDuckedStream(ctx, stream, input, entry.Profile, archive, args, replayGain, userVolume, fade, pad, gain)
+	} else if overlap > 0 {
+		outroFilters := []string{cfg.padFilter(), cfg.gainFilter(dj.now(), entry.Outro.Duration)}
+		err = writeCrossfadedStream(ctx, stream, input, outro, overlap, entry.Profile, archive, args, outroFilters, replayGain, userVolume, fade, pad, gain)
+		// the outro was mixed into the track
+		outro = nil
+	} else {
+		args = append(args, entry.Profile.filterArgs(replayGain, userVolume, fade, pad, gain)...)
+		err = writeProfileStream(ctx, stream, input, entry.Profile, archive, args...)
+	}
+	if err != nil {
+		return outro, err
+	}
+	if soft != nil && soft.faded() {
+		// the outro is skipped as well
+		return outro, ErrorSkipped
+	}
+	return outro, nil
 }
 
 // UserPosition returns a slice of all the position in the queue that belong to the given user.
@@ -287,7 +953,7 @@ func (dj *Dj) UserPosition(nick string) (positions []int) {
 	dj.waitingQueue.Lock()
 	defer dj.waitingQueue.Unlock()
 
-	for i, content := range dj.waitingQueue.Items {
+	for i, content := range dj.waitingQueue.entries() {
 		if content.Owner == nick {
 			positions = append(positions, i)
 		}
@@ -296,45 +962,99 @@ func (dj *Dj) UserPosition(nick string) (positions []int) {
 }
 
 // DurationUntilUser returns a slice of all the durations to the songs in the queue that belong to the given user.
+//
+// The estimate includes the gaps between songs, the pre-roll, a scheduled break and the entries
+// of queues with a higher priority. Announcements are mixed into songs and don't add any time.
 func (dj *Dj) DurationUntilUser(nick string) (durations []time.Duration) {
 	dj.waitingQueue.Lock()
 	defer dj.waitingQueue.Unlock()
 
-	dur := dj.currentEntry.Media.Duration - time.Since(dj.songStarted)
-	for _, content := range dj.waitingQueue.Items {
+	cfg := dj.cfg()
+	overhead := cfg.overhead()
+	dur := dj.remaining()
+	if dur > 0 {
+		dur += cfg.gap()
+	}
+	dur += dj.breaks.pending()
+	// queues with a higher priority are played first
+	for _, named := range dj.queues {
+		if named.priority > 0 {
+			for _, content := range named.queue.entries() {
+				dur += content.TotalDuration() + overhead
+			}
+		}
+	}
+	for _, content := range dj.waitingQueue.entries() {
 		if content.Owner == nick {
-			durations = append(durations, dur)
+			// the pre-roll of the song itself is waited for as well
+			durations = append(durations, dur+cfg.preRoll())
 		}
-		dur += content.Media.Duration
+		dur += content.TotalDuration() + overhead
 	}
 	return durations
 }
 
-// CurrentlyPlaying returns the song that is currently being played and for how long it has been playing.
+// CurrentlyPlaying returns the song that is currently being played, how long it has been playing,
+// the health of the pipeline and the show that is on.
 //
-// Returns an error if there is nothing playing.
-func (dj *Dj) CurrentlyPlaying() (entry QueueEntry, progress time.Duration, err error) {
-	if dj.currentEntry.Media == (Media{}) {
-		err = errors.New("there is no song being played")
+// Returns ErrorNothingPlaying if there is nothing playing.
+func (dj *Dj) CurrentlyPlaying() (NowPlaying, error) {
+	dj.clock.Lock()
+	defer dj.clock.Unlock()
+
+	entry := dj.currentEntry
+	if entry.Media == (Media{}) {
+		return NowPlaying{}, ErrorNothingPlaying
+	}
+	now := dj.now()
+	np := NowPlaying{
+		EntryID:  entry.ID,
+		Entry:    entry,
+		Started:  dj.clock.started,
+		Progress: dj.clock.elapsed(now),
+		Paused:   dj.clock.resumed != nil,
+		Health:   dj.pipeline.health(),
+	}
+	if remaining := entry.TotalDuration() - np.Progress; remaining > 0 {
+		np.Remaining = remaining
+	}
+	if show, ok := dj.CurrentShow(); ok {
+		np.Show = &show
 	}
-	return dj.currentEntry, time.Since(dj.songStarted), err
+	return np, nil
 }
 
-func writeToFIFO(fifo *os.File, args ...string) error {
-	args = append(args, []string{
-		"-c:a", "aac",
-		"-strict", "-2",
-		"-ar", "44100",
-		"-b:a", "160k",
-		"-ac", "2",
-		"-f", "mpegts", "pipe:1",
-	}...)
+// writeStream transcodes the input given by args into the stream.
+//
+// input is passed to ffmpeg as stdin, it may be nil.
+// If extraOutput is not empty it is added as an additional ffmpeg output.
+func writeStream(ctx context.Context, stream io.Writer, input io.Reader, extraOutput []string, args ...string) error {
+	return writeProfileStream(ctx, stream, input, nil, extraOutput, args...)
+}
 
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = fifo
+// writeProfileStream is like writeStream, but encodes with the options of profile, which may be nil.
+// Filters of the profile have to be added to args with filterArgs.
+func writeProfileStream(ctx context.Context, stream io.Writer, input io.Reader, profile *EncodingProfile, extraOutput []string, args ...string) error {
+	args = append(args, profile.outputArgs()...)
+	return runEncoder(ctx, stream, input, append(args, extraOutput...))
+}
+
+// runEncoder runs ffmpeg with the given arguments, writing its output into the stream.
+//
+// extraFiles are passed to ffmpeg as pipe:3 and so on.
+func runEncoder(ctx context.Context, stream io.Writer, input io.Reader, args []string, extraFiles ...*os.File) error {
+	cmd := newCommand(ctx, "ffmpeg", args...)
+	cmd.Stdin = input
+	cmd.Stdout = stream
+	cmd.ExtraFiles = extraFiles
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to write to pipe: %w", err)
+		return fmt.Errorf("failed to write to stream: %w", err)
 	}
 	return nil
 }
+
+// ffmpegDuration formats d as seconds the way ffmpeg expects durations.
+func ffmpegDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,21 @@
+package opendj
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestDj returns a Dj for tests. yt-dlp and ffmpeg are replaced by scripts that copy
+// their input to their output, so tests don't need them installed.
+func newTestDj(t testing.TB, queue []QueueEntry, options ...Option) *Dj {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"yt-dlp", "ffmpeg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\nexec cat\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return NewDj(queue, append([]Option{WithIDGenerator(&SequentialIDs{})}, options...)...)
+}
@@ -0,0 +1,65 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestFanoutWaitsForRestart checks that writes wait while the only output is restarted
+// and only fail once it was given up.
+func TestFanoutWaitsForRestart(t *testing.T) {
+	stream := newFanout(1, 1024, BufferBlock, 0)
+	stream.cancelOn(context.Background())
+
+	// the output failed and waits to be restarted
+	stream.add().CloseWithError(errors.New("connection lost"))
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := stream.Write([]byte("track"))
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		t.Fatalf("Write() returned %v while the output was restarting", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r := stream.add()
+	if err := <-written; err != nil {
+		t.Fatalf("Write() = %v after the output was restarted", err)
+	}
+	p := make([]byte, 16)
+	if n, _ := r.Read(p); string(p[:n]) != "track" {
+		t.Errorf("restarted output read %q, want %q", p[:n], "track")
+	}
+
+	r.CloseWithError(nil)
+	stream.outputStopped()
+	if _, err := stream.Write([]byte("next")); !errors.Is(err, errNoOutputs) {
+		t.Errorf("Write() = %v after the output was given up, want %v", err, errNoOutputs)
+	}
+}
+
+func TestFanoutCancelled(t *testing.T) {
+	stream := newFanout(1, 1024, BufferBlock, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream.cancelOn(ctx)
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := stream.Write([]byte("track"))
+		written <- err
+	}()
+	cancel()
+	select {
+	case err := <-written:
+		if !errors.Is(err, errNoOutputs) {
+			t.Errorf("Write() = %v after cancel, want %v", err, errNoOutputs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write() still blocks after cancel")
+	}
+}
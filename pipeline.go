@@ -0,0 +1,226 @@
+package opendj
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ComponentStatus is the state of a pipeline component.
+type ComponentStatus string
+
+// The states of a pipeline component.
+const (
+	ComponentIdle       ComponentStatus = "idle"
+	ComponentRunning    ComponentStatus = "running"
+	ComponentRestarting ComponentStatus = "restarting"
+	ComponentFailed     ComponentStatus = "failed"
+)
+
+// maxTrackedFailures is how many resolution failures are kept for MaintainYtdlp.
+const maxTrackedFailures = 1000
+
+// resolverComponent is the name of the resolver component, the encoder and outputs are added by PlayTo.
+const resolverComponent = "resolver"
+
+// ComponentState describes a component of the pipeline, like the encoder or an output.
+type ComponentState struct {
+	Name   string
+	Status ComponentStatus
+	// LastError is the most recent error of the component, it is kept after it recovered.
+	LastError error
+	// Restarts is how often the component was restarted since playback started.
+	Restarts int
+	// Since is when the component entered its current state.
+	Since time.Time
+	// Latency is how long audio waits in the buffer of an output, it is zero for other components.
+	Latency time.Duration
+}
+
+type pipeline struct {
+	sync.Mutex
+	components []*ComponentState
+	resolving  int
+	// latency returns the current latency of the output with the given name
+	latency map[string]func() time.Duration
+	// failures are the times resolutions failed for other reasons than unavailable media
+	failures []time.Time
+	// clock is the clock of the Dj, nil uses the system clock
+	clock func() time.Time
+}
+
+func (p *pipeline) now() time.Time {
+	if p.clock == nil {
+		return time.Now()
+	}
+	return p.clock()
+}
+
+// PipelineState returns the state of every pipeline component, so operators can build status pages.
+//
+// The components are the resolver, the encoder and every output passed to PlayTo, named "output 1" and so on.
+func (dj *Dj) PipelineState() []ComponentState {
+	return dj.PipelineStateInto([]ComponentState{})
+}
+
+// PipelineStateInto is like PipelineState, but copies the states into buf, reusing its memory, see QueueInto.
+func (dj *Dj) PipelineStateInto(buf []ComponentState) []ComponentState {
+	dj.pipeline.Lock()
+	defer dj.pipeline.Unlock()
+
+	states := buf[:0]
+	for _, c := range dj.pipeline.components {
+		state := *c
+		if latency, ok := dj.pipeline.latency[c.Name]; ok {
+			state.Latency = latency()
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// health returns the worst status of all components, ComponentRunning if there are none.
+func (p *pipeline) health() ComponentStatus {
+	p.Lock()
+	defer p.Unlock()
+
+	health := ComponentRunning
+	for _, c := range p.components {
+		switch {
+		case c.Status == ComponentFailed:
+			return ComponentFailed
+		case c.Status == ComponentRestarting:
+			health = ComponentRestarting
+		}
+	}
+	return health
+}
+
+// component returns the component with the given name, adding it if it does not exist. The lock has to be held.
+func (p *pipeline) component(name string) *ComponentState {
+	for _, c := range p.components {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &ComponentState{Name: name, Status: ComponentIdle, Since: p.now()}
+	p.components = append(p.components, c)
+	return c
+}
+
+// set updates the state of a component, err is only recorded if it is not nil.
+func (p *pipeline) set(name string, status ComponentStatus, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	c := p.component(name)
+	if status == ComponentRestarting {
+		c.Restarts++
+	}
+	if c.Status != status {
+		c.Status, c.Since = status, p.now()
+	}
+	if err != nil {
+		c.LastError = err
+	}
+}
+
+// reset removes all components but the resolver, it is called when playback starts.
+func (p *pipeline) reset() {
+	p.Lock()
+	defer p.Unlock()
+
+	kept := p.components[:0]
+	for _, c := range p.components {
+		if c.Name == resolverComponent {
+			kept = append(kept, c)
+		}
+	}
+	p.components = kept
+	p.latency = nil
+}
+
+// remove removes a component that is not part of the pipeline anymore.
+func (p *pipeline) remove(name string) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i, c := range p.components {
+		if c.Name == name {
+			p.components = append(p.components[:i], p.components[i+1:]...)
+			break
+		}
+	}
+	delete(p.latency, name)
+}
+
+// latencyOf returns a function to set how the latency of the component is measured.
+func (p *pipeline) latencyOf(name string) func(func() time.Duration) {
+	return func(latency func() time.Duration) {
+		p.Lock()
+		defer p.Unlock()
+		if p.latency == nil {
+			p.latency = make(map[string]func() time.Duration)
+		}
+		p.latency[name] = latency
+	}
+}
+
+// resolveStarted and resolveDone track the resolutions in progress.
+func (p *pipeline) resolveStarted() {
+	p.Lock()
+	defer p.Unlock()
+	p.resolving++
+	c := p.component(resolverComponent)
+	if c.Status != ComponentRunning {
+		c.Status, c.Since = ComponentRunning, p.now()
+	}
+}
+
+func (p *pipeline) resolveDone(err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.resolving--
+	c := p.component(resolverComponent)
+	if err != nil {
+		c.LastError = err
+		if !errors.Is(err, ErrorUnavailable) {
+			p.failures = append(p.failures, p.now())
+			if len(p.failures) > maxTrackedFailures {
+				p.failures = p.failures[len(p.failures)-maxTrackedFailures:]
+			}
+		}
+	}
+	if p.resolving == 0 {
+		c.Status, c.Since = ComponentIdle, p.now()
+	}
+}
+
+// recentFailures returns how many resolutions failed within window.
+func (p *pipeline) recentFailures(window time.Duration) int {
+	p.Lock()
+	defer p.Unlock()
+
+	now := p.now()
+	count := 0
+	for _, failure := range p.failures {
+		if now.Sub(failure) <= window {
+			count++
+		}
+	}
+	return count
+}
+
+func (p *pipeline) clearFailures() {
+	p.Lock()
+	defer p.Unlock()
+	p.failures = nil
+}
+
+// tracker returns a function for supervise that records the state changes of the component.
+func (p *pipeline) tracker(name string) func(ComponentStatus, error) {
+	p.set(name, ComponentIdle, nil)
+	return func(status ComponentStatus, err error) {
+		p.set(name, status, err)
+	}
+}
@@ -0,0 +1,188 @@
+package opendj
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySource serves tracks from memory.
+type memorySource struct {
+	mu     sync.Mutex
+	tracks map[string][]byte
+	opened []string
+}
+
+func (s *memorySource) Open(ctx context.Context, media Media) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	track, ok := s.tracks[media.URL]
+	if !ok {
+		return nil, errors.New("unknown track")
+	}
+	s.opened = append(s.opened, media.URL)
+	return io.NopCloser(bytes.NewReader(track)), nil
+}
+
+// bufferOutput collects the stream.
+type bufferOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *bufferOutput) Start(ctx context.Context, stream io.Reader) error {
+	p := make([]byte, 4096)
+	for {
+		n, err := stream.Read(p)
+		o.mu.Lock()
+		o.buf.Write(p[:n])
+		o.mu.Unlock()
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func (o *bufferOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// TestPipeline plays the queue from a TrackSource into an output, ffmpeg is replaced by cat.
+func TestPipeline(t *testing.T) {
+	source := &memorySource{tracks: map[string][]byte{
+		"mem://first":  []byte("first track;"),
+		"mem://second": []byte("second track;"),
+	}}
+	dj := newTestDj(t, []QueueEntry{
+		{Media: Media{Title: "First", URL: "mem://first"}, Owner: "alice"},
+		{Media: Media{Title: "Second", URL: "mem://second"}, Owner: "bob"},
+	})
+	dj.SetTrackSource(source)
+	started, ended := make(chan string, 2), make(chan string, 2)
+	dj.AddNewSongHandler(func(entry QueueEntry) { started <- entry.Media.Title })
+	dj.AddEndOfSongHandler(func(entry QueueEntry, err error) {
+		if err != nil {
+			t.Errorf("%s ended with %v", entry.Media.Title, err)
+		}
+		ended <- entry.Media.Title
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output := &bufferOutput{}
+	done := make(chan error, 1)
+	go func() { done <- dj.PlayTo(ctx, output) }()
+
+	// read the current entry while it changes, for the race detector
+	go func() {
+		for ctx.Err() == nil {
+			_, _ = dj.CurrentlyPlaying()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var startedTitles, endedTitles []string
+	for len(endedTitles) < 2 {
+		select {
+		case title := <-started:
+			startedTitles = append(startedTitles, title)
+		case title := <-ended:
+			endedTitles = append(endedTitles, title)
+		case <-ctx.Done():
+			t.Fatalf("timed out, started %v and ended %v", startedTitles, endedTitles)
+		}
+	}
+	cancel()
+	<-done
+	for len(started) > 0 {
+		startedTitles = append(startedTitles, <-started)
+	}
+
+	if want := []string{"First", "Second"}; !equalStrings(startedTitles, want) || !equalStrings(endedTitles, want) {
+		t.Errorf("started %v and ended %v, want %v", startedTitles, endedTitles, want)
+	}
+	if want := []string{"mem://first", "mem://second"}; !equalStrings(source.opened, want) {
+		t.Errorf("opened %v, want %v", source.opened, want)
+	}
+	stream := output.String()
+	first, second := strings.Index(stream, "first track;"), strings.Index(stream, "second track;")
+	if first < 0 || second < first {
+		t.Errorf("the output did not receive both tracks in order: %q", stream)
+	}
+	if len(dj.Queue()) != 0 {
+		t.Errorf("%d entries left in the queue", len(dj.Queue()))
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestSuperviseFakeClock checks the restart backoff and that only failures within restartWindow count.
+func TestSuperviseFakeClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, time.May, 1, 20, 0, 0, 0, time.UTC)}
+	dj := newTestDj(t, nil, WithClock(clock))
+	crash := errors.New("crash")
+
+	run := func(ctx context.Context) (chan error, chan error) {
+		errs, done := make(chan error), make(chan error, 1)
+		state := dj.pipeline.tracker("encoder")
+		go func() {
+			done <- dj.supervise(ctx, errs, state, func(context.Context) error { return crash })
+		}()
+		return errs, done
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	errs, done := run(ctx)
+	backoff := time.Second
+	for i := 0; i < maxRestarts; i++ {
+		<-errs
+		if d := clock.waitForTimer(t); d != backoff {
+			t.Errorf("restart %d waits %v, want %v", i+1, d, backoff)
+		}
+		if state := dj.PipelineState()[0]; state.Status != ComponentRestarting || state.Restarts != i+1 {
+			t.Errorf("state after %d failures = %+v", i+1, state)
+		}
+		clock.advance(backoff)
+		backoff *= 2
+	}
+	<-errs
+	if err := <-done; !errors.Is(err, crash) {
+		t.Errorf("supervise() = %v, want the last error", err)
+	}
+	if state := dj.PipelineState()[0]; state.Status != ComponentFailed || !state.Since.Equal(clock.Now()) {
+		t.Errorf("state after giving up = %+v, want failed since %v", state, clock.Now())
+	}
+
+	// failures that are further apart than restartWindow never give up the component
+	runCtx, stop := context.WithCancel(ctx)
+	errs, done = run(runCtx)
+	for i := 0; i < 2*maxRestarts; i++ {
+		<-errs
+		if d := clock.waitForTimer(t); d > maxRestartBackoff {
+			t.Errorf("restart %d waits %v, more than %v", i+1, d, maxRestartBackoff)
+		}
+		clock.advance(restartWindow)
+	}
+	stop()
+	if err := <-done; err != nil {
+		t.Errorf("supervise() = %v after it was stopped", err)
+	}
+}
@@ -0,0 +1,80 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+)
+
+// A Playback controls playback started with Start.
+type Playback struct {
+	dj     *Dj
+	cancel context.CancelFunc
+	done   chan error
+
+	err error
+	// finished is closed after err was set
+	finished chan struct{}
+}
+
+// Start starts the playback to all given outputs like PlayTo, but returns right away.
+//
+// The returned Playback can be used to control the playback and to wait for it to end.
+// Cancelling ctx stops the playback just like Playback.Stop.
+func (dj *Dj) Start(ctx context.Context, outputs ...Output) (*Playback, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("no outputs given")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Playback{
+		dj:       dj,
+		cancel:   cancel,
+		done:     make(chan error, 1),
+		finished: make(chan struct{}),
+	}
+	go func() {
+		defer cancel()
+		p.err = dj.PlayTo(ctx, outputs...)
+		close(p.finished)
+		p.done <- p.err
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Skip skips the current entry, see Dj.Skip.
+func (p *Playback) Skip() error {
+	return p.dj.Skip()
+}
+
+// Pause pauses the current entry, see Dj.Pause.
+func (p *Playback) Pause() error {
+	return p.dj.Pause()
+}
+
+// Resume resumes the paused entry, see Dj.Resume.
+func (p *Playback) Resume() error {
+	return p.dj.Resume()
+}
+
+// Stop stops the playback and waits until all outputs are closed.
+//
+// It returns the error playback ended with, which is nil if it was stopped by Stop.
+// Calling Stop again returns the same error.
+func (p *Playback) Stop() error {
+	p.cancel()
+	return p.Wait()
+}
+
+// Wait blocks until the playback ended and returns the error it ended with.
+func (p *Playback) Wait() error {
+	<-p.finished
+	return p.err
+}
+
+// Done returns a channel that receives the error playback ended with and is closed afterwards.
+//
+// Only one receiver gets the error, others should use Wait instead.
+func (p *Playback) Done() <-chan error {
+	return p.done
+}
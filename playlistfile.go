@@ -0,0 +1,164 @@
+package opendj
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportPlaylistFile reads an M3U, M3U8 or PLS playlist, e.g. to use it with SetFallback.
+//
+// Relative paths in the playlist are relative to the directory of the playlist file.
+func ImportPlaylistFile(path string) ([]QueueEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := ParsePlaylistFile(f, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to import %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// ParsePlaylistFile parses an M3U, M3U8 or PLS playlist, the format is detected from the content.
+//
+// Every item becomes an entry with the title and duration from the playlist if it has them,
+// local files without a title are named after the file.
+// Items can be URLs or local paths, relative paths are joined with dir unless it is empty.
+func ParsePlaylistFile(r io.Reader, dir string) ([]QueueEntry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var media []Media
+	if len(lines) > 0 && strings.EqualFold(lines[0], "[playlist]") {
+		media = parsePLS(lines[1:])
+	} else {
+		media = parseM3U(lines)
+	}
+
+	entries := make([]QueueEntry, len(media))
+	for i, m := range media {
+		m.URL = playlistItemURL(m.URL, dir)
+		if m.Title == "" && isLocalFile(m.URL) {
+			name := filepath.Base(filePath(m.URL))
+			m.Title = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		entries[i] = QueueEntry{Media: m}
+	}
+	return entries, nil
+}
+
+// parseM3U parses an extended or simple M3U playlist.
+func parseM3U(lines []string) []Media {
+	var media []Media
+	var current Media
+	for _, line := range lines {
+		if info, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			// #EXTINF:<seconds> [attributes],<title>
+			length, title, _ := strings.Cut(info, ",")
+			length, _, _ = strings.Cut(length, " ")
+			current.Title = strings.TrimSpace(title)
+			current.Duration = playlistDuration(length)
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		current.URL = line
+		media = append(media, current)
+		current = Media{}
+	}
+	return media
+}
+
+// parsePLS parses the lines of a PLS playlist after the [playlist] header.
+func parsePLS(lines []string) []Media {
+	items := make(map[int]*Media)
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		var field string
+		for _, prefix := range []string{"File", "Title", "Length"} {
+			if strings.HasPrefix(key, prefix) {
+				field, key = prefix, strings.TrimPrefix(key, prefix)
+				break
+			}
+		}
+		n, err := strconv.Atoi(key)
+		if field == "" || err != nil {
+			continue
+		}
+		item, ok := items[n]
+		if !ok {
+			item = &Media{}
+			items[n] = item
+		}
+		switch field {
+		case "File":
+			item.URL = strings.TrimSpace(value)
+		case "Title":
+			item.Title = strings.TrimSpace(value)
+		case "Length":
+			item.Duration = playlistDuration(value)
+		}
+	}
+
+	numbers := make([]int, 0, len(items))
+	for n, item := range items {
+		if item.URL != "" {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	media := make([]Media, len(numbers))
+	for i, n := range numbers {
+		media[i] = *items[n]
+	}
+	return media
+}
+
+// playlistDuration parses a duration in seconds, -1 and invalid values mean unknown.
+func playlistDuration(s string) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// playlistItemURL returns the URL of a playlist item, joining relative paths with dir.
+func playlistItemURL(item, dir string) string {
+	if u, err := url.Parse(item); err == nil && len(u.Scheme) > 1 {
+		// a single letter is a windows drive
+		return item
+	}
+	if dir == "" || filepath.IsAbs(item) {
+		return item
+	}
+	return filepath.Join(dir, filepath.FromSlash(item))
+}
@@ -0,0 +1,173 @@
+package opendj
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A PodcastFeed is the RSS feed of a podcast.
+type PodcastFeed struct {
+	URL    string
+	Client *http.Client
+}
+
+// An Episode is an episode of a podcast.
+type Episode struct {
+	// Media is the audio file of the episode.
+	Media Media
+	// GUID identifies the episode, it is the URL of the audio if the feed has no GUIDs.
+	GUID      string
+	Published time.Time
+}
+
+// rssFeed is the subset of an RSS feed with iTunes extensions that is used.
+type rssFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title     string `xml:"title"`
+			GUID      string `xml:"guid"`
+			PubDate   string `xml:"pubDate"`
+			Duration  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			Enclosure struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// pubDateLayouts are the date formats found in feeds, RFC 1123 is required but not always followed.
+var pubDateLayouts = []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2 Jan 2006 15:04:05 -0700", time.RFC3339}
+
+// Episodes returns all episodes of the feed that have audio, newest first.
+//
+// The titles are prefixed with the title of the podcast.
+func (f PodcastFeed) Episodes(ctx context.Context) ([]Episode, error) {
+	body, err := fetchPage(ctx, f.Client, f.URL)
+	if err != nil {
+		return nil, err
+	}
+	var feed rssFeed
+	if err = xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse podcast feed %s: %w", f.URL, err)
+	}
+
+	var episodes []Episode
+	for _, item := range feed.Channel.Items {
+		if item.Enclosure.URL == "" || (item.Enclosure.Type != "" && !strings.HasPrefix(item.Enclosure.Type, "audio/")) {
+			continue
+		}
+		title := item.Title
+		if podcast := strings.TrimSpace(feed.Channel.Title); podcast != "" {
+			title = podcast + " - " + title
+		}
+		episode := Episode{
+			Media: Media{
+				Title:    title,
+				URL:      item.Enclosure.URL,
+				Duration: parseEpisodeDuration(item.Duration),
+			},
+			GUID: strings.TrimSpace(item.GUID),
+		}
+		if episode.GUID == "" {
+			episode.GUID = item.Enclosure.URL
+		}
+		for _, layout := range pubDateLayouts {
+			if published, err := time.Parse(layout, strings.TrimSpace(item.PubDate)); err == nil {
+				episode.Published = published
+				break
+			}
+		}
+		episodes = append(episodes, episode)
+	}
+	sort.SliceStable(episodes, func(i, j int) bool {
+		return episodes[i].Published.After(episodes[j].Published)
+	})
+	return episodes, nil
+}
+
+// Latest returns the media of the n newest episodes, newest first.
+func (f PodcastFeed) Latest(ctx context.Context, n int) ([]Media, error) {
+	episodes, err := f.Episodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(episodes) > n {
+		episodes = episodes[:n]
+	}
+	media := make([]Media, len(episodes))
+	for i, episode := range episodes {
+		media[i] = episode.Media
+	}
+	return media, nil
+}
+
+// parseEpisodeDuration parses the itunes:duration of an episode,
+// which is either seconds or [HH:]MM:SS. Invalid durations are zero.
+func parseEpisodeDuration(s string) time.Duration {
+	var total time.Duration
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0
+		}
+		total = total*60 + time.Duration(value*float64(time.Second))
+	}
+	return total
+}
+
+// FollowPodcast checks the feed every interval and adds new episodes to the queue,
+// turning the Dj into a relay for scheduled talk radio.
+//
+// Episodes that are in the feed when FollowPodcast is called are not queued.
+// New episodes are added oldest first with the owner, source and tags of template.
+// Failed checks and rejected entries are passed to the error handler.
+// It blocks until ctx is cancelled, so it should be started in its own goroutine.
+func (dj *Dj) FollowPodcast(ctx context.Context, feed PodcastFeed, interval time.Duration, template QueueEntry) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var seen map[string]bool
+	for {
+		episodes, err := feed.Episodes(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			dj.reportError(fmt.Errorf("failed to check podcast %s: %w", feed.URL, err))
+		} else if seen == nil {
+			seen = make(map[string]bool, len(episodes))
+			for _, episode := range episodes {
+				seen[episode.GUID] = true
+			}
+		} else {
+			for i := len(episodes) - 1; i >= 0; i-- {
+				episode := episodes[i]
+				if seen[episode.GUID] {
+					continue
+				}
+				seen[episode.GUID] = true
+
+				entry := template
+				entry.Media = episode.Media
+				entry.RequestedAt = time.Time{}
+				if err := dj.AddEntry(entry); err != nil {
+					dj.reportError(fmt.Errorf("failed to queue %q: %w", episode.Media.Title, err))
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
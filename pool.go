@@ -0,0 +1,135 @@
+package opendj
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultResolverConcurrency is the amount of concurrent resolutions if nothing else is configured.
+const defaultResolverConcurrency = 4
+
+// resolverPool resolves media with a bounded amount of workers and an optional rate limit.
+type resolverPool struct {
+	sync.Mutex
+	next time.Time
+	// buckets are the rate limits per host, see SetHostRateLimit
+	buckets map[string]*tokenBucket
+}
+
+// SetResolverLimits configures how media is resolved during playlist imports,
+// validation and metadata refreshes.
+//
+// concurrency is the maximum number of resolutions running at the same time and perSecond
+// the maximum number of resolutions started per second, to stay within API quotas.
+// perSecond <= 0 disables the rate limit.
+func (dj *Dj) SetResolverLimits(concurrency int, perSecond float64) {
+	if perSecond < 0 {
+		perSecond = 0
+	}
+	dj.updateConfig(func(c *Config) {
+		c.ResolverConcurrency = concurrency
+		c.ResolverRate = perSecond
+	})
+}
+
+// wait blocks until the rate limit allows another resolution.
+func (p *resolverPool) wait(ctx context.Context, perSecond float64) error {
+	var interval time.Duration
+	if perSecond > 0 {
+		interval = time.Duration(float64(time.Second) / perSecond)
+	}
+
+	p.Lock()
+	now := time.Now()
+	start := now
+	if p.next.After(now) {
+		start = p.next
+	}
+	p.next = start.Add(interval)
+	p.Unlock()
+
+	if delay := start.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// resolveAll resolves all urls in parallel.
+//
+// The returned slices have the same length as urls, for every url either media or err is set.
+func (dj *Dj) resolveAll(ctx context.Context, urls []string) ([]Media, []error) {
+	cfg := dj.cfg()
+	concurrency := cfg.ResolverConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultResolverConcurrency
+	}
+
+	media := make([]Media, len(urls))
+	errs := make([]error, len(urls))
+
+	eg := errgroup.Group{}
+	eg.SetLimit(concurrency)
+	for i, url := range urls {
+		i, url := i, url
+		eg.Go(func() error {
+			if errs[i] = dj.resolvers.wait(ctx, cfg.ResolverRate); errs[i] != nil {
+				return nil
+			}
+			media[i], errs[i] = dj.Resolve(ctx, url)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	return media, errs
+}
+
+// ImportPlaylist resolves every item of the playlist at the given URL.
+//
+// Items that can't be resolved are left out, the first of their errors is returned
+// alongside the media that was resolved successfully.
+func (dj *Dj) ImportPlaylist(ctx context.Context, playlistURL string) ([]Media, error) {
+	output, err := newCommand(dj.withLimits(ctx), "yt-dlp", "-J", "--flat-playlist", "--", playlistURL).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playlist %s: %w", playlistURL, err)
+	}
+
+	var playlist struct {
+		Entries []struct {
+			URL string `json:"url"`
+		} `json:"entries"`
+	}
+	if err = json.Unmarshal(output, &playlist); err != nil {
+		return nil, fmt.Errorf("failed to parse playlist %s: %w", playlistURL, err)
+	}
+
+	urls := make([]string, 0, len(playlist.Entries))
+	for _, entry := range playlist.Entries {
+		urls = append(urls, entry.URL)
+	}
+
+	resolved, errs := dj.resolveAll(ctx, urls)
+	var firstErr error
+	media := make([]Media, 0, len(resolved))
+	for i := range resolved {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		media = append(media, resolved[i])
+	}
+	return media, firstErr
+}
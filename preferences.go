@@ -0,0 +1,94 @@
+package opendj
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+)
+
+// UserPreferences are the settings of a single user.
+type UserPreferences struct {
+	// Volume is added to the gain of the user's entries in dB, e.g. -3 for a user whose uploads are too loud.
+	Volume float64 `json:"volume,omitempty"`
+	// HideDedication keeps the dedications of the user's entries out of announcements.
+	HideDedication bool `json:"hideDedication,omitempty"`
+	// Notify is set if the user wants to be notified, e.g. by a bot when their entry is up next.
+	// It is not used by the Dj itself.
+	Notify bool `json:"notify,omitempty"`
+}
+
+// SetPreferenceStore sets the Store user preferences are persisted in.
+//
+// Without a store Preferences returns the defaults and SetPreferences fails.
+func (dj *Dj) SetPreferenceStore(s Store) {
+	dj.updateConfig(func(c *Config) { c.PreferenceStore = s })
+}
+
+// preferencesKey returns the key the preferences of nick are stored under.
+func preferencesKey(nick string) string {
+	return "preferences/" + nick
+}
+
+// Preferences returns the preferences of the user with the given nick,
+// the defaults if nothing was saved for them.
+func (dj *Dj) Preferences(nick string) (UserPreferences, error) {
+	var prefs UserPreferences
+	store := dj.cfg().PreferenceStore
+	if store == nil {
+		return prefs, nil
+	}
+	data, err := store.Load(preferencesKey(nick))
+	if errors.Is(err, fs.ErrNotExist) {
+		return prefs, nil
+	} else if err != nil {
+		return prefs, fmt.Errorf("failed to load preferences of %s: %w", nick, err)
+	}
+	if err = json.Unmarshal(data, &prefs); err != nil {
+		return prefs, fmt.Errorf("failed to parse preferences of %s: %w", nick, err)
+	}
+	return prefs, nil
+}
+
+// SetPreferences saves the preferences of the user with the given nick.
+func (dj *Dj) SetPreferences(nick string, prefs UserPreferences) error {
+	store := dj.cfg().PreferenceStore
+	if store == nil {
+		return errors.New("no preference store configured")
+	}
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	return store.Save(preferencesKey(nick), data)
+}
+
+// preferencesOf returns the preferences of nick, errors are reported and the defaults returned.
+func (dj *Dj) preferencesOf(nick string) UserPreferences {
+	prefs, err := dj.Preferences(nick)
+	if err != nil {
+		dj.notifyError(err)
+	}
+	return prefs
+}
+
+// userVolumeFilter returns the filter applying the volume preference of the owner of entry.
+func (dj *Dj) userVolumeFilter(entry QueueEntry) string {
+	if entry.Owner == "" {
+		return ""
+	}
+	volume := dj.preferencesOf(entry.Owner).Volume
+	if volume == 0 {
+		return ""
+	}
+	return "volume=" + strconv.FormatFloat(volume, 'f', 2, 64) + "dB"
+}
+
+// announced returns entry the way it may be announced.
+func (dj *Dj) announced(entry QueueEntry) QueueEntry {
+	if entry.Dedication != "" && dj.preferencesOf(entry.Owner).HideDedication {
+		entry.Dedication = ""
+	}
+	return entry.Public()
+}
@@ -0,0 +1,59 @@
+package opendj
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// SetPreRoll delays the start of every track by d after the new song handler was called,
+// so chat announcements and overlay animations can finish before the music starts.
+//
+// If sting is not empty it has to be a file or URL ffmpeg can read, it is played
+// during the delay instead of silence and cut off or padded to match d.
+// A duration <= 0 disables the pre-roll.
+func (dj *Dj) SetPreRoll(d time.Duration, sting string) {
+	if d < 0 {
+		d = 0
+	}
+	dj.updateConfig(func(c *Config) {
+		c.PreRoll = d
+		c.PreRollSting = sting
+	})
+}
+
+// writePreRoll writes the configured pre-roll into the stream.
+func (dj *Dj) writePreRoll(ctx context.Context, stream io.Writer) error {
+	cfg := dj.cfg()
+	if cfg.PreRoll <= 0 {
+		return nil
+	}
+	if cfg.PreRollSting == "" {
+		return writeSilence(ctx, stream, cfg.PreRoll)
+	}
+
+	return writeStream(
+		ctx,
+		stream,
+		nil,
+		nil,
+		"-re",
+		"-i", cfg.PreRollSting,
+		"-af", "apad",
+		"-t", ffmpegDuration(cfg.PreRoll),
+	)
+}
+
+// writeSilence writes d of silence into the stream.
+func writeSilence(ctx context.Context, stream io.Writer, d time.Duration) error {
+	return writeStream(
+		ctx,
+		stream,
+		nil,
+		nil,
+		"-re",
+		"-t", ffmpegDuration(d),
+		"-f", "lavfi",
+		"-i", "anullsrc",
+	)
+}
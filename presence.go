@@ -0,0 +1,49 @@
+package opendj
+
+// PresencePolicy decides what happens to entries whose owner is not present.
+type PresencePolicy int
+
+const (
+	// PresenceIgnore plays entries regardless of whether their owner is present.
+	PresenceIgnore PresencePolicy = iota
+	// PresenceSkip drops entries of absent owners when they are about to be played.
+	// They are passed to the expiry handler and published as EventEntryExpired.
+	PresenceSkip
+	// PresenceDefer plays entries of present owners first, entries of absent
+	// owners are only played if nothing else is left in their queue.
+	PresenceDefer
+)
+
+// SetPresenceChecker sets a function that reports whether a user is present, e.g. in the
+// viewer list of the chat, and what to do with the entries of users that are not.
+//
+// present is consulted before each entry is played, it must not block.
+// Passing nil or PresenceIgnore disables the check.
+// returns an error if the policy is unknown.
+func (dj *Dj) SetPresenceChecker(present func(nick string) bool, policy PresencePolicy) error {
+	return dj.updateConfig(func(c *Config) {
+		c.Present = present
+		c.PresencePolicy = policy
+	})
+}
+
+// absent reports whether the owner of entry is known to be absent under the given policy.
+func (c *Config) absent(entry QueueEntry, policy PresencePolicy) bool {
+	return c.Present != nil && c.PresencePolicy == policy && !c.Present(entry.Owner)
+}
+
+// preferPresent returns the indices of the candidates whose owners are present,
+// or all indices if none are or the policy is not PresenceDefer.
+func (c *Config) preferPresent(candidates []QueueEntry) []int {
+	var all, present []int
+	for i, entry := range candidates {
+		all = append(all, i)
+		if !c.absent(entry, PresenceDefer) {
+			present = append(present, i)
+		}
+	}
+	if len(present) == 0 {
+		return all
+	}
+	return present
+}
@@ -0,0 +1,133 @@
+package opendj
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaInfo describes the first audio stream of a file or URL.
+type MediaInfo struct {
+	// Codec is the ffmpeg name of the codec, e.g. aac or mp3.
+	Codec      string
+	Duration   time.Duration
+	Channels   int
+	SampleRate int
+	// Bitrate is in bits per second, it is zero if unknown.
+	Bitrate int
+	// Tags are the metadata tags of the container and the stream, keys are lower case.
+	Tags map[string]string
+}
+
+// ProbeMedia inspects the file or URL with ffprobe.
+//
+// It can be used to validate media before it is queued, to get accurate durations for
+// sources yt-dlp does not know and to decide whether a track has to be transcoded.
+func ProbeMedia(ctx context.Context, target string) (MediaInfo, error) {
+	input := target
+	if isLocalFile(target) {
+		// the protocol prefix keeps paths starting with - from being read as options
+		input = "file:" + filePath(target)
+	}
+	output, err := newCommand(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "format=duration,bit_rate:format_tags:stream=codec_name,channels,sample_rate,bit_rate:stream_tags",
+		"-of", "json",
+		input,
+	).Output()
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("failed to probe %s: %w", target, err)
+	}
+
+	var probe struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			BitRate  string            `json:"bit_rate"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			CodecName  string            `json:"codec_name"`
+			Channels   int               `json:"channels"`
+			SampleRate string            `json:"sample_rate"`
+			BitRate    string            `json:"bit_rate"`
+			Tags       map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err = json.Unmarshal(output, &probe); err != nil {
+		return MediaInfo{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return MediaInfo{}, fmt.Errorf("%s has no audio stream", target)
+	}
+
+	stream := probe.Streams[0]
+	info := MediaInfo{
+		Codec:    stream.CodecName,
+		Channels: stream.Channels,
+		Tags:     make(map[string]string),
+	}
+	info.SampleRate, _ = strconv.Atoi(stream.SampleRate)
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	// the stream bitrate is not known for every container, fall back to the overall one
+	if info.Bitrate, _ = strconv.Atoi(stream.BitRate); info.Bitrate == 0 {
+		info.Bitrate, _ = strconv.Atoi(probe.Format.BitRate)
+	}
+	// Ogg and FLAC store tags on the stream, most other containers on the format
+	for _, tags := range []map[string]string{probe.Format.Tags, stream.Tags} {
+		for key, value := range tags {
+			info.Tags[strings.ToLower(key)] = value
+		}
+	}
+	return info, nil
+}
+
+// ProbeResolver resolves local files and direct http(s) links to audio files with ffprobe.
+//
+// The title is taken from the artist and title tags, or the file name if there are none.
+type ProbeResolver struct {
+	// Root is the directory local files have to be in, like the Root of FileSource.
+	// No local file is resolved if it is empty.
+	Root string
+}
+
+// Resolve probes rawURL.
+func (r ProbeResolver) Resolve(ctx context.Context, rawURL string) (Media, error) {
+	target := rawURL
+	if isLocalFile(rawURL) {
+		path, err := FileSource{Root: r.Root}.resolve(rawURL)
+		if err != nil {
+			return Media{}, err
+		}
+		target = path
+	} else if u, _ := url.Parse(rawURL); u.Scheme != "http" && u.Scheme != "https" {
+		return Media{}, fmt.Errorf("can't probe %s URLs", u.Scheme)
+	}
+
+	info, err := ProbeMedia(ctx, target)
+	if err != nil {
+		return Media{}, err
+	}
+
+	title := info.Tags["title"]
+	if artist := info.Tags["artist"]; artist != "" && title != "" {
+		title = artist + " - " + title
+	}
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(filePath(rawURL)), path.Ext(filePath(rawURL)))
+	}
+	if title == "" || title == "." || title == "/" {
+		return Media{}, errors.New("media has no title")
+	}
+	return Media{Title: title, URL: rawURL, Duration: info.Duration}, nil
+}
@@ -0,0 +1,74 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeFfprobe replaces ffprobe with a script that reports its last argument as the title.
+func fakeFfprobe(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\nfor last; do :; done\n" +
+		`printf '{"format": {"duration": "1.5", "tags": {"title": "%s"}}, "streams": [{"codec_name": "mp3"}]}' "$last"` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffprobe"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestProbeMediaInput(t *testing.T) {
+	fakeFfprobe(t)
+	tests := map[string]string{
+		"-version":                   "file:-version",
+		"/srv/music/song.mp3":        "file:/srv/music/song.mp3",
+		"file:///srv/music/song.mp3": "file:/srv/music/song.mp3",
+		"https://example.com/a.mp3":  "https://example.com/a.mp3",
+	}
+	for target, want := range tests {
+		info, err := ProbeMedia(context.Background(), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Tags["title"] != want {
+			t.Errorf("ffprobe was called with %q for %q, want %q", info.Tags["title"], target, want)
+		}
+	}
+}
+
+func TestResolveLocalFiles(t *testing.T) {
+	fakeFfprobe(t)
+	root := t.TempDir()
+	inside := filepath.Join(root, "song.mp3")
+	if err := os.WriteFile(inside, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret.mp3")
+	if err := os.WriteFile(outside, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	reg := &ResolverRegistry{Fallback: ResolverFunc(func(context.Context, string) (Media, error) {
+		return Media{}, errors.New("fallback")
+	})}
+	for _, rawURL := range []string{inside, "file://" + inside, "-version"} {
+		if _, err := reg.Resolve(ctx, rawURL); !errors.Is(err, ErrorLocalFile) {
+			t.Errorf("%s was resolved without a files resolver: %v", rawURL, err)
+		}
+	}
+
+	reg.Files = ProbeResolver{Root: root}
+	if media, err := reg.Resolve(ctx, inside); err != nil || media.URL != inside {
+		t.Errorf("resolving %s returned %+v, %v", inside, media, err)
+	}
+	if _, err := reg.Resolve(ctx, outside); !errors.Is(err, ErrorLocalFile) {
+		t.Errorf("%s outside of the root was resolved: %v", outside, err)
+	}
+	if _, err := (ProbeResolver{Root: root}).Resolve(ctx, "ftp://example.com/song.mp3"); err == nil {
+		t.Error("an ftp URL was probed")
+	}
+}
@@ -0,0 +1,92 @@
+package opendj
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// killWaitDelay is how long to wait for the output of a killed process group to be closed.
+const killWaitDelay = 5 * time.Second
+
+// ProcessLimits restricts the resources of the yt-dlp and ffmpeg processes the Dj spawns,
+// so it does not starve other services on a shared host.
+//
+// The zero value does not restrict anything.
+type ProcessLimits struct {
+	// Nice is the niceness the processes run with, from -20 to 19. It requires nice(1).
+	Nice int
+	// IOClass is the ionice scheduling class: 1 realtime, 2 best-effort or 3 idle.
+	// Zero leaves the class unchanged. It requires ionice(1).
+	IOClass int
+	// IOLevel is the priority within the best-effort and realtime classes, from 0 to 7.
+	IOLevel int
+	// Threads is the maximum amount of threads ffmpeg uses to decode every input and encode every output,
+	// 0 means automatic.
+	Threads int
+}
+
+type limitsKey struct{}
+
+// SetProcessLimits sets the limits for all processes started after the call.
+//
+// returns an error if a limit is out of range.
+func (dj *Dj) SetProcessLimits(limits ProcessLimits) error {
+	return dj.updateConfig(func(c *Config) { c.ProcessLimits = limits })
+}
+
+// withLimits attaches the process limits of the Dj to ctx.
+func (dj *Dj) withLimits(ctx context.Context) context.Context {
+	return context.WithValue(ctx, limitsKey{}, dj.cfg().ProcessLimits)
+}
+
+// newCommand returns a command that runs with the process limits attached to ctx.
+//
+// The command runs in its own process group, once ctx is done the whole group is killed,
+// so no orphaned processes keep writing into pipes nobody reads anymore.
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	limits, _ := ctx.Value(limitsKey{}).(ProcessLimits)
+
+	if limits.Threads > 0 {
+		switch name {
+		case "ffmpeg":
+			args = threadArgs(args, limits.Threads)
+		case "ffprobe":
+			args = append([]string{"-threads", strconv.Itoa(limits.Threads)}, args...)
+		}
+	}
+	args = append([]string{name}, args...)
+	if limits.IOClass != 0 {
+		args = append([]string{"ionice", "-c", strconv.Itoa(limits.IOClass), "-n", strconv.Itoa(limits.IOLevel)}, args...)
+	}
+	if limits.Nice != 0 {
+		args = append([]string{"nice", "-n", strconv.Itoa(limits.Nice)}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = killWaitDelay
+	return cmd
+}
+
+// threadArgs adds -threads to every input and output of the ffmpeg arguments.
+//
+// -threads only applies to the file that follows it, so it is added before every -i,
+// the stream written to pipe:1 and the last argument, which is always an output.
+func threadArgs(args []string, threads int) []string {
+	limited := make([]string, 0, len(args)+6)
+	for i, arg := range args {
+		input := arg == "-i"
+		output := i == len(args)-1 || (arg == "pipe:1" && (i == 0 || args[i-1] != "-i"))
+		if input || output {
+			limited = append(limited, "-threads", strconv.Itoa(threads))
+		}
+		limited = append(limited, arg)
+	}
+	return limited
+}
@@ -0,0 +1,41 @@
+package opendj
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestThreadLimitArgs(t *testing.T) {
+	ctx := context.WithValue(context.Background(), limitsKey{}, ProcessLimits{Threads: 2, Nice: 5})
+
+	for _, test := range []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"-re", "-i", "pipe:0", "-c", "copy", "-f", "flv", "rtmp://example.com/live"},
+			want: []string{"-re", "-threads", "2", "-i", "pipe:0", "-c", "copy", "-f", "flv", "-threads", "2", "rtmp://example.com/live"},
+		},
+		{
+			// the stream and the archive are separate outputs
+			args: []string{"-i", "pipe:0", "-f", "mpegts", "pipe:1", "-c:a", "aac", "-y", "song.m4a"},
+			want: []string{"-threads", "2", "-i", "pipe:0", "-f", "mpegts", "-threads", "2", "pipe:1", "-c:a", "aac", "-y", "-threads", "2", "song.m4a"},
+		},
+		{
+			args: []string{"-f", "s16le", "-i", "pipe:1", "-f", "wav", "pipe:1"},
+			want: []string{"-f", "s16le", "-threads", "2", "-i", "pipe:1", "-f", "wav", "-threads", "2", "pipe:1"},
+		},
+	} {
+		cmd := newCommand(ctx, "ffmpeg", test.args...)
+		want := append([]string{"nice", "-n", "5", "ffmpeg"}, test.want...)
+		if !reflect.DeepEqual(cmd.Args, want) {
+			t.Errorf("newCommand(%q) runs\n%q, want\n%q", test.args, cmd.Args, want)
+		}
+	}
+
+	cmd := newCommand(ctx, "ffprobe", "-of", "json", "song.mp3")
+	if want := []string{"nice", "-n", "5", "ffprobe", "-threads", "2", "-of", "json", "song.mp3"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("ffprobe runs %q, want %q", cmd.Args, want)
+	}
+}
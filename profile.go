@@ -0,0 +1,74 @@
+package opendj
+
+import "strings"
+
+// defaultBitrate is the audio bitrate used if the entry has no EncodingProfile.
+const defaultBitrate = "160k"
+
+// An EncodingProfile overrides the encoding options for a single entry,
+// e.g. mono and a lower bitrate for spoken-word announcements.
+//
+// The sample rate and channel count of the stream stay the same for every entry
+// so the outputs don't have to be restarted, Mono downmixes the audio instead.
+// The zero value uses the default options.
+type EncodingProfile struct {
+	// Bitrate is the AAC bitrate like 96k, empty uses the default.
+	Bitrate string
+	// Mono downmixes the audio to mono.
+	Mono bool
+	// Filters are ffmpeg audio filters applied to the entry, e.g. loudnorm.
+	Filters []string
+}
+
+// bitrate returns the bitrate to encode with, p may be nil.
+func (p *EncodingProfile) bitrate() string {
+	if p == nil || p.Bitrate == "" {
+		return defaultBitrate
+	}
+	return p.Bitrate
+}
+
+// filterArgs returns the -af option for the base filters followed by the filters of the profile.
+// Empty base filters are left out, p may be nil.
+func (p *EncodingProfile) filterArgs(base ...string) []string {
+	chain := p.filterChain(base...)
+	if chain == "" {
+		return nil
+	}
+	return []string{"-af", chain}
+}
+
+// filterChain is like filterArgs, but returns the filter chain itself.
+func (p *EncodingProfile) filterChain(base ...string) string {
+	var chain []string
+	for _, filter := range base {
+		if filter != "" {
+			chain = append(chain, filter)
+		}
+	}
+	if p != nil {
+		chain = append(chain, p.Filters...)
+		if p.Mono {
+			// upmixed to stereo again by the encoder
+			chain = append(chain, "pan=mono|c0=0.5*c0+0.5*c1")
+		}
+	}
+	return strings.Join(chain, ",")
+}
+
+// outputArgs returns the ffmpeg options to encode the stream, p may be nil.
+func (p *EncodingProfile) outputArgs() []string {
+	return []string{
+		"-c:a", "aac",
+		"-strict", "-2",
+		"-ar", "44100",
+		"-b:a", p.bitrate(),
+		"-ac", "2",
+		"-f", "mpegts", "pipe:1",
+	}
+}
+
+// transcoded reports whether the profile requires the audio to be transcoded, p may be nil.
+func (p *EncodingProfile) transcoded() bool {
+	return p != nil && (p.Bitrate != "" || p.Mono || len(p.Filters) > 0)
+}
@@ -0,0 +1,269 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress describes how far the current entry has been played.
+type Progress struct {
+	Entry QueueEntry
+	// Elapsed is how long the entry has been playing, excluding time spent paused.
+	Elapsed time.Duration
+	// Remaining is how much of the entry is left, it is never negative.
+	Remaining time.Duration
+	// Position is the position in the media, it includes the start of the clip.
+	Position time.Duration
+	// Percent is the completed percentage between 0 and 100.
+	Percent float64
+	Paused  bool
+}
+
+// NowPlaying describes the entry that is currently being played.
+type NowPlaying struct {
+	EntryID uint64     `json:"entryId"`
+	Entry   QueueEntry `json:"entry"`
+	// Started is when the entry started playing, it is zero during the pre-roll.
+	Started time.Time `json:"started"`
+	// Progress is how long the entry has been playing, excluding time spent paused.
+	Progress time.Duration `json:"progress"`
+	// Remaining is how much of the entry is left, it is zero if the duration is unknown.
+	Remaining time.Duration `json:"remaining"`
+	Paused    bool          `json:"paused"`
+	// Health is the worst status of the pipeline components, see PipelineState.
+	Health ComponentStatus `json:"health"`
+	// Show is the show that is on, if any, see SetShowSchedule.
+	Show *Show `json:"show,omitempty"`
+}
+
+// playClock measures the playback time of the current entry.
+type playClock struct {
+	sync.Mutex
+	started  time.Time
+	pausedAt time.Time
+	paused   time.Duration
+	offset   time.Duration
+
+	// resumed is closed when playback is resumed, it is nil while not paused
+	resumed chan struct{}
+}
+
+// start resets the clock for a new entry that starts playing at offset.
+func (c *playClock) start(now time.Time, offset time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.started, c.paused, c.offset = now, 0, offset
+	c.unpause(now)
+}
+
+// elapsed returns the time played since start, the lock has to be held.
+func (c *playClock) elapsed(now time.Time) time.Duration {
+	if c.started.IsZero() {
+		return 0
+	}
+	if c.resumed != nil {
+		now = c.pausedAt
+	}
+	return now.Sub(c.started) - c.paused
+}
+
+func (c *playClock) unpause(now time.Time) bool {
+	if c.resumed == nil {
+		return false
+	}
+	c.paused += now.Sub(c.pausedAt)
+	close(c.resumed)
+	c.resumed = nil
+	return true
+}
+
+// seek moves the clock to elapsed after a seek, it resumes the clock if it was paused.
+func (c *playClock) seek(now time.Time, elapsed time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.unpause(now)
+	c.started, c.paused = now.Add(-elapsed), 0
+}
+
+// wait blocks while the clock is paused.
+func (c *playClock) wait(ctx context.Context) error {
+	c.Lock()
+	resumed := c.resumed
+	c.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumed:
+		return nil
+	}
+}
+
+// pausableReader stops reading while the clock is paused.
+type pausableReader struct {
+	ctx   context.Context
+	clock *playClock
+	io.ReadCloser
+}
+
+func (r pausableReader) Read(p []byte) (int, error) {
+	if err := r.clock.wait(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
+
+// Pause pauses the current entry until Resume is called or it is skipped.
+//
+// The track input stops being read, so the outputs stall while paused. Servers
+// may drop idle streams, so pauses should be kept short.
+// returns ErrorNothingPlaying if no song is playing.
+func (dj *Dj) Pause() error {
+	return dj.As(directActor).Pause().Err
+}
+
+// pause is Pause without the audit log.
+func (dj *Dj) pause() error {
+	dj.clock.Lock()
+	defer dj.clock.Unlock()
+
+	if dj.clock.started.IsZero() {
+		return ErrorNothingPlaying
+	}
+	if dj.clock.resumed == nil {
+		dj.clock.pausedAt = dj.now()
+		dj.clock.resumed = make(chan struct{})
+	}
+	return nil
+}
+
+// Resume continues the entry paused by Pause.
+//
+// returns ErrorNothingPlaying if no song is playing.
+func (dj *Dj) Resume() error {
+	return dj.As(directActor).Resume().Err
+}
+
+// resume is Resume without the audit log.
+func (dj *Dj) resume() error {
+	dj.clock.Lock()
+	defer dj.clock.Unlock()
+
+	if dj.clock.started.IsZero() {
+		return ErrorNothingPlaying
+	}
+	if dj.clock.unpause(dj.now()) {
+		// the silence while paused doesn't count, see Healthy
+		dj.health.wrote(dj.now())
+	}
+	return nil
+}
+
+// ErrorNotSeekable is returned by Seek if the current entry can't be seeked right now,
+// e.g. during its intro or outro.
+var ErrorNotSeekable = errors.New("the current entry can't be seeked right now")
+
+// Seek continues the current entry at position in its media, like Progress.Position.
+//
+// The track input is restarted at the position and Progress continues from there.
+// A paused entry is resumed. Archives set with SetArchiveDir end at the first seek.
+// returns ErrorNothingPlaying if no song is playing, ErrorNotSeekable during the intro and outro
+// or if the track was not started yet and ErrorOutOfRange if position is not within the clip.
+func (dj *Dj) Seek(position time.Duration) error {
+	return dj.As(directActor).Seek(position).Err
+}
+
+// seek is Seek without the audit log.
+func (dj *Dj) seek(position time.Duration) error {
+	dj.clock.Lock()
+	entry, playing := dj.currentEntry, !dj.clock.started.IsZero()
+	dj.clock.Unlock()
+	if !playing {
+		return ErrorNothingPlaying
+	}
+
+	dj.skipper.Lock()
+	defer dj.skipper.Unlock()
+	if dj.skipper.seek == nil {
+		return ErrorNotSeekable
+	}
+	end := entry.Media.Duration
+	if entry.Clip.End > 0 && (end <= 0 || entry.Clip.End < end) {
+		end = entry.Clip.End
+	}
+	if position < entry.Clip.Start || (end > 0 && position >= end) {
+		return ErrorOutOfRange
+	}
+	dj.skipper.seek(position)
+	return nil
+}
+
+// Progress returns the progress of the current entry.
+//
+// returns ErrorNothingPlaying if no song is playing.
+func (dj *Dj) Progress() (Progress, error) {
+	dj.clock.Lock()
+	defer dj.clock.Unlock()
+
+	if dj.clock.started.IsZero() {
+		return Progress{}, ErrorNothingPlaying
+	}
+
+	entry := dj.currentEntry
+	elapsed := dj.clock.elapsed(dj.now())
+	total := entry.TotalDuration()
+	p := Progress{
+		Entry:     entry,
+		Elapsed:   elapsed,
+		Remaining: total - elapsed,
+		Position:  dj.clock.offset,
+		Percent:   100,
+		Paused:    dj.clock.resumed != nil,
+	}
+	if entry.Intro != nil {
+		elapsed -= entry.Intro.Duration
+	}
+	if elapsed > 0 {
+		p.Position += elapsed
+	}
+	if p.Remaining < 0 {
+		p.Remaining = 0
+	}
+	if total > 0 && p.Elapsed < total {
+		p.Percent = float64(p.Elapsed) / float64(total) * 100
+	}
+	return p, nil
+}
+
+// setCurrentEntry sets the entry that is playing, the empty entry if nothing is.
+func (dj *Dj) setCurrentEntry(entry QueueEntry) {
+	dj.clock.Lock()
+	defer dj.clock.Unlock()
+	dj.currentEntry = entry
+}
+
+// remaining returns how much of the current entry is left, 0 if it is stopped or finished.
+func (dj *Dj) remaining() time.Duration {
+	dj.clock.Lock()
+	defer dj.clock.Unlock()
+	if dj.clock.started.IsZero() {
+		return 0
+	}
+	if left := dj.currentEntry.TotalDuration() - dj.clock.elapsed(dj.now()); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// stop marks that nothing is playing anymore.
+func (c *playClock) stop(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.unpause(now)
+	c.started = time.Time{}
+}
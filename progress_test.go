@@ -0,0 +1,160 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// endlessSource serves every track as a slow stream that never ends.
+type endlessSource struct {
+	mu     sync.Mutex
+	opened int
+}
+
+func (s *endlessSource) Open(ctx context.Context, media Media) (io.ReadCloser, error) {
+	s.mu.Lock()
+	s.opened++
+	s.mu.Unlock()
+	return endlessReader{ctx}, nil
+}
+
+func (s *endlessSource) openCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opened
+}
+
+type endlessReader struct{ ctx context.Context }
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.ctx.Done():
+		return 0, r.ctx.Err()
+	case <-time.After(10 * time.Millisecond):
+		return copy(p, "audio"), nil
+	}
+}
+
+func (endlessReader) Close() error { return nil }
+
+func TestSeek(t *testing.T) {
+	dj := newTestDj(t, []QueueEntry{{Media: Media{Title: "Long", URL: "mem://long", Duration: 10 * time.Minute}}})
+	// record the ffmpeg arguments to check the input is restarted at the position
+	dir, log := t.TempDir(), filepath.Join(t.TempDir(), "ffmpeg.log")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\nexec cat\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if err := dj.Seek(time.Minute); !errors.Is(err, ErrorNothingPlaying) {
+		t.Errorf("Seek() before playing = %v, want ErrorNothingPlaying", err)
+	}
+
+	source := &endlessSource{}
+	dj.SetTrackSource(source)
+	events, cancelEvents := dj.Subscribe(64)
+	defer cancelEvents()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- dj.PlayTo(ctx, NullOutput{}) }()
+
+	waitFor := func(what string, ok func() bool) {
+		t.Helper()
+		for !ok() {
+			if ctx.Err() != nil {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor("the track to start", func() bool { return errors.Is(dj.Seek(-time.Second), ErrorOutOfRange) })
+	if err := dj.Seek(10 * time.Minute); !errors.Is(err, ErrorOutOfRange) {
+		t.Errorf("Seek() past the end = %v, want ErrorOutOfRange", err)
+	}
+	if err := dj.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dj.Seek(2 * time.Minute); err != nil {
+		t.Fatalf("Seek() = %v", err)
+	}
+	waitFor("the track to be reopened", func() bool { return source.openCount() == 2 })
+
+	p, err := dj.Progress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Paused || p.Position < 2*time.Minute || p.Position > 2*time.Minute+time.Second {
+		t.Errorf("Progress() after seeking = %+v, want to continue at 2m", p)
+	}
+	if p.Remaining > 8*time.Minute || p.Elapsed != p.Position {
+		t.Errorf("Progress() after seeking = %+v, want elapsed and remaining to follow the position", p)
+	}
+	waitFor("ffmpeg to be restarted", func() bool {
+		args, _ := os.ReadFile(log)
+		return strings.Contains(string(args), "-ss 120")
+	})
+
+	if err := dj.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	started := 0
+	for ended := false; !ended; {
+		select {
+		case event := <-events:
+			switch event.Type {
+			case EventSongStarted:
+				started++
+			case EventSongEnded:
+				ended = true
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the end of the track")
+		}
+	}
+	if started != 1 {
+		t.Errorf("the track started %d times, want once", started)
+	}
+	cancel()
+	<-done
+}
+
+func TestRemainingStoppedOrFinished(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, time.May, 1, 20, 0, 0, 0, time.UTC)}
+	dj := newTestDj(t, nil, WithClock(clock))
+	dj.setCurrentEntry(QueueEntry{Media: Media{Title: "Song", Duration: 3 * time.Minute}})
+
+	if got := dj.remaining(); got != 0 {
+		t.Errorf("remaining() before the entry started = %v, want 0", got)
+	}
+	dj.clock.start(clock.Now(), 0)
+	clock.advance(time.Minute)
+	if got := dj.remaining(); got != 2*time.Minute {
+		t.Errorf("remaining() after a minute = %v, want 2m", got)
+	}
+	clock.advance(5 * time.Minute)
+	if got := dj.remaining(); got != 0 {
+		t.Errorf("remaining() after the entry ended = %v, want 0", got)
+	}
+
+	// the entry is kept until the next one starts, but the clock is stopped
+	dj.clock.start(clock.Now(), 0)
+	dj.clock.stop(clock.Now())
+	if got := dj.remaining(); got != 0 {
+		t.Errorf("remaining() of a stopped entry = %v, want 0", got)
+	}
+	if err := dj.AddEntry(QueueEntry{Media: Media{Title: "Next", URL: "https://example.com/next", Duration: time.Minute}, Owner: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if durations := dj.DurationUntilUser("bob"); len(durations) != 1 || durations[0] != 0 {
+		t.Errorf("DurationUntilUser() while stopped = %v, want no wait", durations)
+	}
+}
@@ -0,0 +1,208 @@
+package opendj
+
+import "sync"
+
+// maxQueueChanges is the minimum amount of changes kept for ChangesSince.
+// Up to twice as many are kept, so old changes only have to be dropped occasionally.
+const maxQueueChanges = 256
+
+// ChangeKind describes how a queue was changed.
+type ChangeKind int
+
+const (
+	// EntryAdded means Entry was inserted at Index.
+	EntryAdded ChangeKind = iota
+	// EntryRemoved means Entry was removed from Index.
+	EntryRemoved
+	// EntryMoved means Entry was moved from From to Index.
+	EntryMoved
+	// EntryChanged means the entry at Index was replaced by Entry.
+	EntryChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case EntryAdded:
+		return "added"
+	case EntryRemoved:
+		return "removed"
+	case EntryMoved:
+		return "moved"
+	case EntryChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// A QueueChange is a single mutation of the queue.
+//
+// Applying all changes returned by ChangesSince in order to a copy of the queue
+// at the given version results in the current queue.
+type QueueChange struct {
+	// Version is the queue version after the change was applied.
+	Version uint64
+	Kind    ChangeKind
+	Index   int
+	// From is the previous index of a moved entry.
+	From  int
+	Entry QueueEntry
+}
+
+type queue struct {
+	items entryList
+	sync.Mutex
+
+	version uint64
+	changes []QueueChange
+	// snapshot is the content of the queue at version, it is nil if it was not requested yet
+	snapshot []QueueEntry
+	// ids generates the IDs of inserted entries, nil uses the default
+	ids IDGenerator
+}
+
+// The following methods read the queue, the lock has to be held.
+
+func (q *queue) len() int {
+	return q.items.n
+}
+
+func (q *queue) at(index int) QueueEntry {
+	b, i := q.items.locate(index)
+	return q.items.blocks[b][i]
+}
+
+// each calls f for every entry in order until it returns false.
+func (q *queue) each(f func(index int, entry QueueEntry) bool) {
+	index := 0
+	for _, block := range q.items.blocks {
+		for _, entry := range block {
+			if !f(index, entry) {
+				return
+			}
+			index++
+		}
+	}
+}
+
+// entries returns the entries of the queue, the slice is shared until the queue changes.
+func (q *queue) entries() []QueueEntry {
+	if q.snapshot == nil {
+		q.snapshot = q.items.appendTo(make([]QueueEntry, 0, q.items.n))
+	}
+	return q.snapshot[:len(q.snapshot):len(q.snapshot)]
+}
+
+// full returns whether the queue reached the given limit, the lock has to be held.
+func (q *queue) full(limit int) bool {
+	return limit > 0 && q.len() >= limit
+}
+
+// reset replaces all entries without recording changes.
+func (q *queue) reset(entries []QueueEntry) {
+	q.items = newEntryList(entries)
+	q.snapshot = nil
+}
+
+// The following methods modify the queue and record the change, the lock has to be held.
+
+func (q *queue) insert(index int, entry QueueEntry) {
+	// entries moved between queues keep their ID
+	if entry.ID == 0 {
+		entry.assignID(q.ids)
+	}
+	if index >= q.len() {
+		index = q.len()
+	}
+	q.items.insert(index, entry)
+	q.record(QueueChange{Kind: EntryAdded, Index: index, Entry: entry})
+}
+
+func (q *queue) remove(index int) QueueEntry {
+	entry := q.items.remove(index)
+	q.record(QueueChange{Kind: EntryRemoved, Index: index, Entry: entry})
+	return entry
+}
+
+func (q *queue) replace(index int, entry QueueEntry) {
+	b, i := q.items.locate(index)
+	// updates of an entry keep its ID, a different entry gets its own like in insert
+	if entry.ID == 0 {
+		entry.assignID(q.ids)
+	}
+	q.items.blocks[b][i] = entry
+	q.record(QueueChange{Kind: EntryChanged, Index: index, Entry: entry})
+}
+
+func (q *queue) move(from, to int) {
+	entry := q.items.remove(from)
+	q.items.insert(to, entry)
+	q.record(QueueChange{Kind: EntryMoved, Index: to, From: from, Entry: entry})
+}
+
+func (q *queue) record(change QueueChange) {
+	q.snapshot = nil
+	q.version++
+	change.Version = q.version
+	if len(q.changes) >= 2*maxQueueChanges {
+		q.changes = append(q.changes[:0], q.changes[maxQueueChanges:]...)
+	}
+	q.changes = append(q.changes, change)
+}
+
+// since returns the changes after the given version.
+// ok is false if the version is too old and the changes are no longer available.
+func (q *queue) since(version uint64) (changes []QueueChange, ok bool) {
+	if version > q.version {
+		return nil, false
+	} else if version == q.version {
+		return nil, true
+	}
+	if len(q.changes) == 0 || q.changes[0].Version > version+1 {
+		return nil, false
+	}
+
+	start := len(q.changes) - int(q.version-version)
+	changes = make([]QueueChange, len(q.changes)-start)
+	copy(changes, q.changes[start:])
+	return changes, true
+}
+
+// QueueVersion returns the current version of the queue.
+//
+// The version is incremented on every change to the queue.
+func (dj *Dj) QueueVersion() uint64 {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+	return dj.waitingQueue.version
+}
+
+// QueueSnapshot returns the queue and the version it belongs to.
+//
+// The snapshot is only built once per version and shared by all callers, so it is cheap to
+// poll even for long queues. It must not be modified, use Queue or QueueInto for a copy.
+func (dj *Dj) QueueSnapshot() ([]QueueEntry, uint64) {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+	return dj.waitingQueue.entries(), dj.waitingQueue.version
+}
+
+// QueueInto copies the queue into buf, reusing its memory, and returns the result.
+//
+// Pollers that keep passing the previous result, like an overlay refreshing several times
+// a second, don't allocate once the buffer is large enough.
+func (dj *Dj) QueueInto(buf []QueueEntry) []QueueEntry {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+	return dj.waitingQueue.items.appendTo(buf[:0])
+}
+
+// ChangesSince returns all changes made to the queue after the given version, oldest first.
+//
+// Only the most recent changes are kept, if they are not available anymore ok is false
+// and the caller has to fetch the whole queue with QueueSnapshot instead.
+func (dj *Dj) ChangesSince(version uint64) (changes []QueueChange, ok bool) {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+	return dj.waitingQueue.since(version)
+}
@@ -0,0 +1,257 @@
+package opendj
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestEntryListMatchesSlice applies random changes to a queue and to a plain slice.
+func TestEntryListMatchesSlice(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	q := queue{ids: &SequentialIDs{}}
+	q.reset(nil)
+	var want []QueueEntry
+
+	for step := 0; step < 20000; step++ {
+		switch op := rng.Intn(10); {
+		case op < 5 || len(want) == 0:
+			index := rng.Intn(len(want) + 1)
+			q.insert(index, QueueEntry{})
+			entry := q.at(index)
+			want = append(want[:index], append([]QueueEntry{entry}, want[index:]...)...)
+		case op < 8:
+			index := rng.Intn(len(want))
+			if got := q.remove(index); got.ID != want[index].ID {
+				t.Fatalf("step %d: remove(%d) = %d, want %d", step, index, got.ID, want[index].ID)
+			}
+			want = append(want[:index], want[index+1:]...)
+		default:
+			from, to := rng.Intn(len(want)), rng.Intn(len(want))
+			q.move(from, to)
+			entry := want[from]
+			want = append(want[:from], want[from+1:]...)
+			want = append(want[:to], append([]QueueEntry{entry}, want[to:]...)...)
+		}
+		if q.len() != len(want) {
+			t.Fatalf("step %d: len() = %d, want %d", step, q.len(), len(want))
+		}
+		if step%500 == 0 && !reflect.DeepEqual(q.items.appendTo(nil), want) {
+			t.Fatalf("step %d: entries differ from the slice", step)
+		}
+	}
+}
+
+func TestQueueSnapshotShared(t *testing.T) {
+	dj := newTestDj(t, []QueueEntry{{}, {}})
+	first, version := dj.QueueSnapshot()
+	second, _ := dj.QueueSnapshot()
+	if &first[0] != &second[0] {
+		t.Error("QueueSnapshot() built a new snapshot for the same version")
+	}
+	if err := dj.AddEntry(QueueEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	third, newVersion := dj.QueueSnapshot()
+	if newVersion == version || len(third) != 3 || len(first) != 2 {
+		t.Errorf("snapshot after a change has %d entries at version %d, want 3 after version %d",
+			len(third), newVersion, version)
+	}
+}
+
+// benchmarkQueueSize is the size of the queue of marathon streams.
+const benchmarkQueueSize = 5000
+
+func benchmarkQueue() *queue {
+	q := &queue{ids: &SequentialIDs{}}
+	q.reset(make([]QueueEntry, benchmarkQueueSize))
+	return q
+}
+
+func BenchmarkInsert(b *testing.B) {
+	q := benchmarkQueue()
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.insert(rng.Intn(q.len()), QueueEntry{})
+		q.remove(q.len() - 1)
+	}
+}
+
+func BenchmarkRemove(b *testing.B) {
+	q := benchmarkQueue()
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.remove(rng.Intn(q.len()))
+		q.insert(q.len(), QueueEntry{})
+	}
+}
+
+func BenchmarkMove(b *testing.B) {
+	q := benchmarkQueue()
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.move(rng.Intn(q.len()), rng.Intn(q.len()))
+	}
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	dj := newTestDj(b, make([]QueueEntry, benchmarkQueueSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// pollers mostly see the same version
+		if i%100 == 0 {
+			_ = dj.MoveIndex(0, benchmarkQueueSize-1)
+		}
+		dj.QueueSnapshot()
+	}
+}
+
+func TestAddEntryInvalidClip(t *testing.T) {
+	dj := newTestDj(t, nil)
+	media := Media{Title: "Song", URL: "https://example.com/song", Duration: 3 * time.Minute}
+	for _, clip := range []ClipRange{
+		{Start: -time.Second},
+		{End: -time.Second},
+		{Start: time.Minute, End: time.Minute},
+		{Start: time.Minute, End: 30 * time.Second},
+	} {
+		err := dj.AddEntry(QueueEntry{Media: media, Owner: "alice", Clip: clip})
+		if !errors.Is(err, ErrorInvalidClip) || ReasonOf(err) != ReasonInvalidClip {
+			t.Errorf("AddEntry() with clip %+v = %v, want ErrorInvalidClip", clip, err)
+		}
+	}
+	if n := len(dj.Queue()); n != 0 {
+		t.Errorf("%d entries with invalid clips were queued", n)
+	}
+
+	for _, clip := range []ClipRange{{}, {Start: time.Minute}, {End: time.Minute}, {Start: time.Second, End: time.Minute}} {
+		if err := dj.AddEntry(QueueEntry{Media: media, Owner: "alice", Clip: clip}); err != nil {
+			t.Errorf("AddEntry() with clip %+v = %v", clip, err)
+		}
+	}
+}
+
+func TestEntryIDsStayUnique(t *testing.T) {
+	dj := newTestDj(t, nil)
+	if err := dj.AddQueue("jingles", 1); err != nil {
+		t.Fatal(err)
+	}
+	media := Media{Title: "Song", URL: "https://example.com/song", Duration: 3 * time.Minute}
+	if err := dj.AddEntry(QueueEntry{Media: media, Owner: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	taken := dj.Queue()[0].ID
+
+	for i, add := range []func(QueueEntry) error{
+		dj.AddEntry,
+		func(e QueueEntry) error { return dj.AddEntryTo("jingles", e) },
+		func(e QueueEntry) error { return dj.InsertEntry(e, 0) },
+		// replaces the entry inserted above
+		func(e QueueEntry) error { return dj.ChangeIndex(e, 0) },
+	} {
+		if err := add(QueueEntry{ID: taken, Media: media, Owner: "bob"}); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+
+	seen := make(map[uint64]bool)
+	for _, name := range []string{RequestQueue, "jingles"} {
+		entries, err := dj.QueueNamed(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if seen[entry.ID] {
+				t.Errorf("ID %d is used twice", entry.ID)
+			}
+			seen[entry.ID] = true
+		}
+	}
+	if entry, err := dj.EntryByID(taken); err != nil || entry.Owner != "alice" {
+		t.Errorf("EntryByID(%d) = %+v, %v, want the entry of alice", taken, entry, err)
+	}
+
+	before, _ := dj.QueueNamed("jingles")
+	if err := dj.MoveBetweenQueues("jingles", 0, RequestQueue, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := dj.Queue()[0].ID; got != before[0].ID {
+		t.Errorf("moved entry has ID %d, want %d", got, before[0].ID)
+	}
+}
+
+func TestDurationCapCountsAllQueues(t *testing.T) {
+	dj := newTestDj(t, nil)
+	if err := dj.AddQueue("shows", 1); err != nil {
+		t.Fatal(err)
+	}
+	dj.SetUserDurationCap(10 * time.Minute)
+	media := Media{Title: "Song", URL: "https://example.com/song", Duration: 4 * time.Minute}
+
+	for i := 0; i < 2; i++ {
+		if err := dj.AddEntryTo("shows", QueueEntry{Media: media, Owner: "alice"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dj.AddEntry(QueueEntry{Media: media, Owner: "alice"}); !errors.Is(err, ErrorUserDurationCap) {
+		t.Errorf("AddEntry() = %v, want ErrorUserDurationCap", err)
+	}
+	if err := dj.AddEntry(QueueEntry{Media: media, Owner: "bob"}); err != nil {
+		t.Errorf("AddEntry() for another owner = %v", err)
+	}
+
+	// moving doesn't change how much alice has queued
+	if err := dj.MoveBetweenQueues("shows", 0, RequestQueue, 0); err != nil {
+		t.Fatalf("MoveBetweenQueues() = %v", err)
+	}
+
+	dj.SetUserDurationCap(5 * time.Minute)
+	if err := dj.MoveBetweenQueues("shows", 0, RequestQueue, 0); !errors.Is(err, ErrorUserDurationCap) {
+		t.Errorf("MoveBetweenQueues() over the cap = %v, want ErrorUserDurationCap", err)
+	}
+	if shows, _ := dj.QueueNamed("shows"); len(shows) != 1 {
+		t.Errorf("%d entries left in shows, want 1", len(shows))
+	}
+	if n := len(dj.Queue()); n != 2 {
+		t.Errorf("%d entries in the request queue, want 2", n)
+	}
+
+	dj.SetQueueLimit(2)
+	dj.SetUserDurationCap(0)
+	if err := dj.MoveBetweenQueues("shows", 0, RequestQueue, 0); !errors.Is(err, ErrorQueueFull) {
+		t.Errorf("MoveBetweenQueues() into a full queue = %v, want ErrorQueueFull", err)
+	}
+}
+
+func TestReplaceIDs(t *testing.T) {
+	dj := newTestDj(t, nil)
+	media := Media{Title: "Song", URL: "https://example.com/song", Duration: 3 * time.Minute}
+	if err := dj.AddEntry(QueueEntry{Media: media, Owner: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	id := dj.Queue()[0].ID
+
+	// updating an entry keeps its ID
+	if err := dj.SetNotes(id, "play loud"); err != nil {
+		t.Fatal(err)
+	}
+	if entry := dj.Queue()[0]; entry.ID != id || entry.Notes != "play loud" {
+		t.Errorf("updated entry = %+v, want ID %d with notes", entry, id)
+	}
+
+	// a different entry gets its own ID
+	if err := dj.ChangeIndex(QueueEntry{Media: media, Owner: "bob"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if entry := dj.Queue()[0]; entry.ID == 0 || entry.ID == id || entry.Owner != "bob" {
+		t.Errorf("replacement = %+v, want a new ID", entry)
+	}
+	if _, err := dj.EntryByID(id); !errors.Is(err, ErrorUnknownEntry) {
+		t.Errorf("EntryByID() of the replaced entry = %v, want ErrorUnknownEntry", err)
+	}
+}
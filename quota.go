@@ -0,0 +1,89 @@
+package opendj
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorUserDurationCap is returned when an entry would exceed the duration its owner may have queued.
+var ErrorUserDurationCap = errors.New("too much queued by this user")
+
+// errNothingPlayable is returned by pop if the queue is not empty,
+// but none of the entries are allowed to be played right now.
+var errNothingPlayable = errors.New("no entry in the queue can be played right now")
+
+// SetUserQuota limits every user to n played songs per rolling window, e.g. 5 per hour.
+//
+// Entries of users that reached their quota stay in the queue and are skipped over
+// until enough of their songs left the window. The quota is tracked against the history
+// of the current session. n <= 0 or a window <= 0 disables the quota.
+func (dj *Dj) SetUserQuota(n int, window time.Duration) {
+	if window <= 0 {
+		n, window = 0, 0
+	}
+	dj.updateConfig(func(c *Config) {
+		c.UserQuota = n
+		c.UserQuotaWindow = window
+	})
+}
+
+// SetUserDurationCap limits the total duration of the entries every user has in the queue,
+// so a single long mix can't bypass the queue limit and user quota.
+//
+// AddEntry and InsertEntry return ErrorUserDurationCap for entries that would exceed it.
+// d <= 0 disables the cap.
+func (dj *Dj) SetUserDurationCap(d time.Duration) {
+	dj.updateConfig(func(c *Config) { c.UserDurationCap = d })
+}
+
+// room returns an error if entry can't be added to the request queue, the lock has to be held.
+//
+// The duration cap counts the owner's entries in all queues, except entry itself if it is already queued.
+func (dj *Dj) room(entry QueueEntry) error {
+	cfg := dj.cfg()
+	if dj.waitingQueue.full(cfg.QueueLimit) {
+		return ErrorQueueFull
+	}
+	if cfg.UserDurationCap <= 0 {
+		return nil
+	}
+
+	queued := entry.TotalDuration()
+	for _, q := range dj.drainOrder() {
+		q.each(func(_ int, e QueueEntry) bool {
+			if e.Owner == entry.Owner && (entry.ID == 0 || e.ID != entry.ID) {
+				queued += e.TotalDuration()
+			}
+			return true
+		})
+	}
+	if queued > cfg.UserDurationCap {
+		return ErrorUserDurationCap
+	}
+	return nil
+}
+
+// playsSince returns how often each owner started playing something after t.
+func (h *history) playsSince(t time.Time) map[string]int {
+	h.Lock()
+	defer h.Unlock()
+
+	plays := make(map[string]int)
+	for i := len(h.entries) - 1; i >= 0 && h.entries[i].Started.After(t); i-- {
+		plays[h.entries[i].Entry.Owner]++
+	}
+	return plays
+}
+
+// eligible returns a function that reports whether an entry may be played now.
+func (dj *Dj) eligible(now time.Time) func(QueueEntry) bool {
+	cfg := dj.cfg()
+	if cfg.UserQuota <= 0 {
+		return func(QueueEntry) bool { return true }
+	}
+
+	plays := dj.history.playsSince(now.Add(-cfg.UserQuotaWindow))
+	return func(entry QueueEntry) bool {
+		return plays[entry.Owner] < cfg.UserQuota
+	}
+}
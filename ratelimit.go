@@ -0,0 +1,57 @@
+package opendj
+
+import (
+	"sync"
+	"time"
+)
+
+// SetEventRateLimit sets the minimum interval between two notifications for events of type t,
+// events that arrive faster are not passed to handlers, announcers and webhooks.
+// This avoids spamming chat during reconnect storms or rapid skips. <= 0 removes the limit.
+//
+// Subscribers always receive every event.
+func (dj *Dj) SetEventRateLimit(t EventType, interval time.Duration) {
+	dj.updateConfig(func(c *Config) {
+		limits := make(map[EventType]time.Duration, len(c.EventRateLimits)+1)
+		for k, v := range c.EventRateLimits {
+			limits[k] = v
+		}
+		if interval > 0 {
+			limits[t] = interval
+		} else {
+			delete(limits, t)
+		}
+		c.EventRateLimits = limits
+	})
+}
+
+// eventLimiter tracks when each event type was last let through.
+// Every consumer of events has its own, so they don't use up each other's allowance.
+type eventLimiter struct {
+	mu   sync.Mutex
+	last map[EventType]time.Time
+}
+
+// allow reports whether an event of type t at time now should be passed on.
+func (l *eventLimiter) allow(limits map[EventType]time.Duration, t EventType, now time.Time) bool {
+	interval := limits[t]
+	if interval <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.last[t]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if l.last == nil {
+		l.last = make(map[EventType]time.Time)
+	}
+	l.last[t] = now
+	return true
+}
+
+// allowHandler reports whether the handler for an event of type t should be called.
+func (dj *Dj) allowHandler(t EventType) bool {
+	return dj.handlerLimiter.allow(dj.cfg().EventRateLimits, t, dj.now())
+}
@@ -0,0 +1,150 @@
+package opendj
+
+import (
+	"errors"
+)
+
+// ErrorNotAllowed is returned by Actions when the actor's role does not allow the change.
+var ErrorNotAllowed = errors.New("not allowed")
+
+// A Reason is a machine-readable code for why a change was rejected, so frontends
+// can show the requester a localized message instead of the error text.
+type Reason string
+
+// The reasons of rejected changes.
+const (
+	// ReasonQueueFull is ErrorQueueFull.
+	ReasonQueueFull Reason = "QUEUE_FULL"
+	// ReasonUserLimit is ErrorUserDurationCap, the requester has too much queued.
+	ReasonUserLimit Reason = "USER_LIMIT"
+	// ReasonBlocked is a *FilterError, the entry was rejected by the content filter.
+	ReasonBlocked Reason = "BLOCKED"
+	// ReasonDuplicate is not used by the Dj itself, admission hooks that reject entries
+	// that are already queued can return it with WithReason.
+	ReasonDuplicate Reason = "DUPLICATE"
+	// ReasonNotAllowed is ErrorNotAllowed.
+	ReasonNotAllowed Reason = "NOT_ALLOWED"
+	// ReasonUnknownEntry is ErrorUnknownEntry, e.g. because the entry was played already.
+	ReasonUnknownEntry Reason = "UNKNOWN_ENTRY"
+	// ReasonUnknownQueue is ErrorUnknownQueue.
+	ReasonUnknownQueue Reason = "UNKNOWN_QUEUE"
+	// ReasonOutOfRange is ErrorOutOfRange.
+	ReasonOutOfRange Reason = "OUT_OF_RANGE"
+	// ReasonNothingPlaying is ErrorNothingPlaying.
+	ReasonNothingPlaying Reason = "NOTHING_PLAYING"
+	// ReasonNotSeekable is ErrorNotSeekable.
+	ReasonNotSeekable Reason = "NOT_SEEKABLE"
+	// ReasonInvalidClip is ErrorInvalidClip.
+	ReasonInvalidClip Reason = "INVALID_CLIP"
+	// ReasonNotAdjacent is ErrorNotAdjacent.
+	ReasonNotAdjacent Reason = "NOT_ADJACENT"
+	// ReasonUnavailable is ErrorUnavailable.
+	ReasonUnavailable Reason = "UNAVAILABLE"
+	// ReasonRejected is any other error, e.g. of an admission hook that gave no reason.
+	ReasonRejected Reason = "REJECTED"
+)
+
+// reasons maps the errors of the Dj to their reasons.
+var reasons = []struct {
+	err    error
+	reason Reason
+}{
+	{ErrorQueueFull, ReasonQueueFull},
+	{ErrorUserDurationCap, ReasonUserLimit},
+	{ErrorNotAllowed, ReasonNotAllowed},
+	{ErrorUnknownEntry, ReasonUnknownEntry},
+	{ErrorUnknownQueue, ReasonUnknownQueue},
+	{ErrorOutOfRange, ReasonOutOfRange},
+	{ErrorNothingPlaying, ReasonNothingPlaying},
+	{ErrorNotSeekable, ReasonNotSeekable},
+	{ErrorInvalidClip, ReasonInvalidClip},
+	{ErrorNotAdjacent, ReasonNotAdjacent},
+	{ErrorUnavailable, ReasonUnavailable},
+}
+
+// ReasonError is an error with a reason, see WithReason.
+type ReasonError struct {
+	Reason Reason
+	Err    error
+}
+
+func (e *ReasonError) Error() string {
+	if e.Err == nil {
+		return string(e.Reason)
+	}
+	return e.Err.Error()
+}
+
+func (e *ReasonError) Unwrap() error {
+	return e.Err
+}
+
+// WithReason attaches a reason to err, e.g. an admission hook returns
+// WithReason(ReasonDuplicate, err) for entries that are already queued.
+func WithReason(reason Reason, err error) error {
+	return &ReasonError{Reason: reason, Err: err}
+}
+
+// ReasonOf returns the reason of an error returned by the Dj, it is empty for nil.
+func ReasonOf(err error) Reason {
+	if err == nil {
+		return ""
+	}
+	var reasonErr *ReasonError
+	if errors.As(err, &reasonErr) && reasonErr.Reason != "" {
+		return reasonErr.Reason
+	}
+	var filterErr *FilterError
+	if errors.As(err, &filterErr) {
+		return ReasonBlocked
+	}
+	for _, r := range reasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return ReasonRejected
+}
+
+// ResultStatus is the outcome of a change.
+type ResultStatus string
+
+// The outcomes of changes.
+const (
+	StatusAccepted ResultStatus = "accepted"
+	// StatusPending means the request waits for approval, see SetApprovalRequired.
+	StatusPending  ResultStatus = "pending"
+	StatusRejected ResultStatus = "rejected"
+)
+
+// A Result is the outcome of a change made through Actions.
+type Result struct {
+	Status ResultStatus `json:"status"`
+	// Reason is set if the change was rejected.
+	Reason Reason `json:"reason,omitempty"`
+	// EntryID is the entry that was changed, if any.
+	EntryID uint64 `json:"entryId,omitempty"`
+	// Message is the error text, it is meant for logs, not for the requester.
+	Message string `json:"message,omitempty"`
+	// Err is the error the change was rejected with.
+	Err error `json:"-"`
+}
+
+// OK reports whether the change was accepted or is pending.
+func (r Result) OK() bool {
+	return r.Status != StatusRejected
+}
+
+// ResultOf returns the result of a change of the entry with the given ID that failed with err, or succeeded if it is nil.
+func ResultOf(id uint64, err error) Result {
+	if err == nil {
+		return Result{Status: StatusAccepted, EntryID: id}
+	}
+	return Result{
+		Status:  StatusRejected,
+		Reason:  ReasonOf(err),
+		EntryID: id,
+		Message: err.Error(),
+		Err:     err,
+	}
+}
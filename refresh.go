@@ -0,0 +1,125 @@
+package opendj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ErrorUnavailable is returned when media was deleted, made private or is otherwise gone for good.
+var ErrorUnavailable = errors.New("media is unavailable")
+
+// unavailableMessage matches yt-dlp errors for media that will never become available again.
+var unavailableMessage = regexp.MustCompile(`(?i)video unavailable|private video|has been removed|account .* terminated|does not exist|is not available`)
+
+// ytdlpInfo is the subset of the yt-dlp JSON output that is used.
+type ytdlpInfo struct {
+	Title      string  `json:"title"`
+	Duration   float64 `json:"duration"`
+	WebpageURL string  `json:"webpage_url"`
+	// Entries is set for search results like "ytsearch1:query".
+	Entries []ytdlpInfo `json:"entries"`
+}
+
+// resolveMedia fetches up to date metadata for the given URL with yt-dlp.
+//
+// For searches like "ytsearch1:query" the first result is returned.
+// returns an error wrapping ErrorUnavailable if the media is gone.
+func resolveMedia(ctx context.Context, url string) (Media, error) {
+	var stderr bytes.Buffer
+	cmd := newCommand(ctx, "yt-dlp", "-J", "--no-playlist", "--", url)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if unavailableMessage.Match(stderr.Bytes()) {
+			return Media{}, fmt.Errorf("%s: %w", url, ErrorUnavailable)
+		}
+		return Media{}, fmt.Errorf("failed to resolve %s: %w", url, err)
+	}
+
+	var info ytdlpInfo
+	if err = json.Unmarshal(output, &info); err != nil {
+		return Media{}, fmt.Errorf("failed to parse yt-dlp output for %s: %w", url, err)
+	}
+	if len(info.Entries) > 0 {
+		info = info.Entries[0]
+		url = info.WebpageURL
+	}
+	return Media{
+		Title:    info.Title,
+		URL:      url,
+		Duration: time.Duration(info.Duration * float64(time.Second)),
+	}, nil
+}
+
+// RefreshQueueMetadata re-resolves all queued entries and updates their titles and durations.
+//
+// Resolutions are limited as configured with SetResolverLimits.
+// Entries of all queues are refreshed, including the named queues of AddQueue.
+// Entries whose media is no longer available are flagged as Dead and stay in their queue,
+// so they can be reviewed or removed, but they are skipped when the next entry is picked.
+// The first error that is not caused by unavailable media is returned after all entries were processed.
+func (dj *Dj) RefreshQueueMetadata(ctx context.Context) error {
+	seen := make(map[string]bool)
+	var urls []string
+	dj.waitingQueue.Lock()
+	for _, q := range dj.drainOrder() {
+		q.each(func(_ int, entry QueueEntry) bool {
+			if !seen[entry.Media.URL] {
+				seen[entry.Media.URL] = true
+				urls = append(urls, entry.Media.URL)
+			}
+			return true
+		})
+	}
+	dj.waitingQueue.Unlock()
+
+	type result struct {
+		media Media
+		dead  bool
+	}
+	results := make(map[string]result)
+	var firstErr error
+
+	resolved, errs := dj.resolveAll(ctx, urls)
+	for i, url := range urls {
+		if errors.Is(errs[i], ErrorUnavailable) {
+			results[url] = result{dead: true}
+		} else if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+		} else {
+			results[url] = result{media: resolved[i]}
+		}
+	}
+
+	dj.lockQueue()
+	defer dj.unlockQueue()
+	for _, q := range dj.drainOrder() {
+		for i, entry := range q.entries() {
+			res, ok := results[entry.Media.URL]
+			if !ok {
+				continue
+			}
+
+			updated := entry
+			if res.dead {
+				updated.Dead = true
+			} else {
+				updated.Dead = false
+				updated.Media.Title = res.media.Title
+				updated.Media.Duration = res.media.Duration
+			}
+			if updated.Dead != entry.Dead || updated.Media != entry.Media {
+				q.replace(i, updated)
+			}
+		}
+	}
+
+	return firstErr
+}
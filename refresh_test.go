@@ -0,0 +1,83 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeYtdlp resolves every URL to the title Fresh, URLs containing "gone" are unavailable.
+const fakeYtdlp = `#!/bin/sh
+for url; do :; done
+case "$url" in
+*gone*) echo "ERROR: Video unavailable" >&2; exit 1 ;;
+esac
+echo '{"title":"Fresh","duration":42,"webpage_url":"'$url'"}'
+`
+
+func TestRefreshFlagsDeadEntries(t *testing.T) {
+	dj := newTestDj(t, []QueueEntry{
+		{Media: Media{Title: "Gone", URL: "https://example.org/gone"}},
+		{Media: Media{Title: "Stale", URL: "https://example.org/alive"}},
+	})
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "yt-dlp"), []byte(fakeYtdlp), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if err := dj.AddQueue("jingles", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := dj.AddEntryTo("jingles", QueueEntry{Media: Media{Title: "Gone Jingle", URL: "https://example.org/gone-jingle"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dj.RefreshQueueMetadata(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	queue := dj.Queue()
+	if !queue[0].Dead || queue[1].Dead {
+		t.Errorf("dead flags are %v and %v, want true and false", queue[0].Dead, queue[1].Dead)
+	}
+	if queue[1].Media.Title != "Fresh" || queue[1].Media.Duration != 42*time.Second {
+		t.Errorf("entry was not refreshed: %+v", queue[1].Media)
+	}
+	jingles, err := dj.QueueNamed("jingles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jingles[0].Dead {
+		t.Error("the entry of the named queue was not refreshed")
+	}
+
+	// the dead entries of both queues are skipped, but stay queued
+	entry, err := dj.pop()
+	if err != nil || entry.Media.URL != "https://example.org/alive" {
+		t.Fatalf("pop() = %s, %v, want the alive entry", entry.Media.URL, err)
+	}
+	if _, err = dj.pop(); !errors.Is(err, ErrorEmptyQueue) {
+		t.Errorf("pop() with only dead entries returned %v, want ErrorEmptyQueue", err)
+	}
+	if len(dj.Queue()) != 1 {
+		t.Errorf("%d entries left in the queue, want the dead one", len(dj.Queue()))
+	}
+}
+
+func TestResolveMediaEndsOptions(t *testing.T) {
+	// fails unless the URL follows --, so it can't be read as an option
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do\n\t[ $# -gt 0 ] || exit 1\n\tshift\ndone\necho '{\"title\":\"'\"$2\"'\"}'\n"
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "yt-dlp"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	media, err := resolveMedia(context.Background(), "--exec=true")
+	if err != nil || media.Title != "--exec=true" {
+		t.Errorf("resolved %+v, %v", media, err)
+	}
+}
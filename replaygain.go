@@ -0,0 +1,88 @@
+package opendj
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// r128Offset converts R128 gains, which are relative to -23 LUFS, to the ReplayGain reference of -18 LUFS.
+const r128Offset = 5
+
+// SetReplayGain applies the ReplayGain or R128 tags of local files, so libraries that are
+// already tagged are normalized without analyzing them again. preamp in dB is added to the stored gain.
+//
+// Files without tags and other media are played unchanged.
+// Tagged files are transcoded, since the gain is applied through the filter chain.
+func (dj *Dj) SetReplayGain(enabled bool, preamp float64) {
+	dj.updateConfig(func(c *Config) {
+		c.ReplayGain = enabled
+		c.ReplayGainPreamp = preamp
+	})
+}
+
+// isLocalFile reports whether the media URL is a path or a file:// URL.
+func isLocalFile(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err != nil || u.Scheme == "" || u.Scheme == "file"
+}
+
+// localPath returns the real path of a local media file if the configured TrackSource
+// plays it with a FileSource, so only files below its root are inspected.
+func (dj *Dj) localPath(rawURL string) (string, bool) {
+	if !isLocalFile(rawURL) {
+		return "", false
+	}
+	var files FileSource
+	switch s := dj.cfg().TrackSource.(type) {
+	case FileSource:
+		files = s
+	case *TrackSourceRegistry:
+		source, err := s.lookup(rawURL)
+		if files, _ = source.(FileSource); err != nil || files.Root == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	path, err := files.resolve(rawURL)
+	return path, err == nil
+}
+
+// replayGainFilter returns a volume filter for the stored gain of media,
+// or an empty string if ReplayGain is disabled or the media is not tagged.
+func (dj *Dj) replayGainFilter(ctx context.Context, media Media) string {
+	cfg := dj.cfg()
+	if !cfg.ReplayGain {
+		return ""
+	}
+	path, ok := dj.localPath(media.URL)
+	if !ok {
+		return ""
+	}
+	gain, ok := readReplayGain(dj.withLimits(ctx), path)
+	if !ok {
+		return ""
+	}
+	return "volume=" + formatGain(gain+cfg.ReplayGainPreamp) + "dB"
+}
+
+// readReplayGain returns the track gain stored in the tags of the file at path.
+func readReplayGain(ctx context.Context, path string) (float64, bool) {
+	info, err := ProbeMedia(ctx, path)
+	if err != nil {
+		return 0, false
+	}
+
+	// e.g. "-6.50 dB"
+	value := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(info.Tags["replaygain_track_gain"]), "dB"))
+	if gain, err := strconv.ParseFloat(value, 64); err == nil {
+		return gain, true
+	}
+	// a Q7.8 fixed point number
+	if q, err := strconv.Atoi(strings.TrimSpace(info.Tags["r128_track_gain"])); err == nil {
+		return float64(q)/256 + r128Offset, true
+	}
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package opendj
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ReportFormat is the format of a history report.
+type ReportFormat int
+
+const (
+	// ReportCSV writes a header row followed by one row per played entry.
+	ReportCSV ReportFormat = iota
+	// ReportJSON writes an array of objects.
+	ReportJSON
+)
+
+// reportEntry is a row of a history report.
+type reportEntry struct {
+	Started   time.Time `json:"started"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	Requester string    `json:"requester"`
+	// Duration is how long the entry was played in seconds.
+	Duration float64 `json:"duration"`
+	Skipped  bool    `json:"skipped"`
+}
+
+// ExportHistory writes a report of all entries played in the current session to w,
+// e.g. for royalty reporting or community recaps.
+//
+// Every entry has the time it started playing, its title, URL, requester, how long
+// it was played in seconds and whether it was skipped.
+func (dj *Dj) ExportHistory(w io.Writer, format ReportFormat) error {
+	var entries []reportEntry
+	for _, played := range dj.History() {
+		entries = append(entries, reportEntry{
+			Started:   played.Started,
+			Title:     played.Entry.Media.Title,
+			URL:       played.Entry.Media.URL,
+			Requester: played.Entry.PublicOwner(),
+			Duration:  played.Duration.Seconds(),
+			Skipped:   errors.Is(played.Err, ErrorSkipped),
+		})
+	}
+
+	switch format {
+	case ReportCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"started", "title", "url", "requester", "duration", "skipped"})
+		for _, entry := range entries {
+			_ = cw.Write([]string{
+				entry.Started.Format(time.RFC3339),
+				entry.Title,
+				entry.URL,
+				entry.Requester,
+				strconv.FormatFloat(entry.Duration, 'f', 3, 64),
+				strconv.FormatBool(entry.Skipped),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case ReportJSON:
+		if entries == nil {
+			entries = []reportEntry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	default:
+		return fmt.Errorf("unknown report format %d", format)
+	}
+}
@@ -0,0 +1,222 @@
+package opendj
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// A Resolver fetches the metadata of the media at a URL.
+type Resolver interface {
+	// Resolve returns the media at url.
+	// The error wraps ErrorUnavailable if the media is gone for good.
+	Resolve(ctx context.Context, url string) (Media, error)
+}
+
+// An Expander is a Resolver for URLs that can contain several media, like albums or playlists.
+type Expander interface {
+	Resolver
+	// Expand returns all media at url in order, a single track results in a single media.
+	Expand(ctx context.Context, url string) ([]Media, error)
+}
+
+// ErrorMultipleMedia is returned by Resolve for URLs of albums or playlists, they have to be expanded instead.
+var ErrorMultipleMedia = errors.New("url contains multiple media")
+
+// ResolverFunc is a function that implements Resolver.
+type ResolverFunc func(ctx context.Context, url string) (Media, error)
+
+// Resolve calls f.
+func (f ResolverFunc) Resolve(ctx context.Context, url string) (Media, error) {
+	return f(ctx, url)
+}
+
+// YtdlpResolver resolves media with the JSON output of yt-dlp.
+// It supports every site yt-dlp supports.
+type YtdlpResolver struct{}
+
+// Resolve calls yt-dlp for url.
+func (YtdlpResolver) Resolve(ctx context.Context, url string) (Media, error) {
+	return resolveMedia(ctx, url)
+}
+
+// OEmbedResolver resolves media with an oEmbed endpoint, e.g. https://www.youtube.com/oembed.
+//
+// oEmbed does not provide durations, so they are always zero.
+type OEmbedResolver struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// Resolve queries the oEmbed endpoint for url.
+func (r OEmbedResolver) Resolve(ctx context.Context, mediaURL string) (Media, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	query := url.Values{}
+	query.Set("url", mediaURL)
+	query.Set("format", "json")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.Endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return Media{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Media{}, fmt.Errorf("failed to query oEmbed endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+		return Media{}, fmt.Errorf("%s: %w", mediaURL, ErrorUnavailable)
+	default:
+		return Media{}, fmt.Errorf("oEmbed endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Title string `json:"title"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Media{}, fmt.Errorf("failed to decode oEmbed response: %w", err)
+	}
+	return Media{Title: body.Title, URL: mediaURL}, nil
+}
+
+// A ResolverRegistry selects a Resolver based on the host of the URL.
+//
+// The zero value resolves everything with yt-dlp except local paths and file:// URLs,
+// which are rejected unless Files is set. It is safe for concurrent use.
+type ResolverRegistry struct {
+	mu        sync.RWMutex
+	resolvers map[string]Resolver
+	// Fallback is used for URLs no resolver was registered for, it defaults to YtdlpResolver.
+	Fallback Resolver
+	// Files resolves local paths and file:// URLs, e.g. ProbeResolver{Root: "/srv/music"}.
+	// It should only accept the files the FileSource of the TrackSource plays.
+	Files Resolver
+}
+
+// Register uses r for all URLs with the given host or one of its subdomains.
+func (reg *ResolverRegistry) Register(host string, r Resolver) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.resolvers == nil {
+		reg.resolvers = make(map[string]Resolver)
+	}
+	reg.resolvers[strings.ToLower(host)] = r
+}
+
+// Resolve resolves url with the resolver registered for its host.
+func (reg *ResolverRegistry) Resolve(ctx context.Context, rawURL string) (Media, error) {
+	return reg.lookup(rawURL).Resolve(ctx, rawURL)
+}
+
+// Expand expands url with the resolver registered for its host,
+// resolvers that are not an Expander return a single media.
+func (reg *ResolverRegistry) Expand(ctx context.Context, rawURL string) ([]Media, error) {
+	return expand(ctx, reg.lookup(rawURL), rawURL)
+}
+
+// expand expands url with r if it is an Expander and resolves it otherwise.
+func expand(ctx context.Context, r Resolver, url string) ([]Media, error) {
+	if e, ok := r.(Expander); ok {
+		return e.Expand(ctx, url)
+	}
+	media, err := r.Resolve(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return []Media{media}, nil
+}
+
+func (reg *ResolverRegistry) lookup(rawURL string) Resolver {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	if u, err := url.Parse(rawURL); err == nil {
+		host := strings.ToLower(u.Hostname())
+		for host != "" {
+			if r, ok := reg.resolvers[host]; ok {
+				return r
+			}
+			_, parent, found := strings.Cut(host, ".")
+			if !found {
+				break
+			}
+			host = parent
+		}
+	}
+
+	if isLocalFile(rawURL) {
+		if reg.Files != nil {
+			return reg.Files
+		}
+		return ResolverFunc(func(context.Context, string) (Media, error) {
+			return Media{}, fmt.Errorf("%s: %w", rawURL, ErrorLocalFile)
+		})
+	}
+	if reg.Fallback != nil {
+		return reg.Fallback
+	}
+	return YtdlpResolver{}
+}
+
+// SetResolver sets the Resolver used for playlist imports, validation and metadata refreshes.
+//
+// It defaults to YtdlpResolver, a ResolverRegistry can be used to pick one per URL.
+// A resolver using the YouTube Data API is available in the youtube subpackage.
+func (dj *Dj) SetResolver(r Resolver) {
+	dj.updateConfig(func(c *Config) { c.Resolver = r })
+}
+
+// Resolve returns the media at url using the configured Resolver.
+func (dj *Dj) Resolve(ctx context.Context, url string) (Media, error) {
+	r := dj.cfg().Resolver
+	if r == nil {
+		r = YtdlpResolver{}
+	}
+	if dj.circuitOpen() {
+		return Media{}, ErrorCircuitOpen
+	}
+	if err := dj.waitHost(ctx, url); err != nil {
+		return Media{}, err
+	}
+	dj.pipeline.resolveStarted()
+	media, err := r.Resolve(dj.withLimits(ctx), url)
+	dj.pipeline.resolveDone(err)
+	if ctx.Err() == nil {
+		dj.recordResult(err)
+	}
+	return media, err
+}
+
+// Expand returns all media at url using the configured Resolver, e.g. every track of an album.
+//
+// If the resolver is not an Expander the result is the single media returned by Resolve.
+func (dj *Dj) Expand(ctx context.Context, url string) ([]Media, error) {
+	r := dj.cfg().Resolver
+	if r == nil {
+		r = YtdlpResolver{}
+	}
+	if dj.circuitOpen() {
+		return nil, ErrorCircuitOpen
+	}
+	if err := dj.waitHost(ctx, url); err != nil {
+		return nil, err
+	}
+	dj.pipeline.resolveStarted()
+	media, err := expand(dj.withLimits(ctx), r, url)
+	dj.pipeline.resolveDone(err)
+	if ctx.Err() == nil {
+		dj.recordResult(err)
+	}
+	return media, err
+}
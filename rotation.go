@@ -0,0 +1,117 @@
+package opendj
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Common rotation categories, any other value can be used as well.
+const (
+	CategoryHeavy  = "heavy"
+	CategoryMedium = "medium"
+	CategoryLight  = "light"
+)
+
+// A Rotation plays library tracks by category like radio automation,
+// e.g. current hits in heavy rotation several times a day and older tracks only occasionally.
+type Rotation struct {
+	// Pattern is the order categories are played in, it repeats once it ran out,
+	// e.g. heavy, medium, heavy, light. Categories without tracks are skipped.
+	Pattern []string
+	// ArtistSeparation and TitleSeparation are the minimum time between two plays
+	// of the same artist or title. They are relaxed, the artist first, if no track
+	// of the category satisfies them.
+	ArtistSeparation time.Duration
+	TitleSeparation  time.Duration
+}
+
+// SetRotation plays the fallback library by the categories of the rotation instead of
+// picking the track that was played longest ago, see SetFallbackLibrary. The category
+// of a track is LibraryTrack.Category. A nil rotation disables it.
+func (dj *Dj) SetRotation(r *Rotation) {
+	dj.fallback.Lock()
+	defer dj.fallback.Unlock()
+
+	if r != nil {
+		copied := *r
+		copied.Pattern = append([]string(nil), r.Pattern...)
+		r = &copied
+	}
+	dj.fallback.rotation = r
+	dj.fallback.position = 0
+}
+
+// category returns the category played at position.
+func (r *Rotation) category(position int) string {
+	return strings.ToLower(r.Pattern[position%len(r.Pattern)])
+}
+
+// pickRotation returns the next track of the rotation starting at position matching query
+// and records it as played. It also returns the position of the category after the picked one.
+func (l *Library) pickRotation(r *Rotation, position int, query string, now time.Time) (LibraryTrack, int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(r.Pattern) == 0 {
+		return LibraryTrack{}, position, false
+	}
+
+	// the last time every artist and title was played
+	artists := make(map[string]time.Time)
+	titles := make(map[string]time.Time)
+	for _, track := range l.tracks {
+		artist, title := strings.ToLower(track.Artist), strings.ToLower(track.Title)
+		if artist != "" && track.LastPlayed.After(artists[artist]) {
+			artists[artist] = track.LastPlayed
+		}
+		if track.LastPlayed.After(titles[title]) {
+			titles[title] = track.LastPlayed
+		}
+	}
+	separated := func(last time.Time, d time.Duration) bool {
+		return d <= 0 || last.IsZero() || now.Sub(last) >= d
+	}
+
+	matching := l.matching(strings.Fields(strings.ToLower(query)))
+	for tried := 0; tried < len(r.Pattern); tried++ {
+		category := r.category(position + tried)
+		var candidates []*LibraryTrack
+		for _, track := range matching {
+			if strings.ToLower(track.Category) == category {
+				candidates = append(candidates, track)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		rand.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+
+		// every rule is relaxed in turn until there is a track
+		rules := []func(*LibraryTrack) bool{
+			func(t *LibraryTrack) bool {
+				return separated(artists[strings.ToLower(t.Artist)], r.ArtistSeparation) &&
+					separated(titles[strings.ToLower(t.Title)], r.TitleSeparation)
+			},
+			func(t *LibraryTrack) bool {
+				return separated(titles[strings.ToLower(t.Title)], r.TitleSeparation)
+			},
+			func(*LibraryTrack) bool { return true },
+		}
+		for _, allowed := range rules {
+			var pick *LibraryTrack
+			for _, track := range candidates {
+				if allowed(track) && (pick == nil || track.LastPlayed.Before(pick.LastPlayed)) {
+					pick = track
+				}
+			}
+			if pick != nil {
+				l.played(pick, now)
+				return *pick, position + tried + 1, true
+			}
+		}
+	}
+	return LibraryTrack{}, position, false
+}
@@ -0,0 +1,186 @@
+package rtmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// AMF0 type markers.
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0a
+	amfLongString  = 0x0c
+)
+
+var errAMF = errors.New("invalid AMF0 data")
+
+// property is a key value pair of an AMF object, objects are slices so the key order is kept.
+type property struct {
+	key   string
+	value any
+}
+
+type object []property
+
+// amfEncode encodes values as AMF0, it supports numbers, strings, booleans, nil and objects.
+func amfEncode(values ...any) []byte {
+	var b bytes.Buffer
+	for _, v := range values {
+		amfEncodeValue(&b, v)
+	}
+	return b.Bytes()
+}
+
+func amfEncodeValue(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteByte(amfNull)
+	case bool:
+		b.WriteByte(amfBoolean)
+		if v {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+	case int:
+		amfEncodeValue(b, float64(v))
+	case float64:
+		b.WriteByte(amfNumber)
+		_ = binary.Write(b, binary.BigEndian, math.Float64bits(v))
+	case string:
+		if len(v) > math.MaxUint16 {
+			b.WriteByte(amfLongString)
+			_ = binary.Write(b, binary.BigEndian, uint32(len(v)))
+		} else {
+			b.WriteByte(amfString)
+			_ = binary.Write(b, binary.BigEndian, uint16(len(v)))
+		}
+		b.WriteString(v)
+	case object:
+		b.WriteByte(amfObject)
+		for _, p := range v {
+			_ = binary.Write(b, binary.BigEndian, uint16(len(p.key)))
+			b.WriteString(p.key)
+			amfEncodeValue(b, p.value)
+		}
+		b.Write([]byte{0, 0, amfObjectEnd})
+	default:
+		panic(fmt.Sprintf("rtmp: can't encode %T as AMF0", v))
+	}
+}
+
+// amfDecode decodes all AMF0 values in data.
+// Objects and ECMA arrays are decoded as map[string]any, strict arrays as []any.
+func amfDecode(data []byte) ([]any, error) {
+	r := bytes.NewReader(data)
+	var values []any
+	for r.Len() > 0 {
+		v, err := amfDecodeValue(r)
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func amfDecodeValue(r *bytes.Reader) (any, error) {
+	marker, err := r.ReadByte()
+	if err != nil {
+		return nil, errAMF
+	}
+	switch marker {
+	case amfNumber:
+		var bits uint64
+		if err = binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, errAMF
+		}
+		return math.Float64frombits(bits), nil
+	case amfBoolean:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, errAMF
+		}
+		return b != 0, nil
+	case amfString:
+		return amfDecodeString(r, 2)
+	case amfLongString:
+		return amfDecodeString(r, 4)
+	case amfNull, amfUndefined:
+		return nil, nil
+	case amfECMAArray:
+		if _, err = r.Seek(4, 1); err != nil {
+			return nil, errAMF
+		}
+		return amfDecodeObject(r)
+	case amfObject:
+		return amfDecodeObject(r)
+	case amfStrictArray:
+		var n uint32
+		if err = binary.Read(r, binary.BigEndian, &n); err != nil || int(n) > r.Len() {
+			return nil, errAMF
+		}
+		values := make([]any, 0, n)
+		for i := uint32(0); i < n; i++ {
+			v, err := amfDecodeValue(r)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported type %#x", errAMF, marker)
+}
+
+func amfDecodeString(r *bytes.Reader, sizeBytes int) (string, error) {
+	var n int
+	if sizeBytes == 2 {
+		var n16 uint16
+		if err := binary.Read(r, binary.BigEndian, &n16); err != nil {
+			return "", errAMF
+		}
+		n = int(n16)
+	} else {
+		var n32 uint32
+		if err := binary.Read(r, binary.BigEndian, &n32); err != nil {
+			return "", errAMF
+		}
+		n = int(n32)
+	}
+	if n > r.Len() {
+		return "", errAMF
+	}
+	b := make([]byte, n)
+	_, _ = r.Read(b)
+	return string(b), nil
+}
+
+func amfDecodeObject(r *bytes.Reader) (map[string]any, error) {
+	obj := make(map[string]any)
+	for {
+		key, err := amfDecodeString(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			if marker, err := r.ReadByte(); err != nil || marker != amfObjectEnd {
+				return nil, errAMF
+			}
+			return obj, nil
+		}
+		if obj[key], err = amfDecodeValue(r); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package rtmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Message type IDs.
+const (
+	typeSetChunkSize     = 1
+	typeAbort            = 2
+	typeAcknowledgement  = 3
+	typeUserControl      = 4
+	typeWindowAckSize    = 5
+	typeSetPeerBandwidth = 6
+	typeAudio            = 8
+	typeDataAMF0         = 18
+	typeCommandAMF0      = 20
+)
+
+// Chunk stream IDs used for the messages that are sent.
+const (
+	csidControl = 2
+	csidCommand = 3
+	csidAudio   = 4
+)
+
+const (
+	defaultChunkSize = 128
+	// outgoingChunkSize is the chunk size announced to the server right after the handshake.
+	outgoingChunkSize = 4096
+	maxMessageSize    = 16 << 20
+	// extendedTimestamp marks that a timestamp does not fit into 3 bytes.
+	extendedTimestamp = 0xffffff
+)
+
+// A message is a complete RTMP message.
+type message struct {
+	typeID    uint8
+	streamID  uint32
+	timestamp uint32
+	payload   []byte
+}
+
+// chunkWriter splits messages into chunks, every message is sent with a full header.
+type chunkWriter struct {
+	w    *bufio.Writer
+	size int
+}
+
+func (cw *chunkWriter) write(csid uint8, m message) error {
+	timestamp := m.timestamp
+	extended := timestamp >= extendedTimestamp
+	if extended {
+		timestamp = extendedTimestamp
+	}
+
+	header := make([]byte, 0, 16)
+	header = append(header, csid&0x3f,
+		byte(timestamp>>16), byte(timestamp>>8), byte(timestamp),
+		byte(len(m.payload)>>16), byte(len(m.payload)>>8), byte(len(m.payload)),
+		m.typeID,
+	)
+	header = binary.LittleEndian.AppendUint32(header, m.streamID)
+	if extended {
+		header = binary.BigEndian.AppendUint32(header, m.timestamp)
+	}
+	if _, err := cw.w.Write(header); err != nil {
+		return err
+	}
+
+	payload := m.payload
+	for {
+		n := len(payload)
+		if n > cw.size {
+			n = cw.size
+		}
+		if _, err := cw.w.Write(payload[:n]); err != nil {
+			return err
+		}
+		payload = payload[n:]
+		if len(payload) == 0 {
+			break
+		}
+
+		// continuation chunks only have a basic header and the extended timestamp
+		continuation := []byte{0xc0 | csid&0x3f}
+		if extended {
+			continuation = binary.BigEndian.AppendUint32(continuation, m.timestamp)
+		}
+		if _, err := cw.w.Write(continuation); err != nil {
+			return err
+		}
+	}
+	return cw.w.Flush()
+}
+
+// chunkStream is the state of a chunk stream that is read.
+type chunkStream struct {
+	timestamp uint32
+	delta     uint32
+	length    int
+	typeID    uint8
+	streamID  uint32
+	extended  bool
+	payload   []byte
+}
+
+// chunkReader assembles messages from the chunks read from a connection.
+type chunkReader struct {
+	r       *bufio.Reader
+	size    int
+	streams map[uint32]*chunkStream
+}
+
+func newChunkReader(r *bufio.Reader) *chunkReader {
+	return &chunkReader{r: r, size: defaultChunkSize, streams: make(map[uint32]*chunkStream)}
+}
+
+// read returns the next complete message.
+func (cr *chunkReader) read() (message, error) {
+	for {
+		m, complete, err := cr.readChunk()
+		if err != nil || complete {
+			return m, err
+		}
+	}
+}
+
+func (cr *chunkReader) readChunk() (m message, complete bool, err error) {
+	b, err := cr.r.ReadByte()
+	if err != nil {
+		return m, false, err
+	}
+	format := b >> 6
+	csid := uint32(b & 0x3f)
+	switch csid {
+	case 0:
+		next, err := cr.r.ReadByte()
+		if err != nil {
+			return m, false, err
+		}
+		csid = 64 + uint32(next)
+	case 1:
+		var next [2]byte
+		if _, err = io.ReadFull(cr.r, next[:]); err != nil {
+			return m, false, err
+		}
+		csid = 64 + uint32(next[0]) + uint32(next[1])<<8
+	}
+
+	cs, ok := cr.streams[csid]
+	if !ok {
+		if format != 0 {
+			return m, false, fmt.Errorf("chunk stream %d does not start with a full header", csid)
+		}
+		cs = &chunkStream{}
+		cr.streams[csid] = cs
+	}
+
+	headerSizes := [...]int{11, 7, 3, 0}
+	header := make([]byte, headerSizes[format])
+	if _, err = io.ReadFull(cr.r, header); err != nil {
+		return m, false, err
+	}
+	newMessage := len(cs.payload) == 0
+	var timestamp uint32
+	if format < 3 {
+		timestamp = uint32(header[0])<<16 | uint32(header[1])<<8 | uint32(header[2])
+		cs.extended = timestamp == extendedTimestamp
+	}
+	if format < 2 {
+		cs.length = int(header[3])<<16 | int(header[4])<<8 | int(header[5])
+		cs.typeID = header[6]
+		if cs.length > maxMessageSize {
+			return m, false, fmt.Errorf("message of %d bytes is too large", cs.length)
+		}
+	}
+	if format == 0 {
+		cs.streamID = binary.LittleEndian.Uint32(header[7:])
+	}
+	if cs.extended {
+		var ext [4]byte
+		if _, err = io.ReadFull(cr.r, ext[:]); err != nil {
+			return m, false, err
+		}
+		if format < 3 {
+			timestamp = binary.BigEndian.Uint32(ext[:])
+		}
+	}
+
+	switch {
+	case format == 0:
+		cs.timestamp, cs.delta = timestamp, 0
+	case format < 3:
+		cs.delta = timestamp
+		cs.timestamp += timestamp
+	case newMessage:
+		cs.timestamp += cs.delta
+	}
+
+	n := cs.length - len(cs.payload)
+	if n > cr.size {
+		n = cr.size
+	}
+	start := len(cs.payload)
+	cs.payload = append(cs.payload, make([]byte, n)...)
+	if _, err = io.ReadFull(cr.r, cs.payload[start:]); err != nil {
+		return m, false, err
+	}
+	if len(cs.payload) < cs.length {
+		return m, false, nil
+	}
+
+	m = message{typeID: cs.typeID, streamID: cs.streamID, timestamp: cs.timestamp, payload: cs.payload}
+	cs.payload = nil
+	return m, true, nil
+}
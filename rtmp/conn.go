@@ -0,0 +1,378 @@
+package rtmp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	handshakeSize    = 1536
+	handshakeTimeout = 10 * time.Second
+	flashVersion     = "FMLE/3.0 (compatible; opendj)"
+)
+
+// ErrorRejected is returned when the server refuses the connection or the stream,
+// e.g. because of a wrong stream key.
+var ErrorRejected = errors.New("rejected by the server")
+
+// A Conn is an RTMP connection that publishes a single stream.
+type Conn struct {
+	conn     net.Conn
+	r        *chunkReader
+	streamID uint32
+	key      string
+	// server is the URL without the stream key, it is used in errors since the key is a secret
+	server string
+
+	writeMu sync.Mutex
+	w       *chunkWriter
+
+	errMu sync.Mutex
+	err   error
+	done  chan struct{}
+}
+
+// Dial connects to the RTMP server and starts publishing to the stream at rawURL,
+// e.g. rtmp://live.twitch.tv/app/<stream key>. rtmps URLs are supported as well.
+//
+// The last path segment of the URL is the stream key, the rest the application.
+func Dial(ctx context.Context, rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.Trim(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("%s://%s is missing the application or stream key", u.Scheme, u.Host)
+	}
+	app, key := path[:i], path[i+1:]
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+
+	port := "1935"
+	switch u.Scheme {
+	case "rtmp":
+	case "rtmps":
+		port = "443"
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	server := u.Scheme + "://" + u.Host + "/" + app
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", server, err)
+	}
+	if u.Scheme == "rtmps" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+
+	// the connection is closed if ctx is cancelled before publishing started
+	established := make(chan struct{})
+	defer close(established)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-established:
+		}
+	}()
+
+	deadline := time.Now().Add(handshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	reader := bufio.NewReader(conn)
+	c := &Conn{
+		conn:   conn,
+		r:      newChunkReader(reader),
+		w:      &chunkWriter{w: bufio.NewWriter(conn), size: defaultChunkSize},
+		server: server,
+		done:   make(chan struct{}),
+	}
+	if err = c.handshake(reader); err == nil {
+		err = c.publish(app, key, u.Scheme+"://"+u.Host+"/"+app)
+	}
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to publish to %s: %w", server, err)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	go c.readLoop()
+	return c, nil
+}
+
+// handshake performs the simple handshake, the digest handshake is not needed for publishing.
+func (c *Conn) handshake(r *bufio.Reader) error {
+	c1 := make([]byte, 1+handshakeSize)
+	c1[0] = 3
+	if _, err := rand.Read(c1[9:]); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(c1); err != nil {
+		return err
+	}
+
+	s := make([]byte, 1+2*handshakeSize)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return fmt.Errorf("handshake failed: %w", err)
+	}
+	if s[0] != 3 {
+		return fmt.Errorf("handshake failed: unsupported RTMP version %d", s[0])
+	}
+	// C2 echoes S1
+	if _, err := c.conn.Write(s[1 : 1+handshakeSize]); err != nil {
+		return err
+	}
+
+	c.w.size = outgoingChunkSize
+	return c.write(csidControl, message{typeID: typeSetChunkSize, payload: binary.BigEndian.AppendUint32(nil, outgoingChunkSize)})
+}
+
+// publish connects to the application and starts publishing the stream.
+func (c *Conn) publish(app, key, tcURL string) error {
+	c.key = key
+	err := c.command(0, "connect", 1, object{
+		{"app", app},
+		{"type", "nonprivate"},
+		{"flashVer", flashVersion},
+		{"tcUrl", tcURL},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = c.result(1); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+
+	// not every server responds to releaseStream and FCPublish, their results are ignored
+	if err = c.command(0, "releaseStream", 2, nil, key); err != nil {
+		return err
+	}
+	if err = c.command(0, "FCPublish", 3, nil, key); err != nil {
+		return err
+	}
+	if err = c.command(0, "createStream", 4, nil); err != nil {
+		return err
+	}
+	values, err := c.result(4)
+	if err != nil {
+		return fmt.Errorf("createStream: %w", err)
+	}
+	id, ok := values[len(values)-1].(float64)
+	if !ok {
+		return errors.New("createStream: no stream ID in response")
+	}
+	c.streamID = uint32(id)
+
+	if err = c.command(c.streamID, "publish", 5, nil, key, "live"); err != nil {
+		return err
+	}
+	for {
+		m, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		if name, info, ok := status(m); ok {
+			if code, _ := info["code"].(string); code == "NetStream.Publish.Start" {
+				return nil
+			}
+			if err := statusError(name, info); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+}
+
+// command sends a command message.
+func (c *Conn) command(streamID uint32, name string, transaction int, args ...any) error {
+	return c.write(csidCommand, message{
+		typeID:   typeCommandAMF0,
+		streamID: streamID,
+		payload:  amfEncode(append([]any{name, transaction}, args...)...),
+	})
+}
+
+// result waits for the response to the command with the given transaction ID
+// and returns its values after the command name and transaction ID.
+func (c *Conn) result(transaction int) ([]any, error) {
+	for {
+		m, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		if m.typeID != typeCommandAMF0 {
+			continue
+		}
+		values, err := amfDecode(m.payload)
+		if err != nil || len(values) < 3 {
+			continue
+		}
+		name, _ := values[0].(string)
+		id, _ := values[1].(float64)
+		if int(id) != transaction {
+			continue
+		}
+		switch name {
+		case "_result":
+			return values[2:], nil
+		case "_error":
+			info, _ := values[len(values)-1].(map[string]any)
+			return nil, statusError(name, info)
+		}
+	}
+}
+
+// readMessage returns the next message that is not a protocol control message.
+func (c *Conn) readMessage() (message, error) {
+	for {
+		m, err := c.r.read()
+		if err != nil {
+			return m, err
+		}
+		if handled, err := c.control(m); err != nil {
+			return m, err
+		} else if !handled {
+			return m, nil
+		}
+	}
+}
+
+// control handles protocol control messages, it reports whether m was one.
+func (c *Conn) control(m message) (bool, error) {
+	switch m.typeID {
+	case typeSetChunkSize:
+		if len(m.payload) < 4 {
+			return true, errors.New("invalid chunk size")
+		}
+		size := binary.BigEndian.Uint32(m.payload) & 0x7fffffff
+		if size == 0 || size > maxMessageSize {
+			return true, fmt.Errorf("invalid chunk size %d", size)
+		}
+		c.r.size = int(size)
+		return true, nil
+	case typeUserControl:
+		// answer ping requests, the server may drop the connection otherwise
+		if len(m.payload) >= 6 && binary.BigEndian.Uint16(m.payload) == 6 {
+			response := append([]byte{0, 7}, m.payload[2:6]...)
+			return true, c.write(csidControl, message{typeID: typeUserControl, payload: response})
+		}
+		return true, nil
+	case typeAbort, typeAcknowledgement, typeWindowAckSize, typeSetPeerBandwidth:
+		return true, nil
+	}
+	return false, nil
+}
+
+// status returns the name and info object of an onStatus or _error command.
+func status(m message) (string, map[string]any, bool) {
+	if m.typeID != typeCommandAMF0 {
+		return "", nil, false
+	}
+	values, err := amfDecode(m.payload)
+	if err != nil || len(values) < 3 {
+		return "", nil, false
+	}
+	name, _ := values[0].(string)
+	if name != "onStatus" && name != "_error" {
+		return "", nil, false
+	}
+	info, _ := values[len(values)-1].(map[string]any)
+	return name, info, true
+}
+
+// statusError returns the error described by an info object, nil if it is not an error.
+func statusError(name string, info map[string]any) error {
+	level, _ := info["level"].(string)
+	code, _ := info["code"].(string)
+	description, _ := info["description"].(string)
+	if name != "_error" && level != "error" {
+		return nil
+	}
+	if description != "" {
+		return fmt.Errorf("%w: %s: %s", ErrorRejected, code, description)
+	}
+	return fmt.Errorf("%w: %s", ErrorRejected, code)
+}
+
+// readLoop handles the messages of the server while publishing.
+func (c *Conn) readLoop() {
+	defer close(c.done)
+	for {
+		m, err := c.readMessage()
+		if err != nil {
+			c.fail(fmt.Errorf("connection to %s lost: %w", c.server, err))
+			return
+		}
+		if name, info, ok := status(m); ok {
+			if err := statusError(name, info); err != nil {
+				c.fail(fmt.Errorf("stream on %s stopped: %w", c.server, err))
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// fail records the first error of the connection.
+func (c *Conn) fail(err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// write sends a message, it is safe for concurrent use.
+func (c *Conn) write(csid uint8, m message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.w.write(csid, m)
+}
+
+// WriteAudio sends an FLV audio tag body with the given timestamp in milliseconds.
+func (c *Conn) WriteAudio(timestamp uint32, tag []byte) error {
+	err := c.write(csidAudio, message{typeID: typeAudio, streamID: c.streamID, timestamp: timestamp, payload: tag})
+	if err != nil {
+		c.errMu.Lock()
+		defer c.errMu.Unlock()
+		if c.err != nil {
+			return c.err
+		}
+		return fmt.Errorf("failed to send to %s: %w", c.server, err)
+	}
+	return nil
+}
+
+// Close unpublishes the stream and closes the connection, so the server ends the stream right away.
+func (c *Conn) Close() error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.command(0, "FCUnpublish", 0, nil, c.key)
+	_ = c.command(c.streamID, "deleteStream", 0, nil, float64(c.streamID))
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
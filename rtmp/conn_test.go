@@ -0,0 +1,272 @@
+package rtmp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a minimal RTMP server that accepts a single publisher.
+type testServer struct {
+	t        *testing.T
+	listener net.Listener
+	// rejectKey makes publishing with this stream key fail
+	rejectKey string
+
+	mu       sync.Mutex
+	app, key string
+	commands []string
+	audio    []message
+	done     chan struct{}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{t: t, listener: listener, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+	go s.accept()
+	return s
+}
+
+func (s *testServer) url(app, key string) string {
+	return "rtmp://" + s.listener.Addr().String() + "/" + app + "/" + key
+}
+
+func (s *testServer) accept() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err = s.serve(conn); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		s.t.Errorf("server: %v", err)
+	}
+}
+
+func (s *testServer) serve(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(r, c1); err != nil {
+		return err
+	}
+	if c1[0] != 3 {
+		return fmt.Errorf("client sent version %d", c1[0])
+	}
+	s1 := bytes.Repeat([]byte{0x5a}, handshakeSize)
+	if _, err := conn.Write(append(append([]byte{3}, s1...), c1[1:]...)); err != nil {
+		return err
+	}
+	c2 := make([]byte, handshakeSize)
+	if _, err := io.ReadFull(r, c2); err != nil {
+		return err
+	}
+	if !bytes.Equal(c2, s1) {
+		return errors.New("C2 does not echo S1")
+	}
+
+	cr := newChunkReader(r)
+	cw := &chunkWriter{w: bufio.NewWriter(conn), size: defaultChunkSize}
+	reply := func(streamID uint32, values ...any) error {
+		return cw.write(csidCommand, message{typeID: typeCommandAMF0, streamID: streamID, payload: amfEncode(values...)})
+	}
+	for {
+		m, err := cr.read()
+		if err != nil {
+			return err
+		}
+		switch m.typeID {
+		case typeSetChunkSize:
+			cr.size = int(binary.BigEndian.Uint32(m.payload))
+		case typeAudio:
+			s.mu.Lock()
+			s.audio = append(s.audio, m)
+			s.mu.Unlock()
+		case typeCommandAMF0:
+			values, err := amfDecode(m.payload)
+			if err != nil {
+				return err
+			}
+			name, _ := values[0].(string)
+			transaction, _ := values[1].(float64)
+			s.mu.Lock()
+			s.commands = append(s.commands, name)
+			s.mu.Unlock()
+
+			switch name {
+			case "connect":
+				info, _ := values[2].(map[string]any)
+				s.mu.Lock()
+				s.app, _ = info["app"].(string)
+				s.mu.Unlock()
+				err = reply(0, "_result", transaction, object{{"fmsVer", "FMS/3,0,1,123"}},
+					object{{"level", "status"}, {"code", "NetConnection.Connect.Success"}})
+			case "createStream":
+				err = reply(0, "_result", transaction, nil, 1)
+			case "publish":
+				key, _ := values[3].(string)
+				s.mu.Lock()
+				s.key = key
+				s.mu.Unlock()
+				if key == s.rejectKey {
+					err = reply(m.streamID, "onStatus", 0, nil, object{{"level", "error"},
+						{"code", "NetStream.Publish.BadName"}, {"description", "invalid stream key"}})
+				} else {
+					err = reply(m.streamID, "onStatus", 0, nil, object{{"level", "status"}, {"code", "NetStream.Publish.Start"}})
+				}
+			case "deleteStream":
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// wait returns once the client disconnected.
+func (s *testServer) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client did not disconnect")
+	}
+}
+
+func TestDialAndWriteAudio(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := Dial(ctx, s.url("live", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// larger than a chunk, so it is split with continuation chunks
+	tag := aacTag(aacRaw, bytes.Repeat([]byte{1, 2, 3}, 3000))
+	if err = conn.WriteAudio(0x1000000, tag); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.wait(t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.app != "live" || s.key != "secret" {
+		t.Errorf("published to app %q with key %q, want live and secret", s.app, s.key)
+	}
+	want := []string{"connect", "releaseStream", "FCPublish", "createStream", "publish", "FCUnpublish", "deleteStream"}
+	if fmt.Sprint(s.commands) != fmt.Sprint(want) {
+		t.Errorf("commands = %v, want %v", s.commands, want)
+	}
+	if len(s.audio) != 1 || !bytes.Equal(s.audio[0].payload, tag) || s.audio[0].timestamp != 0x1000000 || s.audio[0].streamID != 1 {
+		t.Errorf("the server did not receive the audio tag with its extended timestamp on stream 1")
+	}
+}
+
+func TestDialRejected(t *testing.T) {
+	s := newTestServer(t)
+	s.rejectKey = "wrong"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := Dial(ctx, s.url("live", "wrong"))
+	if !errors.Is(err, ErrorRejected) {
+		t.Fatalf("Dial() = %v, want ErrorRejected", err)
+	}
+	if bytes.Contains([]byte(err.Error()), []byte("wrong")) {
+		t.Errorf("the error %q contains the stream key", err)
+	}
+}
+
+func TestDialInvalidURL(t *testing.T) {
+	for _, url := range []string{"rtmp://localhost/onlyapp", "http://localhost/app/key", "rtmp://%zz"} {
+		if _, err := Dial(context.Background(), url); err == nil {
+			t.Errorf("Dial(%q) succeeded", url)
+		}
+	}
+}
+
+// tsPacket returns a TS packet with the payload, padded with an adaptation field.
+func tsPacket(pid uint16, start bool, counter byte, payload []byte) []byte {
+	p := []byte{tsSyncByte, byte(pid>>8) & 0x1f, byte(pid), 0x10 | counter&0x0f}
+	if start {
+		p[1] |= 0x40
+	}
+	if stuffing := tsPacketSize - 4 - len(payload); stuffing > 0 {
+		p[3] |= 0x20
+		p = append(p, byte(stuffing-1))
+		if stuffing > 1 {
+			p = append(p, 0)
+			p = append(p, bytes.Repeat([]byte{0xff}, stuffing-2)...)
+		}
+	}
+	return append(p, payload...)
+}
+
+// testTS returns MPEG-TS with a single ADTS stream of stereo 44.1 kHz AAC frames.
+func testTS(frames [][]byte) []byte {
+	pat := []byte{0, 0x00, 0xb0, 13, 0, 1, 0xc1, 0, 0, 0, 1, 0xf0, 0x00, 0, 0, 0, 0}
+	pmt := []byte{0, 0x02, 0xb0, 18, 0, 1, 0xc1, 0, 0, 0xe1, 0x00, 0xf0, 0x00, streamTypeADTS, 0xe1, 0x00, 0xf0, 0x00, 0, 0, 0, 0}
+	ts := append(tsPacket(0, true, 0, pat), tsPacket(0x1000, true, 0, pmt)...)
+	for i, frame := range frames {
+		size := 7 + len(frame)
+		adts := []byte{0xff, 0xf1, 0x50, 0x80 | byte(size>>11), byte(size >> 3), byte(size<<5) | 0x1f, 0xfc}
+		pes := append([]byte{0, 0, 1, 0xc0, 0, 0, 0x80, 0x80, 5, 0x21, 0, 1, 0, 1}, adts...)
+		ts = append(ts, tsPacket(0x100, true, byte(i), append(pes, frame...))...)
+	}
+	return ts
+}
+
+func TestPublish(t *testing.T) {
+	s := newTestServer(t)
+	var frames [][]byte
+	for i := 0; i < 10; i++ {
+		frames = append(frames, bytes.Repeat([]byte{byte(i + 1)}, 20))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	started := time.Now()
+	if err := Publish(ctx, s.url("live", "key"), bytes.NewReader(testTS(frames))); err != nil {
+		t.Fatal(err)
+	}
+	// ten frames of 1024 samples at 44.1 kHz are sent in real time
+	if elapsed := time.Since(started); elapsed < 150*time.Millisecond {
+		t.Errorf("published 200ms of audio in %s", elapsed)
+	}
+	s.wait(t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.audio) != 1+len(frames) {
+		t.Fatalf("the server received %d audio tags, want a sequence header and %d frames", len(s.audio), len(frames))
+	}
+	if header := s.audio[0].payload; !bytes.Equal(header, []byte{0xaf, aacSequenceHeader, 0x12, 0x10}) {
+		t.Errorf("sequence header = %x, want the config of stereo 44.1 kHz AAC LC", header)
+	}
+	for i, frame := range frames {
+		m := s.audio[i+1]
+		if !bytes.Equal(m.payload, aacTag(aacRaw, frame)) {
+			t.Errorf("frame %d = %x, want %x", i, m.payload, frame)
+		}
+		if want := uint32(i * 1024 * 1000 / 44100); m.timestamp != want {
+			t.Errorf("frame %d has timestamp %d, want %d", i, m.timestamp, want)
+		}
+	}
+}
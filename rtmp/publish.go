@@ -0,0 +1,121 @@
+// Package rtmp publishes the AAC audio of an MPEG-TS stream to an RTMP server without ffmpeg.
+//
+// It implements just enough of RTMP and FLV to push a live audio stream,
+// like the ones of Twitch, YouTube or nginx-rtmp.
+package rtmp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxLag is how far publishing may fall behind real time before the clock is reset,
+// e.g. after the input was paused, instead of sending the backlog as fast as possible.
+const maxLag = time.Second
+
+// FLV AAC packet types.
+const (
+	aacSequenceHeader = 0
+	aacRaw            = 1
+)
+
+// A Clock paces Publish, opendj passes the clock of the Dj with WithClock.
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel once d elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type clockKey struct{}
+
+// WithClock makes Publish pace the streams it is passed ctx for with c instead of the system clock.
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+// aacTag returns the body of an FLV audio tag, for AAC the sound format flags are always the same.
+func aacTag(packetType byte, data []byte) []byte {
+	return append([]byte{0xaf, packetType}, data...)
+}
+
+// Publish streams the AAC audio of stream, which has to be MPEG-TS, to the RTMP server at rawURL.
+// The audio is sent in real time, as told by the clock set with WithClock, until stream ends or ctx is cancelled.
+func Publish(ctx context.Context, rawURL string, stream io.Reader) error {
+	clock, ok := ctx.Value(clockKey{}).(Clock)
+	if !ok {
+		clock = systemClock{}
+	}
+
+	conn, err := Dial(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// a blocked write is interrupted by closing the connection
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.conn.Close()
+		case <-stopped:
+		}
+	}()
+
+	demuxer := newTSDemuxer(stream)
+	var (
+		config   [2]byte
+		position time.Duration
+		start    time.Time
+	)
+	for {
+		frame, err := demuxer.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		if start.IsZero() {
+			start = clock.Now()
+		}
+		if wait := start.Add(position).Sub(clock.Now()); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-clock.After(wait):
+			}
+		} else if -wait > maxLag {
+			start = clock.Now().Add(-position)
+		}
+
+		timestamp := uint32(position.Milliseconds())
+		if frame.config != config {
+			// the format may change between tracks
+			config = frame.config
+			if err = conn.WriteAudio(timestamp, aacTag(aacSequenceHeader, config[:])); err != nil {
+				return contextError(ctx, err)
+			}
+		}
+		if err = conn.WriteAudio(timestamp, aacTag(aacRaw, frame.data)); err != nil {
+			return contextError(ctx, err)
+		}
+		position += time.Duration(frame.samples) * time.Second / time.Duration(frame.sampleRate)
+	}
+}
+
+// contextError returns the error of ctx if it was cancelled, since that caused err.
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
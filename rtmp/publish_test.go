@@ -0,0 +1,138 @@
+package rtmp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// skippingClock fires every timer at once and moves forward by its duration.
+type skippingClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	skipped time.Duration
+}
+
+func (c *skippingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *skippingClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.skipped += d
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestPublishWithClock(t *testing.T) {
+	s := newTestServer(t)
+	var frames [][]byte
+	for i := 0; i < 50; i++ {
+		frames = append(frames, bytes.Repeat([]byte{byte(i + 1)}, 20))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := &skippingClock{now: time.Date(2024, time.May, 1, 20, 0, 0, 0, time.UTC)}
+	started := time.Now()
+	if err := Publish(WithClock(ctx, clock), s.url("live", "key"), bytes.NewReader(testTS(frames))); err != nil {
+		t.Fatal(err)
+	}
+	s.wait(t)
+
+	// the last frame is sent after 49 frames of 1024 samples at 44.1 kHz
+	want := 49 * (1024 * time.Second / 44100)
+	if clock.skipped != want {
+		t.Errorf("waited %v for the clock, want %v", clock.skipped, want)
+	}
+	if elapsed := time.Since(started); elapsed > want {
+		t.Errorf("publishing took %v of real time, the clock was ignored", elapsed)
+	}
+}
+
+// ffmpegRTMP returns the path of ffmpeg, it skips the test if ffmpeg is not installed or lacks RTMP support.
+func ffmpegRTMP(t *testing.T) string {
+	t.Helper()
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	protocols, err := exec.Command(path, "-hide_banner", "-protocols").Output()
+	if err != nil || !strings.Contains(string(protocols), "rtmp") {
+		t.Skip("ffmpeg does not support RTMP")
+	}
+	return path
+}
+
+// freePort returns a local address that was free a moment ago.
+func freePort(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// TestPublishToFFmpeg publishes to ffmpeg acting as RTMP server, which remuxes the audio into ADTS.
+func TestPublishToFFmpeg(t *testing.T) {
+	ffmpeg := ffmpegRTMP(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	input, err := exec.CommandContext(ctx, ffmpeg, "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-c:a", "aac", "-ar", "44100", "-ac", "2", "-f", "mpegts", "pipe:1").Output()
+	if err != nil {
+		t.Skipf("ffmpeg can't encode the test input: %v", err)
+	}
+
+	url := "rtmp://" + freePort(t) + "/live/key"
+	out := filepath.Join(t.TempDir(), "out.aac")
+	server := exec.CommandContext(ctx, ffmpeg, "-hide_banner", "-loglevel", "error",
+		"-listen", "1", "-i", url, "-c", "copy", "-f", "adts", out)
+	var stderr bytes.Buffer
+	server.Stderr = &stderr
+	if err = server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// ffmpeg needs a moment until it listens
+	for {
+		err = Publish(ctx, url, bytes.NewReader(input))
+		if !errors.Is(err, syscall.ECONNREFUSED) || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if err = server.Wait(); err != nil {
+		t.Fatalf("ffmpeg failed: %v\n%s", err, stderr.String())
+	}
+
+	adts, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// about 43 frames of 1024 samples, each starting with the ADTS sync word
+	if frames := bytes.Count(adts, []byte{0xff, 0xf1}); len(adts) == 0 || adts[0] != 0xff || frames < 40 {
+		t.Errorf("ffmpeg received %d bytes with %d ADTS frames, want about 43 frames", len(adts), frames)
+	}
+}
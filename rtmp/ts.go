@@ -0,0 +1,222 @@
+package rtmp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+	// streamTypeADTS is the MPEG-TS stream type of AAC audio with ADTS headers.
+	streamTypeADTS = 0x0F
+	// maxADTSBuffer limits the audio buffered while no complete frame was found.
+	maxADTSBuffer = 1 << 16
+)
+
+// sampleRates are the sampling frequencies by ADTS frequency index.
+var sampleRates = [...]int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// errNotTS is returned when the stream does not look like MPEG-TS at all.
+var errNotTS = errors.New("stream is not MPEG-TS")
+
+// An aacFrame is a single AAC frame without its ADTS header.
+type aacFrame struct {
+	// config is the AudioSpecificConfig of the frame
+	config     [2]byte
+	data       []byte
+	samples    int
+	sampleRate int
+}
+
+// tsDemuxer extracts the AAC frames of the first ADTS stream from MPEG-TS.
+//
+// The stream may be several MPEG-TS streams concatenated, like the output of one encoder per track,
+// the tables are followed whenever they change.
+type tsDemuxer struct {
+	r        *bufio.Reader
+	packet   [tsPacketSize]byte
+	pmtPIDs  map[uint16]bool
+	audioPID int
+	adts     []byte
+}
+
+func newTSDemuxer(r io.Reader) *tsDemuxer {
+	return &tsDemuxer{r: bufio.NewReader(r), pmtPIDs: make(map[uint16]bool), audioPID: -1}
+}
+
+// next returns the next AAC frame.
+func (d *tsDemuxer) next() (aacFrame, error) {
+	for {
+		if frame, ok := d.frame(); ok {
+			return frame, nil
+		}
+		if err := d.readPacket(); err != nil {
+			return aacFrame{}, err
+		}
+	}
+}
+
+// readPacket reads the next packet and handles its payload.
+func (d *tsDemuxer) readPacket() error {
+	// resynchronize if the stream is not aligned, e.g. after packets were dropped
+	skipped := 0
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == tsSyncByte {
+			break
+		}
+		if skipped++; skipped > 10*tsPacketSize {
+			return errNotTS
+		}
+	}
+	d.packet[0] = tsSyncByte
+	if _, err := io.ReadFull(d.r, d.packet[1:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+
+	p := d.packet[:]
+	start := p[1]&0x40 != 0
+	pid := uint16(p[1]&0x1f)<<8 | uint16(p[2])
+	adaptation := (p[3] >> 4) & 0x3
+	payload := p[4:]
+	if adaptation&0x2 != 0 {
+		if int(payload[0]) >= len(payload) {
+			return nil
+		}
+		payload = payload[1+int(payload[0]):]
+	}
+	if adaptation&0x1 == 0 {
+		return nil
+	}
+
+	switch {
+	case pid == 0:
+		d.parsePAT(section(payload, start))
+	case d.pmtPIDs[pid]:
+		d.parsePMT(section(payload, start))
+	case int(pid) == d.audioPID:
+		if start {
+			payload = pesPayload(payload)
+		}
+		if len(d.adts)+len(payload) > maxADTSBuffer {
+			d.adts = d.adts[:0]
+		}
+		d.adts = append(d.adts, payload...)
+	}
+	return nil
+}
+
+// section returns the table section in the payload of a packet, nil if it does not start one.
+// Tables are assumed to fit into a single packet, which is true for the PAT and PMT of a single program.
+func section(payload []byte, start bool) []byte {
+	if !start || len(payload) == 0 || 1+int(payload[0]) >= len(payload) {
+		return nil
+	}
+	s := payload[1+int(payload[0]):]
+	if len(s) < 3 {
+		return nil
+	}
+	length := int(s[1]&0x0f)<<8 | int(s[2])
+	if 3+length > len(s) || length < 9 {
+		return nil
+	}
+	// without the CRC
+	return s[:3+length-4]
+}
+
+func (d *tsDemuxer) parsePAT(s []byte) {
+	if len(s) < 8 || s[0] != 0x00 {
+		return
+	}
+	pids := make(map[uint16]bool)
+	for i := 8; i+4 <= len(s); i += 4 {
+		program := uint16(s[i])<<8 | uint16(s[i+1])
+		if program != 0 {
+			pids[uint16(s[i+2]&0x1f)<<8|uint16(s[i+3])] = true
+		}
+	}
+	d.pmtPIDs = pids
+}
+
+func (d *tsDemuxer) parsePMT(s []byte) {
+	if len(s) < 12 || s[0] != 0x02 {
+		return
+	}
+	i := 12 + (int(s[10]&0x0f)<<8 | int(s[11]))
+	for i+5 <= len(s) {
+		streamType := s[i]
+		pid := int(s[i+1]&0x1f)<<8 | int(s[i+2])
+		if streamType == streamTypeADTS {
+			if pid != d.audioPID {
+				d.audioPID = pid
+				d.adts = d.adts[:0]
+			}
+			return
+		}
+		i += 5 + (int(s[i+3]&0x0f)<<8 | int(s[i+4]))
+	}
+}
+
+// pesPayload returns the data of a packet that starts a PES packet.
+func pesPayload(p []byte) []byte {
+	if len(p) < 9 || p[0] != 0 || p[1] != 0 || p[2] != 1 {
+		return nil
+	}
+	start := 9 + int(p[8])
+	if start > len(p) {
+		return nil
+	}
+	return p[start:]
+}
+
+// frame removes the first complete ADTS frame from the buffer.
+func (d *tsDemuxer) frame() (aacFrame, bool) {
+	for {
+		// find the sync word
+		i := 0
+		for i+1 < len(d.adts) && !(d.adts[i] == 0xff && d.adts[i+1]&0xf6 == 0xf0) {
+			i++
+		}
+		d.adts = d.adts[i:]
+		if len(d.adts) < 7 {
+			return aacFrame{}, false
+		}
+
+		h := d.adts
+		headerSize := 7
+		if h[1]&0x1 == 0 {
+			headerSize = 9
+		}
+		profile := h[2] >> 6
+		frequency := (h[2] >> 2) & 0xf
+		channels := (h[2]&0x1)<<2 | h[3]>>6
+		size := int(h[3]&0x3)<<11 | int(h[4])<<3 | int(h[5])>>5
+		blocks := int(h[6]&0x3) + 1
+		if int(frequency) >= len(sampleRates) || size <= headerSize {
+			// not a real header, continue searching after it
+			d.adts = d.adts[1:]
+			continue
+		}
+		if len(d.adts) < size {
+			return aacFrame{}, false
+		}
+
+		objectType := profile + 1
+		frame := aacFrame{
+			config:     [2]byte{objectType<<3 | frequency>>1, frequency<<7 | channels<<3},
+			data:       append([]byte(nil), d.adts[headerSize:size]...),
+			samples:    1024 * blocks,
+			sampleRate: sampleRates[frequency],
+		}
+		d.adts = d.adts[:copy(d.adts, d.adts[size:])]
+		return frame, true
+	}
+}
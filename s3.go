@@ -0,0 +1,296 @@
+package opendj
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload is used as payload hash, S3 does not require GET requests to sign their (empty) body.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Source streams objects from S3 or S3 compatible object storage like MinIO, R2 or B2.
+//
+// Media URLs have the form s3://<bucket>/<key>, register the source with
+// TrackSourceRegistry.RegisterScheme("s3", source). Requests are signed with AWS Signature Version 4,
+// anonymous requests are sent if no access key is set.
+//
+// It is also a Resolver, register it with a ResolverRegistry for s3 URLs to probe objects
+// for their title and duration.
+type S3Source struct {
+	// Endpoint is the URL of the storage, it defaults to https://s3.<region>.amazonaws.com
+	Endpoint string
+	// Region defaults to us-east-1.
+	Region string
+	// PathStyle puts the bucket into the path instead of the host name, most self-hosted storage requires it.
+	PathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is needed for temporary credentials.
+	SessionToken string
+
+	Client *http.Client
+
+	// now returns the signing time, it is replaced in tests
+	now func() time.Time
+}
+
+// Open reads the object of the media URL.
+func (s S3Source) Open(ctx context.Context, media Media) (io.ReadCloser, error) {
+	bucket, key, err := parseS3URL(media.URL)
+	if err != nil {
+		return nil, err
+	}
+	objectURL, err := s.objectURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %w", media.URL, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", media.URL, ErrorUnavailable)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to request %s: %s", media.URL, resp.Status)
+	}
+}
+
+// Resolve probes the object at the s3 URL with ffprobe through a presigned URL.
+func (s S3Source) Resolve(ctx context.Context, rawURL string) (Media, error) {
+	signed, err := s.Presign(rawURL, 15*time.Minute)
+	if err != nil {
+		return Media{}, err
+	}
+	media, err := ProbeResolver{}.Resolve(ctx, signed)
+	if err != nil {
+		return Media{}, fmt.Errorf("failed to probe %s: %w", rawURL, err)
+	}
+	if strings.Contains(media.Title, "X-Amz-") {
+		// the title fell back to the file name, which includes the signature
+		_, key, _ := parseS3URL(rawURL)
+		media.Title = strings.TrimSuffix(path.Base(key), path.Ext(key))
+	}
+	media.URL = rawURL
+	return media, nil
+}
+
+// Presign returns an https URL for the object at the s3 URL that can be read without credentials
+// until it expires, e.g. to pass it to other programs. Expiry can be at most 7 days.
+func (s S3Source) Presign(rawURL string, expires time.Duration) (string, error) {
+	bucket, key, err := parseS3URL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	objectURL, err := s.objectURL(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	if s.AccessKeyID == "" {
+		return objectURL.String(), nil
+	}
+
+	now := s.time()
+	query := objectURL.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if s.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	objectURL.RawQuery = canonicalQuery(query)
+
+	headers := map[string]string{"host": objectURL.Host}
+	signature := s.signature(now, http.MethodGet, objectURL, headers, unsignedPayload)
+	objectURL.RawQuery += "&X-Amz-Signature=" + signature
+	return objectURL.String(), nil
+}
+
+// sign adds the authorization headers to req.
+func (s S3Source) sign(req *http.Request) {
+	if s.AccessKeyID == "" {
+		return
+	}
+	now := s.time()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": unsignedPayload,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		headers["x-amz-security-token"] = s.SessionToken
+	}
+
+	signature := s.signature(now, req.Method, req.URL, headers, unsignedPayload)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, s.scope(now), signedHeaders(headers), signature,
+	))
+}
+
+// signature calculates the Signature Version 4 of a request.
+func (s S3Source) signature(now time.Time, method string, u *url.URL, headers map[string]string, payloadHash string) string {
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), now.Format("20060102"))
+	for _, part := range []string{s.region(), "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, s.stringToSign(now, canonicalRequest(method, u, headers, payloadHash))))
+}
+
+// canonicalRequest returns the request in the form its hash is signed in.
+func canonicalRequest(method string, u *url.URL, headers map[string]string, payloadHash string) string {
+	names := strings.Split(signedHeaders(headers), ";")
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	return strings.Join([]string{
+		method,
+		uriEncode(u.Path, false),
+		canonicalQuery(u.Query()),
+		canonicalHeaders.String(),
+		strings.Join(names, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// stringToSign returns the string the signature is calculated for.
+func (s S3Source) stringToSign(now time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		s.scope(now),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+func (s S3Source) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region() + "/s3/aws4_request"
+}
+
+func (s S3Source) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+func (s S3Source) time() time.Time {
+	if s.now != nil {
+		return s.now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// objectURL returns the https URL of the object.
+func (s S3Source) objectURL(bucket, key string) (*url.URL, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.region() + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.PathStyle {
+		u.Path = base + "/" + bucket + "/" + key
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	return u, nil
+}
+
+// parseS3URL splits an s3://<bucket>/<key> URL.
+func parseS3URL(rawURL string) (bucket, key string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", "", errors.New(rawURL + " is not an s3://<bucket>/<key> URL")
+	}
+	return u.Host, key, nil
+}
+
+// signedHeaders returns the sorted header names separated by semicolons.
+func signedHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ";")
+}
+
+// canonicalQuery encodes the query sorted by name as required for signing.
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, slashes are kept unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
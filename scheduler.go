@@ -0,0 +1,94 @@
+package opendj
+
+import (
+	"sync"
+	"time"
+)
+
+// A Scheduler decides which entry of the queue gets played next.
+type Scheduler interface {
+	// Next returns the index of the entry in candidates that should be played next.
+	//
+	// candidates are all entries that may be played right now in queue order, it is never empty.
+	// Next is also called to announce the entry that is up next or to estimate wait times,
+	// so it must not change the state of the scheduler, see Played.
+	Next(candidates []QueueEntry) int
+	// Played is called when entry is taken from the queue to be played at the given time.
+	Played(entry QueueEntry, at time.Time)
+}
+
+// SetScheduler sets the Scheduler used to pick the next entry.
+//
+// The default plays entries in queue order, passing nil restores it.
+func (dj *Dj) SetScheduler(s Scheduler) {
+	dj.updateConfig(func(c *Config) { c.Scheduler = s })
+}
+
+// FIFOScheduler plays entries in queue order.
+type FIFOScheduler struct{}
+
+// Next always returns the first candidate.
+func (FIFOScheduler) Next([]QueueEntry) int {
+	return 0
+}
+
+// Played does nothing.
+func (FIFOScheduler) Played(QueueEntry, time.Time) {}
+
+// FairScheduler alternates between owners, the owner that waited longest since
+// their last song gets to play next.
+// Ties are broken by the time the entries were requested.
+type FairScheduler struct {
+	mu         sync.Mutex
+	lastPlayed map[string]time.Time
+}
+
+// Next returns the oldest entry of the owner that played least recently.
+func (s *FairScheduler) Next(candidates []QueueEntry) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := 0
+	for i, entry := range candidates[1:] {
+		i++
+		last, bestLast := s.lastPlayed[entry.Owner], s.lastPlayed[candidates[best].Owner]
+		if last.Before(bestLast) || (last.Equal(bestLast) && entry.RequestedAt.Before(candidates[best].RequestedAt)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Played records when the owner of entry played last.
+func (s *FairScheduler) Played(entry QueueEntry, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastPlayed == nil {
+		s.lastPlayed = make(map[string]time.Time)
+	}
+	s.lastPlayed[entry.Owner] = at
+}
+
+// WeightedScheduler plays the entry with the highest weight first,
+// e.g. based on the owner's subscription tier or channel points spent on the request.
+// Ties are broken by the time the entries were requested.
+type WeightedScheduler struct {
+	// Weight returns the weight of the entry, it must not be nil.
+	Weight func(QueueEntry) float64
+}
+
+// Next returns the entry with the highest weight.
+func (s WeightedScheduler) Next(candidates []QueueEntry) int {
+	best, bestWeight := 0, s.Weight(candidates[0])
+	for i, entry := range candidates[1:] {
+		i++
+		weight := s.Weight(entry)
+		if weight > bestWeight || (weight == bestWeight && entry.RequestedAt.Before(candidates[best].RequestedAt)) {
+			best, bestWeight = i, weight
+		}
+	}
+	return best
+}
+
+// Played does nothing.
+func (WeightedScheduler) Played(QueueEntry, time.Time) {}
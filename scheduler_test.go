@@ -0,0 +1,58 @@
+package opendj
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFairSchedulerNextIsPure(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	candidates := []QueueEntry{
+		{Owner: "bob", RequestedAt: start},
+		{Owner: "bob", RequestedAt: start.Add(time.Minute)},
+		{Owner: "eve", RequestedAt: start.Add(2 * time.Minute)},
+	}
+
+	var s FairScheduler
+	for i := 0; i < 3; i++ {
+		if next := s.Next(candidates); next != 0 {
+			t.Fatalf("Next() = %d on call %d, want 0", next, i+1)
+		}
+	}
+	s.Played(candidates[0], start)
+	if next := s.Next(candidates[1:]); next != 1 {
+		t.Errorf("Next() after bob played = %d, want the entry of eve", next)
+	}
+}
+
+func TestPeekDoesNotAffectFairness(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	dj := newTestDj(t, []QueueEntry{
+		{Media: Media{Title: "bob 1"}, Owner: "bob", RequestedAt: start},
+		{Media: Media{Title: "bob 2"}, Owner: "bob", RequestedAt: start.Add(time.Minute)},
+		{Media: Media{Title: "eve 1"}, Owner: "eve", RequestedAt: start.Add(2 * time.Minute)},
+	})
+	dj.SetScheduler(&FairScheduler{})
+
+	var played []string
+	for range dj.Queue() {
+		// up next displays and wait time estimates peek repeatedly
+		for i := 0; i < 3; i++ {
+			if _, err := dj.peek(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		entry, err := dj.pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		played = append(played, entry.Media.Title)
+	}
+
+	want := []string{"bob 1", "eve 1", "bob 2"}
+	for i := range want {
+		if played[i] != want[i] {
+			t.Fatalf("played %v, want %v", played, want)
+		}
+	}
+}
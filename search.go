@@ -0,0 +1,60 @@
+package opendj
+
+import "strings"
+
+// HasTag reports whether the entry has the given tag, tags are compared case-insensitively.
+func (e QueueEntry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the title, owner or a tag of the entry contains query, ignoring case.
+func (e QueueEntry) matches(query string) bool {
+	query = strings.ToLower(query)
+	if strings.Contains(strings.ToLower(e.Media.Title), query) || strings.Contains(strings.ToLower(e.Owner), query) {
+		return true
+	}
+	for _, tag := range e.Tags {
+		if strings.Contains(strings.ToLower(tag), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindInQueue returns all entries of all queues the predicate returns true for, in the order they are played.
+//
+// The entries can be removed with RemoveByID.
+func (dj *Dj) FindInQueue(predicate func(QueueEntry) bool) []QueueEntry {
+	dj.waitingQueue.Lock()
+	defer dj.waitingQueue.Unlock()
+
+	var found []QueueEntry
+	for _, q := range dj.drainOrder() {
+		for _, entry := range q.entries() {
+			if predicate(entry) {
+				found = append(found, entry)
+			}
+		}
+	}
+	return found
+}
+
+// SearchHistory returns the entries played in the current session whose title,
+// owner or tags contain query, ignoring case. Oldest first.
+func (dj *Dj) SearchHistory(query string) []HistoryEntry {
+	dj.history.Lock()
+	defer dj.history.Unlock()
+
+	var found []HistoryEntry
+	for _, played := range dj.history.entries {
+		if played.Entry.matches(query) {
+			found = append(found, played)
+		}
+	}
+	return found
+}
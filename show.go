@@ -0,0 +1,108 @@
+package opendj
+
+import (
+	"sync"
+	"time"
+)
+
+// The show events are published when a scheduled show starts or ends, Event.Show is the show.
+// When one show follows another, the end of the first is published before the start of the second.
+const (
+	EventShowStarted EventType = "showStarted"
+	EventShowEnded   EventType = "showEnded"
+)
+
+// A Show is a program on the channel, like a weekly mix show with its host.
+type Show struct {
+	Name string `json:"name"`
+	Host string `json:"host,omitempty"`
+	// Artwork is the URL of an image for the show.
+	Artwork string `json:"artwork,omitempty"`
+}
+
+// A ScheduledShow is a show that is on from Start until End.
+type ScheduledShow struct {
+	Show
+	Start time.Time
+	End   time.Time
+}
+
+// showSchedule holds the scheduled shows and the show that is on.
+type showSchedule struct {
+	sync.Mutex
+	shows   []ScheduledShow
+	current *ScheduledShow
+	// timer fires at the next start or end of a show
+	timer *time.Timer
+}
+
+// SetShowSchedule sets the shows of the channel. The show that is on is included in CurrentlyPlaying
+// and the show events are published when shows start and end. If shows overlap, the one that
+// started last is on. Passing no shows ends the current show.
+//
+// FollowCalendar replaces the schedule with the shows of its calendar.
+func (dj *Dj) SetShowSchedule(shows []ScheduledShow) {
+	dj.shows.Lock()
+	defer dj.shows.Unlock()
+
+	dj.shows.shows = append([]ScheduledShow(nil), shows...)
+	dj.updateShow()
+}
+
+// CurrentShow returns the show that is on, ok is false if there is none.
+func (dj *Dj) CurrentShow() (show Show, ok bool) {
+	dj.shows.Lock()
+	defer dj.shows.Unlock()
+
+	if dj.shows.current == nil {
+		return Show{}, false
+	}
+	return dj.shows.current.Show, true
+}
+
+// updateShow publishes the show events if the show that is on changed
+// and waits for the next change, the lock has to be held.
+func (dj *Dj) updateShow() {
+	now := dj.now()
+	var active *ScheduledShow
+	for i, show := range dj.shows.shows {
+		if !show.Start.After(now) && show.End.After(now) && (active == nil || !show.Start.Before(active.Start)) {
+			active = &dj.shows.shows[i]
+		}
+	}
+
+	current := dj.shows.current
+	if current == nil || active == nil || current.Show != active.Show || !current.Start.Equal(active.Start) {
+		if current != nil {
+			dj.publish(Event{Type: EventShowEnded, Show: current.Show})
+		}
+		if active != nil {
+			dj.publish(Event{Type: EventShowStarted, Show: active.Show})
+		}
+	}
+	if active != nil {
+		copied := *active
+		active = &copied
+	}
+	dj.shows.current = active
+
+	if dj.shows.timer != nil {
+		dj.shows.timer.Stop()
+		dj.shows.timer = nil
+	}
+	var next time.Duration
+	for _, show := range dj.shows.shows {
+		for _, t := range []time.Time{show.Start, show.End} {
+			if d := t.Sub(now); d > 0 && (next == 0 || d < next) {
+				next = d
+			}
+		}
+	}
+	if next > 0 {
+		dj.shows.timer = time.AfterFunc(next, func() {
+			dj.shows.Lock()
+			defer dj.shows.Unlock()
+			dj.updateShow()
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package opendj
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fallback struct {
+	sync.Mutex
+	entries    []QueueEntry
+	separation int
+	upcoming   []QueueEntry
+	recent     []QueueEntry
+	// library is played instead of the entries if it is set, see SetFallbackLibrary
+	library *Library
+	query   string
+	// rotation picks library tracks by category, position is the next category of its pattern
+	rotation *Rotation
+	position int
+}
+
+// SetFallback sets a playlist that is played in shuffled order whenever the queue is empty.
+//
+// The same artist or title is not repeated within separation tracks where possible.
+// Passing no entries disables the fallback playlist.
+func (dj *Dj) SetFallback(entries []QueueEntry, separation int) {
+	dj.fallback.Lock()
+	defer dj.fallback.Unlock()
+
+	dj.fallback.entries = append([]QueueEntry(nil), entries...)
+	// negative separations would slice past the recent entries
+	if separation < 0 {
+		separation = 0
+	}
+	dj.fallback.separation = separation
+	dj.fallback.upcoming = nil
+}
+
+// next returns the next entry of the fallback library or playlist, reshuffling the playlist when it ran out.
+func (f *fallback) next(now time.Time) (QueueEntry, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	var entry QueueEntry
+	if f.library != nil && f.rotation != nil {
+		track, position, ok := f.library.pickRotation(f.rotation, f.position, f.query, now)
+		if !ok {
+			return QueueEntry{}, false
+		}
+		f.position = position
+		entry = track.entry()
+	} else if f.library != nil {
+		track, ok := f.library.pick(f.query, f.recent, now)
+		if !ok {
+			return QueueEntry{}, false
+		}
+		entry = track.entry()
+	} else {
+		if len(f.entries) == 0 {
+			return QueueEntry{}, false
+		}
+		if len(f.upcoming) == 0 {
+			f.upcoming = shuffle(f.entries, f.separation, f.recent)
+		}
+		entry = f.upcoming[0]
+		f.upcoming = f.upcoming[1:]
+	}
+	f.recent = append(f.recent, entry)
+	if len(f.recent) > f.separation {
+		f.recent = f.recent[len(f.recent)-f.separation:]
+	}
+	return entry, true
+}
+
+// Shuffle returns a shuffled copy of entries.
+//
+// The same artist or title is not repeated within separation tracks where possible,
+// artist and title are parsed with ParseTitle.
+func Shuffle(entries []QueueEntry, separation int) []QueueEntry {
+	return shuffle(entries, separation, nil)
+}
+
+// shuffle is like Shuffle, but also keeps the separation to the recently played entries.
+func shuffle(entries []QueueEntry, separation int, recent []QueueEntry) []QueueEntry {
+	remaining := append([]QueueEntry(nil), entries...)
+	rand.Shuffle(len(remaining), func(i, j int) {
+		remaining[i], remaining[j] = remaining[j], remaining[i]
+	})
+	if separation <= 0 {
+		return remaining
+	}
+
+	played := append([]QueueEntry(nil), recent...)
+	result := make([]QueueEntry, 0, len(remaining))
+	for len(remaining) > 0 {
+		window := played
+		if len(window) > separation {
+			window = window[len(window)-separation:]
+		}
+
+		// pick the first entry that is not in the window, or the one that was played longest ago
+		pick, pickDistance := 0, -1
+		for i, entry := range remaining {
+			distance := separationDistance(entry, window)
+			if distance < 0 {
+				pick = i
+				break
+			} else if distance > pickDistance {
+				pick, pickDistance = i, distance
+			}
+		}
+
+		result = append(result, remaining[pick])
+		played = append(played, remaining[pick])
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+	}
+	return result
+}
+
+// separationDistance returns how many entries ago an entry with the same artist or title
+// was played in window, or -1 if there is none.
+func separationDistance(entry QueueEntry, window []QueueEntry) int {
+	artist, title := artistKey(entry.Media.Title)
+	for i := len(window) - 1; i >= 0; i-- {
+		otherArtist, otherTitle := artistKey(window[i].Media.Title)
+		if title == otherTitle || (artist != "" && artist == otherArtist) {
+			return len(window) - 1 - i
+		}
+	}
+	return -1
+}
+
+// artistKey returns the normalized artist and track parsed from a title.
+func artistKey(title string) (artist, track string) {
+	artist, track = ParseTitle(title)
+	return strings.ToLower(artist), strings.ToLower(track)
+}
@@ -0,0 +1,23 @@
+package opendj
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFallbackNegativeSeparation(t *testing.T) {
+	dj := newTestDj(t, nil)
+	dj.SetFallback([]QueueEntry{
+		{Media: Media{Title: "A - One", URL: "https://example.com/1"}},
+		{Media: Media{Title: "B - Two", URL: "https://example.com/2"}},
+	}, -3)
+
+	for i := 0; i < 5; i++ {
+		if _, ok := dj.fallback.next(time.Now()); !ok {
+			t.Fatalf("pick %d: no fallback entry", i)
+		}
+	}
+	if len(dj.fallback.recent) != 0 {
+		t.Errorf("%d recent entries are kept without separation", len(dj.fallback.recent))
+	}
+}
@@ -0,0 +1,130 @@
+package opendj
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// encoderStop ends the encoder of a running playback without cancelling the outputs,
+// so they read the end of the stream and close it properly.
+type encoderStop struct {
+	sync.Mutex
+	afterCurrent bool
+	cancel       context.CancelFunc
+}
+
+// start registers cancel as the function that stops the encoder and returns the context to encode with.
+func (s *encoderStop) start(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.Lock()
+	s.afterCurrent, s.cancel = false, cancel
+	s.Unlock()
+	return ctx, func() {
+		s.Lock()
+		s.cancel = nil
+		s.Unlock()
+		cancel()
+	}
+}
+
+func (s *encoderStop) stop() {
+	s.Lock()
+	defer s.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// stopping returns whether playback should end instead of starting the next entry.
+func (s *encoderStop) stopping() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.afterCurrent
+}
+
+// StopAfterCurrent ends playback once the current entry finished, right away if nothing is playing.
+//
+// The outputs receive the end of the stream instead of being killed, files are written completely
+// and RTMP streams are unpublished, then PlayTo returns.
+func (dj *Dj) StopAfterCurrent() {
+	dj.stopper.Lock()
+	dj.stopper.afterCurrent = true
+	dj.stopper.Unlock()
+
+	dj.skipper.Lock()
+	idle := dj.skipper.cancel == nil
+	dj.skipper.Unlock()
+	if idle {
+		dj.stopper.stop()
+	}
+}
+
+// StopAfterCurrent ends the playback once the current entry finished, see Dj.StopAfterCurrent.
+func (p *Playback) StopAfterCurrent() {
+	p.dj.StopAfterCurrent()
+}
+
+// Shutdown stops the playback right away, but lets the outputs finish writing what they already
+// received and end their streams properly, unlike Stop.
+//
+// If the outputs did not finish when ctx is done they are stopped like with Stop.
+// Shutdown returns the error playback ended with.
+func (p *Playback) Shutdown(ctx context.Context) error {
+	p.dj.stopper.stop()
+	select {
+	case <-p.finished:
+	case <-ctx.Done():
+		p.cancel()
+	}
+	return p.Wait()
+}
+
+// StopOnSignal ends the playback when the process receives one of the signals,
+// SIGTERM and interrupt if none are given, e.g. when systemd stops the service.
+//
+// With afterCurrent the current entry is played to the end first, otherwise the playback
+// is shut down right away with Shutdown. A second signal stops the playback immediately.
+// The returned function stops listening for the signals.
+func (p *Playback) StopOnSignal(afterCurrent bool, signals ...os.Signal) (release func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+
+	released := make(chan struct{})
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(released)
+		})
+	}
+
+	go func() {
+		defer release()
+		select {
+		case <-received:
+		case <-p.finished:
+			return
+		case <-released:
+			return
+		}
+		if afterCurrent {
+			p.StopAfterCurrent()
+		} else {
+			p.dj.stopper.stop()
+		}
+
+		select {
+		case <-received:
+			p.cancel()
+		case <-p.finished:
+		case <-released:
+		}
+	}()
+	return release
+}
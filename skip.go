@@ -0,0 +1,125 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrorSkipped is passed to the end of song handler if the song was skipped.
+var ErrorSkipped = errors.New("song was skipped")
+
+// ErrorNothingPlaying is returned when an action needs a song to be playing.
+var ErrorNothingPlaying = errors.New("there is no song being played")
+
+type skipper struct {
+	sync.Mutex
+	cancel context.CancelFunc
+	// id is the ID of the entry that is playing, it is zero during breaks
+	id uint64
+	// fade starts fading out the current track, it returns false if that is not possible
+	fade func() bool
+	// seek restarts the current track at the given position, it is nil if that is not possible
+	seek func(position time.Duration)
+}
+
+// Skip stops the song that is currently playing and continues with the next one.
+//
+// All processes involved in playing the song are killed,
+// after fading it out if that is enabled with SetSkipFade.
+// returns ErrorNothingPlaying if no song is playing.
+func (dj *Dj) Skip() error {
+	return dj.As(directActor).Skip().Err
+}
+
+// skip is Skip without the audit log.
+func (dj *Dj) skip() error {
+	dj.skipper.Lock()
+	defer dj.skipper.Unlock()
+
+	if dj.skipper.cancel == nil {
+		return ErrorNothingPlaying
+	}
+	dj.skipper.skip()
+	return nil
+}
+
+// skipIfID is skip, but only if the entry with the given ID is still playing.
+//
+// returns ErrorUnknownEntry if a different entry is playing.
+func (dj *Dj) skipIfID(id uint64) error {
+	dj.skipper.Lock()
+	defer dj.skipper.Unlock()
+
+	if dj.skipper.cancel == nil {
+		return ErrorNothingPlaying
+	} else if dj.skipper.id != id {
+		return ErrorUnknownEntry
+	}
+	dj.skipper.skip()
+	return nil
+}
+
+// skip fades out or cancels the current track, the lock has to be held.
+func (s *skipper) skip() {
+	if s.fade != nil && s.fade() {
+		return
+	}
+	s.cancel()
+}
+
+// setFade sets the function that fades out the current track, nil cuts tracks right away.
+func (s *skipper) setFade(fade func() bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.fade = fade
+}
+
+// track returns a context for playing the entry with the given ID that is cancelled by Skip,
+// id is zero for breaks.
+//
+// skipped reports whether the track was skipped, done has to be called once the track ended.
+func (s *skipper) track(ctx context.Context, id uint64) (trackCtx context.Context, skipped func() bool, done func()) {
+	trackCtx, cancel := context.WithCancel(ctx)
+	s.Lock()
+	s.cancel, s.id = cancel, id
+	s.Unlock()
+
+	skipped = func() bool {
+		return trackCtx.Err() != nil && ctx.Err() == nil
+	}
+	done = func() {
+		s.Lock()
+		s.cancel, s.fade, s.seek, s.id = nil, nil, nil, 0
+		s.Unlock()
+		cancel()
+	}
+	return trackCtx, skipped, done
+}
+
+// seekable returns a context for playing the main part of a track that is cancelled by Seek.
+//
+// seeked returns the position to continue at if the track was seeked,
+// it has to be called once the track ended.
+func (s *skipper) seekable(ctx context.Context) (trackCtx context.Context, seeked func() (time.Duration, bool)) {
+	trackCtx, cancel := context.WithCancel(ctx)
+	var position time.Duration
+	requested := false
+	s.Lock()
+	s.seek = func(p time.Duration) {
+		position, requested = p, true
+		cancel()
+	}
+	s.Unlock()
+
+	seeked = func() (time.Duration, bool) {
+		s.Lock()
+		defer s.Unlock()
+		// the fade belongs to the input that was seeked away from
+		s.seek, s.fade = nil, nil
+		cancel()
+		return position, requested
+	}
+	return trackCtx, seeked
+}
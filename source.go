@@ -0,0 +1,282 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A TrackSource provides the audio of media as a byte stream ffmpeg can decode.
+type TrackSource interface {
+	// Open starts reading the audio of media.
+	// The returned stream is closed once it was played or playback was aborted.
+	Open(ctx context.Context, media Media) (io.ReadCloser, error)
+}
+
+// YtdlpSource streams the best audio format of anything yt-dlp supports.
+//
+// The zero value uses the best audio format regardless of its codec and bitrate.
+type YtdlpSource struct {
+	// Format is the yt-dlp format selector, e.g. bestaudio[ext=m4a]/bestaudio or a specific itag.
+	// It defaults to bestaudio.
+	Format string
+	// MaxBitrate in kbit/s excludes formats with a higher audio bitrate, e.g. giant FLAC streams.
+	// <= 0 means unlimited.
+	MaxBitrate int
+}
+
+// formatSelector returns the format selector passed to yt-dlp.
+func (s YtdlpSource) formatSelector() string {
+	format := s.Format
+	if format == "" {
+		format = "bestaudio"
+	}
+	if s.MaxBitrate <= 0 {
+		return format
+	}
+
+	alternatives := strings.Split(format, "/")
+	for i, alternative := range alternatives {
+		alternatives[i] = alternative + "[abr<=" + strconv.Itoa(s.MaxBitrate) + "]"
+	}
+	return strings.Join(alternatives, "/")
+}
+
+// Open starts yt-dlp and returns its output.
+func (s YtdlpSource) Open(ctx context.Context, media Media) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := newCommand(ctx, "yt-dlp", "-f", s.formatSelector(), "-o", "-", "--quiet", "--", media.URL)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start yt-dlp: %w", err)
+	}
+	return &commandReader{ReadCloser: stdout, cmd: cmd, cancel: cancel}, nil
+}
+
+// commandReader is the output of a command that is stopped when the reader is closed.
+//
+// If the command exits with an error the error is returned instead of io.EOF,
+// so an unexpected exit is reported instead of looking like the end of the track.
+type commandReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	cancel func()
+
+	waitOnce sync.Once
+	waitErr  error
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, fmt.Errorf("%s exited unexpectedly: %w", r.cmd.Path, waitErr)
+		}
+	}
+	return n, err
+}
+
+func (r *commandReader) wait() error {
+	r.waitOnce.Do(func() {
+		r.waitErr = r.cmd.Wait()
+	})
+	return r.waitErr
+}
+
+func (r *commandReader) Close() error {
+	r.cancel()
+	r.ReadCloser.Close()
+	_ = r.wait()
+	return nil
+}
+
+// ErrorLocalFile is returned for local files that may not be read, because no FileSource
+// was registered or the file is outside of its root directory.
+var ErrorLocalFile = errors.New("local file is not allowed")
+
+// FileSource reads local files below Root, the media URL is either a path or a file:// URL.
+//
+// A TrackSourceRegistry only reads local files once a FileSource is registered for the file scheme,
+// e.g. RegisterScheme("file", FileSource{Root: "/srv/music"}), so requesters can't stream any file
+// the process can read. Files whose real path is outside of Root are rejected, symlinks included.
+type FileSource struct {
+	// Root is the directory all files have to be in, no file is allowed if it is empty.
+	Root string
+}
+
+// Open opens the file.
+func (s FileSource) Open(_ context.Context, media Media) (io.ReadCloser, error) {
+	path, err := s.resolve(media.URL)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// resolve returns the real path of the file at rawURL if it is inside Root.
+func (s FileSource) resolve(rawURL string) (string, error) {
+	if s.Root == "" {
+		return "", fmt.Errorf("%s: %w", rawURL, ErrorLocalFile)
+	}
+	root, err := realPath(s.Root)
+	if err != nil {
+		return "", err
+	}
+	path, err := realPath(filePath(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: %w", rawURL, ErrorLocalFile)
+	}
+	return path, nil
+}
+
+// realPath returns the absolute path with all symlinks resolved.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// filePath returns the path of a file:// URL or the URL itself if it is a plain path.
+func filePath(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return rawURL
+}
+
+// HTTPSource downloads media URLs that point directly to audio files.
+type HTTPSource struct {
+	Client *http.Client
+}
+
+// Open requests the media URL.
+func (s HTTPSource) Open(ctx context.Context, media Media) (io.ReadCloser, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, media.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %w", media.URL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to request %s: %s", media.URL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// A TrackSourceRegistry selects a TrackSource based on the URL of the media.
+//
+// The zero value reads everything with YtdlpSource except local paths and file:// URLs,
+// which are rejected until a FileSource is registered for the file scheme.
+// It is safe for concurrent use.
+type TrackSourceRegistry struct {
+	mu      sync.RWMutex
+	schemes map[string]TrackSource
+	hosts   map[string]TrackSource
+	// Fallback is used for URLs no source was registered for, it defaults to YtdlpSource.
+	Fallback TrackSource
+}
+
+// RegisterScheme uses s for all URLs with the given scheme, e.g. "s3".
+func (reg *TrackSourceRegistry) RegisterScheme(scheme string, s TrackSource) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.schemes == nil {
+		reg.schemes = make(map[string]TrackSource)
+	}
+	reg.schemes[strings.ToLower(scheme)] = s
+}
+
+// RegisterHost uses s for all URLs with the given host or one of its subdomains.
+func (reg *TrackSourceRegistry) RegisterHost(host string, s TrackSource) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.hosts == nil {
+		reg.hosts = make(map[string]TrackSource)
+	}
+	reg.hosts[strings.ToLower(host)] = s
+}
+
+// Open opens media with the source registered for its URL.
+func (reg *TrackSourceRegistry) Open(ctx context.Context, media Media) (io.ReadCloser, error) {
+	s, err := reg.lookup(media.URL)
+	if err != nil {
+		return nil, err
+	}
+	return s.Open(ctx, media)
+}
+
+func (reg *TrackSourceRegistry) lookup(rawURL string) (TrackSource, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	// plain paths are local files as well
+	u, err := url.Parse(rawURL)
+	scheme := "file"
+	if err == nil && u.Scheme != "" {
+		scheme = strings.ToLower(u.Scheme)
+	}
+	if s, ok := reg.schemes[scheme]; ok {
+		return s, nil
+	} else if scheme == "file" {
+		return nil, fmt.Errorf("%s: %w", rawURL, ErrorLocalFile)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for host != "" {
+		if s, ok := reg.hosts[host]; ok {
+			return s, nil
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	if reg.Fallback != nil {
+		return reg.Fallback, nil
+	}
+	return YtdlpSource{}, nil
+}
+
+// SetTrackSource sets the TrackSource the audio of every entry is read from.
+//
+// It defaults to an empty TrackSourceRegistry.
+func (dj *Dj) SetTrackSource(s TrackSource) {
+	dj.updateConfig(func(c *Config) { c.TrackSource = s })
+}
+
+// openTrack opens media with the configured TrackSource.
+func (dj *Dj) openTrack(ctx context.Context, media Media) (io.ReadCloser, error) {
+	s := dj.cfg().TrackSource
+	if s == nil {
+		s = &TrackSourceRegistry{}
+	}
+	return s.Open(ctx, media)
+}
@@ -0,0 +1,57 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrackSourceRegistryLocalFiles(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "music")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inside := filepath.Join(root, "song.mp3")
+	outside := filepath.Join(dir, "secret")
+	for _, name := range []string{inside, outside} {
+		if err := os.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link.mp3")); err != nil {
+		t.Fatal(err)
+	}
+
+	open := func(reg *TrackSourceRegistry, rawURL string) error {
+		r, err := reg.Open(context.Background(), Media{URL: rawURL})
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.ReadAll(r)
+		return err
+	}
+
+	reg := &TrackSourceRegistry{}
+	for _, rawURL := range []string{inside, "file://" + inside, "song.mp3"} {
+		if err := open(reg, rawURL); !errors.Is(err, ErrorLocalFile) {
+			t.Errorf("%s was opened without a FileSource: %v", rawURL, err)
+		}
+	}
+
+	reg.RegisterScheme("file", FileSource{Root: root})
+	for _, rawURL := range []string{inside, "file://" + inside, filepath.Join(root, "..", "music", "song.mp3")} {
+		if err := open(reg, rawURL); err != nil {
+			t.Errorf("failed to open %s: %v", rawURL, err)
+		}
+	}
+	for _, rawURL := range []string{outside, "file://" + outside, filepath.Join(root, "..", "secret"), filepath.Join(root, "link.mp3")} {
+		if err := open(reg, rawURL); !errors.Is(err, ErrorLocalFile) {
+			t.Errorf("%s outside of the root was opened: %v", rawURL, err)
+		}
+	}
+}
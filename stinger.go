@@ -0,0 +1,43 @@
+package opendj
+
+import (
+	"context"
+	"io"
+	"math/rand"
+)
+
+// SetStingers plays one of the given stings after every k requested tracks,
+// e.g. a short station ID. Stings have to be files or URLs ffmpeg can read.
+//
+// Unlike breaks and announcements stings are not part of any queue, they should be
+// a few seconds long at most. They are played between the end of one track and the
+// new song handler of the next, so the progress of tracks is not affected.
+// k <= 0 or no stings disables them.
+func (dj *Dj) SetStingers(k int, stings ...string) {
+	dj.updateConfig(func(c *Config) {
+		c.StingerEvery = k
+		c.Stingers = append([]string(nil), stings...)
+	})
+}
+
+// writeStinger writes a random sting into the stream if one is due after the track that just ended.
+func (dj *Dj) writeStinger(ctx context.Context, stream io.Writer) error {
+	cfg := dj.cfg()
+	if cfg.StingerEvery <= 0 || len(cfg.Stingers) == 0 {
+		dj.sinceStinger = 0
+		return nil
+	}
+	if dj.sinceStinger++; dj.sinceStinger < cfg.StingerEvery {
+		return nil
+	}
+	dj.sinceStinger = 0
+
+	return writeStream(
+		ctx,
+		stream,
+		nil,
+		nil,
+		"-re",
+		"-i", cfg.Stingers[rand.Intn(len(cfg.Stingers))],
+	)
+}
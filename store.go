@@ -0,0 +1,103 @@
+package opendj
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A Store persists small pieces of data by key, e.g. in a database or on disk.
+//
+// Keys consist of segments separated by slashes, like "preferences/nick".
+type Store interface {
+	// Load returns the data saved for key, the error wraps fs.ErrNotExist if nothing was saved.
+	Load(key string) ([]byte, error)
+	// Save replaces the data saved for key.
+	Save(key string, data []byte) error
+}
+
+// FileStore saves every key as a file in Dir.
+type FileStore struct {
+	Dir string
+}
+
+// Load reads the file of key.
+func (s FileStore) Load(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Save writes the file of key, replacing it atomically.
+func (s FileStore) Save(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// path returns the file of key, segments are escaped so keys can't leave Dir.
+func (s FileStore) path(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+		segments[i] = escapeSegment(segment)
+	}
+	return filepath.Join(append([]string{s.Dir}, segments...)...), nil
+}
+
+// escapeSegment replaces characters that are not safe in file names by their hex code.
+func escapeSegment(segment string) string {
+	var b strings.Builder
+	for _, r := range segment {
+		if r == '%' || r == '\\' || r == '.' && b.Len() == 0 || r < ' ' || strings.ContainsRune(`<>:"|?*`, r) {
+			fmt.Fprintf(&b, "%%%02x", r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// MemoryStore keeps everything in memory, e.g. for tests. The zero value is ready to use.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+// Load returns a copy of the data of key.
+func (s *MemoryStore) Load(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Save stores a copy of data.
+func (s *MemoryStore) Save(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data == nil {
+		s.data = make(map[string][]byte)
+	}
+	s.data[key] = append([]byte(nil), data...)
+	return nil
+}
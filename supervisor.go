@@ -0,0 +1,74 @@
+package opendj
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// maxRestarts is how often a pipeline component may fail within restartWindow before it is given up.
+	maxRestarts   = 5
+	restartWindow = 5 * time.Minute
+	// maxRestartBackoff caps the exponential delay between restarts.
+	maxRestartBackoff = 30 * time.Second
+)
+
+// supervise runs task until it returns nil or ctx is cancelled, restarting it when it fails.
+//
+// Every failure is sent to errs. If the task fails more than maxRestarts times within
+// restartWindow it is given up and the last error is returned.
+// Every state change is passed to state. Failures and the backoff are timed with the clock of the Dj.
+func (dj *Dj) supervise(ctx context.Context, errs chan<- error, state func(ComponentStatus, error), task func(context.Context) error) error {
+	var failures []time.Time
+	backoff := time.Second
+	for {
+		started := dj.now()
+		state(ComponentRunning, nil)
+		err := task(ctx)
+		if err == nil || ctx.Err() != nil {
+			state(ComponentIdle, nil)
+			return nil
+		}
+		select {
+		case errs <- err:
+		case <-ctx.Done():
+			state(ComponentIdle, err)
+			return nil
+		}
+
+		now := dj.now()
+		recent := failures[:0]
+		for _, failure := range failures {
+			if now.Sub(failure) < restartWindow {
+				recent = append(recent, failure)
+			}
+		}
+		failures = append(recent, now)
+		if len(failures) > maxRestarts {
+			state(ComponentFailed, err)
+			return err
+		}
+		state(ComponentRestarting, err)
+
+		if now.Sub(started) > stableStreamDuration {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			state(ComponentIdle, nil)
+			return nil
+		case <-dj.after(backoff):
+		}
+		if backoff *= 2; backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
+
+// reportErrors passes all errors sent to errs to the error handler until errs is closed.
+func (dj *Dj) reportErrors(errs <-chan error, done chan<- struct{}) {
+	for err := range errs {
+		dj.reportError(err)
+	}
+	close(done)
+}
@@ -0,0 +1,57 @@
+package opendj
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StartOffset returns the start time encoded in a media URL, like the ?t=90 or #t=1m30s
+// of YouTube links that point to a specific moment of a video.
+//
+// Plain seconds and the h, m and s units are supported, also in the start parameter.
+// ok is false if the URL has no valid start time.
+func StartOffset(rawURL string) (offset time.Duration, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, false
+	}
+	query := u.Query()
+	// the fragment has the same format as a query, e.g. #t=1m30s
+	fragment, _ := url.ParseQuery(u.Fragment)
+	for _, value := range []string{query.Get("t"), query.Get("start"), fragment.Get("t")} {
+		if offset, ok := parseTimestamp(value); ok {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// parseTimestamp parses seconds like 90 or 90s and durations like 1h2m3s.
+func parseTimestamp(s string) (time.Duration, bool) {
+	if s == "" || strings.Trim(s, "0123456789.hms") != "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64); err == nil {
+		if seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// clipFromURL starts the clip at the start time of the media URL if the clip has no start yet.
+func (e *QueueEntry) clipFromURL() {
+	if e.Clip.Start != 0 {
+		return
+	}
+	if offset, ok := StartOffset(e.Media.URL); ok && (e.Clip.End <= 0 || offset < e.Clip.End) && (e.Media.Duration <= 0 || offset < e.Media.Duration) {
+		e.Clip.Start = offset
+	}
+}
@@ -0,0 +1,85 @@
+package opendj
+
+import "time"
+
+// SetLocation sets the time zone schedules follow, like the gain schedule and hour clocks.
+// nil uses the local time zone of the system.
+//
+// Schedules use the wall clock of the location, so they stay at the same time of day when
+// daylight saving time starts or ends:
+//   - A time in an hour that is skipped when the clock jumps forward applies at the jump,
+//     e.g. 02:30 applies at 03:00 if the clock jumps from 02:00 to 03:00.
+//   - A time in an hour that is repeated when the clock is set back applies on its first
+//     occurrence. Schedules stay as they were at the end of the first pass during the second one.
+//   - Hour clocks start over at the start of every hour that is played, a repeated hour
+//     gets the clock twice and a skipped hour not at all.
+func (dj *Dj) SetLocation(loc *time.Location) {
+	dj.updateConfig(func(c *Config) { c.Location = loc })
+}
+
+// location returns the time zone schedules follow.
+func (c *Config) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// localNow returns the current time in the time zone schedules follow.
+func (dj *Dj) localNow() time.Time {
+	return dj.now().In(dj.cfg().location())
+}
+
+// wallClock returns the time of day shown by a clock in the location of t.
+func wallClock(t time.Time) time.Duration {
+	hour, min, sec := t.Clock()
+	return time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute +
+		time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())
+}
+
+// timeOfDay returns the wall clock time of t for schedules. During the second pass of an hour
+// that is repeated at the end of daylight saving time it returns the last time of the first pass.
+func timeOfDay(t time.Time) time.Duration {
+	year, month, day := t.Date()
+	d := wallClock(t)
+	if first := wallClockTime(year, month, day, d, t.Location()); first.Before(t) {
+		transition, _ := t.ZoneBounds()
+		return wallClock(transition.Add(-time.Nanosecond))
+	}
+	return d
+}
+
+// wallClockTime returns the first time the wall clock in loc shows the time of day d on the given day.
+// If the time is skipped by a daylight saving time change it returns the time of the change.
+// The day is normalized like in time.Date.
+func wallClockTime(year int, month time.Month, day int, d time.Duration, loc *time.Location) time.Time {
+	// wall is the wanted wall clock time as if loc was UTC
+	wall := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Add(d)
+
+	// a day has at most one offset change, so the offsets of the surrounding days are the candidates
+	var first, earliest time.Time
+	for _, probe := range []time.Time{wall.Add(-24 * time.Hour), wall.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if earliest.IsZero() || candidate.Before(earliest) {
+			earliest = candidate
+		}
+		shown := time.Date(candidate.Year(), candidate.Month(), candidate.Day(), 0, 0, 0, 0, time.UTC).Add(wallClock(candidate))
+		if shown.Equal(wall) && (first.IsZero() || candidate.Before(first)) {
+			first = candidate
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+	// skipped, the earliest candidate is in the zone before the change
+	_, change := earliest.ZoneBounds()
+	return change
+}
+
+// hourStart returns the start of the hour of t on the wall clock of its location.
+// Repeated hours are two different hours.
+func hourStart(t time.Time) time.Time {
+	min, sec := t.Minute(), t.Second()
+	return t.Add(-(time.Duration(min)*time.Minute + time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())))
+}
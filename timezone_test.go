@@ -0,0 +1,110 @@
+package opendj
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone data is not available: %v", err)
+	}
+	return loc
+}
+
+func TestWallClockTime(t *testing.T) {
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		location string
+		month    time.Month
+		day      int
+		clock    time.Duration
+		want     time.Time
+	}{
+		{"new york summer", "America/New_York", time.July, 1, 12 * time.Hour, utc(time.July, 1, 16, 0)},
+		{"new york winter", "America/New_York", time.January, 15, 12 * time.Hour, utc(time.January, 15, 17, 0)},
+		{"new york before the jump", "America/New_York", time.March, 10, time.Hour + 59*time.Minute, utc(time.March, 10, 6, 59)},
+		{"new york skipped 02:30", "America/New_York", time.March, 10, 2*time.Hour + 30*time.Minute, utc(time.March, 10, 7, 0)},
+		{"new york after the jump", "America/New_York", time.March, 10, 3 * time.Hour, utc(time.March, 10, 7, 0)},
+		{"new york repeated 01:30", "America/New_York", time.November, 3, time.Hour + 30*time.Minute, utc(time.November, 3, 5, 30)},
+		{"new york after the repeat", "America/New_York", time.November, 3, 2 * time.Hour, utc(time.November, 3, 7, 0)},
+		{"berlin skipped 02:30", "Europe/Berlin", time.March, 31, 2*time.Hour + 30*time.Minute, utc(time.March, 31, 1, 0)},
+		{"berlin after the jump", "Europe/Berlin", time.March, 31, 3*time.Hour + 30*time.Minute, utc(time.March, 31, 1, 30)},
+		{"berlin repeated 02:30", "Europe/Berlin", time.October, 27, 2*time.Hour + 30*time.Minute, utc(time.October, 27, 0, 30)},
+		{"berlin after the repeat", "Europe/Berlin", time.October, 27, 3 * time.Hour, utc(time.October, 27, 2, 0)},
+		{"berlin midnight", "Europe/Berlin", time.October, 27, 0, utc(time.October, 26, 22, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := loadLocation(t, tt.location)
+			got := wallClockTime(2024, tt.month, tt.day, tt.clock, loc)
+			if !got.Equal(tt.want) {
+				t.Errorf("wallClockTime() = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeOfDayRepeatedHour(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		at       time.Time
+		want     time.Duration
+	}{
+		{"new york first 01:30", "America/New_York", time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC), time.Hour + 30*time.Minute},
+		{"new york second 01:30", "America/New_York", time.Date(2024, time.November, 3, 6, 30, 0, 0, time.UTC), 2*time.Hour - time.Nanosecond},
+		{"new york after the repeat", "America/New_York", time.Date(2024, time.November, 3, 7, 0, 0, 0, time.UTC), 2 * time.Hour},
+		{"new york after the jump", "America/New_York", time.Date(2024, time.March, 10, 7, 0, 0, 0, time.UTC), 3 * time.Hour},
+		{"berlin first 02:30", "Europe/Berlin", time.Date(2024, time.October, 27, 0, 30, 0, 0, time.UTC), 2*time.Hour + 30*time.Minute},
+		{"berlin second 02:30", "Europe/Berlin", time.Date(2024, time.October, 27, 1, 30, 0, 0, time.UTC), 3*time.Hour - time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := loadLocation(t, tt.location)
+			if got := timeOfDay(tt.at.In(loc)); got != tt.want {
+				t.Errorf("timeOfDay() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHourClockTransitions checks that hour clocks start once per hour that is played.
+func TestHourClockTransitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		day      time.Time
+		hours    int
+	}{
+		{"new york normal day", "America/New_York", time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC), 24},
+		{"new york skipped hour", "America/New_York", time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC), 23},
+		{"new york repeated hour", "America/New_York", time.Date(2024, time.November, 3, 0, 0, 0, 0, time.UTC), 25},
+		{"berlin skipped hour", "Europe/Berlin", time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC), 23},
+		{"berlin repeated hour", "Europe/Berlin", time.Date(2024, time.October, 27, 0, 0, 0, 0, time.UTC), 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := loadLocation(t, tt.location)
+			start := wallClockTime(tt.day.Year(), tt.day.Month(), tt.day.Day(), 0, loc)
+			end := wallClockTime(tt.day.Year(), tt.day.Month(), tt.day.Day()+1, 0, loc)
+
+			starts := make(map[time.Time]bool)
+			for at := start; at.Before(end); at = at.Add(time.Minute) {
+				hour := hourStart(at.In(loc))
+				if at.Sub(hour) >= time.Hour || at.Before(hour) {
+					t.Fatalf("hourStart(%s) = %s", at, hour)
+				}
+				starts[hour] = true
+			}
+			if len(starts) != tt.hours {
+				t.Errorf("the day has %d hour clocks, want %d", len(starts), tt.hours)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package opendj
+
+import (
+	"regexp"
+	"strings"
+)
+
+// titleNoise matches bracketed suffixes commonly added to video titles.
+var titleNoise = regexp.MustCompile(`(?i)\s*[(\[【](?:[^)\]】]*\b(?:official|video|audio|lyrics?|visuali[sz]er|hd|hq|4k|1080p|720p|remaster(?:ed)?|explicit|clean|mv|m/v)\b[^)\]】]*)[)\]】]`)
+
+// titleSeparators are the separators between artist and track, in order of preference.
+var titleSeparators = []string{" - ", " – ", " — ", " ~ ", " | "}
+
+// ParseTitle splits a raw media title into artist and track.
+//
+// Common noise like "(Official Video)" or "[HD]" is removed and the title is split on
+// the first " - ". If no artist can be found artist is empty and track is the cleaned up title.
+func ParseTitle(raw string) (artist, track string) {
+	title := titleNoise.ReplaceAllString(raw, "")
+	title = strings.Join(strings.Fields(title), " ")
+
+	for _, sep := range titleSeparators {
+		if i := strings.Index(title, sep); i >= 0 {
+			artist = strings.TrimSpace(title[:i])
+			track = strings.TrimSpace(title[i+len(sep):])
+			break
+		}
+	}
+	if artist == "" || track == "" {
+		return "", strings.Trim(title, ` "`)
+	}
+
+	return artist, strings.Trim(track, ` "`)
+}
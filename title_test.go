@@ -0,0 +1,30 @@
+package opendj
+
+import "testing"
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		raw, artist, track string
+	}{
+		{"Artist - Track (Official Video)", "Artist", "Track"},
+		{"Artist - Track [HD]", "Artist", "Track"},
+		{"Artist - Track (Official Music Video) [4K Remastered]", "Artist", "Track"},
+		{"Artist - Track (Lyrics)", "Artist", "Track"},
+		{"Artist ft. Guest - Track", "Artist ft. Guest", "Track"},
+		{"Artist - Track (ft. Guest)", "Artist", "Track (ft. Guest)"},
+		{"Artist - Track - Live at Venue", "Artist", "Track - Live at Venue"},
+		{"Artist – Track", "Artist", "Track"},
+		{`Artist - "Track"`, "Artist", "Track"},
+		{"Just A Title", "", "Just A Title"},
+		{"Just A Title (Official Audio)", "", "Just A Title"},
+		{"- Track", "", "- Track"},
+		{"  Artist   -   Track  ", "Artist", "Track"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		artist, track := ParseTitle(tt.raw)
+		if artist != tt.artist || track != tt.track {
+			t.Errorf("ParseTitle(%q) = %q, %q, want %q, %q", tt.raw, artist, track, tt.artist, tt.track)
+		}
+	}
+}
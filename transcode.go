@@ -0,0 +1,91 @@
+package opendj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// TranscodeMode decides whether tracks are transcoded before they are streamed.
+type TranscodeMode int
+
+const (
+	// TranscodeAlways transcodes every track to the output format, this is the default.
+	TranscodeAlways TranscodeMode = iota
+	// TranscodeAuto copies tracks that are already AAC with the output sample rate and
+	// channel layout instead of transcoding them, which saves a lot of CPU.
+	// Such tracks are not resampled or filtered.
+	TranscodeAuto
+)
+
+const (
+	outputCodec      = "aac"
+	outputSampleRate = 44100
+	outputChannels   = 2
+
+	// probeSize is how much of a track is read to detect its format.
+	probeSize = 512 << 10
+)
+
+// SetTranscodeMode sets whether tracks are transcoded or copied if possible.
+//
+// returns an error if the mode is unknown.
+func (dj *Dj) SetTranscodeMode(mode TranscodeMode) error {
+	return dj.updateConfig(func(c *Config) { c.TranscodeMode = mode })
+}
+
+// streamInfo describes the first audio stream of a track.
+type streamInfo struct {
+	CodecName  string `json:"codec_name"`
+	SampleRate string `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+}
+
+// copyable returns whether the stream can be copied into the output without transcoding.
+func (i streamInfo) copyable() bool {
+	return i.CodecName == outputCodec && i.SampleRate == strconv.Itoa(outputSampleRate) && i.Channels == outputChannels
+}
+
+// peekStreamInfo probes the beginning of r.
+//
+// The returned reader yields the complete stream including the bytes used for probing.
+func peekStreamInfo(ctx context.Context, r io.Reader) (streamInfo, io.Reader, error) {
+	head := make([]byte, probeSize)
+	n, err := io.ReadFull(r, head)
+	head = head[:n]
+	rest := io.MultiReader(bytes.NewReader(head), r)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return streamInfo{}, rest, err
+	}
+
+	cmd := newCommand(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,sample_rate,channels",
+		"-of", "json",
+		"-i", "pipe:0",
+	)
+	cmd.Stdin = bytes.NewReader(head)
+	output, err := cmd.Output()
+	if err != nil {
+		return streamInfo{}, rest, err
+	}
+
+	var probe struct {
+		Streams []streamInfo `json:"streams"`
+	}
+	if err = json.Unmarshal(output, &probe); err != nil || len(probe.Streams) == 0 {
+		return streamInfo{}, rest, err
+	}
+	return probe.Streams[0], rest, nil
+}
+
+// copyStream remuxes the input given by args into the stream without transcoding it.
+func copyStream(ctx context.Context, stream io.Writer, input io.Reader, extraOutput []string, args ...string) error {
+	args = append(args, "-c:a", "copy", "-f", "mpegts", "pipe:1")
+	return runEncoder(ctx, stream, input, append(args, extraOutput...))
+}
@@ -0,0 +1,263 @@
+package opendj
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxUpload is the size limit of uploaded files if none is configured.
+const defaultMaxUpload = 512 << 20
+
+// UploadOptions configure the handler returned by UploadHandler.
+type UploadOptions struct {
+	// Dir is the directory uploaded files are stored in, e.g. the directory of a Library.
+	Dir string
+	// Authenticate returns the owner of a request, ok is false if it is not allowed to upload.
+	// Every request is rejected if it is nil, see BearerAuth.
+	Authenticate func(r *http.Request) (owner string, ok bool)
+	// MaxSize is the size limit of a file, it defaults to 512 MiB.
+	MaxSize int64
+	// Library gets every uploaded file if it is set.
+	Library *Library
+}
+
+// UploadResult is the response of the upload handler.
+type UploadResult struct {
+	Media Media `json:"media"`
+	// EntryID is the ID of the queued entry, it is zero if the file was not queued.
+	EntryID uint64 `json:"entryId,omitempty"`
+	// QueueError is set if the file was stored but could not be queued.
+	QueueError string `json:"queueError,omitempty"`
+	// QueueReason is the reason code of QueueError.
+	QueueReason Reason `json:"queueReason,omitempty"`
+}
+
+// BearerAuth returns an authentication function for UploadOptions that accepts the
+// tokens in the Authorization header, mapping each to its owner.
+func BearerAuth(tokens map[string]string) func(r *http.Request) (owner string, ok bool) {
+	return func(r *http.Request) (string, bool) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			return "", false
+		}
+		for valid, owner := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+				return owner, true
+			}
+		}
+		return "", false
+	}
+}
+
+// UploadHandler returns a http.Handler that accepts audio files, e.g. pre-produced segments
+// hosts submit remotely.
+//
+// Files are POSTed as multipart form with the field file. They are probed with ffprobe and
+// stored in the upload directory under their file name, files that are no audio are rejected.
+// The optional field title overrides the probed title and queue adds the file to the named queue,
+// see Actions.AddEntryTo, with the authenticated owner. Uploaders are no moderators, so only
+// RequestQueue is allowed. The response is an UploadResult as JSON.
+// Queued files are only played with a FileSource whose root contains the upload directory.
+func (dj *Dj) UploadHandler(opts UploadOptions) http.Handler {
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxUpload
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if opts.Dir == "" {
+			http.Error(w, "uploads are not configured", http.StatusInternalServerError)
+			return
+		}
+		if opts.Authenticate == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		owner, ok := opts.Authenticate(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// the form fields are small, the limit is for the file
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "expected a multipart form", http.StatusBadRequest)
+			return
+		}
+
+		var tmp, name, title, queue string
+		defer func() {
+			// only published once it was probed successfully
+			if tmp != "" {
+				os.Remove(tmp)
+			}
+		}()
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				http.Error(w, "invalid form: "+err.Error(), uploadStatus(err))
+				return
+			}
+
+			switch part.FormName() {
+			case "file":
+				if tmp != "" {
+					http.Error(w, "only one file can be uploaded at once", http.StatusBadRequest)
+					return
+				}
+				name = filepath.Base(part.FileName())
+				if !audioExtensions[strings.ToLower(filepath.Ext(name))] {
+					http.Error(w, "unsupported file type", http.StatusUnsupportedMediaType)
+					return
+				}
+				if tmp, err = storeUpload(opts.Dir, filepath.Ext(name), io.LimitReader(part, maxSize+1), maxSize); err != nil {
+					status := uploadStatus(err)
+					if status == http.StatusInternalServerError {
+						dj.reportError(fmt.Errorf("failed to store upload %s: %w", name, err))
+						http.Error(w, "failed to store the file", status)
+					} else {
+						http.Error(w, err.Error(), status)
+					}
+					return
+				}
+			case "title", "queue":
+				value, err := io.ReadAll(io.LimitReader(part, 4096))
+				if err != nil {
+					http.Error(w, "invalid form: "+err.Error(), uploadStatus(err))
+					return
+				}
+				if part.FormName() == "title" {
+					title = strings.TrimSpace(string(value))
+				} else {
+					queue = strings.TrimSpace(string(value))
+				}
+			}
+		}
+		if tmp == "" {
+			http.Error(w, "no file was uploaded", http.StatusBadRequest)
+			return
+		}
+
+		media, err := ProbeResolver{Root: opts.Dir}.Resolve(dj.withLimits(r.Context()), tmp)
+		if err != nil {
+			http.Error(w, "not a playable audio file", http.StatusUnprocessableEntity)
+			return
+		}
+		ext := filepath.Ext(name)
+		if title != "" {
+			media.Title = title
+		} else if media.Title == strings.TrimSuffix(filepath.Base(tmp), ext) {
+			// the file has no title tags
+			media.Title = strings.TrimSuffix(name, ext)
+		}
+		stored, err := publishUpload(tmp, opts.Dir, name)
+		if err != nil {
+			dj.reportError(fmt.Errorf("failed to store upload %s: %w", name, err))
+			http.Error(w, "failed to store the file", http.StatusInternalServerError)
+			return
+		}
+		tmp = ""
+		media.URL = stored
+
+		if opts.Library != nil {
+			if err = opts.Library.Add(LibraryTrack{Media: media}); err != nil {
+				dj.reportError(fmt.Errorf("failed to add upload %s to the library: %w", stored, err))
+			}
+		}
+		result := UploadResult{Media: media}
+		if queue != "" {
+			entry := QueueEntry{Media: media, Owner: owner, Source: SourceAPI}
+			if queued := dj.As(Actor{Name: owner, Source: SourceAPI}).AddEntryTo(queue, entry); queued.OK() {
+				result.EntryID = queued.EntryID
+			} else {
+				result.QueueError, result.QueueReason = queued.Message, queued.Reason
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// storeUpload writes the file into a hidden file with the extension ext in dir and returns its path.
+// It is hidden until it was probed, so directory watchers and library indexing skip it.
+func storeUpload(dir, ext string, r io.Reader, maxSize int64) (string, error) {
+	tmp, err := os.CreateTemp(dir, ".upload-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > maxSize {
+		err = errUploadTooLarge
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// publishUpload moves the stored file tmp into dir under name, or name with a number if it is taken,
+// and returns its path.
+//
+// Unlike a rename a link fails if the name is taken, so concurrent uploads never replace each other.
+func publishUpload(tmp, dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimLeft(strings.TrimSuffix(name, ext), ".")
+	if base == "" {
+		base = "upload"
+	}
+	for i := 0; ; i++ {
+		path := filepath.Join(dir, base+ext)
+		if i > 0 {
+			path = filepath.Join(dir, base+"-"+strconv.Itoa(i)+ext)
+		}
+		err := os.Link(tmp, path)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		os.Remove(tmp)
+		return path, nil
+	}
+}
+
+// errUploadTooLarge is returned if an uploaded file exceeds the size limit.
+var errUploadTooLarge = errors.New("file is too large")
+
+// uploadStatus returns the status code for an error while reading an upload.
+func uploadStatus(err error) int {
+	var maxBytes *http.MaxBytesError
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, errUploadTooLarge) || errors.As(err, &maxBytes):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &pathErr):
+		// the file could not be stored
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,145 @@
+package opendj
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// uploadRequest returns a request uploading content as a file with the given name.
+func uploadRequest(t *testing.T, token, name, content string, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for field, value := range fields {
+		if err := form.WriteField(field, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// dirNames returns the names of the files in dir.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestUploadHandler(t *testing.T) {
+	dj := newTestDj(t, nil)
+	// files containing "garbage" are no audio, the others have no title tags
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor last; do :; done\n" +
+		"grep -q garbage \"${last#file:}\" && exit 1\n" +
+		`echo '{"format": {"duration": "1.5"}, "streams": [{"codec_name": "mp3"}]}'` + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "ffprobe"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "taken.mp3"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	handler := dj.UploadHandler(UploadOptions{
+		Dir:          dir,
+		Authenticate: BearerAuth(map[string]string{"secret": "alice"}),
+		MaxSize:      16,
+	})
+
+	tests := []struct {
+		name    string
+		token   string
+		file    string
+		content string
+		status  int
+		stored  string
+	}{
+		{name: "no token", file: "song.mp3", content: "audio", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "guess", file: "song.mp3", content: "audio", status: http.StatusUnauthorized},
+		{name: "too large", token: "secret", file: "song.mp3", content: strings.Repeat("a", 17), status: http.StatusRequestEntityTooLarge},
+		{name: "no audio extension", token: "secret", file: "song.txt", content: "audio", status: http.StatusUnsupportedMediaType},
+		{name: "probe failure", token: "secret", file: "song.mp3", content: "garbage", status: http.StatusUnprocessableEntity},
+		{name: "traversal", token: "secret", file: "../../escape.mp3", content: "audio", status: http.StatusCreated, stored: "escape.mp3"},
+		{name: "hidden", token: "secret", file: ".hidden.mp3", content: "audio", status: http.StatusCreated, stored: "hidden.mp3"},
+		{name: "only extension", token: "secret", file: "..mp3", content: "audio", status: http.StatusCreated, stored: "upload.mp3"},
+		{name: "collision", token: "secret", file: "taken.mp3", content: "audio", status: http.StatusCreated, stored: "taken-1.mp3"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, uploadRequest(t, test.token, test.file, test.content, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d: %s", test.name, w.Code, test.status, w.Body)
+			continue
+		}
+		if test.stored == "" {
+			continue
+		}
+		var result UploadResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		want := strings.TrimSuffix(test.stored, ".mp3")
+		if result.Media.URL != filepath.Join(dir, test.stored) || (test.name == "collision" && result.Media.Title != "taken") {
+			t.Errorf("%s: stored %+v, want %s titled %s", test.name, result.Media, test.stored, want)
+		}
+	}
+
+	want := []string{"escape.mp3", "hidden.mp3", "taken-1.mp3", "taken.mp3", "upload.mp3"}
+	if got := dirNames(t, dir); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("upload directory has %v, want %v", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "taken.mp3")); string(data) != "old" {
+		t.Errorf("the existing file was replaced with %q", data)
+	}
+}
+
+func TestPublishUploadCollision(t *testing.T) {
+	dir := t.TempDir()
+	var stored []string
+	for i := 0; i < 3; i++ {
+		tmp, err := storeUpload(dir, ".mp3", strings.NewReader("audio"), 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := publishUpload(tmp, dir, "song.mp3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, filepath.Base(path))
+	}
+	if got := strings.Join(stored, " "); got != "song.mp3 song-1.mp3 song-2.mp3" {
+		t.Errorf("stored as %s", got)
+	}
+	if got := dirNames(t, dir); len(got) != 3 {
+		t.Errorf("upload directory has %v, want no temporary files", got)
+	}
+}
@@ -0,0 +1,45 @@
+package opendj
+
+import (
+	"context"
+	"time"
+)
+
+// EventUpNext is published shortly before the current entry ends, Entry is the entry that plays next.
+const EventUpNext EventType = "upNext"
+
+// SetUpNextLead sets how long before the end of the current entry EventUpNext is published,
+// so hosts and overlays can tease the next song. <= 0 disables the event.
+//
+// Time spent paused is accounted for. The announced entry is reserved and played next
+// even if the scheduler would pick another one by then, unless it is removed from the queue.
+func (dj *Dj) SetUpNextLead(lead time.Duration) {
+	dj.updateConfig(func(c *Config) { c.UpNextLead = lead })
+}
+
+// announceUpNext publishes EventUpNext once the current entry is about to end.
+// It returns when ctx is cancelled or the event was published.
+func (dj *Dj) announceUpNext(ctx context.Context) {
+	for {
+		lead := dj.cfg().UpNextLead
+		if lead <= 0 {
+			return
+		}
+		wait := dj.remaining() - lead
+		if wait <= 0 {
+			break
+		}
+		// re-check after waiting since the entry may have been paused in the meantime
+		select {
+		case <-ctx.Done():
+			return
+		case <-dj.after(wait):
+		}
+	}
+
+	next, err := dj.peek()
+	if err != nil {
+		return
+	}
+	dj.publish(Event{Type: EventUpNext, Entry: next})
+}
@@ -0,0 +1,173 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrorNotAdjacent is returned if a voice track is placed between entries that are not next to each other.
+var ErrorNotAdjacent = errors.New("entries are not next to each other")
+
+// AddVoiceTrack places a pre-recorded host segment between the entry with the ID after and the
+// entry with the ID before, which has to follow it directly in the same queue.
+//
+// The voice track is played as the outro of the first entry, replacing any outro it has, and
+// starts overlap before that entry ends, crossfading the two. A zero overlap plays it after the entry.
+// Use PreviewVoiceTrack to record a transition that fits the surrounding tracks.
+//
+// returns ErrorUnknownEntry if either entry does not exist and ErrorNotAdjacent if they are not next to each other.
+func (dj *Dj) AddVoiceTrack(after, before uint64, voice Media, overlap time.Duration) error {
+	return dj.As(directActor).AddVoiceTrack(after, before, voice, overlap).Err
+}
+
+// addVoiceTrack is AddVoiceTrack without the audit log.
+func (dj *Dj) addVoiceTrack(after, before uint64, voice Media, overlap time.Duration) error {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	q, i, err := dj.adjacentEntries(after, before)
+	if err != nil {
+		return err
+	}
+	entry := q.at(i)
+	entry.Outro = &voice
+	entry.OutroOverlap = overlap
+	q.replace(i, entry)
+	return nil
+}
+
+// RemoveVoiceTrack removes the outro of the entry with the given ID, e.g. a voice track added with AddVoiceTrack.
+//
+// returns ErrorUnknownEntry if there is no such entry.
+func (dj *Dj) RemoveVoiceTrack(id uint64) error {
+	return dj.As(directActor).RemoveVoiceTrack(id).Err
+}
+
+// removeVoiceTrack is RemoveVoiceTrack without the audit log.
+func (dj *Dj) removeVoiceTrack(id uint64) error {
+	dj.lockQueue()
+	defer dj.unlockQueue()
+
+	q, i, ok := dj.findEntry(id)
+	if !ok {
+		return ErrorUnknownEntry
+	}
+	entry := q.at(i)
+	entry.Outro = nil
+	entry.OutroOverlap = 0
+	q.replace(i, entry)
+	return nil
+}
+
+// PreviewVoiceTrack writes the last d of the entry with the ID after followed by the first d of the
+// entry with the ID before into w as WAV, so a host can record a transition between them.
+//
+// returns ErrorUnknownEntry if either entry does not exist and ErrorNotAdjacent if they are not next to each other.
+func (dj *Dj) PreviewVoiceTrack(ctx context.Context, w io.Writer, after, before uint64, d time.Duration) error {
+	dj.waitingQueue.Lock()
+	q, i, err := dj.adjacentEntries(after, before)
+	var first, second QueueEntry
+	if err == nil {
+		first, second = q.at(i), q.at(i+1)
+	}
+	dj.waitingQueue.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tailStart := first.Clip.Start + first.Clip.duration(first.Media.Duration) - d
+	if tailStart < first.Clip.Start {
+		tailStart = first.Clip.Start
+	}
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		err := dj.decodeInto(ctx, pw, first.Media, "-ss", ffmpegDuration(tailStart), "-t", ffmpegDuration(d))
+		if err == nil {
+			err = dj.decodeInto(ctx, pw, second.Media, "-ss", ffmpegDuration(second.Clip.Start), "-t", ffmpegDuration(d))
+		}
+		pw.CloseWithError(err)
+	}()
+
+	cmd := newCommand(ctx, "ffmpeg", append(append([]string(nil), pcmInputArgs...), "-f", "wav", "pipe:1")...)
+	cmd.Stdin = pr
+	cmd.Stdout = w
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("failed to preview voice track: %w", err)
+	}
+	return nil
+}
+
+// adjacentEntries returns the queue and index of the entry with the ID after,
+// which has to be followed by the entry with the ID before. The lock has to be held.
+func (dj *Dj) adjacentEntries(after, before uint64) (*queue, int, error) {
+	q, i, ok := dj.findEntry(after)
+	if !ok {
+		return nil, 0, ErrorUnknownEntry
+	}
+	if _, _, ok = dj.findEntry(before); !ok {
+		return nil, 0, ErrorUnknownEntry
+	}
+	if i+1 >= q.len() || q.at(i+1).ID != before {
+		return nil, 0, ErrorNotAdjacent
+	}
+	return q, i, nil
+}
+
+// outroOverlap returns how long the outro overlaps the main media, at most half of either.
+func (e QueueEntry) outroOverlap() time.Duration {
+	if e.Outro == nil || e.OutroOverlap <= 0 {
+		return 0
+	}
+	overlap := e.OutroOverlap
+	if d := e.Clip.duration(e.Media.Duration); d > 0 && overlap > d/2 {
+		overlap = d / 2
+	}
+	if d := e.Outro.Duration; d > 0 && overlap > d/2 {
+		overlap = d / 2
+	}
+	return overlap
+}
+
+// writeCrossfadedStream is like writeProfileStream, but also plays outro, starting overlap before the
+// main audio ends and crossfading the two.
+//
+// args are the input options, the filters are applied to the main audio and outroFilters to the outro.
+func writeCrossfadedStream(ctx context.Context, stream io.Writer, input, outro io.Reader, overlap time.Duration, profile *EncodingProfile, extraOutput, args, outroFilters []string, filters ...string) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	go func() {
+		// fails once the encoder is done, the outro is closed by the caller
+		_, _ = io.Copy(w, outro)
+		w.Close()
+	}()
+
+	format := []string{"aresample=44100", "aformat=channel_layouts=stereo"}
+	graph := "[0:a]" + profile.filterChain(append(filters, format...)...) + "[main];" +
+		"[1:a]" + profile.filterChain(append(outroFilters, format...)...) + "[outro];" +
+		"[main][outro]acrossfade=d=" + ffmpegDuration(overlap)
+	if len(extraOutput) > 0 {
+		graph += ",asplit=2[out][archive]"
+		extraOutput = append([]string{"-map", "[archive]"}, extraOutput...)
+	} else {
+		graph += "[out]"
+	}
+
+	args = append(args,
+		"-i", "pipe:3",
+		"-filter_complex", graph,
+		"-map", "[out]",
+	)
+	args = append(args, profile.outputArgs()...)
+	return runEncoder(ctx, stream, input, append(args, extraOutput...), r)
+}
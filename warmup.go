@@ -0,0 +1,68 @@
+package opendj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SetWarmUp makes PlayTo resolve the next n entries before the outputs are started,
+// so a stream doesn't open with an error or silence because the first request was deleted.
+//
+// Entries whose media is unavailable are removed from their queue and reported to the error handler.
+// Entries that fail to resolve for other reasons are reported as well, but stay queued since
+// the failure might be temporary. n <= 0 disables the warm-up.
+func (dj *Dj) SetWarmUp(n int) {
+	dj.updateConfig(func(c *Config) { c.WarmUp = n })
+}
+
+// warmUp validates the next n entries as described in SetWarmUp.
+func (dj *Dj) warmUp(ctx context.Context, n int) {
+	var entries []QueueEntry
+	dj.waitingQueue.Lock()
+	for _, q := range dj.drainOrder() {
+		for _, entry := range q.entries() {
+			if len(entries) == n {
+				break
+			}
+			entries = append(entries, entry)
+		}
+	}
+	dj.waitingQueue.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	urls := make([]string, len(entries))
+	for i, entry := range entries {
+		urls[i] = entry.Media.URL
+	}
+	_, errs := dj.resolveAll(ctx, urls)
+	if ctx.Err() != nil {
+		return
+	}
+
+	removed := make([]bool, len(entries))
+	dj.lockQueue()
+	for i, entry := range entries {
+		if !errors.Is(errs[i], ErrorUnavailable) {
+			continue
+		}
+		if q, index, ok := dj.findEntry(entry.ID); ok {
+			q.remove(index)
+			removed[i] = true
+		}
+	}
+	dj.unlockQueue()
+
+	for i, entry := range entries {
+		if errs[i] == nil {
+			continue
+		}
+		if removed[i] {
+			dj.reportError(fmt.Errorf("removed %q from the queue before going live: %w", entry.Media.Title, errs[i]))
+		} else {
+			dj.reportError(fmt.Errorf("failed to validate %q before going live: %w", entry.Media.Title, errs[i]))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package opendj
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// audioExtensions are the file extensions WatchDirectory queues.
+var audioExtensions = map[string]bool{
+	".mp3": true, ".m4a": true, ".aac": true, ".ogg": true, ".oga": true,
+	".opus": true, ".flac": true, ".wav": true, ".aiff": true, ".wma": true,
+}
+
+// watchedFile is the state of a file in a watched directory.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	queued  bool
+}
+
+// WatchDirectory checks dir every interval and adds audio files dropped into it to the queue,
+// e.g. pre-produced segments that are exported into a shared folder.
+//
+// Files that are in the directory when WatchDirectory is called are not queued, neither are
+// hidden files and files in subdirectories. A file is queued once its size and modification time
+// did not change between two checks, so files that are still being copied are not played.
+// Entries are probed with ffprobe for their title and duration and get the owner, source and tags of template.
+// Failed checks and rejected entries are passed to the error handler.
+// The entries are local files, they are only played with a FileSource whose root contains dir.
+// It blocks until ctx is cancelled, so it should be started in its own goroutine.
+func (dj *Dj) WatchDirectory(ctx context.Context, dir string, interval time.Duration, template QueueEntry) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var files map[string]*watchedFile
+	for {
+		found, err := scanAudioFiles(dir)
+		if err != nil {
+			dj.reportError(fmt.Errorf("failed to watch %s: %w", dir, err))
+		} else if files == nil {
+			files = make(map[string]*watchedFile, len(found))
+			for path, file := range found {
+				file.queued = true
+				files[path] = file
+			}
+		} else {
+			for path, file := range found {
+				previous, ok := files[path]
+				if !ok || previous.size != file.size || !previous.modTime.Equal(file.modTime) {
+					// new or still being written, it is queued once it stopped changing
+					if ok && previous.queued {
+						file.queued = true
+					}
+					files[path] = file
+					continue
+				}
+				if previous.queued {
+					continue
+				}
+				previous.queued = true
+				dj.queueDropped(ctx, path, template)
+			}
+			for path := range files {
+				if _, ok := found[path]; !ok {
+					delete(files, path)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// queueDropped adds a file found by WatchDirectory to the queue.
+func (dj *Dj) queueDropped(ctx context.Context, path string, template QueueEntry) {
+	media, err := ProbeResolver{Root: filepath.Dir(path)}.Resolve(dj.withLimits(ctx), path)
+	if err != nil {
+		if ctx.Err() == nil {
+			dj.reportError(fmt.Errorf("failed to probe %s: %w", path, err))
+		}
+		return
+	}
+
+	entry := template
+	entry.Media = media
+	entry.RequestedAt = time.Time{}
+	if err = dj.AddEntry(entry); err != nil {
+		dj.reportError(fmt.Errorf("failed to queue %q: %w", media.Title, err))
+	}
+}
+
+// scanAudioFiles returns the audio files directly in dir.
+func scanAudioFiles(dir string) (map[string]*watchedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*watchedFile)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || !audioExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// removed in the meantime
+			continue
+		}
+		files[filepath.Join(dir, name)] = &watchedFile{size: info.Size(), modTime: info.ModTime()}
+	}
+	return files, nil
+}
@@ -0,0 +1,161 @@
+package opendj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+const (
+	webhookAttempts = 3
+	webhookTimeout  = 10 * time.Second
+)
+
+// WebhookPayload is the data sent to webhooks, it is also what templates are executed with.
+type WebhookPayload struct {
+	Type         EventType `json:"type"`
+	Time         time.Time `json:"time"`
+	ID           uint64    `json:"id,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	Duration     float64   `json:"duration,omitempty"`
+	Owner        string    `json:"owner,omitempty"`
+	Dedication   string    `json:"dedication,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	QueueVersion uint64    `json:"queueVersion,omitempty"`
+	QueueLength  int       `json:"queueLength"`
+	// Show is the show of the show events and the show that is on for all others.
+	Show *Show `json:"show,omitempty"`
+}
+
+// AddWebhook POSTs a payload to url for each of the given events, or every event if events is empty.
+//
+// If tmpl is nil the payload is sent as JSON, otherwise tmpl is executed with a WebhookPayload
+// and the result is sent as is. Failed deliveries are retried, errors are passed to the error handler.
+// remove stops the webhook.
+func (dj *Dj) AddWebhook(url string, events []EventType, tmpl *template.Template) (remove func()) {
+	wanted := make(map[EventType]bool, len(events))
+	for _, event := range events {
+		wanted[event] = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, unsubscribe := dj.Subscribe(64)
+	// only deliver what happens from now on
+	for len(ch) > 0 {
+		<-ch
+	}
+
+	go func() {
+		defer unsubscribe()
+		var limiter eventLimiter
+		for {
+			var event Event
+			select {
+			case <-ctx.Done():
+				return
+			case event = <-ch:
+			}
+			if len(wanted) > 0 && !wanted[event.Type] {
+				continue
+			}
+			if !limiter.allow(dj.cfg().EventRateLimits, event.Type, event.Time) {
+				continue
+			}
+
+			err := dj.deliverWebhook(ctx, url, tmpl, dj.webhookPayload(event))
+			if handler := dj.handlers.load().errorHander; err != nil && ctx.Err() == nil && handler != nil {
+				// not published as an event, a failing error webhook would trigger itself
+				err := fmt.Errorf("webhook %s: %w", url, err)
+				dj.callHandler(EventError, func() { handler(err) })
+			}
+		}
+	}()
+	return cancel
+}
+
+func (dj *Dj) webhookPayload(event Event) WebhookPayload {
+	payload := WebhookPayload{
+		Type:         event.Type,
+		Time:         event.Time,
+		ID:           event.Entry.ID,
+		Title:        event.Entry.Media.Title,
+		URL:          event.Entry.Media.URL,
+		Duration:     event.Entry.Media.Duration.Seconds(),
+		Owner:        event.Entry.PublicOwner(),
+		Dedication:   dj.announced(event.Entry).Dedication,
+		QueueVersion: event.QueueVersion,
+		QueueLength:  len(dj.Queue()),
+	}
+	if event.Err != nil {
+		payload.Error = event.Err.Error()
+	}
+	if event.Type == EventShowStarted || event.Type == EventShowEnded {
+		payload.Show = &event.Show
+	} else if show, ok := dj.CurrentShow(); ok {
+		payload.Show = &show
+	}
+	return payload
+}
+
+func (dj *Dj) deliverWebhook(ctx context.Context, url string, tmpl *template.Template, payload WebhookPayload) error {
+	var body bytes.Buffer
+	contentType := "application/json"
+	if tmpl != nil {
+		if err := tmpl.Execute(&body, payload); err != nil {
+			return err
+		}
+		contentType = "text/plain; charset=utf-8"
+		if json.Valid(body.Bytes()) {
+			contentType = "application/json"
+		}
+	} else if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return err
+	}
+
+	var err error
+	backoff := time.Second
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = postWebhook(ctx, url, contentType, body.Bytes())
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// postWebhook sends the request, retry reports if a failure is worth retrying.
+func postWebhook(ctx context.Context, url, contentType string, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return false, nil
+}
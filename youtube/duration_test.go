@@ -0,0 +1,46 @@
+package youtube
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "PT4M42S", want: 4*time.Minute + 42*time.Second},
+		{in: "PT45S", want: 45 * time.Second},
+		{in: "PT1H", want: time.Hour},
+		{in: "P1DT2H", want: 26 * time.Hour},
+		{in: "P1W", want: 7 * 24 * time.Hour},
+		{in: "PT1.5S", want: 1500 * time.Millisecond},
+		{in: "PT0,5M", want: 30 * time.Second},
+		{in: "P0D", want: 0},
+		{in: "", wantErr: true},
+		{in: "P", wantErr: true},
+		{in: "PT", wantErr: true},
+		{in: "4M42S", wantErr: true},
+		{in: "P1Y", wantErr: true},
+		{in: "P1M", wantErr: true},
+		{in: "PT1D", wantErr: true},
+		{in: "P1H", wantErr: true},
+		{in: "PTM", wantErr: true},
+		{in: "PT5", wantErr: true},
+		{in: "PT1HT2M", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseISO8601Duration(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseISO8601Duration(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseISO8601Duration(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
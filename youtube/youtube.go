@@ -0,0 +1,288 @@
+// Package youtube resolves YouTube videos with the YouTube Data API.
+//
+// It is kept separate from the opendj package so applications that only resolve media
+// with yt-dlp don't depend on it.
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoMuchForSubtlety/opendj"
+)
+
+// ErrorQuotaExceeded is returned by the Client while the API quota is exhausted.
+var ErrorQuotaExceeded = errors.New("youtube API quota exceeded")
+
+const (
+	videosEndpoint = "https://www.googleapis.com/youtube/v3/videos"
+	// maxBatch is the maximum amount of IDs a single Videos.List call accepts.
+	maxBatch = 50
+
+	cacheTTL   = time.Hour
+	minBackoff = time.Minute
+	maxBackoff = time.Hour
+)
+
+// A Video holds the metadata of a video returned by the YouTube Data API.
+type Video struct {
+	ID           string
+	Title        string
+	ChannelTitle string
+	CategoryID   string
+	Duration     time.Duration
+}
+
+// Media returns the video as Media.
+func (v Video) Media() opendj.Media {
+	return opendj.Media{
+		Title:    v.Title,
+		URL:      "https://www.youtube.com/watch?v=" + v.ID,
+		Duration: v.Duration,
+	}
+}
+
+type cachedVideo struct {
+	video   Video
+	found   bool
+	expires time.Time
+}
+
+// Client wraps calls to the YouTube Data API to save quota.
+//
+// Video lookups are batched, responses are cached and once the API reports that the quota
+// is exceeded no further calls are made for an increasing backoff period.
+// It is safe for concurrent use.
+type Client struct {
+	apiKey     string
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	cache        map[string]cachedVideo
+	backoff      time.Duration
+	backoffUntil time.Time
+}
+
+// NewClient returns a Client that authenticates with the given API key.
+func NewClient(apiKey string) *Client {
+	return &Client{
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		cache:      make(map[string]cachedVideo),
+	}
+}
+
+// Videos returns the metadata of the videos with the given IDs.
+//
+// IDs of videos that don't exist are missing from the result.
+// returns ErrorQuotaExceeded while the client is backing off.
+func (c *Client) Videos(ctx context.Context, ids []string) (map[string]Video, error) {
+	videos := make(map[string]Video)
+	var missing []string
+
+	c.mu.Lock()
+	now := time.Now()
+	for _, id := range ids {
+		if cached, ok := c.cache[id]; ok && cached.expires.After(now) {
+			if cached.found {
+				videos[id] = cached.video
+			}
+			continue
+		}
+		missing = append(missing, id)
+	}
+	c.mu.Unlock()
+
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > maxBatch {
+			batch = batch[:maxBatch]
+		}
+		missing = missing[len(batch):]
+
+		fetched, err := c.fetch(ctx, batch)
+		if err != nil {
+			return videos, err
+		}
+		for id, video := range fetched {
+			videos[id] = video
+		}
+	}
+	return videos, nil
+}
+
+// Video returns the metadata of a single video.
+func (c *Client) Video(ctx context.Context, id string) (Video, error) {
+	videos, err := c.Videos(ctx, []string{id})
+	if err != nil {
+		return Video{}, err
+	}
+	video, ok := videos[id]
+	if !ok {
+		return Video{}, fmt.Errorf("video %s: %w", id, opendj.ErrorUnavailable)
+	}
+	return video, nil
+}
+
+// Category returns the category ID of the given media, it can be used as opendj.ContentFilter.Category.
+func (c *Client) Category(media opendj.Media) (string, error) {
+	id, ok := ID(media.URL)
+	if !ok {
+		return "", fmt.Errorf("%s is not a youtube video", media.URL)
+	}
+	video, err := c.Video(context.Background(), id)
+	if err != nil {
+		return "", err
+	}
+	return video.CategoryID, nil
+}
+
+type videosResponse struct {
+	Items []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			Title        string `json:"title"`
+			ChannelTitle string `json:"channelTitle"`
+			CategoryID   string `json:"categoryId"`
+		} `json:"snippet"`
+		ContentDetails struct {
+			Duration string `json:"duration"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+// fetch calls Videos.List for up to maxBatch IDs and caches the result.
+func (c *Client) fetch(ctx context.Context, ids []string) (map[string]Video, error) {
+	c.mu.Lock()
+	if time.Now().Before(c.backoffUntil) {
+		c.mu.Unlock()
+		return nil, ErrorQuotaExceeded
+	}
+	c.mu.Unlock()
+
+	query := url.Values{}
+	query.Set("part", "snippet,contentDetails")
+	query.Set("id", strings.Join(ids, ","))
+	query.Set("key", c.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, videosEndpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query YouTube API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body videosResponse
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode YouTube API response: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if body.Error != nil {
+		for _, e := range body.Error.Errors {
+			if e.Reason == "quotaExceeded" || e.Reason == "rateLimitExceeded" {
+				c.backoff *= 2
+				if c.backoff < minBackoff {
+					c.backoff = minBackoff
+				} else if c.backoff > maxBackoff {
+					c.backoff = maxBackoff
+				}
+				c.backoffUntil = time.Now().Add(c.backoff)
+				return nil, ErrorQuotaExceeded
+			}
+		}
+		return nil, fmt.Errorf("youtube API error %d: %s", body.Error.Code, body.Error.Message)
+	}
+	c.backoff = 0
+
+	expires := time.Now().Add(cacheTTL)
+	videos := make(map[string]Video)
+	for _, item := range body.Items {
+		duration, err := ParseISO8601Duration(item.ContentDetails.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration of %s: %w", item.ID, err)
+		}
+		video := Video{
+			ID:           item.ID,
+			Title:        item.Snippet.Title,
+			ChannelTitle: item.Snippet.ChannelTitle,
+			CategoryID:   item.Snippet.CategoryID,
+			Duration:     duration,
+		}
+		videos[item.ID] = video
+		c.cache[item.ID] = cachedVideo{video: video, found: true, expires: expires}
+	}
+	for _, id := range ids {
+		if _, ok := videos[id]; !ok {
+			c.cache[id] = cachedVideo{expires: expires}
+		}
+	}
+	return videos, nil
+}
+
+// ID returns the video ID of a YouTube URL.
+func ID(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	host = strings.TrimPrefix(host, "music.")
+	switch host {
+	case "youtu.be":
+		id := strings.Trim(u.Path, "/")
+		return id, id != ""
+	case "youtube.com":
+		if id := u.Query().Get("v"); id != "" {
+			return id, true
+		}
+		for _, prefix := range []string{"/shorts/", "/embed/", "/live/", "/v/"} {
+			if strings.HasPrefix(u.Path, prefix) {
+				id := strings.Trim(strings.TrimPrefix(u.Path, prefix), "/")
+				return id, id != ""
+			}
+		}
+	}
+	return "", false
+}
+
+// Resolver resolves YouTube videos with the YouTube Data API, it implements opendj.Resolver.
+type Resolver struct {
+	Client *Client
+}
+
+// Resolve looks up the video at url.
+func (r Resolver) Resolve(ctx context.Context, url string) (opendj.Media, error) {
+	id, ok := ID(url)
+	if !ok {
+		return opendj.Media{}, fmt.Errorf("%s is not a youtube video", url)
+	}
+	video, err := r.Client.Video(ctx, id)
+	if err != nil {
+		return opendj.Media{}, err
+	}
+	media := video.Media()
+	media.URL = url
+	return media, nil
+}
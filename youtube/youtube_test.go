@@ -0,0 +1,139 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redirect sends every request to the test server instead of the API.
+type redirect struct {
+	target *url.URL
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.target.Scheme, r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fakeAPI serves Videos.List and records the requested IDs.
+type fakeAPI struct {
+	mu         sync.Mutex
+	calls      [][]string
+	quotaError bool
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("id"), ",")
+	a.mu.Lock()
+	a.calls = append(a.calls, ids)
+	quotaError := a.quotaError
+	a.mu.Unlock()
+
+	if r.URL.Query().Get("key") != "key" {
+		http.Error(w, `{"error": {"code": 400, "message": "invalid key"}}`, http.StatusBadRequest)
+		return
+	}
+	if quotaError {
+		http.Error(w, `{"error": {"code": 403, "message": "quota", "errors": [{"reason": "quotaExceeded"}]}}`, http.StatusForbidden)
+		return
+	}
+	var items []string
+	for _, id := range ids {
+		if id != "missing" {
+			items = append(items, fmt.Sprintf(`{"id": %q, "snippet": {"title": "Video %s", "categoryId": "10"}, "contentDetails": {"duration": "PT4M2S"}}`, id, id))
+		}
+	}
+	fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeAPI) {
+	api := &fakeAPI{}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	c := NewClient("key")
+	c.httpClient = &http.Client{Transport: redirect{target}}
+	return c, api
+}
+
+func TestVideos(t *testing.T) {
+	c, api := newTestClient(t)
+	ctx := context.Background()
+
+	ids := []string{"missing"}
+	for i := 0; i < maxBatch+1; i++ {
+		ids = append(ids, fmt.Sprint(i))
+	}
+	videos, err := c.Videos(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != maxBatch+1 {
+		t.Errorf("got %d videos, want %d", len(videos), maxBatch+1)
+	}
+	want := Video{ID: "7", Title: "Video 7", CategoryID: "10", Duration: 4*time.Minute + 2*time.Second}
+	if videos["7"] != want {
+		t.Errorf("got %+v, want %+v", videos["7"], want)
+	}
+	if len(api.calls) != 2 || len(api.calls[0]) != maxBatch || len(api.calls[1]) != 2 {
+		t.Errorf("made %d calls, want batches of %d and 2", len(api.calls), maxBatch)
+	}
+
+	// cached, including the missing video
+	if _, err = c.Videos(ctx, ids); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Video(ctx, "missing"); err == nil {
+		t.Error("a missing video returned no error")
+	}
+	if len(api.calls) != 2 {
+		t.Errorf("made %d calls for cached videos", len(api.calls)-2)
+	}
+}
+
+func TestQuotaBackoff(t *testing.T) {
+	c, api := newTestClient(t)
+	api.quotaError = true
+	ctx := context.Background()
+
+	if _, err := c.Video(ctx, "a"); !errors.Is(err, ErrorQuotaExceeded) {
+		t.Fatalf("got %v, want %v", err, ErrorQuotaExceeded)
+	}
+	if _, err := c.Video(ctx, "b"); !errors.Is(err, ErrorQuotaExceeded) {
+		t.Fatalf("got %v while backing off, want %v", err, ErrorQuotaExceeded)
+	}
+	if len(api.calls) != 1 {
+		t.Errorf("made %d calls while backing off", len(api.calls)-1)
+	}
+}
+
+func TestID(t *testing.T) {
+	tests := []struct {
+		url, id string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ&t=42", "dQw4w9WgXcQ"},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ/", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/channel/UC123", ""},
+		{"https://youtu.be/", ""},
+		{"https://vimeo.com/123", ""},
+	}
+	for _, tt := range tests {
+		id, ok := ID(tt.url)
+		if id != tt.id || ok != (tt.id != "") {
+			t.Errorf("ID(%q) = %q, %v, want %q", tt.url, id, ok, tt.id)
+		}
+	}
+}
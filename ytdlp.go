@@ -0,0 +1,128 @@
+package opendj
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events published by MaintainYtdlp.
+const (
+	// EventYtdlpOutdated is published if yt-dlp is older than the minimum version
+	// or resolutions keep failing, Err describes the problem.
+	EventYtdlpOutdated EventType = "ytdlpOutdated"
+	// EventYtdlpUpdated is published after yt-dlp updated itself.
+	EventYtdlpUpdated EventType = "ytdlpUpdated"
+)
+
+// maintenanceCheckInterval is how often MaintainYtdlp looks at the resolution failures.
+const maintenanceCheckInterval = time.Minute
+
+// YtdlpMaintenance configures MaintainYtdlp.
+type YtdlpMaintenance struct {
+	// MinVersion is the oldest acceptable version, e.g. 2024.03.10. Empty skips the check.
+	MinVersion string
+	// Interval is how often the version is checked, <= 0 only checks on start and failure spikes.
+	Interval time.Duration
+	// AutoUpdate runs yt-dlp -U when yt-dlp is outdated. It only works for installations
+	// that can update themselves, like the standalone binary.
+	AutoUpdate bool
+	// FailureThreshold resolution failures within FailureWindow count as a spike, <= 0 disables it.
+	// Outdated extractors are the most common cause of mass failures.
+	FailureThreshold int
+	FailureWindow    time.Duration
+}
+
+// MaintainYtdlp checks that yt-dlp is up to date until ctx is cancelled.
+//
+// It checks the version on start, every Interval and whenever resolution failures spike,
+// publishes EventYtdlpOutdated and optionally updates yt-dlp.
+// It blocks, so it should be started in its own goroutine.
+func (dj *Dj) MaintainYtdlp(ctx context.Context, m YtdlpMaintenance) {
+	check := time.NewTicker(maintenanceCheckInterval)
+	defer check.Stop()
+	var interval <-chan time.Time
+	if m.Interval > 0 {
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+		interval = ticker.C
+	}
+
+	dj.checkYtdlp(ctx, m, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-interval:
+			dj.checkYtdlp(ctx, m, nil)
+		case <-check.C:
+			if m.FailureThreshold <= 0 {
+				continue
+			}
+			if failures := dj.pipeline.recentFailures(m.FailureWindow); failures >= m.FailureThreshold {
+				dj.pipeline.clearFailures()
+				dj.checkYtdlp(ctx, m, fmt.Errorf("%d resolutions failed within %s", failures, m.FailureWindow))
+			}
+		}
+	}
+}
+
+// checkYtdlp checks the version and updates yt-dlp if needed, reason is why it may be outdated.
+func (dj *Dj) checkYtdlp(ctx context.Context, m YtdlpMaintenance, reason error) {
+	ctx = dj.withLimits(ctx)
+	version, err := ytdlpVersion(ctx)
+	if err != nil {
+		dj.notifyError(err)
+		return
+	}
+	if m.MinVersion != "" && compareVersions(version, m.MinVersion) < 0 {
+		reason = fmt.Errorf("yt-dlp %s is older than %s", version, m.MinVersion)
+	}
+	if reason == nil {
+		return
+	}
+
+	dj.publish(Event{Type: EventYtdlpOutdated, Err: reason})
+	if !m.AutoUpdate {
+		return
+	}
+	if output, err := newCommand(ctx, "yt-dlp", "-U").CombinedOutput(); err != nil {
+		dj.notifyError(fmt.Errorf("failed to update yt-dlp: %w: %s", err, strings.TrimSpace(string(output))))
+		return
+	}
+	if updated, err := ytdlpVersion(ctx); err == nil && updated != version {
+		dj.publish(Event{Type: EventYtdlpUpdated})
+	}
+}
+
+// ytdlpVersion returns the installed version of yt-dlp.
+func ytdlpVersion(ctx context.Context) (string, error) {
+	output, err := newCommand(ctx, "yt-dlp", "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get yt-dlp version: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// compareVersions compares dotted versions like 2024.03.10 numerically.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}